@@ -0,0 +1,4 @@
+ALTER TABLE todo_item
+    DROP COLUMN priority,
+    DROP COLUMN due,
+    DROP COLUMN tags;
\ No newline at end of file
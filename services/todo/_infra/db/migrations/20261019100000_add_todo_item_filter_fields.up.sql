@@ -0,0 +1,4 @@
+ALTER TABLE todo_item
+    ADD COLUMN priority TINYINT NOT NULL DEFAULT 0, -- rank, see todoitem.Priority
+    ADD COLUMN due TIMESTAMP NULL DEFAULT NULL,
+    ADD COLUMN tags JSON NOT NULL DEFAULT (JSON_ARRAY());
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS smart_list;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS smart_list(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    query TEXT NOT NULL,
+    date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    date_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
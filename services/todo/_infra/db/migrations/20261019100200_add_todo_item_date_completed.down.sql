@@ -0,0 +1,3 @@
+DROP INDEX todo_item_date_completed ON todo_item;
+DROP INDEX todo_item_date_created ON todo_item;
+ALTER TABLE todo_item DROP COLUMN date_completed;
\ No newline at end of file
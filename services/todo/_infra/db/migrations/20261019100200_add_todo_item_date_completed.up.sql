@@ -0,0 +1,5 @@
+ALTER TABLE todo_item ADD COLUMN date_completed TIMESTAMP NULL DEFAULT NULL;
+-- best guess for anything completed before we tracked it. date_updated is set to itself so ON UPDATE leaves it be
+UPDATE todo_item SET date_completed = date_updated, date_updated = date_updated WHERE completed = true;
+CREATE INDEX todo_item_date_created ON todo_item (date_created);
+CREATE INDEX todo_item_date_completed ON todo_item (date_completed);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS webhook(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret varchar(255) NOT NULL,
+    events JSON NOT NULL,
+    date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    webhook_id varchar(40) NOT NULL,
+    event varchar(20) NOT NULL,
+    payload JSON NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP(3) NULL,
+    last_attempt TIMESTAMP(3) NULL,
+    last_status INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    date_created TIMESTAMP(3) DEFAULT CURRENT_TIMESTAMP(3),
+    INDEX webhook_delivery_due (status, next_attempt),
+    INDEX webhook_delivery_log (webhook_id, date_created),
+    CONSTRAINT webhook_delivery_webhook FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP INDEX webhook_delivery_event ON webhook_delivery;
+ALTER TABLE webhook_delivery DROP COLUMN event_id;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,16 @@
+-- seq keeps the relay going oldest first, id is the dedupe id handed to sinks
+CREATE TABLE IF NOT EXISTS outbox(
+    seq BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id varchar(40) NOT NULL DEFAULT (uuid()),
+    event varchar(20) NOT NULL,
+    payload JSON NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    last_error TEXT NULL,
+    date_created TIMESTAMP(3) DEFAULT CURRENT_TIMESTAMP(3),
+    UNIQUE INDEX outbox_id (id),
+    INDEX outbox_due (next_attempt)
+);
+
+ALTER TABLE webhook_delivery ADD COLUMN event_id varchar(40) NULL AFTER webhook_id;
+CREATE UNIQUE INDEX webhook_delivery_event ON webhook_delivery (webhook_id, event_id);
@@ -0,0 +1,3 @@
+DROP INDEX todo_item_version ON todo_item;
+ALTER TABLE todo_item DROP COLUMN version;
+DROP TABLE IF EXISTS sync_clock;
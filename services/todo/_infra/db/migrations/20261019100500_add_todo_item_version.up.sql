@@ -0,0 +1,11 @@
+-- a single row counter handing out versions for the sync api, see tododb.nextVersion
+CREATE TABLE IF NOT EXISTS sync_clock(
+    id TINYINT NOT NULL PRIMARY KEY DEFAULT 1,
+    version BIGINT NOT NULL
+);
+ALTER TABLE todo_item ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+-- number what's already there, oldest change first. date_updated is set to itself so ON UPDATE leaves it be
+SET @version = 0;
+UPDATE todo_item SET version = (@version := @version + 1), date_updated = date_updated ORDER BY date_updated, date_created;
+INSERT INTO sync_clock (id, version) VALUES (1, @version);
+CREATE UNIQUE INDEX todo_item_version ON todo_item (version);
@@ -0,0 +1,4 @@
+ALTER TABLE webhook DROP COLUMN owner_id;
+DROP INDEX todo_item_owner ON todo_item;
+ALTER TABLE todo_item DROP COLUMN owner_id;
+DROP TABLE IF EXISTS `user`;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `user`(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    email varchar(255) NOT NULL,
+    name varchar(255) NOT NULL,
+    password_hash varchar(255) NULL,
+    date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX user_email (email)
+);
+-- items and webhooks made before there were users have no owner, see the README on giving them one
+ALTER TABLE todo_item ADD COLUMN owner_id varchar(40) NULL;
+CREATE INDEX todo_item_owner ON todo_item (owner_id, version);
+ALTER TABLE webhook ADD COLUMN owner_id varchar(40) NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS access_token(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    user_id varchar(40) NOT NULL,
+    name varchar(255) NOT NULL,
+    scope varchar(16) NOT NULL,
+    -- hex sha256 of the token, the token itself is never stored
+    token_hash char(64) NOT NULL,
+    date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires TIMESTAMP NULL,
+    last_used TIMESTAMP NULL,
+    revoked TIMESTAMP NULL,
+    UNIQUE INDEX access_token_hash (token_hash),
+    INDEX access_token_user (user_id, date_created)
+);
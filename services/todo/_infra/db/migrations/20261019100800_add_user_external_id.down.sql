@@ -0,0 +1,2 @@
+DROP INDEX user_external_id ON `user`;
+ALTER TABLE `user` DROP COLUMN external_id;
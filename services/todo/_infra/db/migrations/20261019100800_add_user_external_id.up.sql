@@ -0,0 +1,3 @@
+-- who the user is to the identity provider they log in through, their issuer and subject
+ALTER TABLE `user` ADD COLUMN external_id varchar(255) NULL;
+CREATE UNIQUE INDEX user_external_id ON `user` (external_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS list_member(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    -- a list is everything a user owns, so this is the id of the user whose list it is
+    list_id varchar(40) NOT NULL,
+    user_id varchar(40) NOT NULL,
+    email varchar(255) NOT NULL,
+    role varchar(16) NOT NULL,
+    invited_by varchar(40) NOT NULL,
+    date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- null until the invitation is accepted, members get no access before then
+    date_accepted TIMESTAMP NULL,
+    UNIQUE INDEX list_member_list_user (list_id, user_id),
+    INDEX list_member_user (user_id)
+);
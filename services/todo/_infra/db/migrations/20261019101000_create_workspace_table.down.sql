@@ -0,0 +1,10 @@
+ALTER TABLE list_member DROP COLUMN workspace_id;
+ALTER TABLE access_token DROP COLUMN workspace_id;
+ALTER TABLE `user` DROP COLUMN workspace_id;
+ALTER TABLE outbox DROP COLUMN workspace_id;
+ALTER TABLE webhook DROP COLUMN workspace_id;
+DROP INDEX smart_list_workspace ON smart_list;
+ALTER TABLE smart_list DROP COLUMN workspace_id;
+DROP INDEX todo_item_workspace_version ON todo_item;
+ALTER TABLE todo_item DROP COLUMN workspace_id;
+DROP TABLE IF EXISTS workspace;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS workspace(
+    id varchar(40) NOT NULL DEFAULT (uuid()) PRIMARY KEY,
+    name varchar(255) NOT NULL,
+    date_created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+-- everything from before workspaces lives in this one
+INSERT INTO workspace (id, name) VALUES ('default', 'Default');
+
+ALTER TABLE todo_item ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+CREATE INDEX todo_item_workspace_version ON todo_item (workspace_id, version);
+ALTER TABLE smart_list ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+CREATE INDEX smart_list_workspace ON smart_list (workspace_id);
+ALTER TABLE webhook ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+ALTER TABLE outbox ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+ALTER TABLE `user` ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+ALTER TABLE access_token ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+ALTER TABLE list_member ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+-- sync_clock is one counter for the whole server, so it doesn't need a workspace
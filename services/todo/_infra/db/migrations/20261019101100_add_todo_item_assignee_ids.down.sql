@@ -0,0 +1 @@
+ALTER TABLE todo_item DROP COLUMN assignee_ids;
@@ -0,0 +1,2 @@
+-- the ids of the users doing the item, a json array like tags so it can be searched with JSON_CONTAINS
+ALTER TABLE todo_item ADD COLUMN assignee_ids JSON NOT NULL DEFAULT (JSON_ARRAY());
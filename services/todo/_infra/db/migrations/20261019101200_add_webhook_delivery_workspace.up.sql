@@ -0,0 +1,4 @@
+-- deliveries get their webhook's workspace so the dispatcher can look the webhook up there, and the delivery log can
+-- be filtered on it like everything else
+ALTER TABLE webhook_delivery ADD COLUMN workspace_id varchar(40) NOT NULL DEFAULT 'default';
+UPDATE webhook_delivery d JOIN webhook w ON w.id = d.webhook_id SET d.workspace_id = w.workspace_id;
@@ -0,0 +1,4 @@
+DROP INDEX list_member_email ON list_member;
+DROP INDEX list_member_list_email ON list_member;
+DELETE FROM list_member WHERE user_id IS NULL;
+ALTER TABLE list_member MODIFY user_id varchar(40) NOT NULL;
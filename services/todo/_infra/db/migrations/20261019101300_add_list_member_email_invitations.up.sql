@@ -0,0 +1,4 @@
+-- people can be invited before they've registered, their invitation has no user until someone registers with the email
+ALTER TABLE list_member MODIFY user_id varchar(40) NULL;
+CREATE UNIQUE INDEX list_member_list_email ON list_member (list_id, email);
+CREATE INDEX list_member_email ON list_member (email);
@@ -0,0 +1,146 @@
+// Package auth carries who a request is being made by from wherever they proved it (the rest middleware, a grpc
+// interceptor) down to the cores that decide what they can see. It also checks the credentials that don't need our
+// own accounts to check, JWT bearer tokens, and picks between ways of authenticating by scheme.
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// Principal is who a request is being made for.
+type Principal struct {
+	UserId string
+	//Scope is the most the request is allowed to do, when it's made with an access token. Empty is everything, which
+	//is what passwords and JWTs get.
+	Scope string
+	//WorkspaceId is the workspace the user belongs to. Empty is DefaultWorkspace.
+	WorkspaceId string
+}
+
+// DefaultWorkspace is the workspace of users that weren't registered into another one, and of unscoped requests.
+const DefaultWorkspace = "default"
+
+// Scopes, each allowing everything the ones before it do.
+const (
+	//ScopeRead only reads
+	ScopeRead = "read"
+	//ScopeWrite changes items and smart lists too
+	ScopeWrite = "write"
+	//ScopeAdmin manages webhooks and access tokens as well
+	ScopeAdmin = "admin"
+)
+
+// Scopes are the scopes there are, from least to most allowed.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+func rank(scope string) int {
+	for i, s := range Scopes {
+		if s == scope {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidScope reports if scope is one of Scopes.
+func ValidScope(scope string) bool {
+	return rank(scope) >= 0
+}
+
+// Allows reports if the principal can do what needs scope.
+func (p Principal) Allows(scope string) bool {
+	return p.Scope == "" || rank(p.Scope) >= rank(scope)
+}
+
+// Require is a 403 if the principal in ctx isn't allowed scope, and a 401 if there's no principal and the request
+// isn't Unscoped. Unscoped requests are allowed everything.
+func Require(ctx context.Context, scope string) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		if Unscoped(ctx) {
+			return nil
+		}
+		return terr.ErrorWithCode("unauthorized", "authentication required", 401)
+	}
+	if p.Allows(scope) {
+		return nil
+	}
+	return terr.ErrorWithCode("forbidden", fmt.Sprintf("this needs the %s scope, the token only has %s", scope, p.Scope), 403)
+}
+
+// Authenticator checks the value of an Authorization header, returning who it belongs to. Anything that isn't a
+// valid credential should be a 401 TodoError.
+type Authenticator interface {
+	Authenticate(ctx context.Context, authorization string) (Principal, error)
+}
+
+// AuthenticatorFunc lets a function be an Authenticator.
+type AuthenticatorFunc func(ctx context.Context, authorization string) (Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, authorization string) (Principal, error) {
+	return f(ctx, authorization)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal in ctx. ok is false for requests nobody has authenticated, see Unscoped for what
+// they can do.
+func FromContext(ctx context.Context) (p Principal, ok bool) {
+	if ctx == nil {
+		return Principal{}, false
+	}
+	p, ok = ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+type anonymousKey struct{}
+
+// Anonymous marks ctx as a request that came to the server without any credentials. open is whether the server
+// serves those unscoped, which it only does when it was started with --require-auth=false.
+func Anonymous(ctx context.Context, open bool) context.Context {
+	return context.WithValue(ctx, anonymousKey{}, open)
+}
+
+// Unscoped reports if ctx can see and do everything. That's only when nobody has authenticated it and it's either a
+// tool working on the database directly, or an anonymous request to a server started open. Anonymous requests to
+// any other server can't do anything that needs a scope, and the cores show them nothing.
+func Unscoped(ctx context.Context) bool {
+	if ctx == nil {
+		return true
+	}
+	if _, ok := FromContext(ctx); ok {
+		return false
+	}
+	open, anonymous := ctx.Value(anonymousKey{}).(bool)
+	return !anonymous || open
+}
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a copy of ctx working in a workspace. It's for tools that work on the database directly, a
+// request's workspace comes from its principal.
+func WithWorkspace(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, id)
+}
+
+// Workspace is the workspace everything in ctx happens in: the principal's, then one set with WithWorkspace, then
+// DefaultWorkspace. Stores keep workspaces apart with it, so it's never empty.
+func Workspace(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok && p.WorkspaceId != "" {
+		return p.WorkspaceId
+	}
+	if ctx != nil {
+		if id, ok := ctx.Value(workspaceKey{}).(string); ok && id != "" {
+			return id
+		}
+	}
+	return DefaultWorkspace
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+func TestRequire(t *testing.T) {
+	type test struct {
+		name  string
+		ctx   context.Context
+		scope string
+		code  int
+	}
+	as := func(scope string) context.Context {
+		return WithPrincipal(context.Background(), Principal{UserId: "sam", Scope: scope})
+	}
+	tests := []test{
+		{name: "unscoped", ctx: context.Background(), scope: ScopeAdmin},
+		{name: "anonymous to an open server", ctx: Anonymous(context.Background(), true), scope: ScopeAdmin},
+		{name: "anonymous", ctx: Anonymous(context.Background(), false), scope: ScopeRead, code: 401},
+		{name: "password or jwt", ctx: as(""), scope: ScopeAdmin},
+		{name: "read reading", ctx: as(ScopeRead), scope: ScopeRead},
+		{name: "read writing", ctx: as(ScopeRead), scope: ScopeWrite, code: 403},
+		{name: "write writing", ctx: as(ScopeWrite), scope: ScopeWrite},
+		{name: "write administering", ctx: as(ScopeWrite), scope: ScopeAdmin, code: 403},
+		{name: "admin reading", ctx: as(ScopeAdmin), scope: ScopeRead},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			err := Require(tt.ctx, tt.scope)
+			if tt.code == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tt.code, err.(*terr.TodoError).HttpCode)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, DefaultWorkspace, Workspace(ctx))
+	assert.Equal(t, "ops", Workspace(WithWorkspace(ctx, "ops")))
+	assert.Equal(t, DefaultWorkspace, Workspace(WithPrincipal(ctx, Principal{UserId: "sam"})))
+	assert.Equal(t, "ops", Workspace(WithPrincipal(WithWorkspace(ctx, "sales"), Principal{UserId: "sam", WorkspaceId: "ops"})), "the principal's wins")
+}
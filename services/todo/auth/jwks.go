@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	//jwksMaxAge is how long keys are used before they're fetched again, so removed keys stop working
+	jwksMaxAge = time.Hour
+	//jwksMinRefresh stops tokens with made up key ids from having us fetch the keys on every request
+	jwksMinRefresh = 10 * time.Second
+)
+
+// jwks is a set of RSA public keys by key id, from a file or url. Keys are fetched again when a token has a key id
+// we haven't seen, so keys can be rotated without a restart. Fetches happen outside the lock, one at a time, so a slow
+// identity provider only holds up the tokens that need a key we don't have yet.
+type jwks struct {
+	source string
+	client *http.Client
+	//fetches collapses everyone who wants the keys fetched into the one fetch
+	fetches singleflight.Group
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+func newJWKS(source string) *jwks {
+	return &jwks{source: source, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// key returns the key with the given id. A token without one can still be checked if there's only one key. Keys past
+// jwksMaxAge keep being used while they're fetched again in the background.
+func (s *jwks) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	k, ok, since := s.cached(kid)
+	switch {
+	case ok && since > jwksMaxAge:
+		s.fetches.DoChan("fetch", s.fetch)
+	case !ok && since > jwksMinRefresh:
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+		k, ok, _ = s.cached(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
+
+// cached looks the key up in what we have, and says how long ago that was fetched.
+func (s *jwks) cached(kid string) (*rsa.PublicKey, bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since := time.Since(s.fetched)
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true, since
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok, since
+}
+
+// refresh fetches the keys, joining a fetch that's already going. Giving up on ctx leaves the fetch running for the
+// others waiting on it.
+func (s *jwks) refresh(ctx context.Context) error {
+	select {
+	case res := <-s.fetches.DoChan("fetch", s.fetch):
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch reads the keys, keeping the ones we have if it fails. It's shared, so it isn't cut short by any one request,
+// only by the client's timeout.
+func (s *jwks) fetch() (any, error) {
+	s.mu.Lock()
+	s.fetched = time.Now()
+	s.mu.Unlock()
+	b, err := s.read(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("reading jwks %s: %w", s.source, err)
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwks %s: %w", s.source, err)
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return nil, nil
+}
+
+func (s *jwks) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		return os.ReadFile(s.source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS reads the RSA signing keys out of a JWK set, skipping anything else in it.
+func parseJWKS(b []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: bad modulus", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("key %s: bad exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA signing keys")
+	}
+	return keys, nil
+}
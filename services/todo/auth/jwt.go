@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// JWTConfig is what bearer tokens are checked against. At least one of Secret and JWKS has to be set.
+type JWTConfig struct {
+	//Secret verifies HS256 tokens, which aren't accepted when it's empty
+	Secret string
+	//JWKS is a file or http(s) url of the RSA keys that verify RS256 tokens, which aren't accepted when it's empty
+	JWKS string
+	//Issuer and Audience have to match the iss and aud claims, when set
+	Issuer   string
+	Audience string
+	//Leeway is how far exp, nbf and iat can be off, for clocks that aren't quite in sync
+	Leeway time.Duration
+}
+
+// JWT checks bearer tokens. The sub claim is the user id, so tokens for local users should carry their id, and the
+// subjects from another identity provider simply become owners of their own items. An optional workspace claim puts
+// the request in that workspace, otherwise it's in DefaultWorkspace.
+type JWT struct {
+	secret  []byte
+	keys    *jwks
+	methods []string
+	options []jwt.ParserOption
+}
+
+func NewJWT(ctx context.Context, cfg JWTConfig) (*JWT, error) {
+	j := &JWT{}
+	if cfg.Secret != "" {
+		j.secret = []byte(cfg.Secret)
+		j.methods = append(j.methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKS != "" {
+		j.keys = newJWKS(cfg.JWKS)
+		//find out about a bad url or file now, rather than on the first request
+		if err := j.keys.refresh(ctx); err != nil {
+			return nil, err
+		}
+		j.methods = append(j.methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(j.methods) == 0 {
+		return nil, fmt.Errorf("a jwt secret or jwks is needed to check tokens")
+	}
+	//only the algorithms we have keys for, so an RS256 public key is never used as an HS256 secret
+	j.options = []jwt.ParserOption{jwt.WithValidMethods(j.methods), jwt.WithExpirationRequired(), jwt.WithLeeway(cfg.Leeway)}
+	if cfg.Issuer != "" {
+		j.options = append(j.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		j.options = append(j.options, jwt.WithAudience(cfg.Audience))
+	}
+	return j, nil
+}
+
+// Authenticate checks a bearer token, making JWT an Authenticator.
+func (j *JWT) Authenticate(ctx context.Context, authorization string) (Principal, error) {
+	scheme, token, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "bearer") {
+		return Principal{}, terr.ErrorWithCode("unauthorized", fmt.Sprintf("unsupported authorization scheme %s", scheme), 401)
+	}
+	claims, err := j.Claims(ctx, token)
+	if err != nil {
+		return Principal{}, err
+	}
+	sub, _ := claims["sub"].(string)
+	workspace, _ := claims["workspace"].(string)
+	return Principal{UserId: sub, WorkspaceId: workspace}, nil
+}
+
+// Claims checks a token (without the Bearer in front), returning its claims. It always has a sub.
+func (j *JWT) Claims(ctx context.Context, token string) (map[string]any, error) {
+	keyFunc := func(t *jwt.Token) (any, error) {
+		if t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return j.secret, nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return j.keys.key(ctx, kid)
+	}
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, keyFunc, j.options...)
+	if err != nil {
+		return nil, invalidToken(err.Error())
+	}
+	sub, err := parsed.Claims.GetSubject()
+	if err != nil || sub == "" {
+		return nil, invalidToken("no sub claim")
+	}
+	return claims, nil
+}
+
+func invalidToken(reason string) error {
+	return terr.ErrorWithCode("unauthorized", fmt.Sprintf("invalid bearer token: %s", reason), 401)
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// jwksFor is the JWK set an identity provider would publish for the keys.
+func jwksFor(keys map[string]*rsa.PrivateKey) []byte {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, k := range keys {
+		e := big.NewInt(int64(k.E)).Bytes()
+		set.Keys = append(set.Keys, jwk{Kty: "RSA", Kid: kid, Use: "sig",
+			N: base64.RawURLEncoding.EncodeToString(k.N.Bytes()), E: base64.RawURLEncoding.EncodeToString(e)})
+	}
+	b, _ := json.Marshal(set)
+	return b
+}
+
+func claims(sub string, exp time.Duration) jwt.MapClaims {
+	return jwt.MapClaims{"sub": sub, "iss": "https://idp.example.com", "aud": "todo", "exp": time.Now().Add(exp).Unix()}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, c jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + s
+}
+
+func TestJWT(t *testing.T) {
+	ctx := context.Background()
+	key := newKey(t)
+	other := newKey(t)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(file, jwksFor(map[string]*rsa.PrivateKey{"one": key}), 0600)
+	secret := []byte("not very secret")
+	j, err := NewJWT(ctx, JWTConfig{Secret: string(secret), JWKS: file, Issuer: "https://idp.example.com", Audience: "todo"})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	rsOnly, _ := NewJWT(ctx, JWTConfig{JWKS: file})
+
+	type test struct {
+		name          string
+		j             *JWT
+		authorization string
+		sub           string
+		workspace     string
+		err           string
+	}
+	tests := []test{
+		{name: "hs256", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", claims("sam", time.Hour)), sub: "sam"},
+		{name: "rs256", j: j, authorization: sign(t, jwt.SigningMethodRS256, key, "one", claims("alex", time.Hour)), sub: "alex"},
+		{name: "workspace claim", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "sam", "workspace": "ops", "iss": "https://idp.example.com", "aud": "todo", "exp": time.Now().Add(time.Hour).Unix()}),
+			sub: "sam", workspace: "ops"},
+		{name: "lower case scheme", j: j, authorization: "bearer" + sign(t, jwt.SigningMethodHS256, secret, "", claims("sam", time.Hour))[6:], sub: "sam"},
+		{name: "expired", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", claims("sam", -time.Hour)), err: "token is expired"},
+		{name: "no expiry", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "sam", "iss": "https://idp.example.com", "aud": "todo"}), err: "exp claim is required"},
+		{name: "wrong secret", j: j, authorization: sign(t, jwt.SigningMethodHS256, []byte("guess"), "", claims("sam", time.Hour)), err: "signature is invalid"},
+		{name: "wrong key", j: j, authorization: sign(t, jwt.SigningMethodRS256, other, "one", claims("sam", time.Hour)), err: "verification error"},
+		{name: "unknown key id", j: j, authorization: sign(t, jwt.SigningMethodRS256, other, "two", claims("sam", time.Hour)), err: `unknown key id "two"`},
+		{name: "hs256 without a secret", j: rsOnly, authorization: sign(t, jwt.SigningMethodHS256, secret, "", claims("sam", time.Hour)), err: "signing method HS256 is invalid"},
+		{name: "none", j: j, authorization: sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims("sam", time.Hour)), err: "signing method none is invalid"},
+		{name: "wrong issuer", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "sam", "iss": "elsewhere", "aud": "todo", "exp": time.Now().Add(time.Hour).Unix()}), err: "token has invalid issuer"},
+		{name: "wrong audience", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "sam", "iss": "https://idp.example.com", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()}), err: "token has invalid audience"},
+		{name: "no subject", j: j, authorization: sign(t, jwt.SigningMethodHS256, secret, "", claims("", time.Hour)), err: "no sub claim"},
+		{name: "not a jwt", j: j, authorization: "Bearer hello", err: "invalid bearer token"},
+		{name: "basic", j: j, authorization: "Basic c2FtOnNhbQ==", err: "unsupported authorization scheme Basic"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			p, err := tt.j.Authenticate(ctx, tt.authorization)
+			if tt.err != "" {
+				if assert.NotNil(t, err) {
+					assert.Equal(t, 401, err.(*terr.TodoError).HttpCode)
+					assert.Contains(t, err.(*terr.TodoError).Details(), tt.err)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.sub, p.UserId)
+			assert.Equal(t, tt.workspace, p.WorkspaceId)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	ctx := context.Background()
+	first, second := newKey(t), newKey(t)
+	var keys atomic.Value
+	keys.Store(jwksFor(map[string]*rsa.PrivateKey{"first": first}))
+	var fetches int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(keys.Load().([]byte))
+	}))
+	defer idp.Close()
+
+	j, err := NewJWT(ctx, JWTConfig{JWKS: idp.URL})
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	_, err = j.Authenticate(ctx, sign(t, jwt.SigningMethodRS256, first, "first", jwt.MapClaims{"sub": "sam", "exp": time.Now().Add(time.Hour).Unix()}))
+	assert.Nil(t, err)
+
+	keys.Store(jwksFor(map[string]*rsa.PrivateKey{"first": first, "second": second}))
+	rotated := sign(t, jwt.SigningMethodRS256, second, "second", jwt.MapClaims{"sub": "sam", "exp": time.Now().Add(time.Hour).Unix()})
+	_, err = j.Authenticate(ctx, rotated)
+	assert.NotNil(t, err, "fetched too recently to look again")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	j.keys.fetched = time.Now().Add(-jwksMinRefresh - time.Second)
+	_, err = j.Authenticate(ctx, rotated)
+	assert.Nil(t, err, "a new key id has the keys fetched again")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestJWKSSlowProvider(t *testing.T) {
+	ctx := context.Background()
+	first := newKey(t)
+	release := make(chan struct{})
+	var fetches int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		w.Write(jwksFor(map[string]*rsa.PrivateKey{"first": first}))
+	}))
+	defer idp.Close()
+	defer close(release)
+
+	keys := newJWKS(idp.URL)
+	assert.Nil(t, keys.refresh(ctx))
+	keys.fetched = time.Now().Add(-jwksMaxAge - time.Second)
+	for i := 0; i < 5; i++ {
+		k, err := keys.key(ctx, "first")
+		assert.Nil(t, err)
+		assert.Equal(t, &first.PublicKey, k, "old keys are used while they're fetched again")
+	}
+
+	keys.mu.Lock()
+	keys.fetched = time.Now().Add(-jwksMinRefresh - time.Second)
+	keys.mu.Unlock()
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err := keys.key(timeout, "second")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "an unknown key waits on the fetch, but only as long as the request")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "everyone shares the one fetch")
+}
+
+func TestNewJWT(t *testing.T) {
+	_, err := NewJWT(context.Background(), JWTConfig{})
+	assert.EqualError(t, err, "a jwt secret or jwks is needed to check tokens")
+	_, err = NewJWT(context.Background(), JWTConfig{JWKS: filepath.Join(t.TempDir(), "missing.json")})
+	assert.ErrorContains(t, err, "reading jwks")
+}
+
+func TestSchemes(t *testing.T) {
+	ctx := context.Background()
+	j, _ := NewJWT(ctx, JWTConfig{Secret: "not very secret"})
+	s := Schemes{"bearer": j}
+	p, err := s.Authenticate(ctx, sign(t, jwt.SigningMethodHS256, []byte("not very secret"), "", jwt.MapClaims{"sub": "sam", "exp": time.Now().Add(time.Hour).Unix()}))
+	assert.Nil(t, err)
+	assert.Equal(t, "sam", p.UserId)
+	_, err = s.Authenticate(ctx, "Digest abc")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "unsupported authorization scheme Digest", err.(*terr.TodoError).Details())
+	}
+	assert.Equal(t, []string{"Basic", "Bearer"}, Schemes{"bearer": j, "basic": j}.Names())
+}
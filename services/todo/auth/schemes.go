@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// Schemes picks the Authenticator for the scheme of an Authorization header, keyed by the lower case scheme name,
+// i.e: "basic" or "bearer".
+type Schemes map[string]Authenticator
+
+func (s Schemes) Authenticate(ctx context.Context, authorization string) (Principal, error) {
+	scheme, _, _ := strings.Cut(authorization, " ")
+	a, ok := s[strings.ToLower(scheme)]
+	if !ok {
+		return Principal{}, terr.ErrorWithCode("unauthorized", fmt.Sprintf("unsupported authorization scheme %s", scheme), 401)
+	}
+	return a.Authenticate(ctx, authorization)
+}
+
+// Names are the schemes as they're written in a header (Basic, Bearer), sorted, for telling clients what they can use.
+func (s Schemes) Names() []string {
+	var names []string
+	for name := range s {
+		if name == "" {
+			continue
+		}
+		names = append(names, strings.ToUpper(name[:1])+name[1:])
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,167 @@
+// Package bulk moves every todo item, deleted and completed ones included, in and out of a todoitem.Storer as CSV or
+// newline delimited json. Ids and timestamps are kept as they are, so it works for copying real data between
+// environments, or backends, where the seed command doesn't.
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// Formats are the formats Export and Import understand.
+var Formats = []string{"csv", "ndjson"}
+
+// pageSize is how many items Export reads from the store at a time.
+const pageSize = 500
+
+// Options change how Import treats what's in the store already.
+type Options struct {
+	//Upsert replaces items that already exist, otherwise they're skipped
+	Upsert bool
+	//DryRun works out what would happen without writing anything
+	DryRun bool
+}
+
+// Report is what an import did, or would have done for a dry run.
+type Report struct {
+	DryRun    bool
+	Created   int
+	Updated   int
+	Unchanged int
+	//Skipped are the ids of items that already exist and were different, left alone without Upsert
+	Skipped []string
+}
+
+func (r Report) String() string {
+	s := fmt.Sprintf("created %d, updated %d, unchanged %d, skipped %d", r.Created, r.Updated, r.Unchanged, len(r.Skipped))
+	if r.DryRun {
+		s = "dry run, nothing was written. Would have " + s
+	}
+	if len(r.Skipped) > 0 {
+		s += fmt.Sprintf("\nskipped as they already exist, use upsert to replace them: %s", strings.Join(r.Skipped, ", "))
+	}
+	return s
+}
+
+// Export writes every item in the store to w, oldest change first. It returns how many it wrote.
+func Export(ctx context.Context, store todoitem.Storer, w io.Writer, format string) (int, error) {
+	enc, err := newEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	var since int64
+	for {
+		items, err := store.Changes(ctx, since, pageSize, nil)
+		if err != nil {
+			return count, err
+		}
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return count, err
+			}
+			count++
+			since = *item.Version
+		}
+		if len(items) < pageSize {
+			return count, enc.Flush()
+		}
+	}
+}
+
+// Import reads items from r and puts them in the store by id. It stops at the first record it can't read, anything
+// before it has been imported already, so do a dry run first to check a file.
+func Import(ctx context.Context, store todoitem.Storer, r io.Reader, format string, opts Options) (Report, error) {
+	report := Report{DryRun: opts.DryRun}
+	dec, err := newDecoder(r, format)
+	if err != nil {
+		return report, err
+	}
+	for n := 1; ; n++ {
+		item, err := dec.Decode()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err == nil {
+			err = validate(&item)
+		}
+		if err != nil {
+			return report, fmt.Errorf("record %d: %w", n, err)
+		}
+		existing, err := store.GetById(ctx, *item.Id)
+		if err == nil {
+			stamp(&item, existing.Created, existing.Updated)
+		} else {
+			now := time.Now()
+			stamp(&item, &now, &now)
+		}
+		switch {
+		case err == nil && same(existing, item):
+			report.Unchanged++
+			continue
+		case err == nil && !opts.Upsert:
+			report.Skipped = append(report.Skipped, *item.Id)
+			continue
+		case err == nil:
+			report.Updated++
+		case notFound(err):
+			report.Created++
+		default:
+			return report, err
+		}
+		if opts.DryRun {
+			continue
+		}
+		if _, err := store.Put(ctx, item); err != nil {
+			return report, fmt.Errorf("record %d: %w", n, err)
+		}
+	}
+}
+
+// validate checks an item has what it needs, and fills in the flags a new item would start with.
+func validate(item *todoitem.TodoItem) error {
+	if item.Id == nil || *item.Id == "" {
+		return fmt.Errorf("no id")
+	}
+	if item.Summary == nil || strings.TrimSpace(*item.Summary) == "" {
+		return fmt.Errorf("no summary")
+	}
+	if item.Priority != nil && item.Priority.Rank() < 0 {
+		return fmt.Errorf("unknown priority %s", *item.Priority)
+	}
+	f := false
+	if item.Deleted == nil {
+		item.Deleted = &f
+	}
+	if item.Completed == nil {
+		item.Completed = &f
+	}
+	item.Version = nil
+	return nil
+}
+
+// stamp fills in timestamps left out of a record, with the existing item's if there is one so it doesn't look changed.
+func stamp(item *todoitem.TodoItem, created, updated *time.Time) {
+	if item.Created == nil {
+		item.Created = created
+	}
+	if item.Updated == nil {
+		item.Updated = updated
+	}
+}
+
+// same compares items by what's exported, so versions and time zones don't count.
+func same(a, b todoitem.TodoItem) bool {
+	return strings.Join(record(a), "\x00") == strings.Join(record(b), "\x00")
+}
+
+func notFound(err error) bool {
+	v, ok := err.(*terr.TodoError)
+	return ok && v.HttpCode == 404
+}
@@ -0,0 +1,141 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newSummary(summary string) *string {
+	return &summary
+}
+
+// newStore has one of everything worth exporting: completed, deleted, prioritised, tagged, due, owned and assigned.
+func newStore(t *testing.T) *memdb.Store {
+	ctx := context.Background()
+	store := memdb.NewStore()
+	core := todoitem.NewCore(store)
+	high := todoitem.PriorityHigh
+	due := time.Date(2026, time.October, 20, 0, 0, 0, 0, time.UTC)
+	milk, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk, \"oat\""), Priority: &high, Due: &due,
+		Tags: []string{"home", "errands"}})
+	done := true
+	core.Update(ctx, todoitem.TodoItem{Summary: milk.Summary, Completed: &done}, *milk.Id)
+	dog, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("walk dog")})
+	core.Delete(ctx, *dog.Id)
+	core.Create(auth.WithPrincipal(ctx, auth.Principal{UserId: "sam"}), todoitem.TodoItem{Summary: newSummary("call mom\nabout dinner"),
+		AssigneeIds: []string{"sam"}})
+	return store
+}
+
+func all(t *testing.T, store *memdb.Store) map[string]string {
+	items, err := store.Changes(context.Background(), 0, 1000, nil)
+	assert.Nil(t, err)
+	res := map[string]string{}
+	for _, item := range items {
+		res[*item.Id] = strings.Join(record(item), "|")
+	}
+	return res
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		tf := func(t *testing.T) {
+			from := newStore(t)
+			out := &bytes.Buffer{}
+			n, err := Export(context.Background(), from, out, format)
+			assert.Nil(t, err)
+			assert.Equal(t, 3, n)
+
+			to := memdb.NewStore()
+			report, err := Import(context.Background(), to, bytes.NewReader(out.Bytes()), format, Options{})
+			assert.Nil(t, err)
+			assert.Equal(t, Report{Created: 3}, report)
+			assert.Equal(t, all(t, from), all(t, to), "ids, timestamps, deleted, completed, owners and assignees all kept")
+
+			report, err = Import(context.Background(), to, bytes.NewReader(out.Bytes()), format, Options{})
+			assert.Nil(t, err)
+			assert.Equal(t, Report{Unchanged: 3}, report)
+		}
+		t.Run(format, tf)
+	}
+}
+
+func TestExportPages(t *testing.T) {
+	store := memdb.NewStore()
+	core := todoitem.NewCore(store)
+	for i := 0; i < pageSize+1; i++ {
+		core.Create(context.Background(), todoitem.TodoItem{Summary: newSummary("item")})
+	}
+	out := &bytes.Buffer{}
+	n, err := Export(context.Background(), store, out, "ndjson")
+	assert.Nil(t, err)
+	assert.Equal(t, pageSize+1, n)
+	assert.Equal(t, pageSize+1, strings.Count(out.String(), "\n"))
+	assert.NotContains(t, out.String(), "version")
+}
+
+func TestUpsert(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.NewStore()
+	in := "id,summary,created\n1,buy milk,2023-01-12T12:00:00Z\n2,walk dog,2023-01-12T12:00:00Z\n"
+	_, err := Import(ctx, store, strings.NewReader(in), "csv", Options{})
+	assert.Nil(t, err)
+
+	changed := "id,summary,created\n1,buy oat milk,2023-01-12T12:00:00Z\n2,walk dog,2023-01-12T12:00:00Z\n3,call mom,\n"
+	report, err := Import(ctx, store, strings.NewReader(changed), "csv", Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, Report{Created: 1, Unchanged: 1, Skipped: []string{"1"}}, report)
+	assert.Contains(t, report.String(), "use upsert")
+	item, _ := store.GetById(ctx, "1")
+	assert.Equal(t, "buy milk", *item.Summary, "skipped")
+
+	report, err = Import(ctx, store, strings.NewReader(changed), "csv", Options{Upsert: true, DryRun: true})
+	assert.Nil(t, err)
+	assert.Equal(t, Report{DryRun: true, Updated: 1, Unchanged: 2}, report, "3 is there now")
+	assert.Contains(t, report.String(), "dry run")
+	item, _ = store.GetById(ctx, "1")
+	assert.Equal(t, "buy milk", *item.Summary, "dry run writes nothing")
+
+	report, err = Import(ctx, store, strings.NewReader(changed), "csv", Options{Upsert: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Updated)
+	item, _ = store.GetById(ctx, "1")
+	assert.Equal(t, "buy oat milk", *item.Summary)
+	assert.Equal(t, time.Date(2023, time.January, 12, 12, 0, 0, 0, time.UTC), item.Created.UTC())
+}
+
+func TestImportErrors(t *testing.T) {
+	type test struct {
+		name   string
+		format string
+		in     string
+		err    string
+	}
+	tests := []test{
+		{name: "unknown format", format: "xml", err: "unknown format xml, use csv or ndjson"},
+		{name: "unknown column", format: "csv", in: "id,summary,colour\n", err: "unknown column colour"},
+		{name: "no id", format: "csv", in: "id,summary\n1,one\n,two\n", err: "record 2: no id"},
+		{name: "no summary", format: "ndjson", in: `{"id":"1"}`, err: "record 1: no summary"},
+		{name: "bad priority", format: "ndjson", in: `{"id":"1","summary":"one","priority":"urgent"}`, err: "unknown priority urgent"},
+		{name: "bad time", format: "csv", in: "id,summary,due\n1,one,tomorrow\n", err: "record 1: invalid due tomorrow"},
+		{name: "bad bool", format: "csv", in: "id,summary,deleted\n1,one,nah\n", err: "invalid deleted nah"},
+		{name: "unknown field", format: "ndjson", in: `{"id":"1","summary":"one","colour":"red"}`, err: "colour"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, err := Import(context.Background(), memdb.NewStore(), strings.NewReader(tt.in), tt.format, Options{})
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
@@ -0,0 +1,249 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// columns are the csv columns, in the order they're written. Tags and assignees are comma separated within their
+// column.
+var columns = []string{"id", "created", "updated", "deleted", "completed", "completedAt", "summary", "priority", "due", "tags", "ownerId",
+	"assigneeIds"}
+
+type encoder interface {
+	Encode(todoitem.TodoItem) error
+	Flush() error
+}
+
+// decoder returns io.EOF once there's nothing left.
+type decoder interface {
+	Decode() (todoitem.TodoItem, error)
+}
+
+func newEncoder(w io.Writer, format string) (encoder, error) {
+	switch format {
+	case "csv":
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case "ndjson":
+		return &jsonEncoder{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, unknownFormat(format)
+}
+
+func newDecoder(r io.Reader, format string) (decoder, error) {
+	switch format {
+	case "csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		return &csvDecoder{r: cr}, nil
+	case "ndjson":
+		scanner := bufio.NewScanner(r)
+		//summaries can be long, don't stop at bufio's 64k default
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		return &jsonDecoder{scanner: scanner}, nil
+	}
+	return nil, unknownFormat(format)
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown format %s, use %s", format, strings.Join(Formats, " or "))
+}
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) Encode(item todoitem.TodoItem) error {
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		if err := e.w.Write(columns); err != nil {
+			return err
+		}
+	}
+	return e.w.Write(record(item))
+}
+
+// Flush writes the header even if there were no items, so an empty export can still be imported.
+func (e *csvEncoder) Flush() error {
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		if err := e.w.Write(columns); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// record is an item's csv row. Times are UTC so the same item always writes the same row.
+func record(item todoitem.TodoItem) []string {
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	tm := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	bl := func(b *bool) string {
+		return strconv.FormatBool(b != nil && *b)
+	}
+	priority := ""
+	if item.Priority != nil {
+		priority = string(*item.Priority)
+	}
+	return []string{str(item.Id), tm(item.Created), tm(item.Updated), bl(item.Deleted), bl(item.Completed),
+		tm(item.CompletedAt), str(item.Summary), priority, tm(item.Due), strings.Join(item.Tags, ","), str(item.OwnerId),
+		strings.Join(item.AssigneeIds, ",")}
+}
+
+type csvDecoder struct {
+	r *csv.Reader
+	//index of each column in the file, files from elsewhere don't have to have them all or in our order
+	index map[string]int
+}
+
+func (d *csvDecoder) Decode() (todoitem.TodoItem, error) {
+	if d.index == nil {
+		header, err := d.r.Read()
+		if err != nil {
+			return todoitem.TodoItem{}, err
+		}
+		d.index = map[string]int{}
+		for i, name := range header {
+			if !known(name) {
+				return todoitem.TodoItem{}, fmt.Errorf("unknown column %s, use %s", name, strings.Join(columns, ", "))
+			}
+			d.index[name] = i
+		}
+	}
+	row, err := d.r.Read()
+	if err != nil {
+		return todoitem.TodoItem{}, err
+	}
+	return d.item(row)
+}
+
+func (d *csvDecoder) item(row []string) (todoitem.TodoItem, error) {
+	var item todoitem.TodoItem
+	field := func(name string) string {
+		i, ok := d.index[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+	str := func(name string) *string {
+		v := field(name)
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	var err error
+	tm := func(name string) *time.Time {
+		v := field(name)
+		if v == "" || err != nil {
+			return nil
+		}
+		t, perr := time.Parse(time.RFC3339Nano, v)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %s, use RFC3339", name, v)
+			return nil
+		}
+		return &t
+	}
+	bl := func(name string) *bool {
+		v := field(name)
+		if v == "" || err != nil {
+			return nil
+		}
+		b, perr := strconv.ParseBool(v)
+		if perr != nil {
+			err = fmt.Errorf("invalid %s %s, use true or false", name, v)
+			return nil
+		}
+		return &b
+	}
+	item.Id = str("id")
+	item.Summary = str("summary")
+	item.OwnerId = str("ownerId")
+	item.Created = tm("created")
+	item.Updated = tm("updated")
+	item.CompletedAt = tm("completedAt")
+	item.Due = tm("due")
+	item.Deleted = bl("deleted")
+	item.Completed = bl("completed")
+	if p := field("priority"); p != "" {
+		priority := todoitem.Priority(p)
+		item.Priority = &priority
+	}
+	if tags := field("tags"); tags != "" {
+		item.Tags = strings.Split(tags, ",")
+	}
+	if assignees := field("assigneeIds"); assignees != "" {
+		item.AssigneeIds = strings.Split(assignees, ",")
+	}
+	return item, err
+}
+
+func known(column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+// Encode leaves the version out, it only means something to the store it came from.
+func (e *jsonEncoder) Encode(item todoitem.TodoItem) error {
+	item.Version = nil
+	return e.enc.Encode(item)
+}
+
+func (e *jsonEncoder) Flush() error {
+	return nil
+}
+
+type jsonDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func (d *jsonDecoder) Decode() (todoitem.TodoItem, error) {
+	for d.scanner.Scan() {
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item todoitem.TodoItem
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&item); err != nil {
+			return todoitem.TodoItem{}, err
+		}
+		return item, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return todoitem.TodoItem{}, err
+	}
+	return todoitem.TodoItem{}, io.EOF
+}
@@ -0,0 +1,205 @@
+// Package client is a Go client for the todo rest api, so tools that talk to the service don't each have to write
+// their own http code.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Client talks to a todo server. The zero values of the exported fields are fine apart from BaseURL, NewClient sets
+// sensible defaults.
+type Client struct {
+	//BaseURL is where the api is served from, i.e: http://localhost:9000/api
+	BaseURL    string
+	HTTPClient *http.Client
+	//MaxRetries is how many times a failed call is retried. Only calls that are safe to repeat are retried (see
+	//retryable), so a Create is never sent twice.
+	MaxRetries int
+	//RetryWait is the wait before the first retry, doubling after that.
+	RetryWait time.Duration
+	//PageSize is how many items List fetches per request.
+	PageSize int
+	//Authorization is sent as the Authorization header of every request when set, i.e: BasicAuth(email, password)
+	Authorization string
+}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		RetryWait:  200 * time.Millisecond,
+		PageSize:   100,
+	}
+}
+
+// BasicAuth is the Authorization header for logging in with an email and password.
+func BasicAuth(email, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(email+":"+password))
+}
+
+func (c *Client) Create(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
+	var created todoitem.TodoItem
+	_, err := c.do(ctx, "Create", http.MethodPost, "/todo", item, &created)
+	return created, err
+}
+
+func (c *Client) Get(ctx context.Context, id string) (todoitem.TodoItem, error) {
+	var item todoitem.TodoItem
+	_, err := c.do(ctx, "Get", http.MethodGet, "/todo/"+url.PathEscape(id), nil, &item)
+	return item, err
+}
+
+// Update changes the item with the given id. Like the api, fields left nil are left alone and the summary is
+// required.
+func (c *Client) Update(ctx context.Context, item todoitem.TodoItem, id string) (todoitem.TodoItem, error) {
+	var updated todoitem.TodoItem
+	_, err := c.do(ctx, "Update", http.MethodPatch, "/todo/"+url.PathEscape(id), item, &updated)
+	return updated, err
+}
+
+// Delete soft deletes the item, returning it as it now stands.
+func (c *Client) Delete(ctx context.Context, id string) (todoitem.TodoItem, error) {
+	var deleted todoitem.TodoItem
+	_, err := c.do(ctx, "Delete", http.MethodDelete, "/todo/"+url.PathEscape(id), nil, &deleted)
+	return deleted, err
+}
+
+// List iterates over the items matching a filter language expression, or every item that isn't deleted if query is
+// empty. Pages are fetched as they're needed.
+func (c *Client) List(ctx context.Context, query string) *Iterator {
+	q := url.Values{}
+	if query != "" {
+		q.Set("q", query)
+	}
+	q.Set("limit", fmt.Sprint(c.PageSize))
+	return &Iterator{ctx: ctx, client: c, next: "/todo?" + q.Encode()}
+}
+
+// do sends a request to the api and decodes the response into out, returning the response headers. Errors from the
+// server come back as *errors.TodoError, the same as they were on the server.
+func (c *Client) do(ctx context.Context, name, method, path string, body any, out any) (http.Header, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "client."+name)
+	defer span.End()
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		header, err := c.send(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.MaxRetries || !retryable(method, err) {
+			return header, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out any) (http.Header, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), body)
+	if err != nil {
+		return nil, err
+	}
+	//the server only takes json
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.Authorization != "" {
+		req.Header.Set("Authorization", c.Authorization)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, decodeError(resp.StatusCode, b)
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return nil, fmt.Errorf("decoding %s %s response: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// url joins a path onto the base url. The iterator's next page links are already full urls.
+func (c *Client) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.BaseURL + path
+}
+
+// decodeError turns an error response back into a TodoError. Most are already one, but a few (i.e: a badly formed
+// body) are plain text.
+func decodeError(code int, body []byte) error {
+	var e struct {
+		Message  string `json:"message"`
+		Code     int    `json:"code"`
+		Details  string `json:"details"`
+		Position *int   `json:"position"`
+	}
+	if err := json.Unmarshal(body, &e); err == nil && e.Message != "" {
+		v := terr.ErrorWithCode(e.Message, e.Details, code)
+		if e.Position != nil {
+			v.AtPosition(*e.Position)
+		}
+		return v
+	}
+	return terr.ErrorWithCode(strings.ToLower(http.StatusText(code)), strings.TrimSpace(string(body)), code)
+}
+
+// retryable reports if a failed call is worth trying again. Only reads, updates (which set fields rather than change
+// them) and deletes are repeated, and only if the server didn't get them or was unavailable.
+func retryable(method string, err error) bool {
+	if method == http.MethodPost {
+		return false
+	}
+	if v, ok := err.(*terr.TodoError); ok {
+		switch v.HttpCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	//the request didn't make it, unless we gave up on it ourselves
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/rest"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newServer runs the real rest handlers over memdb, with wrap put in front of them so tests can get in the way.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *Client {
+	srv := rest.NewServer("", "")
+	tdh := rest.NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(srv.Router, "/api")
+	var handler http.Handler = srv.Router
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	c := NewClient(ts.URL + "/api")
+	c.RetryWait = time.Millisecond
+	return c
+}
+
+func newSummary(summary string) *string {
+	return &summary
+}
+
+func newBool(b bool) *bool {
+	return &b
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	c := newServer(t, nil)
+	created, err := c.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk"), Tags: []string{"home"}})
+	assert.Nil(t, err)
+	assert.NotNil(t, created.Id)
+
+	got, err := c.Get(ctx, *created.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "buy milk", *got.Summary)
+	assert.Equal(t, []string{"home"}, got.Tags)
+
+	updated, err := c.Update(ctx, todoitem.TodoItem{Summary: got.Summary, Completed: newBool(true)}, *created.Id)
+	assert.Nil(t, err)
+	assert.True(t, *updated.Completed)
+
+	deleted, err := c.Delete(ctx, *created.Id)
+	assert.Nil(t, err)
+	assert.True(t, *deleted.Deleted)
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newServer(t, nil)
+	created, _ := c.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk")})
+	type test struct {
+		name string
+		call func() error
+		code int
+	}
+	tests := []test{
+		{name: "missing", code: 404, call: func() error {
+			_, err := c.Get(ctx, "nope")
+			return err
+		}},
+		{name: "empty summary", code: 400, call: func() error {
+			_, err := c.Create(ctx, todoitem.TodoItem{Summary: newSummary("")})
+			return err
+		}},
+		{name: "bad filter", code: 400, call: func() error {
+			_, err := c.List(ctx, "priority:urgent").All()
+			return err
+		}},
+		{name: "missing update", code: 404, call: func() error {
+			_, err := c.Update(ctx, todoitem.TodoItem{Summary: created.Summary}, "nope")
+			return err
+		}},
+		{name: "bad body", code: 400, call: func() error {
+			_, err := c.Update(ctx, todoitem.TodoItem{}, *created.Id)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			err := tt.call()
+			v, ok := err.(*terr.TodoError)
+			if assert.True(t, ok, "got %v", err) {
+				assert.Equal(t, tt.code, v.HttpCode)
+				assert.NotEmpty(t, v.Message())
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+
+	_, err := c.List(ctx, "priority:urgent").All()
+	if v, ok := err.(*terr.TodoError); assert.True(t, ok) {
+		pos, ok := v.Position()
+		assert.True(t, ok, "filter errors keep their position")
+		assert.Equal(t, 9, pos)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	var requests int32
+	c := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				atomic.AddInt32(&requests, 1)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c.PageSize = 2
+	for i := 0; i < 5; i++ {
+		item := todoitem.TodoItem{Summary: newSummary(fmt.Sprintf("item %d", i))}
+		if i%2 == 0 {
+			item.Tags = []string{"even"}
+		}
+		c.Create(ctx, item)
+	}
+
+	items, err := c.List(ctx, "").All()
+	assert.Nil(t, err)
+	assert.Len(t, items, 5)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&requests))
+	seen := map[string]bool{}
+	for _, item := range items {
+		seen[*item.Id] = true
+	}
+	assert.Len(t, seen, 5, "no item twice")
+
+	it := c.List(ctx, "tag:even")
+	count := 0
+	for it.Next() {
+		assert.Equal(t, []string{"even"}, it.Item().Tags)
+		count++
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 3, count)
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	var failures int32
+	c := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				w.WriteHeader(503)
+				w.Write([]byte("try again later"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	created, _ := c.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk")})
+
+	type test struct {
+		name     string
+		failures int32
+		retries  int
+		call     func() error
+		code     int
+	}
+	tests := []test{
+		{name: "recovers", failures: 2, retries: 3, call: func() error {
+			_, err := c.Get(ctx, *created.Id)
+			return err
+		}},
+		{name: "gives up", failures: 3, retries: 2, code: 503, call: func() error {
+			_, err := c.Get(ctx, *created.Id)
+			return err
+		}},
+		{name: "doesn't repeat creates", failures: 1, retries: 3, code: 503, call: func() error {
+			_, err := c.Create(ctx, todoitem.TodoItem{Summary: newSummary("walk dog")})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			atomic.StoreInt32(&failures, tt.failures)
+			c.MaxRetries = tt.retries
+			err := tt.call()
+			if tt.code == 0 {
+				assert.Nil(t, err)
+				return
+			}
+			v, ok := err.(*terr.TodoError)
+			if assert.True(t, ok, "got %v", err) {
+				assert.Equal(t, tt.code, v.HttpCode)
+				assert.Equal(t, "try again later", v.Details())
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	var got trace.SpanContext
+	c := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			got = trace.SpanContextFromContext(ctx)
+			next.ServeHTTP(w, r)
+		})
+	})
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+	c.List(ctx, "").All()
+	assert.Equal(t, parent.TraceID(), got.TraceID())
+}
+
+func TestAuthorization(t *testing.T) {
+	var got atomic.Value
+	c := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got.Store(r.Header.Get("Authorization"))
+			next.ServeHTTP(w, r)
+		})
+	})
+	c.Get(context.Background(), "nope")
+	assert.Equal(t, "", got.Load(), "nothing is sent without credentials")
+
+	c.Authorization = BasicAuth("sam@example.com", "correct horse")
+	c.Get(context.Background(), "nope")
+	assert.Equal(t, "Basic c2FtQGV4YW1wbGUuY29tOmNvcnJlY3QgaG9yc2U=", got.Load())
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// Iterator walks through a list a page at a time, following the server's Link headers. Use it like a bufio.Scanner:
+//
+//	it := c.List(ctx, "completed:false")
+//	for it.Next() {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//
+// The list isn't a snapshot, items changed while iterating can be skipped or seen twice.
+type Iterator struct {
+	ctx    context.Context
+	client *Client
+	//next is the url of the page after page, empty once we're on the last one
+	next string
+	page []todoitem.TodoItem
+	pos  int
+	err  error
+}
+
+// Next moves to the next item, fetching another page if needed. It returns false at the end of the list, or if a
+// fetch failed, see Err.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.pos++
+	for it.pos >= len(it.page) {
+		if it.next == "" {
+			return false
+		}
+		var page []todoitem.TodoItem
+		header, err := it.client.do(it.ctx, "List", "GET", it.next, nil, &page)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.pos = page, 0
+		it.next = it.client.nextLink(header.Get("Link"))
+	}
+	return true
+}
+
+// Item is the current item. Only call it after Next has returned true.
+func (it *Iterator) Item() todoitem.TodoItem {
+	return it.page[it.pos]
+}
+
+// Err is the error that stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// All reads the rest of the list into a slice.
+func (it *Iterator) All() ([]todoitem.TodoItem, error) {
+	items := []todoitem.TodoItem{}
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// nextLink pulls the rel="next" url out of a Link header, resolved against the base url.
+func (c *Client) nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		ref, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return ""
+		}
+		base, err := url.Parse(c.BaseURL)
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return ""
+}
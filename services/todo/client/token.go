@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/stumacwastaken/todo/token"
+)
+
+// BearerAuth is the Authorization header for an access token (or a JWT).
+func BearerAuth(t string) string {
+	return "Bearer " + t
+}
+
+// CreateToken makes an access token for whoever the client is logged in as. The secret is in the returned token's
+// Token field, and can't be got again.
+func (c *Client) CreateToken(ctx context.Context, t token.Token) (token.Token, error) {
+	var created token.Token
+	_, err := c.do(ctx, "CreateToken", http.MethodPost, "/tokens", t, &created)
+	return created, err
+}
+
+func (c *Client) Tokens(ctx context.Context) ([]token.Token, error) {
+	var tokens []token.Token
+	_, err := c.do(ctx, "Tokens", http.MethodGet, "/tokens", nil, &tokens)
+	return tokens, err
+}
+
+func (c *Client) RevokeToken(ctx context.Context, id string) (token.Token, error) {
+	var revoked token.Token
+	_, err := c.do(ctx, "RevokeToken", http.MethodDelete, "/tokens/"+url.PathEscape(id), nil, &revoked)
+	return revoked, err
+}
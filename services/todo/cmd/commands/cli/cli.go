@@ -0,0 +1,152 @@
+// Package cli is the todo commands that talk to a running server through the client package: add, ls, done, edit and
+// rm.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/client"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// defaultServer is used when neither --server nor TODO_SERVER are set. It's where `todo server` listens by default.
+const defaultServer = "http://localhost:9000/api"
+
+// options are the flags every client command takes.
+type options struct {
+	server string
+	output string
+}
+
+// Commands builds the client commands. They're built fresh each call so tests don't share flag values.
+func Commands() []*cobra.Command {
+	opts := &options{}
+	cmds := []*cobra.Command{
+		addCmd(opts),
+		lsCmd(opts),
+		doneCmd(opts),
+		editCmd(opts),
+		rmCmd(opts),
+	}
+	server := os.Getenv("TODO_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	for _, cmd := range cmds {
+		cmd.Flags().StringVar(&opts.server, "server", server, "url of the todo api, defaults to $TODO_SERVER if set")
+		cmd.Flags().StringVarP(&opts.output, "output", "o", "table", "output format. use table, json or plain")
+	}
+	//token's flags are shared by its sub commands
+	token := tokenCmd(opts)
+	token.PersistentFlags().StringVar(&opts.server, "server", server, "url of the todo api, defaults to $TODO_SERVER if set")
+	token.PersistentFlags().StringVarP(&opts.output, "output", "o", "table", "output format. use table, json or plain")
+	cmds = append(cmds, token, workspaceCmd(opts))
+	//these write files rather than items, so have no --output
+	for _, cmd := range []*cobra.Command{exportCmd(opts), importCmd(opts), syncCmd(opts)} {
+		cmd.Flags().StringVar(&opts.server, "server", server, "url of the todo api, defaults to $TODO_SERVER if set")
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// client talks to the server with the access token in TODO_TOKEN, or as the user in TODO_EMAIL and TODO_PASSWORD.
+// Credentials are only ever read from the environment so they don't end up in shell history.
+func (o *options) client() *client.Client {
+	c := client.NewClient(o.server)
+	c.Authorization = envAuthorization()
+	return c
+}
+
+// envAuthorization is the Authorization header for the credentials in the environment, empty if there aren't any.
+func envAuthorization() string {
+	if t := os.Getenv("TODO_TOKEN"); t != "" {
+		return client.BearerAuth(t)
+	}
+	if email := os.Getenv("TODO_EMAIL"); email != "" {
+		return client.BasicAuth(email, os.Getenv("TODO_PASSWORD"))
+	}
+	return ""
+}
+
+// printItem writes a single item in the chosen output format. As json it's an object rather than a list of one.
+func (o *options) printItem(w io.Writer, item todoitem.TodoItem) error {
+	if o.output == "json" {
+		return writeJSON(w, item)
+	}
+	return o.printItems(w, []todoitem.TodoItem{item})
+}
+
+// printItems writes items in the chosen output format.
+func (o *options) printItems(w io.Writer, items []todoitem.TodoItem) error {
+	switch o.output {
+	case "json":
+		return writeJSON(w, items)
+	case "plain":
+		for _, item := range items {
+			fmt.Fprintf(w, "%s %s\n", deref(item.Id), deref(item.Summary))
+		}
+		return nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tDONE\tPRIORITY\tDUE\tTAGS\tSUMMARY")
+		for _, item := range items {
+			done := " "
+			if item.Completed != nil && *item.Completed {
+				done = "x"
+			}
+			priority := ""
+			if item.Priority != nil && *item.Priority != todoitem.PriorityNone {
+				priority = string(*item.Priority)
+			}
+			due := ""
+			if item.Due != nil {
+				due = item.Due.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", deref(item.Id), done, priority, due,
+				strings.Join(item.Tags, ","), deref(item.Summary))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %s, use table, json or plain", o.output)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// cliError makes a TodoError readable on a terminal, rather than the json it'd print as.
+func cliError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return fmt.Errorf("%s: %s", v.Message(), v.Details())
+	}
+	return err
+}
+
+// parseDue takes a date (2006-01-02, midnight local time) or an RFC3339 timestamp.
+func parseDue(v string) (*time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due %s, use a date (2006-01-02) or RFC3339 timestamp", v)
+	}
+	return &t, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
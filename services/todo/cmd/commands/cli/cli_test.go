@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/rest"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newServer(t *testing.T) string {
+	srv := rest.NewServer("", "")
+	tdh := rest.NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(srv.Router, "/api")
+	ts := httptest.NewServer(srv.Router)
+	t.Cleanup(ts.Close)
+	return ts.URL + "/api"
+}
+
+// run runs a command against the server the way the todo binary would, returning what it printed.
+func run(t *testing.T, server string, args ...string) (string, error) {
+	root := &cobra.Command{Use: "todo", SilenceErrors: true, SilenceUsage: true}
+	root.AddCommand(Commands()...)
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetArgs(append(args, "--server", server))
+	err := root.Execute()
+	return out.String(), err
+}
+
+func runJSON(t *testing.T, server string, out any, args ...string) {
+	res, err := run(t, server, append(args, "-o", "json")...)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, json.Unmarshal([]byte(res), out))
+}
+
+func TestCommands(t *testing.T) {
+	server := newServer(t)
+	var milk, dog todoitem.TodoItem
+	runJSON(t, server, &milk, "add", "buy", "milk", "-p", "high", "-t", "home", "--due", "2026-10-20")
+	assert.Equal(t, "buy milk", *milk.Summary)
+	assert.Equal(t, todoitem.PriorityHigh, *milk.Priority)
+	assert.Equal(t, []string{"home"}, milk.Tags)
+	assert.NotNil(t, milk.Due)
+	runJSON(t, server, &dog, "add", "walk dog", "-t", "pets")
+
+	var items []todoitem.TodoItem
+	runJSON(t, server, &items, "done", *milk.Id)
+	assert.True(t, *items[0].Completed)
+
+	runJSON(t, server, &items, "ls", "--completed=false")
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, *dog.Id, *items[0].Id)
+	}
+	runJSON(t, server, &items, "ls", "-t", "home")
+	assert.Len(t, items, 1)
+
+	var edited todoitem.TodoItem
+	runJSON(t, server, &edited, "edit", *dog.Id, "-s", "walk the dog", "-p", "low")
+	assert.Equal(t, "walk the dog", *edited.Summary)
+	assert.Equal(t, todoitem.PriorityLow, *edited.Priority)
+	assert.Equal(t, []string{"pets"}, edited.Tags, "left alone")
+
+	out, err := run(t, server, "rm", *dog.Id, "-o", "plain")
+	assert.Nil(t, err)
+	assert.Equal(t, *dog.Id+" walk the dog\n", out)
+	runJSON(t, server, &items, "ls")
+	assert.Len(t, items, 1)
+	runJSON(t, server, &items, "ls", "--deleted")
+	assert.Len(t, items, 1)
+}
+
+func TestTableOutput(t *testing.T) {
+	server := newServer(t)
+	run(t, server, "add", "buy milk", "-t", "home,errands", "-p", "medium")
+	out, err := run(t, server, "ls")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, []string{"ID", "DONE", "PRIORITY", "DUE", "TAGS", "SUMMARY"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"medium", "home,errands", "buy", "milk"}, strings.Fields(lines[1])[1:])
+	}
+}
+
+func TestEditInEditor(t *testing.T) {
+	server := newServer(t)
+	var item todoitem.TodoItem
+	runJSON(t, server, &item, "add", "buy milk")
+	prev := editor
+	defer func() { editor = prev }()
+	editor = func(path string) *exec.Cmd {
+		os.WriteFile(path, []byte("buy oat milk\nignored\n"), 0600)
+		return exec.Command("true")
+	}
+	runJSON(t, server, &item, "edit", *item.Id)
+	assert.Equal(t, "buy oat milk", *item.Summary)
+}
+
+func TestErrors(t *testing.T) {
+	server := newServer(t)
+	type test struct {
+		name string
+		args []string
+		err  string
+	}
+	tests := []test{
+		{name: "missing item", args: []string{"done", "nope"}, err: "not found"},
+		{name: "bad priority", args: []string{"add", "x", "-p", "urgent"}, err: "priority"},
+		{name: "bad due", args: []string{"add", "x", "--due", "soon"}, err: "invalid due soon"},
+		{name: "bad filter", args: []string{"ls", "nope:nope"}, err: "nope"},
+		{name: "bad output", args: []string{"ls", "-o", "yaml"}, err: "unknown output format yaml"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, err := run(t, server, tt.args...)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+				assert.NotContains(t, err.Error(), "{", "not the raw json")
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func addCmd(opts *options) *cobra.Command {
+	var priority, due string
+	var tags []string
+	cmd := &cobra.Command{
+		Use:   "add <summary>",
+		Short: "adds a todo item",
+		Long:  `adds a todo item. Words after add are joined into the summary, so quoting it is optional.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			summary := strings.Join(args, " ")
+			item := todoitem.TodoItem{Summary: &summary, Tags: tags}
+			if priority != "" {
+				p := todoitem.Priority(priority)
+				item.Priority = &p
+			}
+			if due != "" {
+				t, err := parseDue(due)
+				if err != nil {
+					return err
+				}
+				item.Due = t
+			}
+			created, err := opts.client().Create(cmd.Context(), item)
+			if err != nil {
+				return cliError(err)
+			}
+			return opts.printItem(cmd.OutOrStdout(), created)
+		},
+	}
+	cmd.Flags().StringVarP(&priority, "priority", "p", "", "none, low, medium or high")
+	cmd.Flags().StringVar(&due, "due", "", "due date (2006-01-02) or RFC3339 timestamp")
+	cmd.Flags().StringSliceVarP(&tags, "tag", "t", nil, "tag to add, can be repeated")
+	return cmd
+}
+
+func lsCmd(opts *options) *cobra.Command {
+	var completed, deleted bool
+	var priority string
+	var tags []string
+	cmd := &cobra.Command{
+		Use:   "ls [filter expression]",
+		Short: "lists todo items",
+		Long: `lists todo items that aren't deleted. Flags and arguments are combined into a filter expression, so
+todo ls --completed=false tag:work is the same as todo ls completed:false tag:work. See the README for the syntax.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			terms := []string{}
+			if cmd.Flags().Changed("completed") {
+				terms = append(terms, fmt.Sprintf("completed:%t", completed))
+			}
+			if cmd.Flags().Changed("deleted") {
+				terms = append(terms, fmt.Sprintf("deleted:%t", deleted))
+			}
+			if priority != "" {
+				terms = append(terms, "priority:"+priority)
+			}
+			for _, t := range tags {
+				terms = append(terms, "tag:"+t)
+			}
+			terms = append(terms, args...)
+			items, err := opts.client().List(cmd.Context(), strings.Join(terms, " ")).All()
+			if err != nil {
+				return cliError(err)
+			}
+			return opts.printItems(cmd.OutOrStdout(), items)
+		},
+	}
+	cmd.Flags().BoolVar(&completed, "completed", false, "only completed (true) or open (false) items")
+	cmd.Flags().BoolVar(&deleted, "deleted", false, "only deleted (true) items, or explicitly not (false)")
+	cmd.Flags().StringVarP(&priority, "priority", "p", "", "only items with this priority")
+	cmd.Flags().StringSliceVarP(&tags, "tag", "t", nil, "only items with this tag, can be repeated")
+	return cmd
+}
+
+func doneCmd(opts *options) *cobra.Command {
+	var undo bool
+	cmd := &cobra.Command{
+		Use:   "done <id>...",
+		Short: "completes todo items",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := opts.client()
+			completed := !undo
+			items := []todoitem.TodoItem{}
+			for _, id := range args {
+				item, err := c.Get(cmd.Context(), id)
+				if err != nil {
+					return cliError(err)
+				}
+				//the api wants the summary on every update
+				updated, err := c.Update(cmd.Context(), todoitem.TodoItem{Summary: item.Summary, Completed: &completed}, id)
+				if err != nil {
+					return cliError(err)
+				}
+				items = append(items, updated)
+			}
+			return opts.printItems(cmd.OutOrStdout(), items)
+		},
+	}
+	cmd.Flags().BoolVar(&undo, "undo", false, "reopen the items instead")
+	return cmd
+}
+
+// editor is the command edit opens the summary in, pulled out so tests can swap it.
+var editor = func(path string) *exec.Cmd {
+	name := os.Getenv("VISUAL")
+	if name == "" {
+		name = os.Getenv("EDITOR")
+	}
+	if name == "" {
+		name = "vi"
+	}
+	cmd := exec.Command(name, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd
+}
+
+func editCmd(opts *options) *cobra.Command {
+	var summary, priority, due string
+	var tags []string
+	cmd := &cobra.Command{
+		Use:   "edit <id>",
+		Short: "edits a todo item",
+		Long: `edits a todo item. Only the fields given as flags are changed. Without any, the summary is opened in
+$VISUAL or $EDITOR.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := opts.client()
+			item, err := c.Get(cmd.Context(), args[0])
+			if err != nil {
+				return cliError(err)
+			}
+			changes := todoitem.TodoItem{Summary: item.Summary}
+			if countChanged(cmd, "summary", "priority", "due", "tag") == 0 {
+				s, err := editSummary(deref(item.Summary))
+				if err != nil {
+					return err
+				}
+				changes.Summary = &s
+			}
+			if summary != "" {
+				changes.Summary = &summary
+			}
+			if priority != "" {
+				p := todoitem.Priority(priority)
+				changes.Priority = &p
+			}
+			if due != "" {
+				t, err := parseDue(due)
+				if err != nil {
+					return err
+				}
+				changes.Due = t
+			}
+			if cmd.Flags().Changed("tag") {
+				changes.Tags = tags
+			}
+			updated, err := c.Update(cmd.Context(), changes, args[0])
+			if err != nil {
+				return cliError(err)
+			}
+			return opts.printItem(cmd.OutOrStdout(), updated)
+		},
+	}
+	cmd.Flags().StringVarP(&summary, "summary", "s", "", "new summary")
+	cmd.Flags().StringVarP(&priority, "priority", "p", "", "none, low, medium or high")
+	cmd.Flags().StringVar(&due, "due", "", "due date (2006-01-02) or RFC3339 timestamp")
+	cmd.Flags().StringSliceVarP(&tags, "tag", "t", nil, "replaces the tags, can be repeated")
+	return cmd
+}
+
+// countChanged is how many of the named flags were set.
+func countChanged(cmd *cobra.Command, names ...string) int {
+	n := 0
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			n++
+		}
+	}
+	return n
+}
+
+// editSummary opens the summary in an editor, returning the first line of what was saved.
+func editSummary(summary string) (string, error) {
+	f, err := os.CreateTemp("", "todo-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(summary + "\n"); err != nil {
+		f.Close()
+		return "", err
+	}
+	f.Close()
+	if err := editor(f.Name()).Run(); err != nil {
+		return "", fmt.Errorf("running editor: %w", err)
+	}
+	f, err = os.Open(f.Name())
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	edited := strings.TrimSpace(scanner.Text())
+	if edited == "" {
+		return "", fmt.Errorf("summary can't be empty, nothing changed")
+	}
+	return edited, nil
+}
+
+func rmCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm <id>...",
+		Short: "deletes todo items",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := opts.client()
+			items := []todoitem.TodoItem{}
+			for _, id := range args {
+				deleted, err := c.Delete(cmd.Context(), id)
+				if err != nil {
+					return cliError(err)
+				}
+				items = append(items, deleted)
+			}
+			return opts.printItems(cmd.OutOrStdout(), items)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/token"
+)
+
+// tokenCmd manages access tokens. It has to be logged in some other way, with TODO_EMAIL and TODO_PASSWORD or an
+// admin TODO_TOKEN.
+func tokenCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "manages access tokens for scripts and CI jobs",
+	}
+	cmd.AddCommand(tokenCreateCmd(opts), tokenLsCmd(opts), tokenRevokeCmd(opts))
+	return cmd
+}
+
+func tokenCreateCmd(opts *options) *cobra.Command {
+	var scope, expires string
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "creates an access token",
+		Long: `creates an access token, printing its secret. It can't be shown again, so keep it somewhere safe. With -o plain
+only the secret is printed, i.e: export TODO_TOKEN=$(todo token create ci --scope write -o plain)`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := strings.Join(args, " ")
+			t := token.Token{Name: &name, Scope: &scope}
+			if expires != "" {
+				at, err := parseExpires(expires)
+				if err != nil {
+					return err
+				}
+				t.Expires = at
+			}
+			created, err := opts.client().CreateToken(cmd.Context(), t)
+			if err != nil {
+				return cliError(err)
+			}
+			w := cmd.OutOrStdout()
+			switch opts.output {
+			case "json":
+				return writeJSON(w, created)
+			case "plain":
+				fmt.Fprintln(w, *created.Token)
+				return nil
+			}
+			if err := opts.printTokens(w, []token.Token{created}); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\n%s\n\nthis is the only time the token is shown\n", *created.Token)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&scope, "scope", auth.ScopeRead, "what the token can do: read, write or admin")
+	cmd.Flags().StringVar(&expires, "expires", "", "when the token stops working, a duration (720h) or date (2006-01-02). never if empty")
+	return cmd
+}
+
+func tokenLsCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "lists your access tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := opts.client().Tokens(cmd.Context())
+			if err != nil {
+				return cliError(err)
+			}
+			return opts.printTokens(cmd.OutOrStdout(), tokens)
+		},
+	}
+}
+
+func tokenRevokeCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>...",
+		Short: "revokes access tokens, they stop working straight away",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var revoked []token.Token
+			for _, id := range args {
+				t, err := opts.client().RevokeToken(cmd.Context(), id)
+				if err != nil {
+					return cliError(err)
+				}
+				revoked = append(revoked, t)
+			}
+			return opts.printTokens(cmd.OutOrStdout(), revoked)
+		},
+	}
+}
+
+// printTokens writes tokens in the chosen output format, never with their secrets.
+func (o *options) printTokens(w io.Writer, tokens []token.Token) error {
+	day := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Local().Format("2006-01-02")
+	}
+	switch o.output {
+	case "json":
+		return writeJSON(w, tokens)
+	case "plain":
+		for _, t := range tokens {
+			fmt.Fprintf(w, "%s %s\n", deref(t.Id), deref(t.Name))
+		}
+		return nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSCOPE\tEXPIRES\tLAST USED\tREVOKED\tNAME")
+		for _, t := range tokens {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", deref(t.Id), deref(t.Scope), day(t.Expires), day(t.LastUsed),
+				day(t.Revoked), deref(t.Name))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %s, use table, json or plain", o.output)
+}
+
+// parseExpires takes a duration from now, or the same dates and timestamps as --due.
+func parseExpires(v string) (*time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		t := time.Now().Add(d)
+		return &t, nil
+	}
+	t, err := parseDue(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expires %s, use a duration (720h), date (2006-01-02) or RFC3339 timestamp", v)
+	}
+	return t, nil
+}
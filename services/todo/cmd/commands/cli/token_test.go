@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/rest"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// newAuthServer is newServer with accounts and access tokens, requiring one or the other.
+func newAuthServer(t *testing.T) string {
+	users := user.NewCore(memdb.NewUserStore())
+	if _, err := users.Register(context.Background(), user.NewUser{Email: "sam@example.com", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	tokens := token.NewCore(memdb.NewTokenStore())
+	srv := rest.NewServer("", "")
+	srv.Router.Use(rest.Authenticate(auth.Schemes{"basic": users, "bearer": tokens.Bearer(nil)}, true))
+	tkh := rest.NewTokenHandlers(tokens)
+	tkh.RegisterTokenEndpoints(srv.Router, "/api")
+	tdh := rest.NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(srv.Router, "/api")
+	ts := httptest.NewServer(srv.Router)
+	t.Cleanup(ts.Close)
+	return ts.URL + "/api"
+}
+
+func TestTokenCommands(t *testing.T) {
+	server := newAuthServer(t)
+	t.Setenv("TODO_TOKEN", "")
+	t.Setenv("TODO_EMAIL", "")
+	_, err := run(t, server, "ls")
+	assert.EqualError(t, err, "unauthorized: authentication required")
+
+	t.Setenv("TODO_EMAIL", "sam@example.com")
+	t.Setenv("TODO_PASSWORD", "correct horse")
+	secret, err := run(t, server, "token", "create", "nightly", "export", "--scope", "admin", "--expires", "720h", "-o", "plain")
+	assert.Nil(t, err)
+	secret = strings.TrimSpace(secret)
+	assert.True(t, strings.HasPrefix(secret, token.Prefix))
+
+	//the token takes over from the password
+	t.Setenv("TODO_PASSWORD", "wrong")
+	t.Setenv("TODO_TOKEN", secret)
+	_, err = run(t, server, "add", "buy milk")
+	assert.Nil(t, err)
+	var tokens []token.Token
+	runJSON(t, server, &tokens, "token", "ls")
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "nightly export", *tokens[0].Name)
+		assert.NotNil(t, tokens[0].Expires)
+		assert.Nil(t, tokens[0].Token)
+	}
+
+	out, err := run(t, server, "token", "revoke", *tokens[0].Id)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "nightly export")
+	_, err = run(t, server, "ls")
+	assert.EqualError(t, err, "unauthorized: invalid bearer token: access token was revoked")
+
+	_, err = run(t, server, "token", "create", "ci", "--expires", "someday")
+	assert.EqualError(t, err, "invalid expires someday, use a duration (720h), date (2006-01-02) or RFC3339 timestamp")
+}
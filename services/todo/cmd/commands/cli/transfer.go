@@ -0,0 +1,322 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/bulk"
+	"github.com/stumacwastaken/todo/client"
+	"github.com/stumacwastaken/todo/stores/database"
+	"github.com/stumacwastaken/todo/stores/tododb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/todotxt"
+)
+
+func exportCmd(opts *options) *cobra.Command {
+	var format, query string
+	db := &dbOptions{}
+	cmd := &cobra.Command{
+		Use:   "export --format todotxt|csv|ndjson [file]",
+		Short: "exports todo items",
+		Long: `exports todo items to a file, or stdout if none is given. todotxt goes through the server and leaves out deleted
+items. csv and ndjson read every item, deleted ones included, with ids and timestamps straight from the database,
+for moving data between environments.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "todotxt" {
+				return exportTodoTxt(cmd, opts, query, args)
+			}
+			if query != "" {
+				return fmt.Errorf("--query only works with todotxt, %s exports everything", format)
+			}
+			if !isBulk(format) {
+				return unknownFormat(format)
+			}
+			store, closer, err := openStore(db.config)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			ctx := db.context(cmd.Context())
+			if len(args) == 0 {
+				_, err := bulk.Export(ctx, store, cmd.OutOrStdout(), format)
+				return err
+			}
+			return writeAtomic(args[0], func(w io.Writer) error {
+				_, err := bulk.Export(ctx, store, w, format)
+				return err
+			})
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "file format. use todotxt, csv or ndjson")
+	cmd.Flags().StringVarP(&query, "query", "q", "", "only export items matching this filter expression, todotxt only")
+	cmd.MarkFlagRequired("format")
+	db.register(cmd)
+	return cmd
+}
+
+func exportTodoTxt(cmd *cobra.Command, opts *options, query string, args []string) error {
+	items, err := opts.client().List(cmd.Context(), query).All()
+	if err != nil {
+		return cliError(err)
+	}
+	lines := []string{}
+	for _, item := range items {
+		lines = append(lines, todotxt.Format(item))
+	}
+	if len(args) == 0 {
+		return writeLines(cmd.OutOrStdout(), lines)
+	}
+	return writeFile(args[0], lines)
+}
+
+func importCmd(opts *options) *cobra.Command {
+	var format string
+	var bulkOpts bulk.Options
+	db := &dbOptions{}
+	cmd := &cobra.Command{
+		Use:   "import --format todotxt|csv|ndjson [file]",
+		Short: "imports todo items",
+		Long: `imports todo items from a file, or stdin if none is given.
+
+todotxt goes through the server. Every line becomes a new item, ids in the file are ignored and the server sets its
+own creation and completion times. Nothing is imported if a line can't be read.
+
+csv and ndjson write straight to the database, keeping ids and timestamps. Items that already exist are skipped
+unless --upsert is given, --dry-run reports what would happen without writing anything. Import stops at the first
+record it can't read, so dry run a file first.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "todotxt" && !isBulk(format) {
+				return unknownFormat(format)
+			}
+			if format == "todotxt" && (bulkOpts.Upsert || bulkOpts.DryRun) {
+				return fmt.Errorf("--upsert and --dry-run only work with csv and ndjson")
+			}
+			in := cmd.InOrStdin()
+			if len(args) == 1 {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			if format == "todotxt" {
+				return importTodoTxt(cmd, opts, in)
+			}
+			store, closer, err := openStore(db.config)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			report, err := bulk.Import(db.context(cmd.Context()), store, in, format, bulkOpts)
+			fmt.Fprintln(cmd.OutOrStdout(), report)
+			return cliError(err)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "file format. use todotxt, csv or ndjson")
+	cmd.Flags().BoolVar(&bulkOpts.Upsert, "upsert", false, "replace items that already exist, csv and ndjson only")
+	cmd.Flags().BoolVar(&bulkOpts.DryRun, "dry-run", false, "report what would be imported without writing anything, csv and ndjson only")
+	cmd.MarkFlagRequired("format")
+	db.register(cmd)
+	return cmd
+}
+
+func importTodoTxt(cmd *cobra.Command, opts *options, in io.Reader) error {
+	lines, err := todotxt.ReadLines(in)
+	if err != nil {
+		return err
+	}
+	items, err := todotxt.ParseAll(lines)
+	if err != nil {
+		return err
+	}
+	c := opts.client()
+	for i, item := range items {
+		created, err := c.Create(cmd.Context(), todoitem.TodoItem{Summary: item.Summary, Priority: item.Priority,
+			Due: item.Due, Tags: item.Tags})
+		//completion can't be set on create
+		if err == nil && item.Completed != nil && *item.Completed {
+			_, err = c.Update(cmd.Context(), todoitem.TodoItem{Summary: created.Summary, Completed: item.Completed}, *created.Id)
+		}
+		if err != nil {
+			return fmt.Errorf("imported %d of %d: %w", i, len(items), cliError(err))
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "imported %d items\n", len(items))
+	return nil
+}
+
+// dbOptions are the flags for commands that work on the database directly rather than through the server.
+type dbOptions struct {
+	config    database.Config
+	workspace string
+}
+
+func (o *dbOptions) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.config.Host, "dbhost", "localhost:3306", "mysql host and port, csv and ndjson only")
+	cmd.Flags().StringVar(&o.config.User, "dbuser", "", "mysql user, csv and ndjson only")
+	cmd.Flags().StringVar(&o.config.Password, "dbpass", "", "mysql password, csv and ndjson only")
+	cmd.Flags().StringVar(&o.config.Name, "dbname", "todo", "database name, csv and ndjson only")
+	cmd.Flags().StringVar(&o.workspace, "workspace", auth.DefaultWorkspace, "workspace to read or write, csv and ndjson only")
+}
+
+// context puts ctx in the chosen workspace, the database only hands out and takes items in that one.
+func (o *dbOptions) context(ctx context.Context) context.Context {
+	return auth.WithWorkspace(ctx, o.workspace)
+}
+
+// openStore connects to the database for csv and ndjson, pulled out so tests can swap it.
+var openStore = func(cfg database.Config) (todoitem.Storer, io.Closer, error) {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return tododb.NewStore(db), db, nil
+}
+
+func isBulk(format string) bool {
+	for _, f := range bulk.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown format %s, use todotxt, %s", format, strings.Join(bulk.Formats, " or "))
+}
+
+func syncCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync todotxt <file>",
+		Short: "syncs a todo.txt file with the server",
+		Long: `syncs a todo.txt file with the server in both directions. New lines are created on the server, new items
+are added to the file, changes on either side are copied to the other and removed lines are deleted. If an item
+changed on both sides since the last sync the server's version wins. What the last sync saw is kept in <file>.sync,
+the first sync (or one without it) treats everything as new.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] != "todotxt" {
+				return fmt.Errorf("unknown format %s, only todotxt can be synced", args[0])
+			}
+			path := args[1]
+			lines, err := readFileLines(path)
+			if err != nil {
+				return err
+			}
+			state, err := readState(path + ".sync")
+			if err != nil {
+				return err
+			}
+			lines, state, report, err := todotxt.Sync(cmd.Context(), syncRemote{opts.client()}, lines, state)
+			if err != nil {
+				return cliError(err)
+			}
+			if err := writeFile(path, lines); err != nil {
+				return err
+			}
+			if err := writeState(path+".sync", state); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), report)
+			return nil
+		},
+	}
+	return cmd
+}
+
+// syncRemote is the server side of a todo.txt sync.
+type syncRemote struct {
+	*client.Client
+}
+
+func (r syncRemote) All(ctx context.Context) ([]todoitem.TodoItem, error) {
+	return r.Client.List(ctx, "").All()
+}
+
+// readFileLines reads a file's lines. A file that isn't there yet is empty.
+func readFileLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return todotxt.ReadLines(f)
+}
+
+func readState(path string) (todotxt.State, error) {
+	var state todotxt.State
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, fmt.Errorf("reading %s, delete it to start over: %w", path, err)
+	}
+	return state, nil
+}
+
+func writeState(path string, state todotxt.State) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+func writeLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, lines []string) error {
+	return writeAtomic(path, func(w io.Writer) error {
+		return writeLines(w, lines)
+	})
+}
+
+// writeAtomic writes to a temporary file and moves it into place, so a failure half way doesn't leave half a file.
+func writeAtomic(path string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
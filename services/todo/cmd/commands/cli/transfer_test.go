@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/stores/database"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func TestImportExport(t *testing.T) {
+	server := newServer(t)
+	in := filepath.Join(t.TempDir(), "todo.txt")
+	os.WriteFile(in, []byte("(A) call mom +family @phone due:2026-10-20\n\nx file taxes id:ignored\n"), 0600)
+	out, err := run(t, server, "import", "--format", "todotxt", in)
+	assert.Nil(t, err)
+	assert.Equal(t, "imported 2 items\n", out)
+
+	var items []todoitem.TodoItem
+	runJSON(t, server, &items, "ls", "--completed")
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "file taxes", *items[0].Summary)
+		assert.NotEqual(t, "ignored", *items[0].Id)
+	}
+
+	out, err = run(t, server, "export", "--format", "todotxt", "-q", "tag:family")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if assert.Len(t, lines, 1) {
+		assert.Regexp(t, `^\(A\) \d{4}-\d\d-\d\d call mom \+family @phone due:2026-10-20 id:\S+$`, lines[0])
+	}
+
+	export := filepath.Join(t.TempDir(), "export.txt")
+	_, err = run(t, server, "export", "--format", "todotxt", export)
+	assert.Nil(t, err)
+	b, _ := os.ReadFile(export)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 2)
+}
+
+func TestSync(t *testing.T) {
+	server := newServer(t)
+	run(t, server, "add", "buy milk")
+	file := filepath.Join(t.TempDir(), "todo.txt")
+	os.WriteFile(file, []byte("walk dog\n"), 0600)
+
+	out, err := run(t, server, "sync", "todotxt", file)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "created 1, added 1")
+	b, _ := os.ReadFile(file)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 2)
+	_, err = os.Stat(file + ".sync")
+	assert.Nil(t, err, "state is kept next to the file")
+
+	//drop buy milk from the file
+	os.WriteFile(file, []byte(strings.Split(string(b), "\n")[0]+"\n"), 0600)
+	out, err = run(t, server, "sync", "todotxt", file)
+	assert.Nil(t, err)
+	assert.Contains(t, out, "deleted 1")
+	var items []todoitem.TodoItem
+	runJSON(t, server, &items, "ls")
+	assert.Len(t, items, 1)
+}
+
+func TestTransferErrors(t *testing.T) {
+	server := newServer(t)
+	bad := filepath.Join(t.TempDir(), "todo.txt")
+	os.WriteFile(bad, []byte("call mom\n(A) +family\n"), 0600)
+	type test struct {
+		name string
+		args []string
+		err  string
+	}
+	tests := []test{
+		{name: "unknown format", args: []string{"export", "--format", "xml"}, err: "unknown format xml, use todotxt, csv or ndjson"},
+		{name: "query with csv", args: []string{"export", "--format", "csv", "-q", "tag:home"}, err: "--query only works with todotxt"},
+		{name: "upsert with todotxt", args: []string{"import", "--format", "todotxt", "--upsert", bad}, err: "only work with csv"},
+		{name: "no format", args: []string{"import"}, err: "format"},
+		{name: "bad line", args: []string{"import", "--format", "todotxt", bad}, err: "line 2: no description"},
+		{name: "missing file", args: []string{"import", "--format", "todotxt", bad + ".nope"}, err: "no such file"},
+		{name: "sync format", args: []string{"sync", "csv", bad}, err: "only todotxt can be synced"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, err := run(t, server, tt.args...)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+	var items []todoitem.TodoItem
+	runJSON(t, server, &items, "ls")
+	assert.Len(t, items, 0, "nothing imported from a bad file")
+}
+
+// useStore points the csv and ndjson commands at an in memory store rather than mysql.
+func useStore(t *testing.T, store todoitem.Storer) {
+	prev := openStore
+	t.Cleanup(func() { openStore = prev })
+	openStore = func(database.Config) (todoitem.Storer, io.Closer, error) {
+		return store, io.NopCloser(nil), nil
+	}
+}
+
+func TestBulk(t *testing.T) {
+	from := memdb.NewStore()
+	core := todoitem.NewCore(from)
+	summaries := []string{"buy milk", "walk dog"}
+	milk, _ := core.Create(context.Background(), todoitem.TodoItem{Summary: &summaries[0]})
+	dog, _ := core.Create(context.Background(), todoitem.TodoItem{Summary: &summaries[1]})
+	core.Delete(context.Background(), *dog.Id)
+	useStore(t, from)
+	file := filepath.Join(t.TempDir(), "todo.csv")
+	_, err := run(t, "", "export", "--format", "csv", file)
+	assert.Nil(t, err)
+
+	to := memdb.NewStore()
+	useStore(t, to)
+	out, err := run(t, "", "import", "--format", "csv", "--dry-run", file)
+	assert.Nil(t, err)
+	assert.Equal(t, "dry run, nothing was written. Would have created 2, updated 0, unchanged 0, skipped 0\n", out)
+	out, err = run(t, "", "import", "--format", "csv", file)
+	assert.Nil(t, err)
+	assert.Equal(t, "created 2, updated 0, unchanged 0, skipped 0\n", out)
+	item, err := to.GetById(context.Background(), *dog.Id)
+	assert.Nil(t, err)
+	assert.True(t, *item.Deleted, "deleted items come too")
+	item, _ = to.GetById(context.Background(), *milk.Id)
+	assert.True(t, milk.Created.Equal(*item.Created), "timestamps are kept")
+
+	out, err = run(t, "", "export", "--format", "ndjson")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(out, "\n"))
+
+	ops := memdb.NewStore()
+	useStore(t, ops)
+	_, err = run(t, "", "import", "--format", "csv", "--workspace", "ops", file)
+	assert.Nil(t, err)
+	_, err = ops.GetById(context.Background(), *milk.Id)
+	assert.NotNil(t, err, "items go into the workspace they're imported to")
+	_, err = ops.GetById(auth.WithWorkspace(context.Background(), "ops"), *milk.Id)
+	assert.Nil(t, err)
+	out, err = run(t, "", "export", "--format", "ndjson")
+	assert.Nil(t, err)
+	assert.Equal(t, "", out, "the default workspace is empty")
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/stores/database"
+	"github.com/stumacwastaken/todo/stores/userdb"
+	"github.com/stumacwastaken/todo/stores/workspacedb"
+	"github.com/stumacwastaken/todo/user"
+	"github.com/stumacwastaken/todo/workspace"
+)
+
+// workspaceCmd manages workspaces. There's no api for it, workspaces are set up by whoever runs the server so it
+// works on the database directly.
+func workspaceCmd(opts *options) *cobra.Command {
+	db := &dbOptions{}
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "manages workspaces, the separate groups of users sharing a server",
+	}
+	cmd.PersistentFlags().StringVar(&db.config.Host, "dbhost", "localhost:3306", "mysql host and port")
+	cmd.PersistentFlags().StringVar(&db.config.User, "dbuser", "", "mysql user")
+	cmd.PersistentFlags().StringVar(&db.config.Password, "dbpass", "", "mysql password")
+	cmd.PersistentFlags().StringVar(&db.config.Name, "dbname", "todo", "database name")
+	cmd.PersistentFlags().StringVarP(&opts.output, "output", "o", "table", "output format. use table, json or plain")
+	cmd.AddCommand(workspaceCreateCmd(opts, db), workspaceLsCmd(opts, db), workspaceAddUserCmd(opts, db))
+	return cmd
+}
+
+func workspaceCreateCmd(opts *options, db *dbOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "create <name>",
+		Short: "creates a workspace, printing its id",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workspaces, _, closer, err := openWorkspaces(db.config)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			name := strings.Join(args, " ")
+			created, err := workspace.NewCore(workspaces).Create(cmd.Context(), workspace.Workspace{Name: &name})
+			if err != nil {
+				return cliError(err)
+			}
+			return opts.printWorkspaces(cmd.OutOrStdout(), []workspace.Workspace{created})
+		},
+	}
+}
+
+func workspaceLsCmd(opts *options, db *dbOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: "lists workspaces",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workspaces, _, closer, err := openWorkspaces(db.config)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			all, err := workspace.NewCore(workspaces).GetAll(cmd.Context())
+			if err != nil {
+				return cliError(err)
+			}
+			return opts.printWorkspaces(cmd.OutOrStdout(), all)
+		},
+	}
+}
+
+func workspaceAddUserCmd(opts *options, db *dbOptions) *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "adduser <workspace id> <email>",
+		Short: "registers a user in a workspace, with the password in TODO_PASSWORD",
+		Long: `registers a user in a workspace. Users can only see and share with others in their own workspace, and can't be
+moved once registered. The password is read from TODO_PASSWORD so it doesn't end up in shell history.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workspaces, users, closer, err := openWorkspaces(db.config)
+			if err != nil {
+				return err
+			}
+			defer closer.Close()
+			ws, err := workspace.NewCore(workspaces).GetById(cmd.Context(), args[0])
+			if err != nil {
+				return cliError(err)
+			}
+			ctx := auth.WithWorkspace(cmd.Context(), *ws.Id)
+			u, err := user.NewCore(users).Register(ctx, user.NewUser{Email: args[1], Name: name, Password: os.Getenv("TODO_PASSWORD")})
+			if err != nil {
+				return cliError(err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "registered %s in %s\n", *u.Email, *ws.Name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "the user's display name")
+	return cmd
+}
+
+// openWorkspaces connects to the database for the workspace commands, pulled out so tests can swap it.
+var openWorkspaces = func(cfg database.Config) (workspace.Storer, user.Storer, io.Closer, error) {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, nil, err
+	}
+	return workspacedb.NewStore(db), userdb.NewStore(db), db, nil
+}
+
+// printWorkspaces writes workspaces in the chosen output format.
+func (o *options) printWorkspaces(w io.Writer, workspaces []workspace.Workspace) error {
+	switch o.output {
+	case "json":
+		return writeJSON(w, workspaces)
+	case "plain":
+		for _, ws := range workspaces {
+			fmt.Fprintf(w, "%s %s\n", deref(ws.Id), deref(ws.Name))
+		}
+		return nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tCREATED\tNAME")
+		for _, ws := range workspaces {
+			created := ""
+			if ws.Created != nil {
+				created = ws.Created.Local().Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", deref(ws.Id), created, deref(ws.Name))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown output format %s, use table, json or plain", o.output)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/stores/database"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/user"
+	"github.com/stumacwastaken/todo/workspace"
+)
+
+// workspaces is an in memory workspace.Storer, ids are the names.
+type workspaces map[string]workspace.Workspace
+
+func (s workspaces) Create(ctx context.Context, w workspace.Workspace) (workspace.Workspace, error) {
+	w.Id = w.Name
+	s[*w.Id] = w
+	return w, nil
+}
+
+func (s workspaces) GetById(ctx context.Context, id string) (workspace.Workspace, error) {
+	w, ok := s[id]
+	if !ok {
+		return workspace.Workspace{}, terr.ErrorWithCode("not found", "Workspace with id "+id+" not found", 404)
+	}
+	return w, nil
+}
+
+func (s workspaces) GetAll(ctx context.Context) ([]workspace.Workspace, error) {
+	all := []workspace.Workspace{}
+	for _, w := range s {
+		all = append(all, w)
+	}
+	return all, nil
+}
+
+// runLocal runs a command that works on the database rather than the server.
+func runLocal(args ...string) (string, error) {
+	root := &cobra.Command{Use: "todo", SilenceErrors: true, SilenceUsage: true}
+	root.AddCommand(Commands()...)
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetArgs(args)
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestWorkspace(t *testing.T) {
+	ws, users := workspaces{}, memdb.NewUserStore()
+	prev := openWorkspaces
+	t.Cleanup(func() { openWorkspaces = prev })
+	openWorkspaces = func(database.Config) (workspace.Storer, user.Storer, io.Closer, error) {
+		return ws, users, io.NopCloser(nil), nil
+	}
+
+	out, err := runLocal("workspace", "create", "ops", "-o", "plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "ops ops\n", out)
+	out, err = runLocal("workspace", "ls", "-o", "plain")
+	assert.Nil(t, err)
+	assert.Equal(t, "ops ops\n", out)
+
+	t.Setenv("TODO_PASSWORD", "correct horse")
+	out, err = runLocal("workspace", "adduser", "ops", "kim@example.com", "--name", "Kim")
+	assert.Nil(t, err)
+	assert.Equal(t, "registered kim@example.com in ops\n", out)
+	kim, err := users.GetByEmail(context.Background(), "kim@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "ops", *kim.WorkspaceId)
+
+	_, err = runLocal("workspace", "adduser", "sales", "jo@example.com")
+	assert.EqualError(t, err, "not found: Workspace with id sales not found")
+}
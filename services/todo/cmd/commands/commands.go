@@ -2,8 +2,10 @@ package commands
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/cmd/commands/cli"
 	"github.com/stumacwastaken/todo/cmd/commands/rest"
 	"github.com/stumacwastaken/todo/cmd/commands/seed"
+	"github.com/stumacwastaken/todo/cmd/commands/tui"
 )
 
 var (
@@ -23,5 +25,7 @@ func Execute() error {
 func init() {
 	rootCmd.AddCommand(seed.Cmd)
 	rootCmd.AddCommand(rest.Cmd)
+	rootCmd.AddCommand(cli.Commands()...)
+	rootCmd.AddCommand(tui.Cmd)
 
 }
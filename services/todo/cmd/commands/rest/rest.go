@@ -2,14 +2,35 @@ package rest
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/gql"
 	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/oidc"
+	"github.com/stumacwastaken/todo/outbox"
 	"github.com/stumacwastaken/todo/rest"
+	"github.com/stumacwastaken/todo/rpc"
+	"github.com/stumacwastaken/todo/smartlist"
 	"github.com/stumacwastaken/todo/stores/database"
+	"github.com/stumacwastaken/todo/stores/memberdb"
+	"github.com/stumacwastaken/todo/stores/outboxdb"
+	"github.com/stumacwastaken/todo/stores/smartlistdb"
 	"github.com/stumacwastaken/todo/stores/tododb"
+	"github.com/stumacwastaken/todo/stores/tokendb"
+	"github.com/stumacwastaken/todo/stores/userdb"
+	"github.com/stumacwastaken/todo/stores/webhookdb"
 	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/token"
 	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/user"
+	"github.com/stumacwastaken/todo/webhook"
 	"go.uber.org/zap"
 )
 
@@ -24,16 +45,76 @@ var (
 	Port     string
 	LogLevel string
 	DBConfig database.Config
+	//GrpcPort serves the grpc api alongside rest when set
+	GrpcPort string
+	//OutboxSinks are where todo item events are relayed to from the outbox. log, webhook or file
+	OutboxSinks []string
+	OutboxFile  string
+	//RequireAuth turns away requests without credentials, rather than serving them unscoped
+	RequireAuth bool
+	//JWT is what bearer tokens are checked against. They aren't accepted unless a secret or jwks is set
+	JWT auth.JWTConfig
+	//OIDC logs people in through an identity provider, when an issuer is set
+	OIDC oidc.Config
+	//TicketSecret signs the tickets browsers open the change feed with. Replicas need the same one
+	TicketSecret string
+	//OIDCCookieSecret signs the cookie kept during an oidc login. Replicas need the same one
+	OIDCCookieSecret string
+	//OIDCSessionTTL is how long the access token handed out after an oidc login lasts
+	OIDCSessionTTL time.Duration
+	//OIDCSessionScope is the scope of that access token
+	OIDCSessionScope string
+	//OIDCUIURL is where the browser goes once it's logged in through oidc, with the access token in the fragment
+	OIDCUIURL string
+	//PasswordLogin is registering and logging in with an email and password. It can be turned off to only use oidc
+	PasswordLogin bool
+	//WebhookAllowPrivate lets webhooks be sent to private, loopback and link-local addresses
+	WebhookAllowPrivate bool
+	//LiveOrigins are the other sites whose pages can open the live editing websocket
+	LiveOrigins []string
+	//RateLimits are the requests a minute each user can make to groups of paths, as /path=rate[/burst]
+	RateLimits []string
+	//AddressRateLimits are the same for each address, which everyone behind a NAT or proxy shares
+	AddressRateLimits []string
 )
 
+// addressRateMultiple is how many users' worth of requests an address gets when its limits aren't given.
+const addressRateMultiple = 10
+
 func init() {
 	Cmd.PersistentFlags().StringVar(&Address, "addr", "0.0.0.0", "address for the rest server to listen on")
 	Cmd.PersistentFlags().StringVar(&Port, "port", "9000", "port for the server to listen on")
+	Cmd.PersistentFlags().StringVar(&GrpcPort, "grpc-port", "", "port to serve the grpc api on as well, left off if empty")
 	Cmd.PersistentFlags().StringVar(&LogLevel, "log-level", "info", "log level of the application. use error, warn, info, debug")
 	Cmd.PersistentFlags().StringVar(&DBConfig.Host, "dbhost", "localhost:3306", "mysql host and port")
 	Cmd.PersistentFlags().StringVar(&DBConfig.User, "dbuser", "", "mysql user")
 	Cmd.PersistentFlags().StringVar(&DBConfig.Password, "dbpass", "", "mysql password")
 	Cmd.PersistentFlags().StringVar(&DBConfig.Name, "dbname", "todo", "database name")
+	Cmd.PersistentFlags().StringSliceVar(&OutboxSinks, "outbox-sinks", []string{"webhook"}, "where todo item events are relayed to. any of log, webhook, file")
+	Cmd.PersistentFlags().StringVar(&OutboxFile, "outbox-file", "", "file the file outbox sink appends events to as ndjson")
+	Cmd.PersistentFlags().BoolVar(&RequireAuth, "require-auth", true, "require every request to be made by a user. --require-auth=false serves requests with no credentials unscoped, so they see and change everything")
+	Cmd.PersistentFlags().StringVar(&JWT.Secret, "jwt-secret", "", "secret to check HS256 bearer tokens with")
+	Cmd.PersistentFlags().StringVar(&JWT.JWKS, "jwt-jwks", "", "file or url of the JWK set to check RS256 bearer tokens with")
+	Cmd.PersistentFlags().StringVar(&JWT.Issuer, "jwt-issuer", "", "iss bearer tokens must have, if set")
+	Cmd.PersistentFlags().StringVar(&JWT.Audience, "jwt-audience", "", "aud bearer tokens must have, if set")
+	Cmd.PersistentFlags().DurationVar(&JWT.Leeway, "jwt-leeway", time.Minute, "how far off token times can be for clock skew")
+	Cmd.PersistentFlags().StringVar(&OIDC.Issuer, "oidc-issuer", "", "issuer url of the oidc identity provider to log in with, off if empty")
+	Cmd.PersistentFlags().StringVar(&OIDC.ClientId, "oidc-client-id", "", "client id registered with the identity provider")
+	Cmd.PersistentFlags().StringVar(&OIDC.ClientSecret, "oidc-client-secret", "", "client secret registered with the identity provider, if it's a confidential client")
+	Cmd.PersistentFlags().StringVar(&OIDC.RedirectURL, "oidc-redirect-url", "", "url of /api/oidc/callback as the browser sees it, registered with the identity provider")
+	Cmd.PersistentFlags().StringSliceVar(&OIDC.Scopes, "oidc-scopes", []string{"openid", "email", "profile"}, "scopes to ask the identity provider for")
+	Cmd.PersistentFlags().StringVar(&OIDC.EmailClaim, "oidc-email-claim", "email", "id token claim with the user's email")
+	Cmd.PersistentFlags().StringVar(&OIDC.NameClaim, "oidc-name-claim", "name", "id token claim with the user's name")
+	Cmd.PersistentFlags().StringVar(&TicketSecret, "ticket-secret", "", "secret to sign the tickets browsers open the change feed with. random if empty, which only works with one replica")
+	Cmd.PersistentFlags().StringVar(&OIDCCookieSecret, "oidc-cookie-secret", "", "secret to sign the oidc login cookie with. random if empty, which only works with one replica")
+	Cmd.PersistentFlags().DurationVar(&OIDCSessionTTL, "oidc-session-ttl", 24*time.Hour, "how long the access token from an oidc login lasts")
+	Cmd.PersistentFlags().StringVar(&OIDCSessionScope, "oidc-session-scope", auth.ScopeWrite, "scope of the access token from an oidc login. read, write or admin")
+	Cmd.PersistentFlags().StringVar(&OIDCUIURL, "oidc-ui-url", "", "url of the ui to send the browser back to after an oidc login, with the access token in the fragment. the callback answers with json if empty")
+	Cmd.PersistentFlags().BoolVar(&PasswordLogin, "password-login", true, "allow registering and logging in with an email and password")
+	Cmd.PersistentFlags().BoolVar(&WebhookAllowPrivate, "webhook-allow-private", false, "allow webhooks to private, loopback and link-local addresses. only for receivers on a network you trust every admin token holder with")
+	Cmd.PersistentFlags().StringSliceVar(&LiveOrigins, "live-origins", nil, "other sites, as scheme://host[:port], whose pages can open the live editing websocket. the server's own always can")
+	Cmd.PersistentFlags().StringSliceVar(&RateLimits, "rate-limit", nil, "requests a minute each signed in user can make under a path, as /path=rate or /path=rate/burst i.e: /api=600,/api/todo=120/20. off if empty")
+	Cmd.PersistentFlags().StringSliceVar(&AddressRateLimits, "address-rate-limit", nil, fmt.Sprintf("requests a minute each address can make under a path, before its credentials are checked, in the same form as --rate-limit. %d times --rate-limit if empty", addressRateMultiple))
 }
 
 func server(cmd *cobra.Command, args []string) {
@@ -54,21 +135,199 @@ func server(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Default().Panic("failed to ping database.....", zap.Error(err))
 	}
-	srv := rest.NewServer(Address, Port)
+	limits, addressLimits := parseRateLimits(RateLimits), parseRateLimits(AddressRateLimits)
+	if len(AddressRateLimits) == 0 {
+		for _, l := range limits {
+			addressLimits = append(addressLimits, l.Times(addressRateMultiple))
+		}
+	}
+	srv := rest.NewServer(Address, Port, addressLimits...)
+	//middleware has to be in place before any routes are
+	userCore := user.NewCore(userdb.NewStore(db))
+	tokenCore := token.NewCore(tokendb.NewStore(db))
+	//access tokens are bearer tokens too, anything else that's one is a jwt if they're set up
+	var jwt auth.Authenticator
+	if JWT.Secret != "" || JWT.JWKS != "" {
+		bearer, err := auth.NewJWT(context.Background(), JWT)
+		if err != nil {
+			log.Default().Panic("failed to set up bearer tokens", zap.Error(err))
+		}
+		jwt = bearer
+	}
+	//browsers can't send headers when they open the change feed, so they get a short lived ticket to put in the url
+	tickets, err := rest.NewTickets(TicketSecret)
+	if err != nil {
+		log.Default().Panic("failed to set up tickets", zap.Error(err))
+	}
+	authn := auth.Schemes{"bearer": tokenCore.Bearer(jwt), "ticket": tickets}
+	//the calendar feed takes an access token in its url, since calendar apps can't send headers
+	public := append([]string{"/api/openapi.json"}, rest.CalendarPublicPaths("/api")...)
+	if PasswordLogin {
+		authn["basic"] = userCore
+		public = append(public, rest.UserPublicPaths("/api")...)
+	}
+	var oidch *rest.OIDCHandlers
+	if OIDC.Issuer != "" {
+		provider, err := oidc.NewProvider(context.Background(), OIDC)
+		if err != nil {
+			log.Default().Panic("failed to set up oidc", zap.Error(err))
+		}
+		h, err := rest.NewOIDCHandlers(provider, userCore, tokenCore, OIDCSessionTTL, OIDCCookieSecret)
+		if err != nil {
+			log.Default().Panic("failed to set up oidc", zap.Error(err))
+		}
+		if OIDCSessionTTL <= 0 {
+			log.Default().Panic("failed to set up oidc, sessions have to expire", zap.Duration("oidc-session-ttl", OIDCSessionTTL))
+		}
+		if !auth.ValidScope(OIDCSessionScope) {
+			log.Default().Panic("failed to set up oidc", zap.String("oidc-session-scope", OIDCSessionScope))
+		}
+		if u, err := url.Parse(OIDCUIURL); OIDCUIURL != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			log.Default().Panic("failed to set up oidc, the ui url must be an absolute http or https url", zap.String("oidc-ui-url", OIDCUIURL))
+		}
+		h.SessionScope, h.UIURL = OIDCSessionScope, OIDCUIURL
+		oidch = &h
+		public = append(public, rest.OIDCPublicPaths("/api")...)
+	}
+	if !RequireAuth {
+		log.Default().Warn("running without --require-auth, requests with no credentials can see and change every user's items")
+	}
+	srv.Router.Use(rest.TicketQuery(rest.EventTicketPaths("/api")...))
+	srv.Router.Use(rest.Authenticate(authn, RequireAuth, public...))
+	if len(limits) > 0 {
+		srv.Router.Use(rest.LimitUsers(limits...))
+	}
 
-	tdh := rest.NewTodoHandlers(todoitem.NewCore(tododb.NewStore(db)))
+	//the hub backs the change feed. It's in process only, so each replica has its own feed.
+	hub := events.NewHub(1000)
+	srv.RegisterOnShutdown(hub.Close)
+	webhookStore := webhookdb.NewStore(db)
+	webhookCore := webhook.NewCore(webhookStore)
+	//the hub is told about changes straight away. Everything else goes through the outbox, which the store writes to
+	//in the same transaction as the change
+	todoCore := todoitem.NewCore(tododb.NewStore(db), hub)
+	memberCore := member.NewCore(memberdb.NewStore(db), userCore)
+	todoCore.ShareWith(memberCore)
+	webhookCore.ShareWith(memberCore)
+	tdh := rest.NewTodoHandlers(todoCore)
 	//give a default base path for this server of api for now. It's entirely possible we can do this in networking though with k8s
 	//basically, be ready to refactor and rip out
 	tdh.RegisterTodoEndpoints(srv.Router, "/api")
+	tdh.RegisterStatsEndpoints(srv.Router, "/api")
+	tdh.RegisterSyncEndpoints(srv.Router, "/api")
+	tdh.RegisterExportEndpoints(srv.Router, "/api")
+	evh := rest.NewEventHandlers(todoCore, hub)
+	evh.Tickets = tickets
+	evh.RegisterEventEndpoints(srv.Router, "/api")
+	smartListCore := smartlist.NewCore(smartlistdb.NewStore(db), todoCore)
+	slh := rest.NewSmartListHandlers(smartListCore)
+	slh.RegisterSmartListEndpoints(srv.Router, "/api")
+	lvh := rest.NewLiveHandlers(todoCore, smartListCore, hub)
+	lvh.Origins = LiveOrigins
+	lvh.RegisterLiveEndpoints(srv.Router, "/api")
+	whh := rest.NewWebhookHandlers(webhookCore)
+	whh.RegisterWebhookEndpoints(srv.Router, "/api")
+	cah := rest.NewCalendarHandlers(todoCore, tokenCore)
+	cah.RegisterCalendarEndpoints(srv.Router, "/api")
+	ush := rest.NewUserHandlers(userCore)
+	if PasswordLogin {
+		ush.RegisterUserEndpoints(srv.Router, "/api")
+	} else {
+		ush.RegisterMeEndpoint(srv.Router, "/api")
+	}
+	if oidch != nil {
+		oidch.RegisterOIDCEndpoints(srv.Router, "/api")
+	}
+	tkh := rest.NewTokenHandlers(tokenCore)
+	tkh.RegisterTokenEndpoints(srv.Router, "/api")
+	mbh := rest.NewMemberHandlers(memberCore)
+	mbh.RegisterMemberEndpoints(srv.Router, "/api")
+	rest.RegisterOpenAPIEndpoints(srv.Router, "/api")
+	schema, err := gql.NewSchema(todoCore, smartListCore)
+	if err != nil {
+		log.Default().Panic("graphql schema doesn't match its resolvers", zap.Error(err))
+	}
+	gqh := rest.NewGraphQLHandlers(schema)
+	//the ui asks for /graphql rather than going through /api
+	gqh.RegisterGraphQLEndpoints(srv.Router, "")
 
 	//register tracing
 	tp := tracing.InitTracingProvider("todo")
 	ctx := context.Background()
 	defer func() { _ = tp.Shutdown(ctx) }()
 
+	//webhooks are sent from the queue in the background, stopping with the server
+	dispatchCtx, stopDispatch := context.WithCancel(ctx)
+	srv.RegisterOnShutdown(stopDispatch)
+	dispatcher := webhook.NewDispatcher(webhookStore)
+	if WebhookAllowPrivate {
+		dispatcher.AllowPrivate()
+	}
+	go dispatcher.Run(dispatchCtx)
+
+	sinks, err := outboxSinks(webhookCore)
+	if err != nil {
+		log.Default().Panic("failed to set up outbox sinks", zap.Error(err))
+	}
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	srv.RegisterOnShutdown(stopRelay)
+	go outbox.NewRelay(outboxdb.NewStore(db), sinks...).Run(relayCtx)
+
+	//grpc shares the cores (and hub) with rest, so watchers see changes from either
+	if GrpcPort != "" {
+		lis, err := net.Listen("tcp", net.JoinHostPort(Address, GrpcPort))
+		if err != nil {
+			log.Default().Panic("failed to listen for grpc", zap.Error(err))
+		}
+		grpcSrv := rpc.NewServer(rpc.NewTodoServer(todoCore, hub), rpc.Authenticate(authn, RequireAuth)...)
+		srv.RegisterOnShutdown(grpcSrv.GracefulStop)
+		go func() {
+			log.Default().Info("starting todo grpc server", zap.String("addr", lis.Addr().String()))
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Default().Error("grpc server stopped", zap.Error(err))
+			}
+		}()
+	}
+
 	err = srv.Start(ctx)
 	if err != nil {
 		log.Default().Error("error starting restful server. Shutting down", zap.Error(err))
 	}
 
 }
+
+func outboxSinks(webhooks *webhook.Core) ([]outbox.Sink, error) {
+	var sinks []outbox.Sink
+	for _, name := range OutboxSinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, outbox.LogSink{})
+		case "webhook":
+			sinks = append(sinks, outbox.WebhookSink{Webhooks: webhooks})
+		case "file":
+			if OutboxFile == "" {
+				return nil, fmt.Errorf("--outbox-file is required for the file sink")
+			}
+			f, err := outbox.NewFileSink(OutboxFile)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, f)
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %s", name)
+		}
+	}
+	return sinks, nil
+}
+
+func parseRateLimits(values []string) []rest.RateLimit {
+	var limits []rest.RateLimit
+	for _, v := range values {
+		limit, err := rest.ParseRateLimit(v)
+		if err != nil {
+			log.Default().Panic("failed to set up rate limits", zap.Error(err))
+		}
+		limits = append(limits, limit)
+	}
+	return limits
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/spf13/cobra"
+	"github.com/stumacwastaken/todo/client"
+	"github.com/stumacwastaken/todo/stores/database"
+	"github.com/stumacwastaken/todo/stores/tododb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tui"
+)
+
+func init() {
+	server := os.Getenv("TODO_SERVER")
+	if server == "" {
+		server = "http://localhost:9000/api"
+	}
+	Cmd.Flags().StringVar(&Server, "server", server, "url of the todo api, defaults to $TODO_SERVER if set")
+	Cmd.Flags().StringVarP(&Filter, "filter", "f", "", "filter expression to start with, i.e: completed:false tag:work")
+	Cmd.Flags().BoolVar(&Local, "local", false, "work on the database directly rather than through a server")
+	Cmd.Flags().StringVar(&DBConfig.Host, "dbhost", "localhost:3306", "mysql host and port, with --local")
+	Cmd.Flags().StringVar(&DBConfig.User, "dbuser", "", "mysql user, with --local")
+	Cmd.Flags().StringVar(&DBConfig.Password, "dbpass", "", "mysql password, with --local")
+	Cmd.Flags().StringVar(&DBConfig.Name, "dbname", "todo", "database name, with --local")
+}
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "tui",
+		Short: "browse and triage todo items in a full screen terminal ui",
+		Long: `browse and triage todo items in a full screen terminal ui. It talks to a running server, or with --local
+straight to the database. Changes made with --local still reach webhooks through the outbox, but the
+server's event stream and live views won't see them.`,
+		RunE: run,
+	}
+	Server   string
+	Filter   string
+	Local    bool
+	DBConfig database.Config
+)
+
+func run(cmd *cobra.Command, args []string) error {
+	c := client.NewClient(Server)
+	//the same credentials as the other client commands
+	if t := os.Getenv("TODO_TOKEN"); t != "" {
+		c.Authorization = client.BearerAuth(t)
+	} else if email := os.Getenv("TODO_EMAIL"); email != "" {
+		c.Authorization = client.BasicAuth(email, os.Getenv("TODO_PASSWORD"))
+	}
+	var backend tui.Backend = tui.NewRemoteBackend(c)
+	if Local {
+		db, err := database.Open(DBConfig)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if err := db.Ping(); err != nil {
+			return err
+		}
+		backend = tui.NewLocalBackend(todoitem.NewCore(tododb.NewStore(db)))
+	}
+	_, err := tea.NewProgram(tui.NewModel(cmd.Context(), backend, Filter), tea.WithAltScreen()).Run()
+	return err
+}
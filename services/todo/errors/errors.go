@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"encoding/json"
 	"fmt"
 )
 
@@ -10,19 +11,63 @@ type TodoError struct {
 	msg      string
 	HttpCode int
 	details  string
+	position *int
 }
 
 func (e *TodoError) Error() string {
+	if e.position != nil {
+		return fmt.Sprintf( //quickhack
+			`{
+			"message": %s,
+			"code": %d,
+			"details": %s,
+			"position": %d
+		}`,
+			quote(e.msg), e.HttpCode, quote(e.details), *e.position,
+		)
+	}
 	return fmt.Sprintf( //quickhack
 		`{
-			"message": "%s",
+			"message": %s,
 			"code": %d,
-			"details": "%s"
+			"details": %s
 		}`,
-		e.msg, e.HttpCode, e.details,
+		quote(e.msg), e.HttpCode, quote(e.details),
 	)
 }
 
+// Message is the short description of the error, i.e: not found.
+func (e *TodoError) Message() string {
+	return e.msg
+}
+
+// Details is the longer, human readable explanation of what went wrong.
+func (e *TodoError) Details() string {
+	return e.details
+}
+
+// Position is where in the request's input things went wrong (i.e: the character of a filter expression), if the
+// error is about one spot.
+func (e *TodoError) Position() (int, bool) {
+	if e.position == nil {
+		return 0, false
+	}
+	return *e.position, true
+}
+
+// AtPosition points the error at a spot in the request's input, so clients can show where it is without reading the
+// details.
+func (e *TodoError) AtPosition(pos int) *TodoError {
+	e.position = &pos
+	return e
+}
+
+// quote escapes a string for the json above. details can echo user input (i.e: a bad filter), so %s isn't safe.
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
 // ErrorWithCode is the general factory method to create new TodoErrors. Requires a message, additional details if needed
 // and an http based status code.
 func ErrorWithCode(msg, details string, code int) *TodoError {
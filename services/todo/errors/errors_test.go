@@ -15,3 +15,18 @@ func TestUnknownError(t *testing.T) {
 	err := UnknownError()
 	assert.Containsf(t, err.Error(), `"message": "unknown"`, "should contain unknown error reference")
 }
+
+func TestErrorEscapesDetails(t *testing.T) {
+	err := ErrorWithCode("invalid filter", `unknown field "prio"`, 400)
+	assert.Containsf(t, err.Error(), `"details": "unknown field \"prio\""`, "details should be escaped")
+}
+
+func TestErrorPosition(t *testing.T) {
+	err := ErrorWithCode("invalid filter", "unknown field prio (at position 4)", 400)
+	assert.NotContains(t, err.Error(), `"position"`, "only errors about one spot have a position")
+	err = err.AtPosition(4)
+	pos, ok := err.Position()
+	assert.True(t, ok)
+	assert.Equal(t, 4, pos)
+	assert.Contains(t, err.Error(), `"position": 4`)
+}
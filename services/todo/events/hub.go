@@ -0,0 +1,141 @@
+// Package events fans todo item changes out to anyone listening in process, i.e: the server sent events feed. It
+// keeps a bounded buffer of recent events so clients that drop off for a moment can pick up where they left off.
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// Envelope is an event along with the id it was given by the hub.
+type Envelope struct {
+	Id    string
+	Event todoitem.Event
+}
+
+// Hub is an in memory pub/sub for todo item events. It implements todoitem.Publisher.
+type Hub struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	replay []Envelope
+	size   int
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// subscriberBuffer is how far a subscriber can fall behind before it's cut off. It can always resume from the
+// replay buffer by reconnecting.
+const subscriberBuffer = 64
+
+// NewHub creates a hub that keeps the last replaySize events around for resuming.
+func NewHub(replaySize int) *Hub {
+	return &Hub{
+		//ids are only meaningful to the process that handed them out, the epoch stops a restarted server from
+		//mistaking an old id for one of its own
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  replaySize,
+		subs:  map[*Subscription]struct{}{},
+	}
+}
+
+// Publish hands the event to every subscriber without blocking. Subscribers that can't keep up are closed.
+func (h *Hub) Publish(ctx context.Context, e todoitem.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.seq++
+	env := Envelope{Id: fmt.Sprintf("%s-%d", h.epoch, h.seq), Event: e}
+	h.replay = append(h.replay, env)
+	if len(h.replay) > h.size {
+		h.replay = h.replay[len(h.replay)-h.size:]
+	}
+	for s := range h.subs {
+		select {
+		case s.ch <- env:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+// Subscribe starts listening for events. If lastId is set, the events published after it are returned to be sent
+// first. Missed is true when lastId is too old (or from another process) to resume from, so the subscriber should
+// reload everything instead.
+func (h *Hub) Subscribe(lastId string) (sub *Subscription, replay []Envelope, missed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub = &Subscription{ch: make(chan Envelope, subscriberBuffer), hub: h}
+	if h.closed {
+		close(sub.ch)
+		return sub, nil, false
+	}
+	h.subs[sub] = struct{}{}
+	if lastId == "" {
+		return sub, nil, false
+	}
+	seq, ok := h.parseId(lastId)
+	if !ok || seq > h.seq {
+		return sub, nil, true
+	}
+	if seq == h.seq {
+		return sub, nil, false
+	}
+	oldest := h.seq - uint64(len(h.replay)) + 1
+	if len(h.replay) == 0 || seq+1 < oldest {
+		return sub, nil, true
+	}
+	replay = append(replay, h.replay[seq+1-oldest:]...)
+	return sub, replay, false
+}
+
+func (h *Hub) parseId(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// Close ends every subscription and stops accepting events. Call it on shutdown so long lived streams let go.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.drop(s)
+	}
+}
+
+// drop must be called with the lock held
+func (h *Hub) drop(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscription receives events on C until it's closed, either by the subscriber or the hub.
+type Subscription struct {
+	ch  chan Envelope
+	hub *Hub
+}
+
+func (s *Subscription) C() <-chan Envelope {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newSummary(summary string) *string {
+	return &summary
+}
+
+func publishN(h *Hub, n int) {
+	for i := 0; i < n; i++ {
+		h.Publish(context.Background(), todoitem.Event{
+			Type: todoitem.EventCreated,
+			Item: todoitem.TodoItem{Summary: newSummary(fmt.Sprintf("item %d", i))},
+		})
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	h := NewHub(10)
+	sub, replay, missed := h.Subscribe("")
+	defer sub.Close()
+	assert.Empty(t, replay)
+	assert.False(t, missed)
+
+	publishN(h, 2)
+	first := <-sub.C()
+	second := <-sub.C()
+	assert.Equal(t, "item 0", *first.Event.Item.Summary)
+	assert.Equal(t, "item 1", *second.Event.Item.Summary)
+	assert.NotEqual(t, first.Id, second.Id)
+}
+
+func TestResume(t *testing.T) {
+	h := NewHub(5)
+	sub, _, _ := h.Subscribe("")
+	publishN(h, 3)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, (<-sub.C()).Id)
+	}
+	sub.Close()
+
+	type test struct {
+		name    string
+		lastId  string
+		publish int
+		replay  []string
+		missed  bool
+	}
+	tests := []test{
+		{name: "up to date", lastId: ids[2], replay: nil},
+		{name: "behind", lastId: ids[0], replay: []string{"item 1", "item 2"}},
+		{name: "garbage id", lastId: "nope", missed: true},
+		{name: "another process", lastId: "abc-1", missed: true},
+		{name: "fell out of the buffer", lastId: ids[0], publish: 5, missed: true},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			publishN(h, tt.publish)
+			sub, replay, missed := h.Subscribe(tt.lastId)
+			defer sub.Close()
+			assert.Equal(t, tt.missed, missed)
+			var summaries []string
+			for _, env := range replay {
+				summaries = append(summaries, *env.Event.Item.Summary)
+			}
+			assert.Equal(t, tt.replay, summaries)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := NewHub(10)
+	slow, _, _ := h.Subscribe("")
+	publishN(h, subscriberBuffer+1)
+	count := 0
+	for range slow.C() {
+		count++
+	}
+	assert.Equal(t, subscriberBuffer, count, "channel should be closed once the buffer overflows")
+	slow.Close() //closing twice is fine
+}
+
+func TestClose(t *testing.T) {
+	h := NewHub(10)
+	sub, _, _ := h.Subscribe("")
+	h.Close()
+	_, ok := <-sub.C()
+	assert.False(t, ok)
+
+	publishN(h, 1)
+	late, _, _ := h.Subscribe("")
+	_, ok = <-late.C()
+	assert.False(t, ok, "subscribing to a closed hub gets a closed subscription")
+}
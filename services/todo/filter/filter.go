@@ -0,0 +1,207 @@
+// Package filter implements the small query language used for searching todos and for saved smart lists, i.e:
+//
+//	completed:false priority>=high tag:work due<7d
+//
+// An expression is a whitespace separated list of terms that must all match. A term is either `field<op>value` or a
+// bare word, which is matched against the schema's default (text) field. A term can be negated with a leading `-`.
+// Expressions compile down to a Predicate, which is deliberately storage agnostic. Stores either translate it into
+// their own query language (see tododb) or evaluate it in memory with Match.
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kind is the type of value a field holds, which in turn decides the operators and values it accepts.
+type Kind int
+
+const (
+	Bool Kind = iota
+	Text
+	Set
+	Enum
+	Time
+)
+
+// Field describes a single filterable field. Values is only used by Enum fields, and its order is the order used
+// for comparisons (lowest first).
+type Field struct {
+	Name   string
+	Kind   Kind
+	Values []string
+}
+
+// Schema is the set of fields an expression may reference. Default names the Text field bare words search on.
+type Schema struct {
+	Fields  []Field
+	Default string
+}
+
+func (s Schema) field(name string) (Field, bool) {
+	for _, f := range s.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Op is a compiled comparison operator. `!=` never survives compilation, it becomes a negated Eq.
+type Op int
+
+const (
+	Eq Op = iota
+	Contains
+	Lt
+	Le
+	Gt
+	Ge
+	Within
+	//In is only made in code, never compiled from an expression. It's used to scope queries to several owners.
+	In
+)
+
+// Span is a half open [From, To) time range, used as the value of Within conditions.
+type Span struct {
+	From time.Time
+	To   time.Time
+}
+
+// Cond is a single compiled condition. The type of Value depends on the field kind:
+//   - Bool: bool
+//   - Text, Set: string
+//   - Enum: int, the index of the value in Field.Values
+//   - Time: time.Time, Span for Within, or nil to check the field isn't set
+//   - []string for In, on Text fields
+type Cond struct {
+	Field  Field
+	Op     Op
+	Value  any
+	Negate bool
+}
+
+// Predicate is a compiled expression. All of its conditions must hold for an item to match. The zero value matches
+// everything.
+type Predicate struct {
+	Conds []Cond
+}
+
+// Has reports whether the predicate has a condition on the named field.
+func (p Predicate) Has(name string) bool {
+	for _, c := range p.Conds {
+		if c.Field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// And returns a new predicate with the given condition appended.
+func (p Predicate) And(c Cond) Predicate {
+	conds := make([]Cond, 0, len(p.Conds)+1)
+	conds = append(conds, p.Conds...)
+	return Predicate{Conds: append(conds, c)}
+}
+
+// Subject is anything a predicate can be evaluated against in memory. FilterValue must return the value for the
+// named field using the same types as Cond.Value, except Set fields return a []string and Time fields a *time.Time.
+type Subject interface {
+	FilterValue(field string) any
+}
+
+// Match evaluates the predicate against a subject.
+func (p Predicate) Match(s Subject) bool {
+	for _, c := range p.Conds {
+		if c.match(s.FilterValue(c.Field.Name)) == c.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Cond) match(v any) bool {
+	switch c.Field.Kind {
+	case Bool:
+		b, _ := v.(bool)
+		return b == c.Value.(bool)
+	case Text:
+		s, _ := v.(string)
+		if c.Op == In {
+			for _, want := range c.Value.([]string) {
+				if strings.EqualFold(s, want) {
+					return true
+				}
+			}
+			return false
+		}
+		if c.Op == Contains {
+			return strings.Contains(strings.ToLower(s), strings.ToLower(c.Value.(string)))
+		}
+		return strings.EqualFold(s, c.Value.(string))
+	case Set:
+		set, _ := v.([]string)
+		for _, s := range set {
+			if s == c.Value.(string) {
+				return true
+			}
+		}
+		return false
+	case Enum:
+		i, _ := v.(int)
+		return compare(i, c.Value.(int), c.Op)
+	case Time:
+		t, _ := v.(*time.Time)
+		if c.Value == nil {
+			return t == nil
+		}
+		if t == nil {
+			return false
+		}
+		if span, ok := c.Value.(Span); ok {
+			return !t.Before(span.From) && t.Before(span.To)
+		}
+		return compareTime(*t, c.Value.(time.Time), c.Op)
+	}
+	return false
+}
+
+func compare(a, b int, op Op) bool {
+	switch op {
+	case Lt:
+		return a < b
+	case Le:
+		return a <= b
+	case Gt:
+		return a > b
+	case Ge:
+		return a >= b
+	}
+	return a == b
+}
+
+func compareTime(a, b time.Time, op Op) bool {
+	switch op {
+	case Lt:
+		return a.Before(b)
+	case Le:
+		return !a.After(b)
+	case Gt:
+		return a.After(b)
+	case Ge:
+		return !a.Before(b)
+	}
+	return a.Equal(b)
+}
+
+// Error is returned for any expression that fails to parse or compile. Pos is the zero based character (not byte)
+// offset of the offending term or value, so it can be pointed at in a UI.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (at position %d)", e.Msg, e.Pos)
+}
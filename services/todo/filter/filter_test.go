@@ -0,0 +1,180 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = Schema{
+	Default: "summary",
+	Fields: []Field{
+		{Name: "summary", Kind: Text},
+		{Name: "completed", Kind: Bool},
+		{Name: "priority", Kind: Enum, Values: []string{"none", "low", "medium", "high"}},
+		{Name: "tag", Kind: Set},
+		{Name: "due", Kind: Time},
+	},
+}
+
+var testNow = time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+
+type testItem struct {
+	summary   string
+	completed bool
+	priority  int
+	tags      []string
+	due       *time.Time
+}
+
+func (i testItem) FilterValue(field string) any {
+	switch field {
+	case "summary":
+		return i.summary
+	case "completed":
+		return i.completed
+	case "priority":
+		return i.priority
+	case "tag":
+		return i.tags
+	case "due":
+		return i.due
+	}
+	return nil
+}
+
+func newTime(ti time.Time) *time.Time {
+	return &ti
+}
+
+func TestParse(t *testing.T) {
+	terms, err := Parse(`completed:false  -tag:work "buy milk" priority>=high`)
+	assert.Nil(t, err)
+	assert.Equal(t, []Term{
+		{Pos: 0, Field: "completed", Op: ":", Value: "false", ValuePos: 10},
+		{Pos: 17, Negate: true, Field: "tag", Op: ":", Value: "work", ValuePos: 22},
+		{Pos: 27, Value: "buy milk", ValuePos: 27},
+		{Pos: 38, Field: "priority", Op: ">=", Value: "high", ValuePos: 48},
+	}, terms)
+}
+
+func TestParseErrors(t *testing.T) {
+	type test struct {
+		name string
+		expr string
+		pos  int
+	}
+	tests := []test{
+		{name: "missing value", expr: "completed:", pos: 10},
+		{name: "lonely bang", expr: "tag!work", pos: 3},
+		{name: "unterminated quote", expr: `summary:"milk`, pos: 8},
+		{name: "empty quote", expr: `tag:""`, pos: 4},
+		{name: "character not byte offset", expr: `é completed:`, pos: 12},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			var ferr *Error
+			if assert.ErrorAs(t, err, &ferr) {
+				assert.Equal(t, tt.pos, ferr.Pos)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	type test struct {
+		name     string
+		expr     string
+		pos      int
+		contains string
+	}
+	tests := []test{
+		{name: "unknown field", expr: "completed:true prio:high", pos: 15, contains: "unknown field prio"},
+		{name: "bad bool", expr: "completed:maybe", pos: 10, contains: "true or false"},
+		{name: "bad enum", expr: "priority>=urgent", pos: 10, contains: "none, low, medium, high"},
+		{name: "ordering on bool", expr: "completed>true", pos: 9, contains: "operator >"},
+		{name: "ordering on text", expr: "summary<=milk", pos: 7, contains: "operator <="},
+		{name: "bad date", expr: "due<soon", pos: 4, contains: "invalid date soon"},
+		{name: "none ordering", expr: "due<none", pos: 4, contains: "none can only"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, err := Compile(tt.expr, testSchema, testNow)
+			var ferr *Error
+			if assert.ErrorAs(t, err, &ferr) {
+				assert.Equal(t, tt.pos, ferr.Pos)
+				assert.Contains(t, ferr.Error(), tt.contains)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	pred, err := Compile(`completed:false priority!=low due<7d due:today milk`, testSchema, testNow)
+	assert.Nil(t, err)
+	assert.Len(t, pred.Conds, 5)
+	assert.Equal(t, Cond{Field: testSchema.Fields[1], Op: Eq, Value: false}, pred.Conds[0])
+	assert.Equal(t, Cond{Field: testSchema.Fields[2], Op: Eq, Value: 1, Negate: true}, pred.Conds[1])
+	assert.Equal(t, Cond{Field: testSchema.Fields[4], Op: Lt, Value: testNow.AddDate(0, 0, 7)}, pred.Conds[2])
+	startOfDay := time.Date(2023, time.January, 12, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, Cond{Field: testSchema.Fields[4], Op: Within, Value: Span{From: startOfDay, To: startOfDay.AddDate(0, 0, 1)}}, pred.Conds[3])
+	assert.Equal(t, Cond{Field: testSchema.Fields[0], Op: Contains, Value: "milk"}, pred.Conds[4])
+	assert.True(t, pred.Has("due"))
+	assert.False(t, pred.Has("tag"))
+}
+
+func TestMatch(t *testing.T) {
+	item := testItem{
+		summary:  "Buy milk",
+		priority: 3,
+		tags:     []string{"home", "errands"},
+		due:      newTime(testNow.Add(36 * time.Hour)),
+	}
+	type test struct {
+		expr  string
+		match bool
+	}
+	tests := []test{
+		{expr: "", match: true},
+		{expr: "milk", match: true},
+		{expr: "-milk", match: false},
+		{expr: `summary="buy milk"`, match: true},
+		{expr: "completed:false", match: true},
+		{expr: "completed:true", match: false},
+		{expr: "priority>=medium", match: true},
+		{expr: "priority<high", match: false},
+		{expr: "tag:home", match: true},
+		{expr: "tag!=home", match: false},
+		{expr: "tag:work", match: false},
+		{expr: "due<7d", match: true},
+		{expr: "due<1d", match: false},
+		{expr: "due:tomorrow", match: false},
+		{expr: "due:2023-01-14", match: true},
+		{expr: "due:none", match: false},
+		{expr: "-due:none", match: true},
+		{expr: "milk tag:errands due>now priority:high", match: true},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			pred, err := Compile(tt.expr, testSchema, testNow)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.match, pred.Match(item))
+		}
+		t.Run(tt.expr, tf)
+	}
+
+	//nothing to compare against means no match, unless the condition is negated
+	noDue := testItem{summary: "no due date"}
+	pred, _ := Compile("due<7d", testSchema, testNow)
+	assert.False(t, pred.Match(noDue))
+	pred, _ = Compile("-due<7d", testSchema, testNow)
+	assert.True(t, pred.Match(noDue))
+
+	summary := testSchema.Fields[0]
+	assert.True(t, Predicate{}.And(Cond{Field: summary, Op: In, Value: []string{"x", "buy milk"}}).Match(item))
+	assert.False(t, Predicate{}.And(Cond{Field: summary, Op: In, Value: []string{}}).Match(item), "in nothing matches nothing")
+}
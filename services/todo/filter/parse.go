@@ -0,0 +1,304 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Term is a single parsed, but not yet type checked, term of an expression. Field is empty for bare words.
+type Term struct {
+	Pos      int
+	Negate   bool
+	Field    string
+	Op       string
+	Value    string
+	ValuePos int
+}
+
+// Parse splits an expression into its terms, checking syntax only. Most callers want Compile instead.
+func Parse(expr string) ([]Term, error) {
+	p := parser{src: []rune(expr)}
+	var terms []Term
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return terms, nil
+		}
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+}
+
+type parser struct {
+	src []rune
+	pos int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *parser) term() (Term, error) {
+	t := Term{Pos: p.pos}
+	if p.peek() == '-' && p.pos+1 < len(p.src) && (isIdent(p.src[p.pos+1]) || p.src[p.pos+1] == '"') {
+		t.Negate = true
+		p.pos++
+	}
+	start := p.pos
+	for !p.eof() && isIdent(p.peek()) {
+		p.pos++
+	}
+	if p.pos > start && isOp(p.peek()) {
+		t.Field = string(p.src[start:p.pos])
+		op, err := p.op()
+		if err != nil {
+			return Term{}, err
+		}
+		t.Op = op
+	} else {
+		//bare word, start over and treat the whole thing as a value
+		p.pos = start
+	}
+	t.ValuePos = p.pos
+	v, err := p.value()
+	if err != nil {
+		return Term{}, err
+	}
+	t.Value = v
+	return t, nil
+}
+
+func (p *parser) op() (string, error) {
+	start := p.pos
+	switch p.peek() {
+	case ':', '=':
+		p.pos++
+	case '<', '>':
+		p.pos++
+		if p.peek() == '=' {
+			p.pos++
+		}
+	case '!':
+		p.pos++
+		if p.peek() != '=' {
+			return "", &Error{Pos: start, Msg: "expected != operator"}
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos]), nil
+}
+
+func (p *parser) value() (string, error) {
+	start := p.pos
+	if p.peek() == '"' {
+		p.pos++
+		var b strings.Builder
+		for !p.eof() {
+			r := p.peek()
+			p.pos++
+			switch r {
+			case '"':
+				if b.Len() == 0 {
+					return "", &Error{Pos: start, Msg: "empty quoted value"}
+				}
+				return b.String(), nil
+			case '\\':
+				if p.eof() {
+					return "", &Error{Pos: p.pos - 1, Msg: "unfinished escape sequence"}
+				}
+				b.WriteRune(p.peek())
+				p.pos++
+			default:
+				b.WriteRune(r)
+			}
+		}
+		return "", &Error{Pos: start, Msg: "unterminated quoted value"}
+	}
+	for !p.eof() && !unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", &Error{Pos: start, Msg: "missing value"}
+	}
+	return string(p.src[start:p.pos]), nil
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isOp(r rune) bool {
+	return strings.ContainsRune(":=!<>", r)
+}
+
+// Compile parses an expression and type checks it against the schema. Relative dates (i.e: `7d`, `today`) are
+// resolved against now, so predicates for saved filters should be compiled when they are used, not when saved.
+func Compile(expr string, schema Schema, now time.Time) (Predicate, error) {
+	terms, err := Parse(expr)
+	if err != nil {
+		return Predicate{}, err
+	}
+	var pred Predicate
+	for _, t := range terms {
+		c, err := compileTerm(t, schema, now)
+		if err != nil {
+			return Predicate{}, err
+		}
+		pred.Conds = append(pred.Conds, c)
+	}
+	return pred, nil
+}
+
+func compileTerm(t Term, schema Schema, now time.Time) (Cond, error) {
+	name := t.Field
+	op := t.Op
+	if name == "" {
+		name, op = schema.Default, ":"
+	}
+	f, ok := schema.field(name)
+	if !ok {
+		if t.Field == "" {
+			return Cond{}, &Error{Pos: t.Pos, Msg: "free text search is not supported"}
+		}
+		return Cond{}, &Error{Pos: t.Pos, Msg: fmt.Sprintf("unknown field %s", t.Field)}
+	}
+	c := Cond{Field: f, Negate: t.Negate}
+	if op == "!=" {
+		c.Negate = !c.Negate
+		op = "="
+	}
+	switch f.Kind {
+	case Bool:
+		if op != ":" && op != "=" {
+			return Cond{}, opError(t, f)
+		}
+		b, err := strconv.ParseBool(t.Value)
+		if err != nil {
+			return Cond{}, &Error{Pos: t.ValuePos, Msg: fmt.Sprintf("%s expects true or false", f.Name)}
+		}
+		c.Op, c.Value = Eq, b
+	case Text:
+		switch op {
+		case ":":
+			c.Op = Contains
+		case "=":
+			c.Op = Eq
+		default:
+			return Cond{}, opError(t, f)
+		}
+		c.Value = t.Value
+	case Set:
+		if op != ":" && op != "=" {
+			return Cond{}, opError(t, f)
+		}
+		c.Op, c.Value = Contains, t.Value
+	case Enum:
+		c.Op = compOp(op)
+		rank := -1
+		for i, v := range f.Values {
+			if strings.EqualFold(v, t.Value) {
+				rank = i
+			}
+		}
+		if rank < 0 {
+			return Cond{}, &Error{Pos: t.ValuePos, Msg: fmt.Sprintf("%s expects one of %s", f.Name, strings.Join(f.Values, ", "))}
+		}
+		c.Value = rank
+	case Time:
+		if strings.EqualFold(t.Value, "none") {
+			if op != ":" && op != "=" {
+				return Cond{}, &Error{Pos: t.ValuePos, Msg: "none can only be used with : or ="}
+			}
+			c.Op, c.Value = Eq, nil
+			return c, nil
+		}
+		v, err := parseTime(t.Value, now)
+		if err != nil {
+			return Cond{}, &Error{Pos: t.ValuePos, Msg: err.Error()}
+		}
+		if op == ":" || op == "=" {
+			from := startOfDay(v)
+			c.Op, c.Value = Within, Span{From: from, To: from.AddDate(0, 0, 1)}
+		} else {
+			c.Op, c.Value = compOp(op), v
+		}
+	}
+	return c, nil
+}
+
+func opError(t Term, f Field) error {
+	return &Error{Pos: t.ValuePos - len(t.Op), Msg: fmt.Sprintf("operator %s cannot be used with %s", t.Op, f.Name)}
+}
+
+func compOp(op string) Op {
+	switch op {
+	case "<":
+		return Lt
+	case "<=":
+		return Le
+	case ">":
+		return Gt
+	case ">=":
+		return Ge
+	}
+	return Eq
+}
+
+// parseTime understands the keywords now, today, tomorrow and yesterday, dates (2006-01-02), RFC3339 timestamps and
+// offsets from now in hours, days or weeks (i.e: 12h, 7d, -2w).
+func parseTime(v string, now time.Time) (time.Time, error) {
+	switch strings.ToLower(v) {
+	case "now":
+		return now, nil
+	case "today":
+		return startOfDay(now), nil
+	case "tomorrow":
+		return startOfDay(now).AddDate(0, 0, 1), nil
+	case "yesterday":
+		return startOfDay(now).AddDate(0, 0, -1), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if len(v) > 1 {
+		n, err := strconv.Atoi(v[:len(v)-1])
+		if err == nil {
+			switch v[len(v)-1] {
+			case 'h':
+				return now.Add(time.Duration(n) * time.Hour), nil
+			case 'd':
+				return now.AddDate(0, 0, n), nil
+			case 'w':
+				return now.AddDate(0, 0, 7*n), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s, use a date (2006-01-02), a timestamp, or an offset like 7d", v)
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,184 @@
+// Package gql is a GraphQL api over the todo item and smart list cores, see schema.graphql. It's for the UI, which
+// would rather ask for exactly what a page renders than stitch it together from several rest calls.
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/trace/otel"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+//go:embed schema.graphql
+var Schema string
+
+// maxDepth stops queries from nesting far enough to be expensive. Nothing in the schema needs more than a few levels.
+const maxDepth = 8
+
+// NewSchema parses the schema against the resolvers. It only fails if the two have drifted apart.
+func NewSchema(todos *todoitem.Core, lists *smartlist.Core) (*graphql.Schema, error) {
+	return graphql.ParseSchema(Schema, &Resolver{todos: todos, lists: lists},
+		graphql.Tracer(&otel.Tracer{Tracer: tracing.Tracer()}),
+		graphql.MaxDepth(maxDepth),
+		graphql.UseStringDescriptions(),
+	)
+}
+
+// Resolver is the root of the schema, serving both queries and mutations.
+type Resolver struct {
+	todos *todoitem.Core
+	lists *smartlist.Core
+}
+
+func (r *Resolver) Todos(ctx context.Context, args struct{ Filter *string }) ([]*todoResolver, error) {
+	var items []todoitem.TodoItem
+	var err error
+	if args.Filter != nil && *args.Filter != "" {
+		items, err = r.todos.Find(ctx, *args.Filter)
+	} else {
+		items, err = r.todos.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, asError(err)
+	}
+	return todoResolvers(items), nil
+}
+
+func (r *Resolver) Todo(ctx context.Context, args struct{ Id graphql.ID }) (*todoResolver, error) {
+	item, err := r.todos.GetById(ctx, string(args.Id))
+	if err != nil {
+		if v, ok := err.(*terr.TodoError); ok && v.HttpCode == 404 {
+			return nil, nil
+		}
+		return nil, asError(err)
+	}
+	return &todoResolver{item: item}, nil
+}
+
+func (r *Resolver) SmartLists(ctx context.Context) ([]*smartListResolver, error) {
+	lists, err := r.lists.GetAll(ctx)
+	if err != nil {
+		return nil, asError(err)
+	}
+	resolvers := make([]*smartListResolver, 0, len(lists))
+	for _, l := range lists {
+		resolvers = append(resolvers, &smartListResolver{list: l, todos: r.todos})
+	}
+	return resolvers, nil
+}
+
+func (r *Resolver) SmartList(ctx context.Context, args struct{ Id graphql.ID }) (*smartListResolver, error) {
+	list, err := r.lists.GetById(ctx, string(args.Id))
+	if err != nil {
+		if v, ok := err.(*terr.TodoError); ok && v.HttpCode == 404 {
+			return nil, nil
+		}
+		return nil, asError(err)
+	}
+	return &smartListResolver{list: list, todos: r.todos}, nil
+}
+
+type createTodoInput struct {
+	Summary  string
+	Priority *string
+	Due      *graphql.Time
+	Tags     *[]string
+}
+
+func (r *Resolver) CreateTodo(ctx context.Context, args struct{ Input createTodoInput }) (*todoResolver, error) {
+	in := args.Input
+	item := todoitem.TodoItem{Summary: &in.Summary, Priority: fromPriority(in.Priority), Due: fromTime(in.Due)}
+	if in.Tags != nil {
+		item.Tags = *in.Tags
+	}
+	created, err := r.todos.Create(ctx, item)
+	if err != nil {
+		return nil, asError(err)
+	}
+	return &todoResolver{item: created}, nil
+}
+
+type updateTodoInput struct {
+	Summary   string
+	Completed *bool
+	Deleted   *bool
+	Priority  *string
+	Due       *graphql.Time
+	Tags      *[]string
+}
+
+func (r *Resolver) UpdateTodo(ctx context.Context, args struct {
+	Id    graphql.ID
+	Input updateTodoInput
+}) (*todoResolver, error) {
+	in := args.Input
+	item := todoitem.TodoItem{
+		Summary:   &in.Summary,
+		Completed: in.Completed,
+		Deleted:   in.Deleted,
+		Priority:  fromPriority(in.Priority),
+		Due:       fromTime(in.Due),
+	}
+	if in.Tags != nil {
+		item.Tags = append([]string{}, *in.Tags...)
+	}
+	updated, err := r.todos.Update(ctx, item, string(args.Id))
+	if err != nil {
+		return nil, asError(err)
+	}
+	return &todoResolver{item: updated}, nil
+}
+
+func (r *Resolver) DeleteTodo(ctx context.Context, args struct{ Id graphql.ID }) (*todoResolver, error) {
+	deleted, err := r.todos.Delete(ctx, string(args.Id))
+	if err != nil {
+		return nil, asError(err)
+	}
+	return &todoResolver{item: deleted}, nil
+}
+
+type smartListInput struct {
+	Name  *string
+	Query *string
+}
+
+func (r *Resolver) CreateSmartList(ctx context.Context, args struct{ Input smartListInput }) (*smartListResolver, error) {
+	created, err := r.lists.Create(ctx, smartlist.SmartList{Name: args.Input.Name, Query: args.Input.Query})
+	if err != nil {
+		return nil, asError(err)
+	}
+	return &smartListResolver{list: created, todos: r.todos}, nil
+}
+
+func (r *Resolver) UpdateSmartList(ctx context.Context, args struct {
+	Id    graphql.ID
+	Input smartListInput
+}) (*smartListResolver, error) {
+	updated, err := r.lists.Update(ctx, smartlist.SmartList{Name: args.Input.Name, Query: args.Input.Query}, string(args.Id))
+	if err != nil {
+		return nil, asError(err)
+	}
+	return &smartListResolver{list: updated, todos: r.todos}, nil
+}
+
+func (r *Resolver) DeleteSmartList(ctx context.Context, args struct{ Id graphql.ID }) (graphql.ID, error) {
+	if err := r.lists.Delete(ctx, string(args.Id)); err != nil {
+		return "", asError(err)
+	}
+	return args.Id, nil
+}
+
+// fromPriority maps the schema's enum onto ours. The schema has already checked it's one of the known values.
+func fromPriority(p *string) *todoitem.Priority {
+	if p == nil {
+		return nil
+	}
+	v := todoitem.Priority(strings.ToLower(*p))
+	return &v
+}
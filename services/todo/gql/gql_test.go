@@ -0,0 +1,153 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// listStorer keeps smart lists in a map, there's no memdb for them.
+type listStorer struct {
+	lists map[string]smartlist.SmartList
+}
+
+func (s *listStorer) Create(ctx context.Context, l smartlist.SmartList) (smartlist.SmartList, error) {
+	id := "list-1"
+	l.Id = &id
+	s.lists[id] = l
+	return l, nil
+}
+
+func (s *listStorer) GetAll(context.Context) ([]smartlist.SmartList, error) {
+	res := []smartlist.SmartList{}
+	for _, l := range s.lists {
+		res = append(res, l)
+	}
+	return res, nil
+}
+
+func (s *listStorer) GetById(ctx context.Context, id string) (smartlist.SmartList, error) {
+	l, ok := s.lists[id]
+	if !ok {
+		return smartlist.SmartList{}, terr.ErrorWithCode("not found", "no smart list with id "+id, 404)
+	}
+	return l, nil
+}
+
+func (s *listStorer) Update(ctx context.Context, l smartlist.SmartList) (smartlist.SmartList, error) {
+	s.lists[*l.Id] = l
+	return l, nil
+}
+
+func (s *listStorer) Delete(ctx context.Context, id string) error {
+	delete(s.lists, id)
+	return nil
+}
+
+func newSchema(t *testing.T) *graphql.Schema {
+	hub := events.NewHub(10)
+	t.Cleanup(hub.Close)
+	todos := todoitem.NewCore(memdb.NewStore(), hub)
+	schema, err := NewSchema(todos, smartlist.NewCore(&listStorer{lists: map[string]smartlist.SmartList{}}, todos))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func exec(t *testing.T, schema *graphql.Schema, query string, vars map[string]any) (map[string]any, []*gqlerrors.QueryError) {
+	res := schema.Exec(context.Background(), query, "", vars)
+	var data map[string]any
+	if len(res.Data) > 0 {
+		assert.Nil(t, json.Unmarshal(res.Data, &data))
+	}
+	return data, res.Errors
+}
+
+func TestTodos(t *testing.T) {
+	schema := newSchema(t)
+	data, errs := exec(t, schema, `mutation($in: CreateTodoInput!) { createTodo(input: $in) { id summary priority tags } }`,
+		map[string]any{"in": map[string]any{"summary": "buy milk", "priority": "HIGH", "tags": []any{"home"}}})
+	assert.Empty(t, errs)
+	created := data["createTodo"].(map[string]any)
+	assert.Equal(t, "HIGH", created["priority"])
+	assert.Equal(t, []any{"home"}, created["tags"])
+	id := created["id"].(string)
+	exec(t, schema, `mutation { createTodo(input: {summary: "walk dog"}) { id } }`, nil)
+
+	data, errs = exec(t, schema, `mutation($id: ID!) { updateTodo(id: $id, input: {summary: "buy milk", completed: true}) { completed completedAt priority } }`,
+		map[string]any{"id": id})
+	assert.Empty(t, errs)
+	updated := data["updateTodo"].(map[string]any)
+	assert.Equal(t, true, updated["completed"])
+	assert.NotNil(t, updated["completedAt"])
+	assert.Equal(t, "HIGH", updated["priority"], "left out means left alone")
+
+	data, errs = exec(t, schema, `{ todos(filter: "completed:true") { id } all: todos { id } }`, nil)
+	assert.Empty(t, errs)
+	assert.Len(t, data["todos"], 1)
+	assert.Len(t, data["all"], 2)
+
+	data, errs = exec(t, schema, `mutation($id: ID!) { deleteTodo(id: $id) { deleted } }`, map[string]any{"id": id})
+	assert.Empty(t, errs)
+	assert.Equal(t, true, data["deleteTodo"].(map[string]any)["deleted"])
+
+	data, _ = exec(t, schema, `{ todo(id: "nope") { id } }`, nil)
+	assert.Nil(t, data["todo"], "missing is null rather than an error")
+}
+
+func TestSmartLists(t *testing.T) {
+	schema := newSchema(t)
+	exec(t, schema, `mutation { createTodo(input: {summary: "report", tags: ["work"]}) { id } }`, nil)
+	exec(t, schema, `mutation { createTodo(input: {summary: "laundry", tags: ["home"]}) { id } }`, nil)
+
+	data, errs := exec(t, schema, `mutation { createSmartList(input: {name: "work", query: "tag:work"}) { id name todos { summary } } }`, nil)
+	assert.Empty(t, errs)
+	list := data["createSmartList"].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"summary": "report"}}, list["todos"])
+
+	data, errs = exec(t, schema, `mutation($id: ID!) { updateSmartList(id: $id, input: {query: "tag:home"}) { name todos { summary } } }`,
+		map[string]any{"id": list["id"]})
+	assert.Empty(t, errs)
+	updated := data["updateSmartList"].(map[string]any)
+	assert.Equal(t, "work", updated["name"])
+	assert.Equal(t, []any{map[string]any{"summary": "laundry"}}, updated["todos"])
+
+	data, errs = exec(t, schema, `{ smartLists { id } }`, nil)
+	assert.Empty(t, errs)
+	assert.Len(t, data["smartLists"], 1)
+}
+
+func TestErrors(t *testing.T) {
+	schema := newSchema(t)
+	type test struct {
+		name  string
+		query string
+		code  int
+	}
+	tests := []test{
+		{name: "empty summary", query: `mutation { createTodo(input: {summary: ""}) { id } }`, code: 400},
+		{name: "bad filter", query: `{ todos(filter: "priority:urgent") { id } }`, code: 400},
+		{name: "missing todo", query: `mutation { updateTodo(id: "nope", input: {summary: "x"}) { id } }`, code: 404},
+		{name: "bad list query", query: `mutation { createSmartList(input: {name: "x", query: "nope:nope"}) { id } }`, code: 400},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, errs := exec(t, schema, tt.query, nil)
+			if assert.Len(t, errs, 1) {
+				assert.EqualValues(t, tt.code, errs[0].Extensions["code"])
+				assert.NotEmpty(t, errs[0].Extensions["message"])
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
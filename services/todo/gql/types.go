@@ -0,0 +1,151 @@
+package gql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type todoResolver struct {
+	item todoitem.TodoItem
+}
+
+func todoResolvers(items []todoitem.TodoItem) []*todoResolver {
+	resolvers := make([]*todoResolver, 0, len(items))
+	for _, item := range items {
+		resolvers = append(resolvers, &todoResolver{item: item})
+	}
+	return resolvers
+}
+
+func (t *todoResolver) Id() graphql.ID {
+	return graphql.ID(deref(t.item.Id))
+}
+
+func (t *todoResolver) Summary() string {
+	return deref(t.item.Summary)
+}
+
+func (t *todoResolver) Completed() bool {
+	return deref(t.item.Completed)
+}
+
+func (t *todoResolver) Deleted() bool {
+	return deref(t.item.Deleted)
+}
+
+func (t *todoResolver) Priority() string {
+	if t.item.Priority == nil {
+		return strings.ToUpper(string(todoitem.PriorityNone))
+	}
+	return strings.ToUpper(string(*t.item.Priority))
+}
+
+func (t *todoResolver) Due() *graphql.Time {
+	return toTime(t.item.Due)
+}
+
+func (t *todoResolver) Tags() []string {
+	if t.item.Tags == nil {
+		return []string{}
+	}
+	return t.item.Tags
+}
+
+func (t *todoResolver) Created() *graphql.Time {
+	return toTime(t.item.Created)
+}
+
+func (t *todoResolver) Updated() *graphql.Time {
+	return toTime(t.item.Updated)
+}
+
+func (t *todoResolver) CompletedAt() *graphql.Time {
+	return toTime(t.item.CompletedAt)
+}
+
+type smartListResolver struct {
+	list  smartlist.SmartList
+	todos *todoitem.Core
+}
+
+func (s *smartListResolver) Id() graphql.ID {
+	return graphql.ID(deref(s.list.Id))
+}
+
+func (s *smartListResolver) Name() string {
+	return deref(s.list.Name)
+}
+
+func (s *smartListResolver) Query() string {
+	return deref(s.list.Query)
+}
+
+func (s *smartListResolver) Created() *graphql.Time {
+	return toTime(s.list.Created)
+}
+
+func (s *smartListResolver) Updated() *graphql.Time {
+	return toTime(s.list.Updated)
+}
+
+// Todos runs the list's query. We already have the list, so this skips smartlist.Core.Items looking it up again.
+func (s *smartListResolver) Todos(ctx context.Context) ([]*todoResolver, error) {
+	items, err := s.todos.Find(ctx, s.Query())
+	if err != nil {
+		return nil, asError(err)
+	}
+	return todoResolvers(items), nil
+}
+
+func toTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func fromTime(t *graphql.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// resolverError carries a TodoError's status and message in the graphql error's extensions, so the UI can tell a
+// bad filter from a missing item the same way it does with rest.
+type resolverError struct {
+	err *terr.TodoError
+}
+
+func asError(err error) error {
+	v, ok := err.(*terr.TodoError)
+	if !ok {
+		v = terr.InternalError()
+	}
+	return resolverError{err: v}
+}
+
+func (e resolverError) Error() string {
+	return e.err.Details()
+}
+
+func (e resolverError) Extensions() map[string]any {
+	return map[string]any{
+		"code":    e.err.HttpCode,
+		"message": e.err.Message(),
+	}
+}
@@ -0,0 +1,172 @@
+// Package ical writes todo items as an iCalendar (RFC 5545) feed and reads VTODOs back out of .ics files, so deadlines
+// can show up in calendar apps.
+//
+// Items are written as VEVENTs by default, at their due time, as most calendar apps don't show VTODOs. Either way the
+// UID is the item's id, so a subscribed calendar updates entries rather than duplicating them.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+const (
+	dateTimeLayout = "20060102T150405Z"
+	dateLayout     = "20060102"
+	//lines longer than this many bytes are folded onto the next, starting with a space
+	maxLine = 75
+	uidHost = "@todo"
+)
+
+// Component is what items are written as.
+type Component string
+
+const (
+	Event Component = "VEVENT"
+	Todo  Component = "VTODO"
+)
+
+// Write writes a calendar of the items with due dates, as components. Completed items are left out of events, a
+// deadline that's been met doesn't need to be in the way, but are kept as completed todos.
+func Write(w io.Writer, items []todoitem.TodoItem, component Component, name string, now time.Time) error {
+	due := []todoitem.TodoItem{}
+	for _, item := range items {
+		if item.Due == nil || item.Id == nil {
+			continue
+		}
+		if component == Event && completed(item) {
+			continue
+		}
+		due = append(due, item)
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Due.Before(*due[j].Due)
+	})
+
+	lw := &lineWriter{w: w}
+	lw.line("BEGIN", "VCALENDAR")
+	lw.line("VERSION", "2.0")
+	lw.line("PRODID", "-//stumacwastaken//todo//EN")
+	lw.line("CALSCALE", "GREGORIAN")
+	lw.line("METHOD", "PUBLISH")
+	lw.line("X-WR-CALNAME", escape(name))
+	for _, item := range due {
+		lw.line("BEGIN", string(component))
+		lw.line("UID", *item.Id+uidHost)
+		stamp := now
+		if item.Updated != nil {
+			stamp = *item.Updated
+		}
+		lw.line("DTSTAMP", stamp.UTC().Format(dateTimeLayout))
+		if item.Created != nil {
+			lw.line("CREATED", item.Created.UTC().Format(dateTimeLayout))
+		}
+		if item.Updated != nil {
+			lw.line("LAST-MODIFIED", item.Updated.UTC().Format(dateTimeLayout))
+		}
+		if item.Summary != nil {
+			lw.line("SUMMARY", escape(*item.Summary))
+		}
+		if component == Event {
+			//without a DTEND the event is over the moment it starts, which is what a deadline is
+			lw.line("DTSTART", item.Due.UTC().Format(dateTimeLayout))
+		} else {
+			lw.line("DUE", item.Due.UTC().Format(dateTimeLayout))
+			if completed(item) {
+				lw.line("STATUS", "COMPLETED")
+				if item.CompletedAt != nil {
+					lw.line("COMPLETED", item.CompletedAt.UTC().Format(dateTimeLayout))
+				}
+			} else {
+				lw.line("STATUS", "NEEDS-ACTION")
+			}
+		}
+		if item.Priority != nil {
+			if p := toPriority(*item.Priority); p > 0 {
+				lw.line("PRIORITY", fmt.Sprint(p))
+			}
+		}
+		if len(item.Tags) > 0 {
+			tags := []string{}
+			for _, tag := range item.Tags {
+				tags = append(tags, escape(tag))
+			}
+			lw.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		lw.line("END", string(component))
+	}
+	lw.line("END", "VCALENDAR")
+	return lw.err
+}
+
+func completed(item todoitem.TodoItem) bool {
+	return item.Completed != nil && *item.Completed
+}
+
+// lineWriter writes content lines, folded and CRLF terminated as RFC 5545 wants. It keeps the first error, so Write
+// only has to check once.
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(name, value string) {
+	if lw.err != nil {
+		return
+	}
+	_, lw.err = io.WriteString(lw.w, fold(name+":"+value))
+}
+
+// fold splits a line into 75 byte pieces, without breaking up a utf-8 character.
+func fold(line string) string {
+	b := &strings.Builder{}
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > maxLine {
+			b.WriteString("\r\n ")
+			//the space counts towards the next line
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// toPriority maps onto iCalendar's 1 (highest) to 9 (lowest), 0 being undefined.
+func toPriority(p todoitem.Priority) int {
+	switch p {
+	case todoitem.PriorityHigh:
+		return 1
+	case todoitem.PriorityMedium:
+		return 5
+	case todoitem.PriorityLow:
+		return 9
+	}
+	return 0
+}
+
+// fromPriority is the inverse of toPriority, with the ranges RFC 5545 suggests: 1-4 high, 5 medium, 6-9 low.
+func fromPriority(p int) todoitem.Priority {
+	switch {
+	case p >= 1 && p <= 4:
+		return todoitem.PriorityHigh
+	case p == 5:
+		return todoitem.PriorityMedium
+	case p >= 6 && p <= 9:
+		return todoitem.PriorityLow
+	}
+	return todoitem.PriorityNone
+}
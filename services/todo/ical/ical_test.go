@@ -0,0 +1,152 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+func newTime(t time.Time) *time.Time {
+	return &t
+}
+
+var (
+	created = time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+	due     = time.Date(2026, time.October, 20, 17, 30, 0, 0, time.UTC)
+	high    = todoitem.PriorityHigh
+	yes     = true
+)
+
+func items() []todoitem.TodoItem {
+	return []todoitem.TodoItem{
+		{Id: newString("2"), Summary: newString("no due date")},
+		{Id: newString("1"), Summary: newString("call mom; about dinner, again"), Due: &due, Priority: &high,
+			Tags: []string{"family", "a,b"}, Created: &created, Updated: &created},
+		{Id: newString("3"), Summary: newString("file taxes"), Due: newTime(due.Add(-time.Hour)), Completed: &yes,
+			CompletedAt: &created},
+	}
+}
+
+func TestWriteEvents(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Write(out, items(), Event, "todo", created)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//stumacwastaken//todo//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:todo",
+		"BEGIN:VEVENT",
+		"UID:1@todo",
+		"DTSTAMP:20261001T090000Z",
+		"CREATED:20261001T090000Z",
+		"LAST-MODIFIED:20261001T090000Z",
+		`SUMMARY:call mom\; about dinner\, again`,
+		"DTSTART:20261020T173000Z",
+		"PRIORITY:1",
+		`CATEGORIES:family,a\,b`,
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"), out.String(), "only items that are due and not done")
+}
+
+func TestWriteTodos(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Write(out, items(), Todo, "todo", created)
+	assert.Nil(t, err)
+	s := out.String()
+	assert.Equal(t, 2, strings.Count(s, "BEGIN:VTODO"))
+	assert.Less(t, strings.Index(s, "UID:3@todo"), strings.Index(s, "UID:1@todo"), "soonest due first")
+	assert.Contains(t, s, "STATUS:COMPLETED\r\nCOMPLETED:20261001T090000Z\r\n")
+	assert.Contains(t, s, "DUE:20261020T173000Z\r\nSTATUS:NEEDS-ACTION\r\n")
+
+	parsed, err := Parse(out)
+	assert.Nil(t, err)
+	if assert.Len(t, parsed, 2) {
+		assert.Equal(t, "call mom; about dinner, again", *parsed[1].Summary)
+		assert.Equal(t, []string{"family", "a,b"}, parsed[1].Tags)
+		assert.Equal(t, high, *parsed[1].Priority)
+		assert.True(t, due.Equal(*parsed[1].Due))
+		assert.True(t, *parsed[0].Completed)
+		assert.Nil(t, parsed[0].Id, "uids aren't kept")
+	}
+}
+
+func TestFold(t *testing.T) {
+	line := fold("SUMMARY:" + strings.Repeat("é", 50))
+	for _, l := range strings.Split(strings.TrimSuffix(line, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(l), maxLine)
+	}
+	lines, err := unfold(strings.NewReader(line))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"SUMMARY:" + strings.Repeat("é", 50)}, lines)
+}
+
+func TestParse(t *testing.T) {
+	type test struct {
+		name   string
+		in     string
+		expect []todoitem.TodoItem
+		err    string
+	}
+	no := false
+	low := todoitem.PriorityLow
+	ny, _ := time.LoadLocation("America/New_York")
+
+	tests := []test{
+		{
+			name: "dates and zones",
+			in: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:not a todo\nEND:VEVENT\n" +
+				"BEGIN:VTODO\nSUMMARY:a date\nDUE;VALUE=DATE:20261020\nEND:VTODO\n" +
+				"BEGIN:VTODO\nSUMMARY:new york\nDUE;TZID=America/New_York:20261020T090000\nPRIORITY:7\nEND:VTODO\n" +
+				"END:VCALENDAR\n",
+			expect: []todoitem.TodoItem{
+				{Summary: newString("a date"), Completed: &no, Due: newTime(time.Date(2026, time.October, 20, 0, 0, 0, 0, time.Local))},
+				{Summary: newString("new york"), Completed: &no, Due: newTime(time.Date(2026, time.October, 20, 9, 0, 0, 0, ny)), Priority: &low},
+			},
+		},
+		{
+			name: "alarms are someone else's",
+			in: "BEGIN:VTODO\r\nSUMMARY:call\r\n  mom\r\nDTSTART:20261020T090000Z\r\n" +
+				"BEGIN:VALARM\r\nSUMMARY:ring ring\r\nEND:VALARM\r\nEND:VTODO\r\n",
+			expect: []todoitem.TodoItem{
+				{Summary: newString("call mom"), Completed: &no, Due: newTime(time.Date(2026, time.October, 20, 9, 0, 0, 0, time.UTC))},
+			},
+		},
+		{name: "no summary", in: "BEGIN:VTODO\nDUE:20261020T090000Z\nEND:VTODO\n", err: "line 1: VTODO has no SUMMARY"},
+		{name: "no end", in: "BEGIN:VTODO\nSUMMARY:x\n", err: "line 1: VTODO has no END"},
+		{name: "bad due", in: "BEGIN:VTODO\nSUMMARY:x\nDUE:tomorrow\nEND:VTODO\n", err: "line 3: invalid DUE tomorrow"},
+		{name: "not ical", in: "hello there\n", err: "line 1: not a content line"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			res, err := Parse(strings.NewReader(tt.in))
+			if tt.err != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), tt.err)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			if assert.Len(t, res, len(tt.expect)) {
+				for i := range res {
+					assert.True(t, tt.expect[i].Due.Equal(*res[i].Due))
+					res[i].Due = tt.expect[i].Due
+				}
+			}
+			assert.Equal(t, tt.expect, res)
+		}
+		t.Run(tt.name, tf)
+	}
+}
@@ -0,0 +1,198 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// property is a content line, NAME;PARAM=VALUE:value.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the VTODOs out of a calendar, anything else in it is ignored. Items come back with their summary,
+// priority, due date, categories as tags and whether they're completed. Their UIDs aren't kept, they're new items.
+func Parse(r io.Reader) ([]todoitem.TodoItem, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	items := []todoitem.TodoItem{}
+	var item *todoitem.TodoItem
+	//depth counts components nested in a VTODO, i.e: VALARM, so their properties aren't taken as the todo's
+	depth := 0
+	started := 0
+	for i, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch {
+		case p.name == "BEGIN" && item == nil && strings.EqualFold(p.value, "VTODO"):
+			f := false
+			item = &todoitem.TodoItem{Completed: &f}
+			started = i + 1
+		case item == nil:
+		case p.name == "BEGIN":
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case p.name == "END":
+			if item.Summary == nil || strings.TrimSpace(*item.Summary) == "" {
+				return nil, fmt.Errorf("line %d: VTODO has no SUMMARY", started)
+			}
+			items = append(items, *item)
+			item = nil
+		case depth > 0:
+		default:
+			if err := apply(item, p); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+	}
+	if item != nil {
+		return nil, fmt.Errorf("line %d: VTODO has no END", started)
+	}
+	return items, nil
+}
+
+func apply(item *todoitem.TodoItem, p property) error {
+	switch p.name {
+	case "SUMMARY":
+		s := unescape(p.value)
+		item.Summary = &s
+	case "DUE":
+		t, err := parseTime(p)
+		if err != nil {
+			return err
+		}
+		item.Due = &t
+	case "DTSTART":
+		//only a fallback, DUE is the deadline
+		if item.Due == nil {
+			t, err := parseTime(p)
+			if err != nil {
+				return err
+			}
+			item.Due = &t
+		}
+	case "PRIORITY":
+		n, err := strconv.Atoi(p.value)
+		if err != nil {
+			return fmt.Errorf("invalid PRIORITY %s", p.value)
+		}
+		priority := fromPriority(n)
+		item.Priority = &priority
+	case "CATEGORIES":
+		for _, tag := range splitList(p.value) {
+			if tag != "" {
+				item.Tags = append(item.Tags, tag)
+			}
+		}
+	case "STATUS":
+		done := strings.EqualFold(p.value, "COMPLETED")
+		item.Completed = &done
+	case "COMPLETED":
+		done := true
+		item.Completed = &done
+	}
+	return nil
+}
+
+// unfold reads content lines, joining folded ones back together.
+func unfold(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseProperty(line string) (property, error) {
+	p := property{params: map[string]string{}}
+	//the value starts at the first colon that isn't in a quoted param
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return p, fmt.Errorf("not a content line: %s", line)
+	}
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return p, nil
+}
+
+// parseTime takes a DATE, a UTC DATE-TIME, or a local one in its TZID (or ours if there isn't one).
+func parseTime(p property) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(p.value, "Z") {
+		loc = time.UTC
+	} else if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	for _, layout := range []string{dateTimeLayout, "20060102T150405", dateLayout} {
+		if t, err := time.ParseInLocation(layout, p.value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %s", p.name, p.value)
+}
+
+// splitList splits a TEXT list on commas that aren't escaped, unescaping each value.
+func splitList(v string) []string {
+	values := []string{}
+	current := &strings.Builder{}
+	escaped := false
+	for _, r := range v {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			values = append(values, unescape(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(values, unescape(current.String()))
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
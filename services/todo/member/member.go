@@ -0,0 +1,404 @@
+// Package member is sharing lists. A user's list is everything they own, and they can invite other users to it as
+// viewers, editors or owners. What each role can do to items is enforced by todoitem.Core, this package only keeps
+// track of who has which.
+package member
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// Storer keeps every read and write to the workspace in ctx, see auth.Workspace.
+type Storer interface {
+	// Create saves an invitation, the store gives out the id.
+	Create(context.Context, Member) (Member, error)
+	GetById(context.Context, string) (Member, error)
+	// Get is a user's membership of a list, 404 if they've never been invited to it.
+	Get(ctx context.Context, listId, userId string) (Member, error)
+	// GetByEmail is the invitation of an email to a list, whether or not anyone has registered with it. 404 if there
+	// isn't one.
+	GetByEmail(ctx context.Context, listId, email string) (Member, error)
+	// Claim gives the invitations sent to an email before anyone had registered with it to the user who now has.
+	Claim(ctx context.Context, email, userId string) error
+	// GetByList is everyone a list has been shared with, pending invitations included.
+	GetByList(ctx context.Context, listId string) ([]Member, error)
+	// GetByUser is every list a user has been invited to, pending invitations included.
+	GetByUser(ctx context.Context, userId string) ([]Member, error)
+	// Update saves a member's role and when they accepted.
+	Update(context.Context, Member) (Member, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Users finds who's being invited, see user.Core.
+type Users interface {
+	GetById(ctx context.Context, id string) (user.User, error)
+	GetByEmail(ctx context.Context, email string) (user.User, error)
+}
+
+type Core struct {
+	storer Storer
+	users  Users
+}
+
+func NewCore(storer Storer, users Users) *Core {
+	return &Core{
+		storer: storer,
+		users:  users,
+	}
+}
+
+// Me can be given as a list id for the list of whoever is asking.
+const Me = "me"
+
+var nowFn = time.Now
+
+// Role is what a user can do with a list. Their own list they own, anyone else's is whatever role they've accepted,
+// or "" if they can't see it at all.
+func (c *Core) Role(ctx context.Context, listId, userId string) (Role, error) {
+	if listId == userId {
+		return RoleOwner, nil
+	}
+	m, err := c.storer.Get(ctx, listId, userId)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", asTodoError(err)
+	}
+	if m.Accepted == nil {
+		return "", nil
+	}
+	return *m.Role, nil
+}
+
+// Lists is the ids of every list a user can see, their own first.
+func (c *Core) Lists(ctx context.Context, userId string) ([]string, error) {
+	members, err := c.storer.GetByUser(ctx, userId)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	lists := []string{userId}
+	for _, m := range members {
+		if m.Accepted != nil {
+			lists = append(lists, *m.ListId)
+		}
+	}
+	return lists, nil
+}
+
+// Invite shares a list with another user, by email. It needs an owner of the list, the list's roles are what decide
+// who hands out access to it. Roles default to viewer. An email nobody has registered with yet gets an invitation
+// that waits for them, which looks the same as inviting someone who has, so inviting people can't be used to find out
+// who has an account.
+func (c *Core) Invite(ctx context.Context, listId string, m Member) (Member, error) {
+	userId, err := caller(ctx, auth.ScopeWrite)
+	if err != nil {
+		return Member{}, err
+	}
+	listId = list(listId, userId)
+	if err := c.require(ctx, listId, userId, RoleOwner); err != nil {
+		return Member{}, err
+	}
+	if m.Id != nil {
+		return Member{}, terr.ErrorWithCode("invalid param", "cannot invite with an id", 400)
+	}
+	if m.Email == nil || strings.TrimSpace(*m.Email) == "" {
+		return Member{}, terr.ErrorWithCode("invalid param", "email cannot be empty", 400)
+	}
+	if m.Role == nil {
+		viewer := RoleViewer
+		m.Role = &viewer
+	}
+	if err := validateRole(*m.Role); err != nil {
+		return Member{}, err
+	}
+	email := strings.ToLower(strings.TrimSpace(*m.Email))
+	var inviteeId *string
+	invitee, err := c.users.GetByEmail(ctx, email)
+	switch {
+	//users in other workspaces are the same as users that don't exist, so nothing leaks across them
+	case err == nil && inWorkspace(ctx, invitee):
+		if *invitee.Id == listId {
+			return Member{}, terr.ErrorWithCode("invalid param", "a list can't be shared with the user it belongs to", 400)
+		}
+		inviteeId = invitee.Id
+	case err == nil || isNotFound(err):
+		//the invitation waits for whoever registers with the email
+	default:
+		return Member{}, asTodoError(err)
+	}
+	if _, err := c.storer.GetByEmail(ctx, listId, email); err == nil {
+		return Member{}, terr.ErrorWithCode("conflict", fmt.Sprintf("%s has already been invited", email), 409)
+	} else if !isNotFound(err) {
+		return Member{}, asTodoError(err)
+	}
+	created, err := c.storer.Create(ctx, Member{
+		ListId:    &listId,
+		UserId:    inviteeId,
+		Email:     &email,
+		Role:      m.Role,
+		InvitedBy: &userId,
+	})
+	if err != nil {
+		return Member{}, asTodoError(err)
+	}
+	return pending(created), nil
+}
+
+// Members is everyone a list is shared with, invitations that haven't been accepted included. Anyone who can see the
+// list can see who else can.
+func (c *Core) Members(ctx context.Context, listId string) ([]Member, error) {
+	userId, err := caller(ctx, auth.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	listId = list(listId, userId)
+	if err := c.require(ctx, listId, userId, RoleViewer); err != nil {
+		return nil, err
+	}
+	members, err := c.storer.GetByList(ctx, listId)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	for i := range members {
+		members[i] = pending(members[i])
+	}
+	return members, nil
+}
+
+// Update changes a member's role. Only the role is looked at.
+func (c *Core) Update(ctx context.Context, listId, id string, m Member) (Member, error) {
+	userId, err := caller(ctx, auth.ScopeWrite)
+	if err != nil {
+		return Member{}, err
+	}
+	listId = list(listId, userId)
+	if err := c.require(ctx, listId, userId, RoleOwner); err != nil {
+		return Member{}, err
+	}
+	if m.Role == nil {
+		return Member{}, terr.ErrorWithCode("invalid param", "role cannot be empty", 400)
+	}
+	if err := validateRole(*m.Role); err != nil {
+		return Member{}, err
+	}
+	existing, err := c.member(ctx, listId, id)
+	if err != nil {
+		return Member{}, err
+	}
+	existing.Role = m.Role
+	updated, err := c.storer.Update(ctx, existing)
+	if err != nil {
+		return Member{}, asTodoError(err)
+	}
+	return pending(updated), nil
+}
+
+// Remove stops sharing a list with someone, whether or not they'd accepted. Owners can remove anyone, and members can
+// always remove themselves.
+func (c *Core) Remove(ctx context.Context, listId, id string) (Member, error) {
+	userId, err := caller(ctx, auth.ScopeWrite)
+	if err != nil {
+		return Member{}, err
+	}
+	listId = list(listId, userId)
+	if err := c.require(ctx, listId, userId, RoleViewer); err != nil {
+		return Member{}, err
+	}
+	existing, err := c.member(ctx, listId, id)
+	if err != nil {
+		return Member{}, err
+	}
+	if existing.UserId == nil || *existing.UserId != userId {
+		if err := c.require(ctx, listId, userId, RoleOwner); err != nil {
+			return Member{}, err
+		}
+	}
+	if err := c.storer.Delete(ctx, id); err != nil {
+		return Member{}, asTodoError(err)
+	}
+	return pending(existing), nil
+}
+
+// Invitations is every list shared with whoever is asking, with the ones they haven't accepted yet.
+func (c *Core) Invitations(ctx context.Context) ([]Member, error) {
+	userId, err := caller(ctx, auth.ScopeRead)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.claim(ctx, userId); err != nil {
+		return nil, err
+	}
+	members, err := c.storer.GetByUser(ctx, userId)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	return members, nil
+}
+
+// Accept gives whoever is asking the access they were invited with. Accepting again leaves it as it was.
+func (c *Core) Accept(ctx context.Context, id string) (Member, error) {
+	m, err := c.invitation(ctx, id)
+	if err != nil {
+		return Member{}, err
+	}
+	if m.Accepted != nil {
+		return m, nil
+	}
+	now := nowFn()
+	m.Accepted = &now
+	accepted, err := c.storer.Update(ctx, m)
+	if err != nil {
+		return Member{}, asTodoError(err)
+	}
+	return accepted, nil
+}
+
+// Decline turns an invitation down, or leaves a list that was accepted before.
+func (c *Core) Decline(ctx context.Context, id string) (Member, error) {
+	m, err := c.invitation(ctx, id)
+	if err != nil {
+		return Member{}, err
+	}
+	if err := c.storer.Delete(ctx, id); err != nil {
+		return Member{}, asTodoError(err)
+	}
+	return m, nil
+}
+
+// require checks a user can do what role can with a list. A list they can't see at all is a 404, the same as one
+// that doesn't exist.
+func (c *Core) require(ctx context.Context, listId, userId string, role Role) error {
+	has, err := c.Role(ctx, listId, userId)
+	if err != nil {
+		return err
+	}
+	if has == "" {
+		return terr.ErrorWithCode("not found", fmt.Sprintf("List with id %s not found", listId), 404)
+	}
+	if !has.Allows(role) {
+		return Forbidden(has, role)
+	}
+	return nil
+}
+
+// member is a membership of the list, anything else is a 404.
+func (c *Core) member(ctx context.Context, listId, id string) (Member, error) {
+	m, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return Member{}, asTodoError(err)
+	}
+	if *m.ListId != listId {
+		return Member{}, notFound(id)
+	}
+	return m, nil
+}
+
+// invitation is one of the caller's own invitations, anyone else's is a 404.
+func (c *Core) invitation(ctx context.Context, id string) (Member, error) {
+	userId, err := caller(ctx, auth.ScopeWrite)
+	if err != nil {
+		return Member{}, err
+	}
+	if err := c.claim(ctx, userId); err != nil {
+		return Member{}, err
+	}
+	m, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return Member{}, asTodoError(err)
+	}
+	if m.UserId == nil || *m.UserId != userId {
+		return Member{}, notFound(id)
+	}
+	return m, nil
+}
+
+// claim gives a user the invitations that were sent to their email before they registered with it.
+func (c *Core) claim(ctx context.Context, userId string) error {
+	u, err := c.users.GetById(ctx, userId)
+	if err != nil {
+		//someone signed in with a jwt from another provider needn't be one of our users, so nobody could invite them
+		if isNotFound(err) {
+			return nil
+		}
+		return asTodoError(err)
+	}
+	if u.Email == nil || !inWorkspace(ctx, u) {
+		return nil
+	}
+	if err := c.storer.Claim(ctx, *u.Email, userId); err != nil {
+		return asTodoError(err)
+	}
+	return nil
+}
+
+// pending hides who an invitation that hasn't been accepted is for, since whether it has a user says whether anyone
+// has registered with the email.
+func pending(m Member) Member {
+	if m.Accepted == nil {
+		m.UserId = nil
+	}
+	return m
+}
+
+// Forbidden is the 403 for a member trying to do more than their role lets them.
+func Forbidden(has, needs Role) error {
+	return terr.ErrorWithCode("forbidden", fmt.Sprintf("this needs a list %s, you're a %s", needs, has), 403)
+}
+
+// caller is the id of whoever is asking. Lists belong to someone, so unscoped requests can't share them.
+func caller(ctx context.Context, scope string) (string, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", terr.ErrorWithCode("unauthorized", "log in to share lists", 401)
+	}
+	if err := auth.Require(ctx, scope); err != nil {
+		return "", err
+	}
+	return p.UserId, nil
+}
+
+func list(listId, userId string) string {
+	if listId == Me {
+		return userId
+	}
+	return listId
+}
+
+func validateRole(r Role) error {
+	if r.Rank() < 0 {
+		return terr.ErrorWithCode("invalid param", fmt.Sprintf("unknown role %s, use viewer, editor or owner", r), 400)
+	}
+	return nil
+}
+
+// inWorkspace is whether u belongs to the caller's workspace. Users from before workspaces are in the default one.
+func inWorkspace(ctx context.Context, u user.User) bool {
+	ws := auth.DefaultWorkspace
+	if u.WorkspaceId != nil && *u.WorkspaceId != "" {
+		ws = *u.WorkspaceId
+	}
+	return ws == auth.Workspace(ctx)
+}
+
+func isNotFound(err error) bool {
+	v, ok := err.(*terr.TodoError)
+	return ok && v.HttpCode == 404
+}
+
+func notFound(id string) error {
+	return terr.ErrorWithCode("not found", fmt.Sprintf("Member with id %s not found", id), 404)
+}
+
+func asTodoError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
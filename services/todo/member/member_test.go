@@ -0,0 +1,267 @@
+package member
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// mapStorer is the smallest store that does the job. memdb has a real one, but it imports this package.
+type mapStorer struct {
+	members map[string]Member
+	next    int
+}
+
+func (s *mapStorer) Create(ctx context.Context, m Member) (Member, error) {
+	s.next++
+	id := string(rune('0' + s.next))
+	m.Id = &id
+	s.members[id] = m
+	return m, nil
+}
+
+func (s *mapStorer) GetById(ctx context.Context, id string) (Member, error) {
+	m, ok := s.members[id]
+	if !ok {
+		return Member{}, notFound(id)
+	}
+	return m, nil
+}
+
+func (s *mapStorer) Get(ctx context.Context, listId, userId string) (Member, error) {
+	for _, m := range s.members {
+		if *m.ListId == listId && m.UserId != nil && *m.UserId == userId {
+			return m, nil
+		}
+	}
+	return Member{}, terr.ErrorWithCode("not found", "Member not found", 404)
+}
+
+func (s *mapStorer) GetByEmail(ctx context.Context, listId, email string) (Member, error) {
+	for _, m := range s.members {
+		if *m.ListId == listId && *m.Email == email {
+			return m, nil
+		}
+	}
+	return Member{}, terr.ErrorWithCode("not found", "Member not found", 404)
+}
+
+func (s *mapStorer) GetByList(ctx context.Context, listId string) ([]Member, error) {
+	res := []Member{}
+	for _, m := range s.members {
+		if *m.ListId == listId {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (s *mapStorer) GetByUser(ctx context.Context, userId string) ([]Member, error) {
+	res := []Member{}
+	for _, m := range s.members {
+		if m.UserId != nil && *m.UserId == userId {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (s *mapStorer) Update(ctx context.Context, m Member) (Member, error) {
+	s.members[*m.Id] = m
+	return m, nil
+}
+
+func (s *mapStorer) Claim(ctx context.Context, email, userId string) error {
+	for id, m := range s.members {
+		if m.UserId == nil && *m.Email == email {
+			m.UserId = &userId
+			s.members[id] = m
+		}
+	}
+	return nil
+}
+
+func (s *mapStorer) Delete(ctx context.Context, id string) error {
+	delete(s.members, id)
+	return nil
+}
+
+// users is everyone registered by their id, with their email the id @example.com. kim is in the ops workspace,
+// everyone else in the default one.
+type users map[string]bool
+
+func newUsers() users {
+	return users{"sam": true, "alex": true, "jo": true, "kim": true}
+}
+
+func (u users) GetById(ctx context.Context, id string) (user.User, error) {
+	if !u[id] {
+		return user.User{}, terr.ErrorWithCode("not found", "User not found", 404)
+	}
+	workspace := auth.DefaultWorkspace
+	if id == "kim" {
+		workspace = "ops"
+	}
+	return user.User{Id: newString(id), Email: newString(id + "@example.com"), WorkspaceId: &workspace}, nil
+}
+
+func (u users) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	return u.GetById(ctx, strings.TrimSuffix(email, "@example.com"))
+}
+
+func as(userId string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserId: userId})
+}
+
+func newString(s string) *string {
+	return &s
+}
+
+func newRole(r Role) *Role {
+	return &r
+}
+
+func TestRoleAllows(t *testing.T) {
+	assert.True(t, RoleOwner.Allows(RoleEditor))
+	assert.True(t, RoleEditor.Allows(RoleEditor))
+	assert.False(t, RoleViewer.Allows(RoleEditor))
+	assert.False(t, Role("").Allows(RoleViewer), "no role allows nothing")
+}
+
+func TestInvite(t *testing.T) {
+	core := NewCore(&mapStorer{members: map[string]Member{}}, newUsers())
+	sam := as("sam")
+	type test struct {
+		name   string
+		ctx    context.Context
+		listId string
+		member Member
+		err    error
+	}
+	tests := []test{
+		{name: "happy path", ctx: sam, listId: Me, member: Member{Email: newString("alex@example.com"), Role: newRole(RoleEditor)}},
+		{name: "already invited", ctx: sam, listId: "sam", member: Member{Email: newString("alex@example.com")},
+			err: terr.ErrorWithCode("conflict", "alex@example.com has already been invited", 409)},
+		{name: "nobody has registered with the email", ctx: sam, listId: Me, member: Member{Email: newString("nobody@example.com")}},
+		{name: "someone in another workspace", ctx: sam, listId: Me, member: Member{Email: newString(" Kim@Example.com")}},
+		{name: "invited before registering", ctx: sam, listId: Me, member: Member{Email: newString("nobody@example.com")},
+			err: terr.ErrorWithCode("conflict", "nobody@example.com has already been invited", 409)},
+		{name: "yourself", ctx: sam, listId: Me, member: Member{Email: newString("sam@example.com")},
+			err: terr.ErrorWithCode("invalid param", "a list can't be shared with the user it belongs to", 400)},
+		{name: "unknown role", ctx: sam, listId: Me, member: Member{Email: newString("jo@example.com"), Role: newRole("boss")},
+			err: terr.ErrorWithCode("invalid param", "unknown role boss, use viewer, editor or owner", 400)},
+		{name: "someone else's list", ctx: sam, listId: "jo", member: Member{Email: newString("alex@example.com")},
+			err: terr.ErrorWithCode("not found", "List with id jo not found", 404)},
+		{name: "not logged in", ctx: context.Background(), listId: "sam", member: Member{Email: newString("jo@example.com")},
+			err: terr.ErrorWithCode("unauthorized", "log in to share lists", 401)},
+		{name: "a write token is enough", ctx: auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam", Scope: auth.ScopeWrite}),
+			listId: Me, member: Member{Email: newString("jo@example.com")}},
+		{name: "a read token isn't", ctx: auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam", Scope: auth.ScopeRead}),
+			listId: Me, member: Member{Email: newString("jo@example.com")},
+			err: terr.ErrorWithCode("forbidden", "this needs the write scope, the token only has read", 403)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			m, err := core.Invite(tt.ctx, tt.listId, tt.member)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, "sam", *m.ListId)
+				assert.Equal(t, strings.ToLower(strings.TrimSpace(*tt.member.Email)), *m.Email)
+				assert.Nil(t, m.UserId, "whether anyone has the email doesn't show")
+				assert.Equal(t, "sam", *m.InvitedBy)
+				assert.Nil(t, m.Accepted)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestSharing(t *testing.T) {
+	ctx := context.Background()
+	core := NewCore(&mapStorer{members: map[string]Member{}}, newUsers())
+	sam, alex, jo := as("sam"), as("alex"), as("jo")
+
+	invite, err := core.Invite(sam, Me, Member{Email: newString("alex@example.com"), Role: newRole(RoleOwner)})
+	assert.Nil(t, err)
+	role, _ := core.Role(ctx, "sam", "alex")
+	assert.Equal(t, Role(""), role, "nothing until it's accepted")
+	_, err = core.Accept(jo, *invite.Id)
+	assert.Equal(t, notFound(*invite.Id), err, "only the invitee can accept")
+
+	_, err = core.Accept(alex, *invite.Id)
+	assert.Nil(t, err)
+	role, _ = core.Role(ctx, "sam", "alex")
+	assert.Equal(t, RoleOwner, role)
+	lists, _ := core.Lists(ctx, "alex")
+	assert.Equal(t, []string{"alex", "sam"}, lists)
+
+	//a list owner can share it on, the new member can only look
+	joins, err := core.Invite(alex, "sam", Member{Email: newString("jo@example.com")})
+	assert.Nil(t, err)
+	_, err = core.Accept(jo, *joins.Id)
+	assert.Nil(t, err)
+	members, err := core.Members(jo, "sam")
+	assert.Nil(t, err)
+	assert.Len(t, members, 2)
+	_, err = core.Update(jo, "sam", *invite.Id, Member{Role: newRole(RoleViewer)})
+	assert.Equal(t, Forbidden(RoleViewer, RoleOwner), err)
+	_, err = core.Remove(jo, "sam", *invite.Id)
+	assert.Equal(t, Forbidden(RoleViewer, RoleOwner), err)
+
+	updated, err := core.Update(sam, Me, *joins.Id, Member{Role: newRole(RoleEditor)})
+	assert.Nil(t, err)
+	assert.Equal(t, RoleEditor, *updated.Role)
+	_, err = core.Update(sam, Me, "nope", Member{Role: newRole(RoleEditor)})
+	assert.Equal(t, notFound("nope"), err)
+
+	//members can always leave
+	_, err = core.Remove(jo, "sam", *joins.Id)
+	assert.Nil(t, err)
+	_, err = core.Members(jo, "sam")
+	assert.Equal(t, terr.ErrorWithCode("not found", "List with id sam not found", 404), err)
+
+	_, err = core.Decline(alex, *invite.Id)
+	assert.Nil(t, err)
+	invitations, _ := core.Invitations(alex)
+	assert.Empty(t, invitations)
+}
+
+func TestInviteBeforeRegistering(t *testing.T) {
+	registered := newUsers()
+	core := NewCore(&mapStorer{members: map[string]Member{}}, registered)
+	sam, lee := as("sam"), as("lee")
+
+	invite, err := core.Invite(sam, Me, Member{Email: newString("lee@example.com"), Role: newRole(RoleEditor)})
+	assert.Nil(t, err)
+	alex, err := core.Invite(sam, Me, Member{Email: newString("alex@example.com"), Role: newRole(RoleEditor)})
+	assert.Nil(t, err)
+	assert.Equal(t, pending(alex), alex, "someone who's registered looks the same as someone who hasn't")
+	members, _ := core.Members(sam, Me)
+	for _, m := range members {
+		assert.Nil(t, m.UserId)
+	}
+
+	_, err = core.Accept(lee, *invite.Id)
+	assert.Equal(t, notFound(*invite.Id), err, "nobody has the email yet")
+	registered["lee"] = true
+	invitations, err := core.Invitations(lee)
+	assert.Nil(t, err)
+	if assert.Len(t, invitations, 1) {
+		assert.Equal(t, "lee", *invitations[0].UserId)
+	}
+	_, err = core.Accept(lee, *invite.Id)
+	assert.Nil(t, err)
+	role, _ := core.Role(context.Background(), "sam", "lee")
+	assert.Equal(t, RoleEditor, role)
+	members, _ = core.Members(sam, Me)
+	for _, m := range members {
+		if *m.Id == *invite.Id {
+			assert.Equal(t, "lee", *m.UserId, "members show up once they've accepted")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package member
+
+import "time"
+
+// Role is what a member can do with a list. Every role can do what the ones before it can.
+type Role string
+
+const (
+	//RoleViewer can read the list's items
+	RoleViewer Role = "viewer"
+	//RoleEditor can also create, change and delete them
+	RoleEditor Role = "editor"
+	//RoleOwner can also invite people, change their roles and remove them
+	RoleOwner Role = "owner"
+)
+
+// ordered least to most. The zero value, "", is no role at all.
+var roles = []Role{RoleViewer, RoleEditor, RoleOwner}
+
+// Rank is the position of the role from least to most, -1 if it isn't a role.
+func (r Role) Rank() int {
+	for i, v := range roles {
+		if v == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Allows reports if r can do what the given role can.
+func (r Role) Allows(role Role) bool {
+	return r.Rank() >= 0 && r.Rank() >= role.Rank()
+}
+
+// Member is someone a list has been shared with. A list is everything a user owns, so its id is the id of the user
+// whose list it is. They're invited first, and only get access once they've accepted.
+type Member struct {
+	Id     *string `json:"id,omitempty"`
+	ListId *string `json:"listId,omitempty"`
+	//UserId is who was invited. It's left out of the list's members until they accept, and the store doesn't have one
+	//until someone has registered with the email
+	UserId *string `json:"userId,omitempty"`
+	//Email is who to invite, or was invited
+	Email *string `json:"email,omitempty"`
+	Role  *Role   `json:"role,omitempty"`
+	//InvitedBy is the user who sent the invitation
+	InvitedBy *string    `json:"invitedBy,omitempty"`
+	Created   *time.Time `json:"created,omitempty"`
+	//Accepted is when the invitation was accepted, nil while it's pending
+	Accepted *time.Time `json:"accepted,omitempty"`
+}
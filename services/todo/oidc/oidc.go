@@ -0,0 +1,231 @@
+// Package oidc logs people in through an OpenID Connect identity provider, with the authorization code flow and PKCE.
+// It only speaks the protocol: keeping a Flow between the redirect and the callback, and what to hand out once
+// someone's logged in, are left to the caller (see rest.OIDCHandlers).
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/user"
+	"go.uber.org/zap"
+)
+
+// Config is the client registered with the identity provider.
+type Config struct {
+	//Issuer is the provider's issuer url. Everything else about it is found from <issuer>/.well-known/openid-configuration
+	Issuer   string
+	ClientId string
+	//ClientSecret is sent with client_secret_basic. Public clients can leave it empty and rely on PKCE.
+	ClientSecret string
+	//RedirectURL is our callback, exactly as it's registered with the provider
+	RedirectURL string
+	//Scopes default to openid, email and profile
+	Scopes []string
+	//EmailClaim and NameClaim are the id token claims for the user's email and name, email and name by default
+	EmailClaim string
+	NameClaim  string
+}
+
+// Provider is an identity provider we've found the endpoints of.
+type Provider struct {
+	cfg      Config
+	authURL  string
+	tokenURL string
+	ids      *auth.JWT
+	client   *http.Client
+}
+
+type discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// NewProvider reads the provider's discovery document, so a misconfigured provider fails at startup rather than on
+// the first login.
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientId == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("an oidc issuer, client id and redirect url are all needed")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if cfg.EmailClaim == "" {
+		cfg.EmailClaim = "email"
+	}
+	if cfg.NameClaim == "" {
+		cfg.NameClaim = "name"
+	}
+	p := &Provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+	var d discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(cfg.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("reading oidc discovery: %w", err)
+	}
+	//the spec has them match exactly, otherwise id tokens would be checked against the wrong issuer
+	if d.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery is for issuer %s, not %s", d.Issuer, cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery is missing the authorization, token or jwks endpoint")
+	}
+	if len(d.CodeChallengeMethods) > 0 && !contains(d.CodeChallengeMethods, "S256") {
+		return nil, fmt.Errorf("oidc provider doesn't support S256 PKCE")
+	}
+	ids, err := auth.NewJWT(ctx, auth.JWTConfig{JWKS: d.JWKSURI, Issuer: d.Issuer, Audience: cfg.ClientId, Leeway: time.Minute})
+	if err != nil {
+		return nil, err
+	}
+	p.authURL, p.tokenURL, p.ids = d.AuthorizationEndpoint, d.TokenEndpoint, ids
+	return p, nil
+}
+
+// RedirectURL is where the provider sends people back to.
+func (p *Provider) RedirectURL() string {
+	return p.cfg.RedirectURL
+}
+
+// Flow is one login in progress. State and Nonce tie the callback and id token to it, Verifier is the PKCE secret.
+// None of it is sent anywhere but the provider, so the caller has to keep it somewhere only the user's browser has.
+type Flow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func NewFlow() (Flow, error) {
+	var f Flow
+	for _, v := range []*string{&f.State, &f.Nonce, &f.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Flow{}, err
+		}
+		*v = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return f, nil
+}
+
+// AuthCodeURL is where to send someone to log in.
+func (p *Provider) AuthCodeURL(f Flow) string {
+	challenge := sha256.Sum256([]byte(f.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientId},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {f.State},
+		"nonce":                 {f.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + q.Encode()
+}
+
+// Exchange swaps the code from the callback for an id token, checks it, and returns who it's for. The callback's
+// state has to have been checked against the flow already.
+func (p *Provider) Exchange(ctx context.Context, f Flow, code string) (user.External, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientId},
+		"code_verifier": {f.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return user.External{}, terr.InternalError()
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientId), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	var res struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &res)
+	if err != nil {
+		log.Default().Warn("oidc token request failed", zap.Error(err))
+		return user.External{}, terr.ErrorWithCode("bad gateway", "couldn't reach the identity provider", 502)
+	}
+	if status != http.StatusOK || res.IdToken == "" {
+		return user.External{}, loginFailed(fmt.Sprintf("the identity provider refused the code: %s %s", res.Error, res.ErrorDescription))
+	}
+	claims, err := p.ids.Claims(ctx, res.IdToken)
+	if err != nil {
+		return user.External{}, loginFailed(err.(*terr.TodoError).Details())
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != f.Nonce {
+		return user.External{}, loginFailed("the id token's nonce doesn't match")
+	}
+	iss, _ := claims["iss"].(string)
+	sub, _ := claims["sub"].(string)
+	email, _ := claims[p.cfg.EmailClaim].(string)
+	name, _ := claims[p.cfg.NameClaim].(string)
+	verified, _ := claims["email_verified"].(bool)
+	return user.External{Id: iss + " " + sub, Email: email, EmailVerified: verified, Name: name}, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	status, err := p.do(req, out)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s answered %d", u, status)
+	}
+	return nil
+}
+
+func (p *Provider) do(req *http.Request, out any) (int, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(b, out); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+func loginFailed(reason string) error {
+	return terr.ErrorWithCode("unauthorized", fmt.Sprintf("login failed: %s", reason), 401)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
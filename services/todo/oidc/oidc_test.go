@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/oidc/oidctest"
+	"github.com/stumacwastaken/todo/user"
+)
+
+func newProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	idp, err := oidctest.NewServer("todo", "shh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	p, err := NewProvider(context.Background(), Config{Issuer: idp.URL, ClientId: "todo", ClientSecret: "shh",
+		RedirectURL: "http://localhost:9000/api/oidc/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, idp
+}
+
+// login goes through the provider, returning the code from the callback.
+func login(t *testing.T, p *Provider, idp *oidctest.Server, f Flow, claims map[string]any) string {
+	callback, err := idp.Authorize(p.AuthCodeURL(f), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(callback)
+	assert.Equal(t, f.State, u.Query().Get("state"))
+	return u.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+	p, idp := newProvider(t)
+	sam := map[string]any{"sub": "123", "email": "sam@example.com", "email_verified": true, "name": "Sam"}
+	type test struct {
+		name string
+		//change what's sent back from what the login was started with
+		change func(f *Flow)
+		err    string
+	}
+	tests := []test{
+		{name: "happy path"},
+		{name: "wrong verifier", change: func(f *Flow) { f.Verifier = "guess" }, err: "code verifier doesn't match the challenge"},
+		{name: "wrong nonce", change: func(f *Flow) { f.Nonce = "replayed" }, err: "the id token's nonce doesn't match"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			f, err := NewFlow()
+			assert.Nil(t, err)
+			code := login(t, p, idp, f, sam)
+			if tt.change != nil {
+				tt.change(&f)
+			}
+			ext, err := p.Exchange(ctx, f, code)
+			if tt.err != "" {
+				if assert.NotNil(t, err) {
+					assert.Equal(t, 401, err.(*terr.TodoError).HttpCode)
+					assert.Contains(t, err.(*terr.TodoError).Details(), tt.err)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, user.External{Id: idp.URL + " 123", Email: "sam@example.com", EmailVerified: true, Name: "Sam"}, ext)
+
+			_, err = p.Exchange(ctx, f, code)
+			assert.Contains(t, err.(*terr.TodoError).Details(), "unknown code", "codes only work once")
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestWrongClientSecret(t *testing.T) {
+	p, idp := newProvider(t)
+	p.cfg.ClientSecret = "guess"
+	f, _ := NewFlow()
+	_, err := p.Exchange(context.Background(), f, login(t, p, idp, f, map[string]any{"sub": "123"}))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "login failed: the identity provider refused the code: invalid_client ", err.(*terr.TodoError).Details())
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	idp, err := oidctest.NewServer("todo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idp.Close()
+	ctx := context.Background()
+	_, err = NewProvider(ctx, Config{Issuer: idp.URL})
+	assert.EqualError(t, err, "an oidc issuer, client id and redirect url are all needed")
+	_, err = NewProvider(ctx, Config{Issuer: idp.URL + "/", ClientId: "todo", RedirectURL: "http://localhost/cb"})
+	assert.EqualError(t, err, "oidc discovery is for issuer "+idp.URL+", not "+idp.URL+"/")
+
+	p, err := NewProvider(ctx, Config{Issuer: idp.URL, ClientId: "todo", RedirectURL: "http://localhost/cb"})
+	assert.Nil(t, err)
+	f, _ := NewFlow()
+	u, _ := url.Parse(p.AuthCodeURL(f))
+	assert.Equal(t, idp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+	assert.NotEqual(t, f.Verifier, u.Query().Get("code_challenge"), "only the challenge is sent")
+}
@@ -0,0 +1,155 @@
+// Package oidctest is a stand-in OpenID Connect provider for tests. It does the provider's half of the authorization
+// code flow with PKCE, without a login page: Authorize plays the part of someone logging in.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Server is the provider. Its URL is the issuer.
+type Server struct {
+	*httptest.Server
+	ClientId     string
+	ClientSecret string
+	key          *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant is what a code was given out for.
+type grant struct {
+	redirect  string
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+// NewServer starts a provider for one client. Close it when done.
+func NewServer(clientId, clientSecret string) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{ClientId: clientId, ClientSecret: clientSecret, key: key, codes: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+// Authorize logs someone in with the given claims (sub, email and so on) at an authorization url from the client,
+// returning the callback url the provider would redirect them to.
+func (s *Server) Authorize(authURL string, claims map[string]any) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if q.Get("client_id") != s.ClientId || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		return "", fmt.Errorf("not an authorization code request with PKCE for %s: %s", s.ClientId, authURL)
+	}
+	code := random()
+	s.mu.Lock()
+	s.codes[code] = grant{redirect: q.Get("redirect_uri"), challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), claims: claims}
+	s.mu.Unlock()
+	callback, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return "", err
+	}
+	cq := callback.Query()
+	cq.Set("code", code)
+	cq.Set("state", q.Get("state"))
+	callback.RawQuery = cq.Encode()
+	return callback.String(), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]any{
+		"issuer":                           s.URL,
+		"authorization_endpoint":           s.URL + "/authorize",
+		"token_endpoint":                   s.URL + "/token",
+		"jwks_uri":                         s.URL + "/jwks",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA", "kid": "test", "use": "sig", "alg": "RS256",
+		"n": base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}}})
+}
+
+// token checks the code, client and PKCE verifier the way a real provider would, then hands out an id token.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	refuse := func(reason string) {
+		writeJSON(w, 400, map[string]string{"error": "invalid_grant", "error_description": reason})
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		refuse("not an authorization code grant")
+		return
+	}
+	if id, secret, _ := r.BasicAuth(); s.ClientSecret != "" && (id != s.ClientId || secret != s.ClientSecret) {
+		writeJSON(w, 401, map[string]string{"error": "invalid_client"})
+		return
+	}
+	s.mu.Lock()
+	g, ok := s.codes[r.PostForm.Get("code")]
+	//codes can only be used once
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	if !ok {
+		refuse("unknown code")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		refuse("code verifier doesn't match the challenge")
+		return
+	}
+	if r.PostForm.Get("redirect_uri") != g.redirect {
+		refuse("redirect uri doesn't match")
+		return
+	}
+	claims := jwt.MapClaims{"iss": s.URL, "aud": s.ClientId, "nonce": g.nonce, "iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "test"
+	signed, err := tok.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, 500, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, 200, map[string]any{"access_token": random(), "token_type": "Bearer", "id_token": signed, "expires_in": 3600})
+}
+
+func random() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,134 @@
+// Package outbox relays todo item events that were saved in the same transaction as the change that caused them.
+// Nothing is lost if the process dies between committing a change and telling anyone about it, the relay just picks
+// the event up again on the next start. Every event is sent at least once, so sinks pass the message id along for
+// whatever is downstream to drop repeats with.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// Message is an event read back out of the outbox. Id is unique to the event and stays the same however many times
+// it's sent.
+type Message struct {
+	Id       string
+	Event    todoitem.Event
+	Attempts int
+}
+
+// Sink is somewhere events are relayed to. Send should only return once the message is safely handed off, and must
+// cope with seeing the same message more than once.
+type Sink interface {
+	Name() string
+	Send(context.Context, Message) error
+}
+
+type Storer interface {
+	// Claim returns up to limit messages due by now, oldest first, pushing them back by lease so nobody else picks
+	// them up while they're being sent.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Message, error)
+	// Done removes a message that's been sent to every sink.
+	Done(ctx context.Context, id string) error
+	// Retry records a failed attempt and when to try again.
+	Retry(ctx context.Context, id string, next time.Time, lastError string) error
+}
+
+// pulled out for testing
+var (
+	nowFn        = time.Now
+	pollInterval = 500 * time.Millisecond
+	//retryBase is the wait after the first failure, doubling after every failure up to maxRetryWait. There's no
+	//giving up, the outbox is the only copy of the event.
+	retryBase    = time.Second
+	maxRetryWait = 5 * time.Minute
+)
+
+const (
+	claimBatch = 50
+	claimLease = time.Minute
+)
+
+// Relay moves messages from the outbox to the sinks.
+type Relay struct {
+	storer Storer
+	sinks  []Sink
+}
+
+func NewRelay(storer Storer, sinks ...Sink) *Relay {
+	return &Relay{
+		storer: storer,
+		sinks:  sinks,
+	}
+}
+
+// Run relays messages as they come in until the context is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		//keep going while there's a backlog, otherwise wait for the next tick
+		n, err := r.RelayDue(ctx)
+		if err != nil {
+			log.Default().Error("failed to claim outbox messages", zap.Error(err))
+		}
+		if n == claimBatch && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayDue makes one pass over the outbox, returning how many messages it tried to send.
+func (r *Relay) RelayDue(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "outbox-relay-due")
+	defer span.End()
+	due, err := r.storer.Claim(ctx, nowFn(), claimLease, claimBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range due {
+		r.relay(ctx, m)
+	}
+	return len(due), nil
+}
+
+// relay sends a message to every sink. If any of them fail it's retried for all of them, which is fine since sinks
+// have to handle repeats anyway.
+func (r *Relay) relay(ctx context.Context, m Message) {
+	for _, s := range r.sinks {
+		if err := s.Send(ctx, m); err != nil {
+			log.Default().Warn("outbox sink failed, will retry", zap.String("sink", s.Name()), zap.String("id", m.Id), zap.Error(err))
+			next := nowFn().Add(backoff(m.Attempts + 1))
+			if err := r.storer.Retry(ctx, m.Id, next, s.Name()+": "+err.Error()); err != nil {
+				log.Default().Error("failed to record outbox retry", zap.String("id", m.Id), zap.Error(err))
+			}
+			return
+		}
+	}
+	if err := r.storer.Done(ctx, m.Id); err != nil {
+		//it'll be claimed again once the lease is up and sent twice, which sinks are fine with
+		log.Default().Error("failed to mark outbox message done", zap.String("id", m.Id), zap.Error(err))
+	}
+}
+
+// backoff is how long to wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	wait := retryBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxRetryWait {
+			return maxRetryWait
+		}
+	}
+	return wait
+}
@@ -0,0 +1,243 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type memRow struct {
+	msg     Message
+	seq     int
+	next    time.Time
+	lastErr string
+}
+
+// memStorer stands in for the outbox table.
+type memStorer struct {
+	mu   sync.Mutex
+	rows map[string]*memRow
+	seq  int
+}
+
+func newMemStorer(events ...todoitem.Event) *memStorer {
+	m := &memStorer{rows: map[string]*memRow{}}
+	for _, e := range events {
+		m.seq++
+		id := string(rune('a' + m.seq - 1))
+		m.rows[id] = &memRow{msg: Message{Id: id, Event: e}, seq: m.seq}
+	}
+	return m
+}
+
+func (m *memStorer) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var rows []*memRow
+	for _, r := range m.rows {
+		if !r.next.After(now) {
+			rows = append(rows, r)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+	var due []Message
+	for _, r := range rows {
+		if len(due) == limit {
+			break
+		}
+		r.next = now.Add(lease)
+		due = append(due, r.msg)
+	}
+	return due, nil
+}
+
+func (m *memStorer) Done(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rows, id)
+	return nil
+}
+
+func (m *memStorer) Retry(ctx context.Context, id string, next time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.rows[id]
+	r.msg.Attempts++
+	r.next, r.lastErr = next, lastError
+	return nil
+}
+
+func (m *memStorer) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.rows)
+}
+
+// recordingSink keeps everything it's sent, failing while fail is set.
+type recordingSink struct {
+	mu   sync.Mutex
+	name string
+	fail error
+	got  []Message
+}
+
+func (s *recordingSink) Name() string {
+	return s.name
+}
+
+func (s *recordingSink) Send(ctx context.Context, m Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail != nil {
+		return s.fail
+	}
+	s.got = append(s.got, m)
+	return nil
+}
+
+func (s *recordingSink) ids() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, m := range s.got {
+		ids = append(ids, m.Id)
+	}
+	return ids
+}
+
+var testNow = time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+
+func newString(s string) *string {
+	return &s
+}
+
+func testEvents() []todoitem.Event {
+	return []todoitem.Event{
+		{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1")}, Time: testNow},
+		{Type: todoitem.EventCompleted, Item: todoitem.TodoItem{Id: newString("1")}, Time: testNow},
+		{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("2")}, Time: testNow},
+	}
+}
+
+func TestRelayDue(t *testing.T) {
+	store := newMemStorer(testEvents()...)
+	first, second := &recordingSink{name: "first"}, &recordingSink{name: "second"}
+	n, err := NewRelay(store, first, second).RelayDue(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{"a", "b", "c"}, first.ids(), "oldest first")
+	assert.Equal(t, []string{"a", "b", "c"}, second.ids())
+	assert.Equal(t, 0, store.len(), "sent messages are removed")
+}
+
+func TestRelayRetriesFailedSinks(t *testing.T) {
+	defer func() { nowFn = time.Now }()
+	now := testNow
+	nowFn = func() time.Time { return now }
+	store := newMemStorer(testEvents()[0])
+	good, flaky := &recordingSink{name: "good"}, &recordingSink{name: "flaky", fail: errors.New("boom")}
+	relay := NewRelay(store, good, flaky)
+
+	relay.RelayDue(context.Background())
+	assert.Equal(t, 1, store.len())
+	assert.Equal(t, "flaky: boom", store.rows["a"].lastErr)
+	assert.Equal(t, testNow.Add(retryBase), store.rows["a"].next)
+
+	n, _ := relay.RelayDue(context.Background())
+	assert.Equal(t, 0, n, "waits for the backoff")
+
+	now = now.Add(retryBase)
+	relay.RelayDue(context.Background())
+	assert.Equal(t, testNow.Add(retryBase+2*retryBase), store.rows["a"].next, "backs off further every failure")
+
+	flaky.fail = nil
+	now = now.Add(time.Hour)
+	relay.RelayDue(context.Background())
+	assert.Equal(t, 0, store.len())
+	assert.Equal(t, []string{"a"}, flaky.ids())
+	assert.Equal(t, []string{"a", "a", "a"}, good.ids(), "at least once, sinks see repeats with the same id")
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, 5*time.Minute, backoff(20))
+}
+
+func TestRun(t *testing.T) {
+	defer func(p time.Duration) { pollInterval = p }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+	store := newMemStorer(testEvents()...)
+	sink := &recordingSink{name: "sink"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewRelay(store, sink).Run(ctx)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return store.len() == 0 }, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	assert.Len(t, sink.ids(), 3)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := NewFileSink(path)
+	assert.Nil(t, err)
+	for i, e := range testEvents() {
+		assert.Nil(t, sink.Send(context.Background(), Message{Id: string(rune('a' + i)), Event: e}))
+	}
+	assert.Nil(t, sink.Close())
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	var records []fileRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r fileRecord
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "b", records[1].Id)
+		assert.Equal(t, todoitem.EventCompleted, records[1].Type)
+		assert.Equal(t, "1", *records[1].Item.Id)
+	}
+
+	//reopening appends rather than starting over
+	sink, err = NewFileSink(path)
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Send(context.Background(), Message{Id: "d", Event: testEvents()[0]}))
+	sink.Close()
+	b, _ := os.ReadFile(path)
+	assert.Equal(t, 4, bytes.Count(b, []byte("\n")))
+}
+
+type fakeEnqueuer struct {
+	ids []string
+}
+
+func (f *fakeEnqueuer) Enqueue(ctx context.Context, eventId string, e todoitem.Event) error {
+	f.ids = append(f.ids, eventId)
+	return nil
+}
+
+func TestWebhookSink(t *testing.T) {
+	enq := &fakeEnqueuer{}
+	sink := WebhookSink{Webhooks: enq}
+	assert.Nil(t, sink.Send(context.Background(), Message{Id: "a", Event: testEvents()[0]}))
+	assert.Equal(t, []string{"a"}, enq.ids, "the message id is what webhooks dedupe on")
+}
@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"go.uber.org/zap"
+)
+
+// LogSink writes every event to the service log. Handy for seeing what's going on, not for anything to depend on.
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Send(ctx context.Context, m Message) error {
+	var id string
+	if m.Event.Item.Id != nil {
+		id = *m.Event.Item.Id
+	}
+	log.Default().Info("todo item event", zap.String("id", m.Id), zap.String("type", string(m.Event.Type)), zap.String("item", id))
+	return nil
+}
+
+// fileRecord is one line of the file sink.
+type fileRecord struct {
+	Id   string             `json:"id"`
+	Type todoitem.EventType `json:"type"`
+	Time time.Time          `json:"time"`
+	Item todoitem.TodoItem  `json:"item"`
+	//Assignees is who was assigned the item, on assigned events
+	Assignees []string `json:"assignees,omitempty"`
+}
+
+// FileSink appends events to a file as newline delimited json, syncing after every write. Readers should drop lines
+// with an id they've already seen.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Send(ctx context.Context, m Message) error {
+	b, err := json.Marshal(fileRecord{Id: m.Id, Type: m.Event.Type, Time: m.Event.Time, Item: m.Event.Item,
+		Assignees: m.Event.Assignees})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Enqueuer queues an event for delivery, ignoring events it's already queued. It's satisfied by *webhook.Core.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, eventId string, e todoitem.Event) error
+}
+
+// WebhookSink queues events for webhooks. The message id makes queueing the same event twice a no-op, so receivers
+// don't see repeats from the outbox on top of their own retries.
+type WebhookSink struct {
+	Webhooks Enqueuer
+}
+
+func (WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s WebhookSink) Send(ctx context.Context, m Message) error {
+	return s.Webhooks.Enqueue(ctx, m.Id, m.Event)
+}
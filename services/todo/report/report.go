@@ -0,0 +1,175 @@
+// Package report renders todo items as a checklist report in markdown or html, for pasting into status updates. Items
+// are grouped by whether they're done, and optionally by day within that: done items by the day they were completed,
+// the rest by when they're due.
+package report
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+//go:embed templates
+var templates embed.FS
+
+const dateLayout = "2006-01-02"
+
+// Formats are the formats Render understands.
+var Formats = []string{"markdown", "html"}
+
+var (
+	markdown = texttemplate.Must(texttemplate.New("report.md.tmpl").Funcs(texttemplate.FuncMap{"md": escapeMarkdown}).
+			ParseFS(templates, "templates/report.md.tmpl"))
+	html = htmltemplate.Must(htmltemplate.New("report.html.tmpl").ParseFS(templates, "templates/report.html.tmpl"))
+)
+
+// Options change how a report is laid out.
+type Options struct {
+	Title string
+	//ByDate groups items by day within their status
+	ByDate bool
+	//Location is the time zone dates are shown in. UTC if nil.
+	Location *time.Location
+}
+
+// Report is what the templates are given.
+type Report struct {
+	Title     string
+	Generated string
+	Groups    []Group
+}
+
+// Group is a status, To do or Done. Without ByDate there's one section with no name.
+type Group struct {
+	Name     string
+	Count    int
+	Sections []Section
+}
+
+type Section struct {
+	Name  string
+	Items []Item
+}
+
+// Item is a todo item as the templates show it, dates already formatted and empty where there aren't any.
+type Item struct {
+	Done     bool
+	Summary  string
+	Priority string
+	Due      string
+	Tags     []string
+}
+
+// Render writes items as a report in format, markdown or html.
+func Render(w io.Writer, format string, items []todoitem.TodoItem, opts Options, now time.Time) error {
+	r := Build(items, opts, now)
+	switch format {
+	case "markdown":
+		return markdown.Execute(w, r)
+	case "html":
+		return html.Execute(w, r)
+	}
+	return fmt.Errorf("unknown format %s, use %s", format, strings.Join(Formats, " or "))
+}
+
+// Build lays items out into a report. Items still to do come first, soonest due first, then done ones in the order
+// they were done.
+func Build(items []todoitem.TodoItem, opts Options, now time.Time) Report {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	var open, done []todoitem.TodoItem
+	for _, item := range items {
+		if item.Completed != nil && *item.Completed {
+			done = append(done, item)
+		} else {
+			open = append(open, item)
+		}
+	}
+	sortByTime(open, func(item todoitem.TodoItem) *time.Time { return item.Due })
+	sortByTime(done, func(item todoitem.TodoItem) *time.Time { return item.CompletedAt })
+
+	r := Report{Title: opts.Title, Generated: now.In(loc).Format(dateLayout)}
+	r.Groups = append(r.Groups,
+		group("To do", open, opts.ByDate, loc, "No due date", func(item todoitem.TodoItem) *time.Time { return item.Due }),
+		group("Done", done, opts.ByDate, loc, "Unknown", func(item todoitem.TodoItem) *time.Time { return item.CompletedAt }),
+	)
+	return r
+}
+
+// group makes a status group, split into a section per day of when(item) with ByDate. Items without a date go last.
+func group(name string, items []todoitem.TodoItem, byDate bool, loc *time.Location, undated string, when func(todoitem.TodoItem) *time.Time) Group {
+	g := Group{Name: name, Count: len(items)}
+	if !byDate {
+		s := Section{}
+		for _, item := range items {
+			s.Items = append(s.Items, toItem(item, loc))
+		}
+		if len(s.Items) > 0 {
+			g.Sections = append(g.Sections, s)
+		}
+		return g
+	}
+	for _, item := range items {
+		day := undated
+		if t := when(item); t != nil {
+			day = t.In(loc).Format(dateLayout)
+		}
+		//items are already in date order, so a day only ever continues the last section
+		if len(g.Sections) == 0 || g.Sections[len(g.Sections)-1].Name != day {
+			g.Sections = append(g.Sections, Section{Name: day})
+		}
+		last := &g.Sections[len(g.Sections)-1]
+		last.Items = append(last.Items, toItem(item, loc))
+	}
+	return g
+}
+
+func toItem(item todoitem.TodoItem, loc *time.Location) Item {
+	i := Item{Done: item.Completed != nil && *item.Completed, Tags: item.Tags}
+	if item.Summary != nil {
+		i.Summary = *item.Summary
+	}
+	if item.Priority != nil && *item.Priority != todoitem.PriorityNone {
+		i.Priority = string(*item.Priority)
+	}
+	if item.Due != nil {
+		i.Due = item.Due.In(loc).Format(dateLayout)
+	}
+	return i
+}
+
+// sortByTime sorts items by when(item), earliest first and those without one last.
+func sortByTime(items []todoitem.TodoItem, when func(todoitem.TodoItem) *time.Time) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := when(items[i]), when(items[j])
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.Before(*b)
+	})
+}
+
+// escapeMarkdown stops summaries being read as inline markdown, a summary of *urgent* shouldn't come out bold. Block
+// markdown (#, -) only counts at the start of a line, which a summary never is.
+func escapeMarkdown(s string) string {
+	b := &strings.Builder{}
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>|~", r) {
+			b.WriteRune('\\')
+		}
+		if r == '\n' {
+			r = ' '
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+var now = time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+
+func items() []todoitem.TodoItem {
+	yes := true
+	high := todoitem.PriorityHigh
+	none := todoitem.PriorityNone
+	day := func(d int) *time.Time {
+		t := time.Date(2026, time.October, d, 9, 0, 0, 0, time.UTC)
+		return &t
+	}
+	return []todoitem.TodoItem{
+		{Summary: newString("no deadline"), Priority: &none},
+		{Summary: newString("buy *oat* milk"), Due: day(21), Priority: &high, Tags: []string{"home"}},
+		{Summary: newString("call mom"), Due: day(20)},
+		{Summary: newString("file taxes"), Completed: &yes, CompletedAt: day(16)},
+		{Summary: newString("book <flights>"), Completed: &yes, CompletedAt: day(14)},
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Render(out, "markdown", items(), Options{Title: "weekly"}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, `# weekly
+
+_Generated 2026-10-19_
+
+## To do (3)
+
+- [ ] call mom _due 2026-10-20_
+- [ ] buy \*oat\* milk **high** `+"`home`"+` _due 2026-10-21_
+- [ ] no deadline
+
+## Done (2)
+
+- [x] book \<flights\>
+- [x] file taxes
+`, out.String())
+}
+
+func TestMarkdownByDate(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Render(out, "markdown", items()[3:], Options{Title: "weekly", ByDate: true}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, `# weekly
+
+_Generated 2026-10-19_
+
+## To do (0)
+
+Nothing here.
+
+## Done (2)
+
+### 2026-10-14
+
+- [x] book \<flights\>
+
+### 2026-10-16
+
+- [x] file taxes
+`, out.String())
+}
+
+func TestBuild(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	r := Build(items(), Options{ByDate: true, Location: tokyo}, now)
+	names := []string{}
+	for _, s := range r.Groups[0].Sections {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"2026-10-20", "2026-10-21", "No due date"}, names)
+	assert.Equal(t, "2026-10-19", r.Generated)
+	assert.Equal(t, "", r.Groups[0].Sections[2].Items[0].Priority, "none isn't worth showing")
+}
+
+func TestHTML(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Render(out, "html", items(), Options{Title: "weekly <report>", ByDate: true}, now)
+	assert.Nil(t, err)
+	s := out.String()
+	assert.Contains(t, s, "<h1>weekly &lt;report&gt;</h1>")
+	assert.Contains(t, s, `<span class="done">book &lt;flights&gt;</span>`)
+	assert.Contains(t, s, `<h3>2026-10-20</h3>`)
+	assert.Contains(t, s, `<span class="priority">high</span> <span class="tag">home</span> <span class="due">due 2026-10-21</span>`)
+	assert.Equal(t, 2, strings.Count(s, "checked"))
+}
+
+func TestUnknownFormat(t *testing.T) {
+	err := Render(&bytes.Buffer{}, "pdf", nil, Options{}, now)
+	assert.EqualError(t, err, "unknown format pdf, use markdown or html")
+}
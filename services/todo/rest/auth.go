@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// Authenticate is middleware that checks the Authorization header of every request, putting who it belongs to in the
+// request context for the cores to scope by. A bad credential is always a 401. No credential at all is a 401 if
+// required is set, otherwise the request goes through unscoped, the same as before there were users. Public paths
+// are let through without looking at the header, since they're how credentials are got, but never unscoped. A public
+// path can be just for one method by starting it with the method, as in "GET /api/todo/calendar.ics". 401s say which
+// schemes can be used, just Basic unless a is an auth.Schemes.
+func Authenticate(a auth.Authenticator, required bool, public ...string) func(http.Handler) http.Handler {
+	open := map[string]bool{}
+	for _, p := range public {
+		open[p] = true
+	}
+	schemes := []string{"Basic"}
+	if s, ok := a.(auth.Schemes); ok {
+		schemes = s.Names()
+	}
+	unauthorized := func(w http.ResponseWriter, err error) {
+		for _, scheme := range schemes {
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`%s realm="todo"`, scheme))
+		}
+		writeError(w, err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if open[r.URL.Path] || open[r.Method+" "+r.URL.Path] {
+				next.ServeHTTP(w, r.WithContext(auth.Anonymous(r.Context(), false)))
+				return
+			}
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				if required {
+					unauthorized(w, terr.ErrorWithCode("unauthorized", "authentication required", 401))
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(auth.Anonymous(r.Context(), true)))
+				return
+			}
+			p, err := a.Authenticate(r.Context(), header)
+			if err != nil {
+				unauthorized(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
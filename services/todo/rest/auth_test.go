@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/user"
+)
+
+func TestBearerAndBasic(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewCore(memdb.NewUserStore())
+	sam, err := users.Register(ctx, user.NewUser{Email: "sam@example.com", Password: "correct horse"})
+	assert.Nil(t, err)
+	bearer, err := auth.NewJWT(ctx, auth.JWTConfig{Secret: "not very secret"})
+	assert.Nil(t, err)
+	router := chi.NewRouter()
+	router.Use(Authenticate(auth.Schemes{"basic": users, "bearer": bearer}, true))
+	tdh := NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(router, "/api")
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": *sam.Id, "exp": time.Now().Add(time.Hour).Unix()}).
+		SignedString([]byte("not very secret"))
+	rr := serve(router, "POST", "/api/todo", "Bearer "+token, todoitem.TodoItem{Summary: newId("buy milk")})
+	assert.Equal(t, 201, rr.Code, rr.Body.String())
+	var item todoitem.TodoItem
+	json.Unmarshal(rr.Body.Bytes(), &item)
+	assert.Equal(t, sam.Id, item.OwnerId)
+
+	rr = serve(router, "GET", "/api/todo/"+*item.Id, basicAuth("sam@example.com", "correct horse"), nil)
+	assert.Equal(t, 200, rr.Code, "the same user either way")
+
+	rr = serve(router, "GET", "/api/todo", "Bearer "+token[:len(token)-2], nil)
+	assert.Equal(t, 401, rr.Code)
+	assert.Contains(t, rr.Body.String(), "invalid bearer token")
+	assert.Equal(t, []string{`Basic realm="todo"`, `Bearer realm="todo"`}, rr.Header().Values("WWW-Authenticate"))
+}
+
+func TestAnonymous(t *testing.T) {
+	core := todoitem.NewCore(memdb.NewStore())
+	owned, _ := core.Create(auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"}), todoitem.TodoItem{Summary: newId("sam's")})
+	users := user.NewCore(memdb.NewUserStore())
+	type test struct {
+		name     string
+		required bool
+		path     string
+		code     int
+		items    int
+	}
+	tests := []test{
+		{name: "required", required: true, path: "/api/todo", code: 401},
+		{name: "open", required: false, path: "/api/todo", code: 200, items: 1},
+		{name: "public paths never see everything", required: false, path: "/api/todo/public", code: 200},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			router := chi.NewRouter()
+			router.Use(Authenticate(users, tt.required, "/api/todo/public"))
+			router.Get("/api/todo/public", func(w http.ResponseWriter, r *http.Request) {
+				items, err := core.GetAll(r.Context())
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				writeJSON(w, 200, items)
+			})
+			tdh := NewTodoHandlers(core)
+			tdh.RegisterTodoEndpoints(router, "/api")
+
+			rr := serve(router, "GET", tt.path, "", nil)
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+			if tt.code == 200 {
+				var items []todoitem.TodoItem
+				json.Unmarshal(rr.Body.Bytes(), &items)
+				assert.Len(t, items, tt.items)
+				if tt.items > 0 {
+					assert.Equal(t, owned.Id, items[0].Id)
+				}
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
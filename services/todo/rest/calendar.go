@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/ical"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+// CalendarHandlers serve todo items with due dates as an iCalendar feed calendar apps can subscribe to, and import
+// VTODOs from .ics files.
+type CalendarHandlers struct {
+	TodoItem *todoitem.Core
+	//Tokens checks the access token the feed is read with. Calendar apps can't send headers, so it's given as ?token=
+	//and the feed has to be left out of Authenticate's checks, see CalendarPublicPaths.
+	Tokens auth.Authenticator
+}
+
+func NewCalendarHandlers(todoItem *todoitem.Core, tokens auth.Authenticator) CalendarHandlers {
+	return CalendarHandlers{
+		TodoItem: todoItem,
+		Tokens:   tokens,
+	}
+}
+
+// CalendarPublicPaths is reading the feed, which checks its own token. Importing still goes through Authenticate.
+func CalendarPublicPaths(prefix string) []string {
+	return []string{fmt.Sprintf("GET %s/todo/calendar.ics", prefix)}
+}
+
+func (h *CalendarHandlers) RegisterCalendarEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/todo/calendar.ics", prefix), h.GetCalendar)
+	parent.Post(fmt.Sprintf("%s/todo/calendar.ics", prefix), h.ImportCalendar)
+}
+
+// GetCalendar writes the items the ?token= access token's user can see that are due, as VEVENTs, or VTODOs with
+// ?component=todo. ?q= narrows them down with a filter expression, so a feed can be just one tag, say. The token has to
+// be read only, since the url ends up in calendar apps, their servers and anything logging requests along the way.
+func (h *CalendarHandlers) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetCalendar")
+	defer span.End()
+	secret := r.URL.Query().Get("token")
+	if secret == "" {
+		writeError(w, terr.ErrorWithCode("unauthorized", "A read only access token is needed as ?token= for the calendar", 401))
+		return
+	}
+	p, err := h.Tokens.Authenticate(ctx, "Bearer "+secret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if p.Scope != auth.ScopeRead {
+		writeError(w, terr.ErrorWithCode("forbidden", "Use an access token with the read scope for the calendar, the url gets shared with calendar apps", 403))
+		return
+	}
+	ctx = auth.WithPrincipal(ctx, p)
+	component := ical.Event
+	switch v := r.URL.Query().Get("component"); v {
+	case "", "event":
+	case "todo":
+		component = ical.Todo
+	default:
+		writeError(w, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid component %s, use event or todo", v), 400))
+		return
+	}
+	var todos []todoitem.TodoItem
+	name := "todo"
+	if q := r.URL.Query().Get("q"); q != "" {
+		todos, err = h.TodoItem.Find(ctx, q)
+		name += " — " + q
+	} else {
+		todos, err = h.TodoItem.GetAll(ctx)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(200)
+	ical.Write(w, todos, component, name, time.Now())
+}
+
+// ImportCalendar creates an item for each VTODO in an .ics file, sent as the body or as the file field of a form.
+// Nothing is created if the file can't be read.
+func (h *CalendarHandlers) ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "ImportCalendar")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	var body io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, terr.ErrorWithCode("bad request", "Upload the .ics file as the file field", 400))
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+	items, err := ical.Parse(body)
+	if err != nil {
+		writeError(w, terr.ErrorWithCode("bad request", fmt.Sprintf("Could not read the calendar: %s", err), 400))
+		return
+	}
+	created := []todoitem.TodoItem{}
+	for _, item := range items {
+		c, err := h.TodoItem.Create(ctx, todoitem.TodoItem{Summary: item.Summary, Priority: item.Priority, Due: item.Due, Tags: item.Tags})
+		//completion can't be set on create
+		if err == nil && *item.Completed {
+			c, err = h.TodoItem.Update(ctx, todoitem.TodoItem{Summary: c.Summary, Completed: item.Completed}, *c.Id)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		created = append(created, c)
+	}
+	writeJSON(w, 201, created)
+}
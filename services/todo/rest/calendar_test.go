@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/token"
+)
+
+// newCalendarRouter serves the calendar behind Authenticate the way the server does, returning it along with access
+// tokens of each scope for sam.
+func newCalendarRouter(t *testing.T) (*chi.Mux, *todoitem.Core, map[string]string) {
+	core := todoitem.NewCore(memdb.NewStore())
+	tokens := token.NewCore(memdb.NewTokenStore())
+	parent := chi.NewRouter()
+	parent.Use(Authenticate(auth.Schemes{"bearer": tokens.Bearer(nil)}, true, CalendarPublicPaths("/api")...))
+	//the todo routes are mounted too, to check they don't swallow calendar.ics as an id
+	tdh := NewTodoHandlers(core)
+	tdh.RegisterTodoEndpoints(parent, "/api")
+	subject := NewCalendarHandlers(core, tokens)
+	subject.RegisterCalendarEndpoints(parent, "/api")
+	secrets := map[string]string{}
+	for _, scope := range auth.Scopes {
+		scope := scope
+		tok, err := tokens.Issue(context.Background(), "sam", token.Token{Name: newSummary("calendar"), Scope: &scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[scope] = *tok.Token
+	}
+	return parent, core, secrets
+}
+
+func TestGetCalendar(t *testing.T) {
+	router, core, secrets := newCalendarRouter(t)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+	due := time.Date(2026, time.October, 20, 17, 0, 0, 0, time.UTC)
+	milk, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk"), Due: &due, Tags: []string{"home"}})
+	core.Create(ctx, todoitem.TodoItem{Summary: newSummary("file taxes"), Due: &due, Tags: []string{"work"}})
+	core.Create(ctx, todoitem.TodoItem{Summary: newSummary("no deadline")})
+	core.Create(auth.WithPrincipal(context.Background(), auth.Principal{UserId: "alex"}),
+		todoitem.TodoItem{Summary: newSummary("alex's dentist"), Due: &due})
+	feed := "/api/todo/calendar.ics?token=" + secrets[auth.ScopeRead]
+
+	type test struct {
+		name   string
+		url    string
+		code   int
+		expect []string
+		not    []string
+	}
+	tests := []test{
+		{name: "events", url: feed, code: 200,
+			expect: []string{"BEGIN:VEVENT", "UID:" + *milk.Id + "@todo", "DTSTART:20261020T170000Z", "file taxes"},
+			not:    []string{"no deadline", "VTODO", "alex's dentist"}},
+		{name: "todos", url: feed + "&component=todo", code: 200,
+			expect: []string{"BEGIN:VTODO", "DUE:20261020T170000Z", "STATUS:NEEDS-ACTION"}},
+		{name: "filtered", url: feed + "&q=tag:home", code: 200,
+			expect: []string{"buy milk"}, not: []string{"file taxes"}},
+		{name: "bad component", url: feed + "&component=journal", code: 400},
+		{name: "bad filter", url: feed + "&q=nope:nope", code: 400},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			assert.Equal(t, tt.code, rr.Code)
+			if tt.code != 200 {
+				return
+			}
+			assert.Equal(t, "text/calendar; charset=utf-8", rr.Header().Get("Content-Type"))
+			for _, s := range tt.expect {
+				assert.Contains(t, rr.Body.String(), s)
+			}
+			for _, s := range tt.not {
+				assert.NotContains(t, rr.Body.String(), s)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestCalendarToken(t *testing.T) {
+	router, _, secrets := newCalendarRouter(t)
+	for url, code := range map[string]int{
+		"/api/todo/calendar.ics":                                   401,
+		"/api/todo/calendar.ics?token=wrong":                       401,
+		"/api/todo/calendar.ics?token=todo_pat_wrong":              401,
+		"/api/todo/calendar.ics?token=" + secrets[auth.ScopeRead]:  200,
+		"/api/todo/calendar.ics?token=" + secrets[auth.ScopeWrite]: 403,
+		"/api/todo/calendar.ics?token=" + secrets[auth.ScopeAdmin]: 403,
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, code, rr.Code, url)
+	}
+	//only reading the feed is public, importing still needs credentials
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/todo/calendar.ics?token="+secrets[auth.ScopeWrite], strings.NewReader(importCalendar)))
+	assert.Equal(t, 401, rr.Code)
+}
+
+const importCalendar = "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:buy milk\r\nDUE:20261020T170000Z\r\nPRIORITY:1\r\n" +
+	"CATEGORIES:home,errands\r\nEND:VTODO\r\nBEGIN:VTODO\r\nSUMMARY:file taxes\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestImportCalendar(t *testing.T) {
+	router, core, secrets := newCalendarRouter(t)
+	form := &bytes.Buffer{}
+	mw := multipart.NewWriter(form)
+	fw, _ := mw.CreateFormFile("file", "tasks.ics")
+	fw.Write([]byte(importCalendar))
+	mw.Close()
+
+	type test struct {
+		name        string
+		body        string
+		contentType string
+		code        int
+	}
+	tests := []test{
+		{name: "raw", body: importCalendar, contentType: "text/calendar", code: 201},
+		{name: "form", body: form.String(), contentType: mw.FormDataContentType(), code: 201},
+		{name: "no file field", body: form.String(), contentType: "multipart/form-data; boundary=nope", code: 400},
+		{name: "not a calendar", body: "BEGIN:VTODO\r\nEND:VTODO\r\n", contentType: "text/calendar", code: 400},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/todo/calendar.ics", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Authorization", "Bearer "+secrets[auth.ScopeWrite])
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+			if tt.code != 201 {
+				return
+			}
+			var created []todoitem.TodoItem
+			assert.Nil(t, json.NewDecoder(rr.Body).Decode(&created))
+			if assert.Len(t, created, 2) {
+				assert.Equal(t, todoitem.PriorityHigh, *created[0].Priority)
+				assert.Equal(t, []string{"home", "errands"}, created[0].Tags)
+				assert.True(t, *created[1].Completed)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+	items, _ := core.GetAll(auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"}))
+	assert.Len(t, items, 4)
+}
@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+type EventHandlers struct {
+	TodoItem *todoitem.Core
+	Hub      *events.Hub
+	//Tickets hands out tickets to open the stream with, for browsers. Without them there's no ticket endpoint
+	Tickets *Tickets
+}
+
+func NewEventHandlers(todoItem *todoitem.Core, hub *events.Hub) EventHandlers {
+	return EventHandlers{
+		TodoItem: todoItem,
+		Hub:      hub,
+	}
+}
+
+// keepAlive is how often an idle stream gets a comment, so proxies don't decide the connection is dead.
+var keepAlive = 15 * time.Second
+
+func (h *EventHandlers) RegisterEventEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/todo/events", prefix), h.StreamEvents)
+	parent.Post(fmt.Sprintf("%s/todo/events/ticket", prefix), h.IssueTicket)
+}
+
+// EventTicketPaths are where a ticket can be given as ?ticket=, see TicketQuery.
+func EventTicketPaths(prefix string) []string {
+	return []string{fmt.Sprintf("%s/todo/events", prefix)}
+}
+
+// IssueTicket hands out a ticket to open the stream with as ?ticket=, since EventSource can't send an Authorization
+// header. It's only good for a minute, so get a new one for each connection.
+func (h *EventHandlers) IssueTicket(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "IssueTicket")
+	defer span.End()
+	if h.Tickets == nil {
+		writeError(w, terr.ErrorWithCode("not found", "tickets aren't handed out by this server", 404))
+		return
+	}
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		writeError(w, terr.ErrorWithCode("unauthorized", "log in to get a ticket", 401))
+		return
+	}
+	t, err := h.Tickets.Issue(p)
+	if err != nil {
+		log.Default().Error("failed to issue a ticket", zap.Error(err))
+		writeError(w, terr.InternalError())
+		return
+	}
+	writeJSON(w, 201, t)
+}
+
+// StreamEvents is a server sent events stream of todo item changes. Each message's event is the change type
+// (created, updated, completed, deleted) and its data the todoitem.Event as json. Browsers authenticate it with a
+// ticket from IssueTicket. Clients resume with the standard Last-Event-ID header (or a lastEventId query param, since
+// EventSource can't set headers on its first request). If we can't resume from that id a reset event is sent and the
+// client should reload its list. Only events for items the caller can see are sent, shared lists included. Lists
+// shared after the stream starts are picked up on reconnect.
+func (h *EventHandlers) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "StreamEvents")
+	defer span.End()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	audience, err := h.TodoItem.Audience(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	lastId := r.Header.Get("Last-Event-ID")
+	if lastId == "" {
+		lastId = r.URL.Query().Get("lastEventId")
+	}
+	sub, replay, missed := h.Hub.Subscribe(lastId)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if missed {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, env := range replay {
+		if !audience.Sees(env.Event) {
+			continue
+		}
+		if err := writeEvent(w, env); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case env, ok := <-sub.C():
+			if !ok {
+				//either the hub is shutting down or we fell too far behind. The client will reconnect and replay.
+				return
+			}
+			if !audience.Sees(env.Event) {
+				continue
+			}
+			if err := writeEvent(w, env); err != nil {
+				log.Default().Debug("failed writing event, dropping stream", zap.Error(err))
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, env events.Envelope) error {
+	jsn, err := json.Marshal(env.Event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", env.Id, env.Event.Type, jsn)
+	return err
+}
@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type sseMessage struct {
+	id    string
+	event string
+	data  string
+}
+
+// readMessage reads the next message off an event stream, skipping comments and the retry hint.
+func readMessage(t *testing.T, r *bufio.Reader) sseMessage {
+	var msg sseMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if msg.event != "" {
+				return msg
+			}
+		case strings.HasPrefix(line, "id: "):
+			msg.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			msg.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			msg.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func newEventServer(t *testing.T) (*httptest.Server, *events.Hub) {
+	hub := events.NewHub(10)
+	parent := chi.NewRouter()
+	core := todoitem.NewCore(memdb.NewStore(), hub)
+	todos := NewTodoHandlers(core)
+	todos.RegisterTodoEndpoints(parent, "/api")
+	evh := NewEventHandlers(core, hub)
+	evh.RegisterEventEndpoints(parent, "/api")
+	srv := httptest.NewServer(parent)
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	return srv, hub
+}
+
+func openStream(t *testing.T, url, lastId string) *bufio.Reader {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastId != "" {
+		req.Header.Set("Last-Event-ID", lastId)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	return bufio.NewReader(res.Body)
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv, _ := newEventServer(t)
+	stream := openStream(t, srv.URL+"/api/todo/events", "")
+
+	res, err := http.Post(srv.URL+"/api/todo", "application/json", bytes.NewReader([]byte(`{"summary":"streamed"}`)))
+	assert.Nil(t, err)
+	var created todoitem.TodoItem
+	assert.Nil(t, json.NewDecoder(res.Body).Decode(&created))
+
+	req, _ := http.NewRequest(http.MethodPatch, srv.URL+"/api/todo/"+*created.Id, bytes.NewReader([]byte(`{"summary":"streamed","completed":true}`)))
+	_, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+
+	msg := readMessage(t, stream)
+	assert.Equal(t, "created", msg.event)
+	var e todoitem.Event
+	assert.Nil(t, json.Unmarshal([]byte(msg.data), &e))
+	assert.Equal(t, *created.Id, *e.Item.Id)
+	createdId := msg.id
+
+	msg = readMessage(t, stream)
+	assert.Equal(t, "completed", msg.event)
+
+	//picking up after the created event should replay the completion
+	resumed := openStream(t, srv.URL+"/api/todo/events", createdId)
+	msg = readMessage(t, resumed)
+	assert.Equal(t, "completed", msg.event)
+
+	reset := openStream(t, srv.URL+"/api/todo/events?lastEventId=somewhere-else-1", "")
+	msg = readMessage(t, reset)
+	assert.Equal(t, "reset", msg.event)
+}
+
+func TestStreamEndsWhenHubCloses(t *testing.T) {
+	srv, hub := newEventServer(t)
+	stream := openStream(t, srv.URL+"/api/todo/events", "")
+	hub.Close()
+	done := make(chan struct{})
+	go func() {
+		for {
+			if _, err := stream.ReadString('\n'); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream should end once the hub is closed")
+	}
+}
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/report"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+// pull out so tests can pin the generated date
+var exportNowFn = time.Now
+
+var exportContentTypes = map[string]string{
+	"markdown": "text/markdown; charset=utf-8",
+	"html":     "text/html; charset=utf-8",
+}
+
+func (h *TodoHandlers) RegisterExportEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/todo/export", prefix), h.GetExport)
+}
+
+// GetExport renders items as a checklist report, ?format=markdown or html. ?q= is a filter expression, ?group=date
+// splits each status up by day, ?tz= is the time zone days are in (UTC by default) and ?title= heads the report.
+func (h *TodoHandlers) GetExport(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetExport")
+	defer span.End()
+	query := r.URL.Query()
+	format := query.Get("format")
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		writeError(w, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid format %s, use %s", format, strings.Join(report.Formats, " or ")), 400))
+		return
+	}
+	opts := report.Options{Title: query.Get("title")}
+	switch v := query.Get("group"); v {
+	case "", "status":
+	case "date":
+		opts.ByDate = true
+	default:
+		writeError(w, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid group %s, use status or date", v), 400))
+		return
+	}
+	if v := query.Get("tz"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			writeError(w, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid tz %s, use a name like Europe/London", v), 400))
+			return
+		}
+		opts.Location = loc
+	}
+	var todos []todoitem.TodoItem
+	var err error
+	q := query.Get("q")
+	if q != "" {
+		todos, err = h.TodoItem.Find(ctx, q)
+	} else {
+		todos, err = h.TodoItem.GetAll(ctx)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if opts.Title == "" {
+		opts.Title = "todo"
+		if q != "" {
+			opts.Title += " — " + q
+		}
+	}
+	//render before writing anything, so a template error can still be a 500
+	out := &bytes.Buffer{}
+	if err := report.Render(out, format, todos, opts, exportNowFn()); err != nil {
+		writeError(w, terr.InternalError())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(200)
+	w.Write(out.Bytes())
+}
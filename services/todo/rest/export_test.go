@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func TestGetExport(t *testing.T) {
+	prev := exportNowFn
+	defer func() { exportNowFn = prev }()
+	exportNowFn = func() time.Time { return time.Date(2026, time.October, 19, 23, 0, 0, 0, time.UTC) }
+
+	ctx := context.Background()
+	core := todoitem.NewCore(memdb.NewStore())
+	due := time.Date(2026, time.October, 20, 23, 30, 0, 0, time.UTC)
+	core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk"), Due: &due, Tags: []string{"home"}})
+	taxes, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("file taxes"), Tags: []string{"work"}})
+	done := true
+	core.Update(ctx, todoitem.TodoItem{Summary: taxes.Summary, Completed: &done}, *taxes.Id)
+	parent := chi.NewRouter()
+	subject := NewTodoHandlers(core)
+	subject.RegisterTodoEndpoints(parent, "/api")
+	subject.RegisterExportEndpoints(parent, "/api")
+
+	type test struct {
+		name        string
+		url         string
+		code        int
+		contentType string
+		expect      []string
+		not         []string
+	}
+	tests := []test{
+		{name: "markdown", url: "/api/todo/export?format=markdown", code: 200, contentType: "text/markdown; charset=utf-8",
+			expect: []string{"# todo\n", "## To do (1)", "- [ ] buy milk `home` _due 2026-10-20_", "## Done (1)", "- [x] file taxes"}},
+		{name: "html", url: "/api/todo/export?format=html&title=weekly", code: 200, contentType: "text/html; charset=utf-8",
+			expect: []string{"<h1>weekly</h1>", "buy milk"}},
+		{name: "filtered", url: "/api/todo/export?format=markdown&q=tag:work", code: 200,
+			expect: []string{"# todo — tag:work", "## To do (0)", "file taxes"}, not: []string{"buy milk"}},
+		{name: "by date in a zone", url: "/api/todo/export?format=markdown&group=date&tz=Asia/Tokyo", code: 200,
+			expect: []string{"_Generated 2026-10-20_", "### 2026-10-21\n\n- [ ] buy milk"}},
+		{name: "no format", url: "/api/todo/export", code: 400},
+		{name: "bad format", url: "/api/todo/export?format=pdf", code: 400},
+		{name: "bad group", url: "/api/todo/export?format=html&group=tag", code: 400},
+		{name: "bad tz", url: "/api/todo/export?format=html&tz=Mars/Base", code: 400},
+		{name: "bad filter", url: "/api/todo/export?format=html&q=nope:nope", code: 400},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"))
+			}
+			for _, s := range tt.expect {
+				assert.Contains(t, rr.Body.String(), s)
+			}
+			for _, s := range tt.not {
+				assert.NotContains(t, rr.Body.String(), s)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
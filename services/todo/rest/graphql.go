@@ -0,0 +1,25 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+type GraphQLHandlers struct {
+	Schema *graphql.Schema
+}
+
+func NewGraphQLHandlers(schema *graphql.Schema) GraphQLHandlers {
+	return GraphQLHandlers{
+		Schema: schema,
+	}
+}
+
+// RegisterGraphQLEndpoints serves the schema at <prefix>/graphql. Queries are POSTed as the usual
+// {"query": ..., "variables": ...} json body.
+func (h *GraphQLHandlers) RegisterGraphQLEndpoints(parent *chi.Mux, prefix string) {
+	parent.Post(fmt.Sprintf("%s/graphql", prefix), (&relay.Handler{Schema: h.Schema}).ServeHTTP)
+}
@@ -0,0 +1,379 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+type LiveHandlers struct {
+	TodoItem  *todoitem.Core
+	SmartList *smartlist.Core
+	Hub       *events.Hub
+	//Origins are the other sites, as scheme://host[:port], whose pages can open the socket. The server's own is always fine
+	Origins []string
+}
+
+func NewLiveHandlers(todoItem *todoitem.Core, smartList *smartlist.Core, hub *events.Hub) LiveHandlers {
+	return LiveHandlers{
+		TodoItem:  todoItem,
+		SmartList: smartList,
+		Hub:       hub,
+	}
+}
+
+// pulled out so tests can speed things up
+var (
+	//pongWait is how long a connection can go without hearing from the client before it's considered dead
+	pongWait = 60 * time.Second
+	//pingPeriod has to be shorter than pongWait so the client has a chance to answer
+	pingPeriod = 50 * time.Second
+	writeWait  = 10 * time.Second
+	//liveBuffer is how many messages a connection can have waiting to be written before it's cut off as too slow
+	liveBuffer = 256
+)
+
+const (
+	liveMaxMessage = 1048576
+	//liveMaxSubs stops a single connection from making us match every event against an unbounded number of queries
+	liveMaxSubs = 32
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+func (h *LiveHandlers) RegisterLiveEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/todo/live", prefix), h.Live)
+}
+
+// checkOrigin only lets pages from the server itself or one of Origins open the socket. Unlike fetch, browsers don't
+// apply cors to websockets and will replay a cached basic auth login on the handshake, so without this any page the
+// user visits could read and change their items. Clients that aren't browsers don't send an Origin, and are let through.
+func (h *LiveHandlers) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range h.Origins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// liveRequest is anything a client can send. Ref is picked by the client and echoed back on the reply so it can
+// match them up. For subscriptions the ref also names the subscription in the events sent for it.
+type liveRequest struct {
+	Type  string             `json:"type"`
+	Ref   string             `json:"ref"`
+	List  string             `json:"list,omitempty"`
+	Query string             `json:"query,omitempty"`
+	Id    string             `json:"id,omitempty"`
+	Item  *todoitem.TodoItem `json:"item,omitempty"`
+}
+
+// liveMessage is anything we send back.
+type liveMessage struct {
+	Type         string              `json:"type"`
+	Ref          string              `json:"ref,omitempty"`
+	Subscription string              `json:"subscription,omitempty"`
+	EventId      string              `json:"eventId,omitempty"`
+	Event        *todoitem.Event     `json:"event,omitempty"`
+	InList       *bool               `json:"inList,omitempty"`
+	Item         *todoitem.TodoItem  `json:"item,omitempty"`
+	Items        []todoitem.TodoItem `json:"items,omitempty"`
+	Error        json.RawMessage     `json:"error,omitempty"`
+}
+
+// Live is a websocket for editing todo items together. Clients subscribe to a smart list (or any filter expression)
+// and get the items in it, followed by an event every time one of them changes. Changes made over the socket go
+// through the same core as the rest api, so everyone sees them no matter where they came from. See the README for
+// the messages.
+func (h *LiveHandlers) Live(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Live")
+	defer span.End()
+	u := upgrader
+	u.CheckOrigin = h.checkOrigin
+	conn, err := u.Upgrade(w, r, nil)
+	if err != nil {
+		//the upgrader has already written an error response
+		return
+	}
+	s := &liveSession{
+		h:         h,
+		conn:      conn,
+		out:       make(chan liveMessage, liveBuffer),
+		done:      make(chan struct{}),
+		subs:      map[string]*liveSub{},
+		closeCode: websocket.CloseNormalClosure,
+	}
+	sub, _, _ := h.Hub.Subscribe("")
+	defer sub.Close()
+	reqs := make(chan []byte)
+	go s.read(reqs)
+	written := make(chan struct{})
+	go func() {
+		s.write()
+		close(written)
+	}()
+	s.run(ctx, reqs, sub)
+	close(s.done)
+	close(s.out)
+	<-written
+}
+
+type liveSub struct {
+	//query is compiled again for every event, so relative dates like due<today don't go stale on long connections
+	query string
+	//seen holds the ids of the items the client has been told are in the list, so it also hears about them leaving
+	seen map[string]struct{}
+	//audience is the lists the client could see when it subscribed, shared ones included
+	audience todoitem.Audience
+}
+
+// liveSession is one connection. run owns the session state, read and write own their side of the socket.
+type liveSession struct {
+	h    *LiveHandlers
+	conn *websocket.Conn
+	out  chan liveMessage
+	done chan struct{}
+	subs map[string]*liveSub
+
+	//set before out is closed, read by write once it is
+	closeCode   int
+	closeReason string
+}
+
+func (s *liveSession) run(ctx context.Context, reqs <-chan []byte, sub *events.Subscription) {
+	for {
+		select {
+		case raw, ok := <-reqs:
+			if !ok {
+				return
+			}
+			if !s.handle(ctx, raw) {
+				return
+			}
+		case env, ok := <-sub.C():
+			if !ok {
+				//the hub is shutting down or we fell behind it. Either way the client has to reconnect and resubscribe.
+				s.closeCode, s.closeReason = websocket.CloseGoingAway, "event feed ended"
+				return
+			}
+			if !s.dispatch(ctx, env) {
+				return
+			}
+		}
+	}
+}
+
+// read hands each message to run. It doesn't read ahead, so a client sending faster than we can apply changes is
+// slowed down by its own socket.
+func (s *liveSession) read(reqs chan<- []byte) {
+	defer close(reqs)
+	s.conn.SetReadLimit(liveMaxMessage)
+	s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, raw, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Default().Debug("live connection dropped", zap.Error(err))
+			}
+			return
+		}
+		//any message proves the client is still there
+		s.conn.SetReadDeadline(time.Now().Add(pongWait))
+		select {
+		case reqs <- raw:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *liveSession) write() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		s.conn.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-s.out:
+			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(s.closeCode, s.closeReason))
+				return
+			}
+			if err := s.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send queues a message without blocking. If the client has let the queue fill up it's cut off rather than holding
+// up everyone else, and send returns false.
+func (s *liveSession) send(msg liveMessage) bool {
+	select {
+	case s.out <- msg:
+		return true
+	default:
+		s.closeCode, s.closeReason = websocket.CloseTryAgainLater, "client too slow"
+		return false
+	}
+}
+
+func (s *liveSession) sendError(ref string, err error) bool {
+	v, ok := err.(*terr.TodoError)
+	if !ok {
+		v = terr.InternalError()
+	}
+	return s.send(liveMessage{Type: "error", Ref: ref, Error: json.RawMessage(v.Error())})
+}
+
+func (s *liveSession) handle(ctx context.Context, raw []byte) bool {
+	var req liveRequest
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return s.sendError("", terr.ErrorWithCode("bad request", fmt.Sprintf("invalid message: %s", err.Error()), 400))
+	}
+	switch req.Type {
+	case "subscribe":
+		return s.subscribe(ctx, req)
+	case "unsubscribe":
+		delete(s.subs, req.Ref)
+		return s.send(liveMessage{Type: "unsubscribed", Ref: req.Ref})
+	case "create", "update", "delete":
+		return s.mutate(ctx, req)
+	}
+	return s.sendError(req.Ref, terr.ErrorWithCode("bad request", fmt.Sprintf("unknown message type %q", req.Type), 400))
+}
+
+func (s *liveSession) subscribe(ctx context.Context, req liveRequest) bool {
+	ctx, span := tracing.Tracer().Start(ctx, "LiveSubscribe")
+	defer span.End()
+	if req.Ref == "" {
+		return s.sendError(req.Ref, terr.ErrorWithCode("invalid param", "subscriptions need a ref", 400))
+	}
+	if _, ok := s.subs[req.Ref]; !ok && len(s.subs) >= liveMaxSubs {
+		return s.sendError(req.Ref, terr.ErrorWithCode("invalid param", fmt.Sprintf("no more than %d subscriptions per connection", liveMaxSubs), 400))
+	}
+	query := req.Query
+	if req.List != "" {
+		list, err := s.h.SmartList.GetById(ctx, req.List)
+		if err != nil {
+			return s.sendError(req.Ref, err)
+		}
+		query = *list.Query
+	}
+	items, err := s.h.TodoItem.Find(ctx, query)
+	if err != nil {
+		return s.sendError(req.Ref, err)
+	}
+	audience, err := s.h.TodoItem.Audience(ctx)
+	if err != nil {
+		return s.sendError(req.Ref, err)
+	}
+	sub := &liveSub{query: query, seen: map[string]struct{}{}, audience: audience}
+	for _, i := range items {
+		sub.seen[*i.Id] = struct{}{}
+	}
+	s.subs[req.Ref] = sub
+	if items == nil {
+		items = []todoitem.TodoItem{}
+	}
+	return s.send(liveMessage{Type: "subscribed", Ref: req.Ref, Items: items})
+}
+
+func (s *liveSession) mutate(ctx context.Context, req liveRequest) bool {
+	ctx, span := tracing.Tracer().Start(ctx, "LiveMutate")
+	defer span.End()
+	if req.Type != "delete" && req.Item == nil {
+		return s.sendError(req.Ref, terr.ErrorWithCode("invalid param", fmt.Sprintf("%s needs an item", req.Type), 400))
+	}
+	var item todoitem.TodoItem
+	var err error
+	switch req.Type {
+	case "create":
+		item, err = s.h.TodoItem.Create(ctx, *req.Item)
+	case "update":
+		item, err = s.h.TodoItem.Update(ctx, *req.Item, req.Id)
+	case "delete":
+		item, err = s.h.TodoItem.Delete(ctx, req.Id)
+	}
+	if err != nil {
+		return s.sendError(req.Ref, err)
+	}
+	return s.send(liveMessage{Type: "result", Ref: req.Ref, Item: &item})
+}
+
+// dispatch sends an event to every subscription the item is in, or has just left. inList tells the client which.
+// Items in lists the client can't see are never in its subscriptions. Assignments aren't changes of their own, the
+// item's update comes separately.
+func (s *liveSession) dispatch(ctx context.Context, env events.Envelope) bool {
+	e := env.Event
+	if e.Item.Id == nil || e.Type == todoitem.EventAssigned {
+		return true
+	}
+	id := *e.Item.Id
+	for ref, sub := range s.subs {
+		if !sub.audience.Sees(e) {
+			continue
+		}
+		pred, err := s.h.TodoItem.Predicate(sub.query)
+		if err != nil {
+			//it compiled when subscribing, so this really shouldn't happen
+			log.Default().Error("failed compiling live subscription", zap.String("query", sub.query), zap.Error(err))
+			continue
+		}
+		in := pred.Match(e.Item)
+		_, was := sub.seen[id]
+		if !in && !was {
+			continue
+		}
+		if in {
+			sub.seen[id] = struct{}{}
+		} else {
+			delete(sub.seen, id)
+		}
+		if !s.send(liveMessage{Type: "event", Subscription: ref, EventId: env.Id, Event: &e, InList: &in}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,316 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/user"
+)
+
+type liveServer struct {
+	url   string
+	hub   *events.Hub
+	lists *smartlist.Core
+}
+
+func newLiveServer(t *testing.T) liveServer {
+	hub := events.NewHub(10)
+	todos := todoitem.NewCore(memdb.NewStore(), hub)
+	lists := smartlist.NewCore(&mockSmartListStorer{lists: map[string]smartlist.SmartList{}}, todos)
+	parent := chi.NewRouter()
+	subject := NewLiveHandlers(todos, lists, hub)
+	subject.RegisterLiveEndpoints(parent, "/api")
+	srv := httptest.NewServer(parent)
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	return liveServer{url: "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/todo/live", hub: hub, lists: lists}
+}
+
+func dialLive(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendLive(t *testing.T, conn *websocket.Conn, msg string) {
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLive(t *testing.T, conn *websocket.Conn) liveMessage {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg liveMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestLiveSubscribeAndEdit(t *testing.T) {
+	srv := newLiveServer(t)
+	alice := dialLive(t, srv.url)
+	bob := dialLive(t, srv.url)
+
+	sendLive(t, alice, `{"type":"subscribe","ref":"work","query":"tag:work"}`)
+	msg := readLive(t, alice)
+	assert.Equal(t, "subscribed", msg.Type)
+	assert.Equal(t, "work", msg.Ref)
+	assert.Empty(t, msg.Items)
+
+	sendLive(t, bob, `{"type":"create","ref":"1","item":{"summary":"not work"}}`)
+	msg = readLive(t, bob)
+	assert.Equal(t, "result", msg.Type)
+	sendLive(t, bob, `{"type":"create","ref":"2","item":{"summary":"write report","tags":["work"]}}`)
+	msg = readLive(t, bob)
+	assert.Equal(t, "result", msg.Type)
+	assert.Equal(t, "2", msg.Ref)
+	id := *msg.Item.Id
+
+	//alice never hears about the item that isn't in her list
+	msg = readLive(t, alice)
+	assert.Equal(t, "event", msg.Type)
+	assert.Equal(t, "work", msg.Subscription)
+	assert.Equal(t, todoitem.EventCreated, msg.Event.Type)
+	assert.Equal(t, id, *msg.Event.Item.Id)
+	assert.True(t, *msg.InList)
+	assert.NotEmpty(t, msg.EventId)
+
+	//taking the tag off moves it out of the list, which alice is told about once
+	sendLive(t, alice, `{"type":"update","ref":"3","id":"`+id+`","item":{"summary":"write report","tags":["home"]}}`)
+	msg = readLive(t, alice)
+	assert.Equal(t, "result", msg.Type)
+	msg = readLive(t, alice)
+	assert.Equal(t, todoitem.EventUpdated, msg.Event.Type)
+	assert.False(t, *msg.InList)
+
+	sendLive(t, bob, `{"type":"update","ref":"4","id":"`+id+`","item":{"summary":"still not work"}}`)
+	readLive(t, bob)
+	sendLive(t, bob, `{"type":"create","ref":"5","item":{"summary":"review","tags":["work"]}}`)
+	readLive(t, bob)
+	msg = readLive(t, alice)
+	assert.Equal(t, "review", *msg.Event.Item.Summary)
+
+	sendLive(t, bob, `{"type":"delete","ref":"6","id":"`+*msg.Event.Item.Id+`"}`)
+	msg = readLive(t, bob)
+	assert.True(t, *msg.Item.Deleted)
+	msg = readLive(t, alice)
+	assert.Equal(t, todoitem.EventDeleted, msg.Event.Type)
+	assert.False(t, *msg.InList, "deleted items leave the list")
+}
+
+func TestLiveSharedList(t *testing.T) {
+	hub := events.NewHub(10)
+	users := user.NewCore(memdb.NewUserStore())
+	members := member.NewCore(memdb.NewMemberStore(), users)
+	todos := todoitem.NewCore(memdb.NewStore(), hub)
+	todos.ShareWith(members)
+	router := chi.NewRouter()
+	router.Use(Authenticate(users, true, UserPublicPaths("/api")...))
+	ush := NewUserHandlers(users)
+	ush.RegisterUserEndpoints(router, "/api")
+	tdh := NewTodoHandlers(todos)
+	tdh.RegisterTodoEndpoints(router, "/api")
+	mbh := NewMemberHandlers(members)
+	mbh.RegisterMemberEndpoints(router, "/api")
+	lvh := NewLiveHandlers(todos, nil, hub)
+	lvh.RegisterLiveEndpoints(router, "/api")
+	srv := httptest.NewServer(router)
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	sam := register(t, router, "sam@example.com")
+	jo := register(t, router, "jo@example.com")
+	kim := register(t, router, "kim@example.com")
+	editor := member.RoleEditor
+	rr := serve(router, "POST", "/api/lists/me/members", sam, member.Member{Email: newId("jo@example.com"), Role: &editor})
+	assert.Equal(t, 201, rr.Code, rr.Body.String())
+	var invite member.Member
+	json.Unmarshal(rr.Body.Bytes(), &invite)
+	assert.Equal(t, 200, serve(router, "POST", "/api/invitations/"+*invite.Id+"/accept", jo, nil).Code)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/todo/live"
+	dial := func(authorization string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {authorization}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		sendLive(t, conn, `{"type":"subscribe","ref":"all","query":""}`)
+		assert.Equal(t, "subscribed", readLive(t, conn).Type)
+		return conn
+	}
+	joConn, kimConn := dial(jo), dial(kim)
+
+	rr = serve(router, "POST", "/api/todo", sam, todoitem.TodoItem{Summary: newId("sam's item")})
+	assert.Equal(t, 201, rr.Code, rr.Body.String())
+	msg := readLive(t, joConn)
+	assert.Equal(t, todoitem.EventCreated, msg.Event.Type)
+	assert.Equal(t, "sam's item", *msg.Event.Item.Summary, "jo edits sam's list")
+
+	serve(router, "POST", "/api/todo", kim, todoitem.TodoItem{Summary: newId("kim's item")})
+	msg = readLive(t, kimConn)
+	assert.Equal(t, "kim's item", *msg.Event.Item.Summary, "kim never heard about sam's item")
+}
+
+func TestLiveOrigin(t *testing.T) {
+	hub := events.NewHub(10)
+	parent := chi.NewRouter()
+	subject := NewLiveHandlers(todoitem.NewCore(memdb.NewStore(), hub), nil, hub)
+	subject.Origins = []string{"https://app.example.com/"}
+	subject.RegisterLiveEndpoints(parent, "/api")
+	srv := httptest.NewServer(parent)
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/todo/live"
+
+	type test struct {
+		name   string
+		origin string
+		ok     bool
+	}
+	tests := []test{
+		{name: "not a browser", origin: "", ok: true},
+		{name: "the server itself", origin: srv.URL, ok: true},
+		{name: "allowed", origin: "https://App.example.com", ok: true},
+		{name: "another site", origin: "https://evil.example.com", ok: false},
+		{name: "allowed host on another scheme", origin: "http://app.example.com", ok: false},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if !tt.ok {
+				assert.Equal(t, websocket.ErrBadHandshake, err)
+				if assert.NotNil(t, resp) {
+					assert.Equal(t, 403, resp.StatusCode)
+				}
+				return
+			}
+			if assert.Nil(t, err) {
+				conn.Close()
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestLiveSubscribeSmartList(t *testing.T) {
+	srv := newLiveServer(t)
+	name, query := "urgent", "priority:high"
+	list, err := srv.lists.Create(context.Background(), smartlist.SmartList{Name: &name, Query: &query})
+	assert.Nil(t, err)
+	conn := dialLive(t, srv.url)
+
+	sendLive(t, conn, `{"type":"create","ref":"1","item":{"summary":"fire","priority":"high"}}`)
+	readLive(t, conn)
+	sendLive(t, conn, `{"type":"subscribe","ref":"urgent","list":"`+*list.Id+`"}`)
+	msg := readLive(t, conn)
+	assert.Equal(t, "subscribed", msg.Type)
+	if assert.Len(t, msg.Items, 1) {
+		assert.Equal(t, "fire", *msg.Items[0].Summary)
+	}
+
+	sendLive(t, conn, `{"type":"unsubscribe","ref":"urgent"}`)
+	assert.Equal(t, "unsubscribed", readLive(t, conn).Type)
+	sendLive(t, conn, `{"type":"create","ref":"2","item":{"summary":"flood","priority":"high"}}`)
+	assert.Equal(t, "result", readLive(t, conn).Type)
+	sendLive(t, conn, `{"type":"subscribe","ref":"missing","list":"nope"}`)
+	assert.Equal(t, "error", readLive(t, conn).Type, "no event for the unsubscribed list should come first")
+}
+
+func TestLiveErrors(t *testing.T) {
+	srv := newLiveServer(t)
+	conn := dialLive(t, srv.url)
+
+	type test struct {
+		name   string
+		send   string
+		ref    string
+		expect string
+	}
+	tests := []test{
+		{name: "bad json", send: `{"type":`, expect: `"code":400`},
+		{name: "unknown field", send: `{"type":"create","summary":"x"}`, expect: `unknown field`},
+		{name: "unknown type", send: `{"type":"shout","ref":"a"}`, ref: "a", expect: `unknown message type`},
+		{name: "bad filter", send: `{"type":"subscribe","ref":"b","query":"prio:high"}`, ref: "b", expect: `invalid filter`},
+		{name: "missing ref", send: `{"type":"subscribe","query":"tag:work"}`, expect: `need a ref`},
+		{name: "missing list", send: `{"type":"subscribe","ref":"c","list":"nope"}`, ref: "c", expect: `"code":404`},
+		{name: "missing item", send: `{"type":"create","ref":"d"}`, ref: "d", expect: `create needs an item`},
+		{name: "empty summary", send: `{"type":"create","ref":"e","item":{}}`, ref: "e", expect: `summary cannot be empty`},
+		{name: "update unknown", send: `{"type":"update","ref":"f","id":"nope","item":{"summary":"x"}}`, ref: "f", expect: `"code":404`},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			sendLive(t, conn, tt.send)
+			msg := readLive(t, conn)
+			assert.Equal(t, "error", msg.Type)
+			assert.Equal(t, tt.ref, msg.Ref)
+			assert.Contains(t, string(msg.Error), tt.expect)
+			var body map[string]any
+			assert.Nil(t, json.Unmarshal(msg.Error, &body))
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestLivePing(t *testing.T) {
+	defer func(p time.Duration) { pingPeriod = p }(pingPeriod)
+	pingPeriod = 20 * time.Millisecond
+	srv := newLiveServer(t)
+	conn := dialLive(t, srv.url)
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go conn.ReadMessage()
+	select {
+	case <-pinged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a ping")
+	}
+}
+
+func TestLiveClosesWhenHubCloses(t *testing.T) {
+	srv := newLiveServer(t)
+	conn := dialLive(t, srv.url)
+	srv.hub.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+}
+
+func TestLiveSendCutsOffSlowClients(t *testing.T) {
+	s := &liveSession{out: make(chan liveMessage, 1), closeCode: websocket.CloseNormalClosure}
+	assert.True(t, s.send(liveMessage{Type: "event"}))
+	assert.False(t, s.send(liveMessage{Type: "event"}))
+	assert.Equal(t, websocket.CloseTryAgainLater, s.closeCode)
+}
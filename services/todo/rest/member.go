@@ -0,0 +1,140 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+type MemberHandlers struct {
+	Member *member.Core
+}
+
+func NewMemberHandlers(core *member.Core) MemberHandlers {
+	return MemberHandlers{
+		Member: core,
+	}
+}
+
+// RegisterMemberEndpoints mounts who a list is shared with under <prefix>/lists/{listId}/members, where the list id
+// is the id of the user it belongs to, or me. Invitations to other people's lists are under <prefix>/invitations.
+func (h *MemberHandlers) RegisterMemberEndpoints(parent *chi.Mux, prefix string) {
+	router := chi.NewRouter()
+	router.Get("/", h.GetMembers)
+	router.Post("/", h.InviteMember)
+	router.Patch("/{id}", h.UpdateMember)
+	router.Delete("/{id}", h.RemoveMember)
+	parent.Mount(fmt.Sprintf("%s/lists/{listId}/members", prefix), router)
+
+	invitations := chi.NewRouter()
+	invitations.Get("/", h.GetInvitations)
+	invitations.Post("/{id}/accept", h.AcceptInvitation)
+	invitations.Delete("/{id}", h.DeclineInvitation)
+	parent.Mount(fmt.Sprintf("%s/invitations", prefix), invitations)
+}
+
+func (h *MemberHandlers) GetMembers(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetMembers")
+	defer span.End()
+	members, err := h.Member.Members(ctx, chi.URLParam(r, "listId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, members)
+}
+
+// InviteMember shares the list with someone by their email. They can't see it until they accept.
+func (h *MemberHandlers) InviteMember(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "InviteMember")
+	defer span.End()
+	m, ok := decodeMember(w, r)
+	if !ok {
+		return
+	}
+	created, err := h.Member.Invite(ctx, chi.URLParam(r, "listId"), m)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 201, created)
+}
+
+// UpdateMember changes a member's role.
+func (h *MemberHandlers) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "UpdateMember")
+	defer span.End()
+	m, ok := decodeMember(w, r)
+	if !ok {
+		return
+	}
+	updated, err := h.Member.Update(ctx, chi.URLParam(r, "listId"), chi.URLParam(r, "id"), m)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, updated)
+}
+
+// RemoveMember returns who was removed.
+func (h *MemberHandlers) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "RemoveMember")
+	defer span.End()
+	removed, err := h.Member.Remove(ctx, chi.URLParam(r, "listId"), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, removed)
+}
+
+// GetInvitations is every list shared with the caller, accepted or not.
+func (h *MemberHandlers) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetInvitations")
+	defer span.End()
+	invitations, err := h.Member.Invitations(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, invitations)
+}
+
+func (h *MemberHandlers) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "AcceptInvitation")
+	defer span.End()
+	accepted, err := h.Member.Accept(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, accepted)
+}
+
+// DeclineInvitation turns down an invitation, or leaves the list if it was accepted.
+func (h *MemberHandlers) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "DeclineInvitation")
+	defer span.End()
+	declined, err := h.Member.Decline(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, declined)
+}
+
+func decodeMember(w http.ResponseWriter, r *http.Request) (member.Member, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var m member.Member
+	if err := dec.Decode(&m); err != nil {
+		figureDecodeError(err, w, r)
+		return member.Member{}, false
+	}
+	return m, true
+}
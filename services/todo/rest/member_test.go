@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// newSharingRouter serves users, todos and members the way the server does.
+func newSharingRouter(t *testing.T) *chi.Mux {
+	users := user.NewCore(memdb.NewUserStore())
+	members := member.NewCore(memdb.NewMemberStore(), users)
+	todos := todoitem.NewCore(memdb.NewStore())
+	todos.ShareWith(members)
+	router := chi.NewRouter()
+	router.Use(Authenticate(users, true, UserPublicPaths("/api")...))
+	ush := NewUserHandlers(users)
+	ush.RegisterUserEndpoints(router, "/api")
+	tdh := NewTodoHandlers(todos)
+	tdh.RegisterTodoEndpoints(router, "/api")
+	mbh := NewMemberHandlers(members)
+	mbh.RegisterMemberEndpoints(router, "/api")
+	return router
+}
+
+func TestSharingLists(t *testing.T) {
+	router := newSharingRouter(t)
+	sam := register(t, router, "sam@example.com")
+	alex := register(t, router, "alex@example.com")
+	jo := register(t, router, "jo@example.com")
+	rr := serve(router, "GET", "/api/users/me", sam, nil)
+	var me user.User
+	json.Unmarshal(rr.Body.Bytes(), &me)
+
+	rr = serve(router, "POST", "/api/todo", sam, todoitem.TodoItem{Summary: newId("sam's item")})
+	var item todoitem.TodoItem
+	json.Unmarshal(rr.Body.Bytes(), &item)
+	itemPath := "/api/todo/" + *item.Id
+
+	invite := func(authorization, email string, role member.Role) member.Member {
+		rr := serve(router, "POST", "/api/lists/me/members", authorization, member.Member{Email: &email, Role: &role})
+		if !assert.Equal(t, 201, rr.Code, rr.Body.String()) {
+			t.FailNow()
+		}
+		var m member.Member
+		json.Unmarshal(rr.Body.Bytes(), &m)
+		return m
+	}
+	accept := func(authorization string, m member.Member) {
+		rr := serve(router, "POST", "/api/invitations/"+*m.Id+"/accept", authorization, nil)
+		assert.Equal(t, 200, rr.Code, rr.Body.String())
+	}
+	alexs := invite(sam, "alex@example.com", member.RoleViewer)
+	assert.Equal(t, 404, serve(router, "GET", itemPath, alex, nil).Code, "not until it's accepted")
+	rr = serve(router, "GET", "/api/invitations", alex, nil)
+	assert.Contains(t, rr.Body.String(), *alexs.Id)
+	accept(alex, alexs)
+	jos := invite(sam, "jo@example.com", member.RoleEditor)
+	accept(jo, jos)
+
+	type test struct {
+		name          string
+		authorization string
+		method, path  string
+		body          any
+		code          int
+	}
+	tests := []test{
+		{name: "viewer can read", authorization: alex, method: "GET", path: itemPath, code: 200},
+		{name: "viewer can't change", authorization: alex, method: "PATCH", path: itemPath, body: todoitem.TodoItem{Summary: newId("x")}, code: 403},
+		{name: "viewer can't delete", authorization: alex, method: "DELETE", path: itemPath, code: 403},
+		{name: "viewer can't add", authorization: alex, method: "POST", path: "/api/todo", body: todoitem.TodoItem{Summary: newId("x"), OwnerId: me.Id}, code: 403},
+		{name: "viewer can't invite", authorization: alex, method: "POST", path: "/api/lists/" + *me.Id + "/members", body: member.Member{Email: newId("x@example.com")}, code: 403},
+		{name: "viewer sees members", authorization: alex, method: "GET", path: "/api/lists/" + *me.Id + "/members", code: 200},
+		{name: "editor can change", authorization: jo, method: "PATCH", path: itemPath, body: todoitem.TodoItem{Summary: newId("changed")}, code: 200},
+		{name: "editor can add", authorization: jo, method: "POST", path: "/api/todo", body: todoitem.TodoItem{Summary: newId("jo's"), OwnerId: me.Id}, code: 201},
+		{name: "editor can't change roles", authorization: jo, method: "PATCH", path: "/api/lists/" + *me.Id + "/members/" + *alexs.Id, body: member.Member{Role: newRole(member.RoleOwner)}, code: 403},
+		{name: "owner changes roles", authorization: sam, method: "PATCH", path: "/api/lists/me/members/" + *alexs.Id, body: member.Member{Role: newRole(member.RoleEditor)}, code: 200},
+		{name: "now alex can change", authorization: alex, method: "PATCH", path: itemPath, body: todoitem.TodoItem{Summary: newId("again")}, code: 200},
+		{name: "unknown list", authorization: alex, method: "GET", path: "/api/lists/nope/members", code: 404},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := serve(router, tt.method, tt.path, tt.authorization, tt.body)
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+		}
+		t.Run(tt.name, tf)
+	}
+
+	//shared items are listed alongside your own
+	serve(router, "POST", "/api/todo", alex, todoitem.TodoItem{Summary: newId("alex's item")})
+	rr = serve(router, "GET", "/api/todo", alex, nil)
+	var items []todoitem.TodoItem
+	json.Unmarshal(rr.Body.Bytes(), &items)
+	assert.Len(t, items, 3)
+
+	rr = serve(router, "DELETE", "/api/lists/me/members/"+*jos.Id, sam, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 404, serve(router, "GET", itemPath, jo, nil).Code, "removed members lose access")
+}
+
+func newRole(r member.Role) *member.Role {
+	return &r
+}
@@ -0,0 +1,215 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/oidc"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/user"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcCookie = "todo_oidc"
+	//oidcFlowTTL is how long someone has at the identity provider before the login has to be started again
+	oidcFlowTTL = 10 * time.Minute
+)
+
+// OIDCHandlers log people in through an identity provider. The flow in progress is kept in a signed cookie rather
+// than on the server, so the callback can land on any replica.
+type OIDCHandlers struct {
+	Provider *oidc.Provider
+	Users    *user.Core
+	Tokens   *token.Core
+	//SessionTTL is how long the access token handed out on login lasts
+	SessionTTL time.Duration
+	//SessionScope is the scope of that token, write unless it's changed. Admin is more than a browser needs
+	SessionScope string
+	//UIURL is where the browser is sent back to once it's logged in, with the token in the fragment. Without one the
+	//callback answers with the session as json
+	UIURL string
+	key   signer
+}
+
+// NewOIDCHandlers signs the flow cookie with key. Every replica needs the same one, an empty key makes a random one
+// which only works with a single replica.
+func NewOIDCHandlers(provider *oidc.Provider, users *user.Core, tokens *token.Core, sessionTTL time.Duration, key string) (OIDCHandlers, error) {
+	k, err := newSigner(key)
+	if err != nil {
+		return OIDCHandlers{}, err
+	}
+	return OIDCHandlers{
+		Provider:     provider,
+		Users:        users,
+		Tokens:       tokens,
+		SessionTTL:   sessionTTL,
+		SessionScope: auth.ScopeWrite,
+		key:          k,
+	}, nil
+}
+
+// oidcSession is what a login through the identity provider gets back. The token is used as a bearer token after.
+type oidcSession struct {
+	User  user.User   `json:"user"`
+	Token token.Token `json:"token"`
+}
+
+type oidcFlow struct {
+	oidc.Flow
+	Expires int64 `json:"expires"`
+}
+
+// RegisterOIDCEndpoints mounts <prefix>/oidc/login and <prefix>/oidc/callback, which has to be the redirect url
+// registered with the provider. Both have to be left out of Authenticate's checks, see OIDCPublicPaths.
+func (h *OIDCHandlers) RegisterOIDCEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/oidc/login", prefix), h.Login(prefix))
+	parent.Get(fmt.Sprintf("%s/oidc/callback", prefix), h.Callback(prefix))
+}
+
+// OIDCPublicPaths are the oidc endpoints, which are how people get credentials.
+func OIDCPublicPaths(prefix string) []string {
+	return []string{fmt.Sprintf("%s/oidc/login", prefix), fmt.Sprintf("%s/oidc/callback", prefix)}
+}
+
+// Login sends the browser to the identity provider.
+func (h *OIDCHandlers) Login(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Tracer().Start(r.Context(), "OIDCLogin")
+		defer span.End()
+		f, err := oidc.NewFlow()
+		if err != nil {
+			log.Default().Error("failed to start oidc login", zap.Error(err))
+			writeError(w, terr.InternalError())
+			return
+		}
+		value, err := h.key.sign(oidcFlow{Flow: f, Expires: time.Now().Add(oidcFlowTTL).Unix()})
+		if err != nil {
+			log.Default().Error("failed to start oidc login", zap.Error(err))
+			writeError(w, terr.InternalError())
+			return
+		}
+		http.SetCookie(w, h.cookie(prefix, value, int(oidcFlowTTL.Seconds())))
+		http.Redirect(w, r, h.Provider.AuthCodeURL(f), http.StatusFound)
+	}
+}
+
+// Callback is where the provider sends the browser back to. It logs the user in (making their account the first
+// time) and hands out an access token. With a UIURL the browser is sent on to the ui with the token (or what went
+// wrong) in the url's fragment, which browsers keep to themselves rather than sending to any server or in a Referer.
+func (h *OIDCHandlers) Callback(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Tracer().Start(r.Context(), "OIDCCallback")
+		defer span.End()
+		q := r.URL.Query()
+		//the flow is done with whatever happens, it can't be used again
+		http.SetCookie(w, h.cookie(prefix, "", -1))
+		if e := q.Get("error"); e != "" {
+			h.fail(w, r, terr.ErrorWithCode("unauthorized", fmt.Sprintf("login failed: %s %s", e, q.Get("error_description")), 401))
+			return
+		}
+		f, err := h.flow(r)
+		if err != nil {
+			h.fail(w, r, err)
+			return
+		}
+		if !hmac.Equal([]byte(q.Get("state")), []byte(f.State)) {
+			h.fail(w, r, terr.ErrorWithCode("unauthorized", "login failed: the state doesn't match the login that was started", 401))
+			return
+		}
+		ext, err := h.Provider.Exchange(ctx, f.Flow, q.Get("code"))
+		if err != nil {
+			h.fail(w, r, err)
+			return
+		}
+		u, err := h.Users.LoginExternal(ctx, ext)
+		if err != nil {
+			h.fail(w, r, err)
+			return
+		}
+		if u.WorkspaceId != nil {
+			//the session belongs wherever the user does, not the workspace they'd land in if they were new
+			ctx = auth.WithWorkspace(ctx, *u.WorkspaceId)
+		}
+		name, scope, expires := "oidc login", h.SessionScope, time.Now().Add(h.SessionTTL)
+		t, err := h.Tokens.Session(ctx, *u.Id, token.Token{Name: &name, Scope: &scope, Expires: &expires})
+		if err != nil {
+			h.fail(w, r, err)
+			return
+		}
+		if h.UIURL == "" {
+			writeJSON(w, 200, oidcSession{User: u, Token: t})
+			return
+		}
+		h.toUI(w, r, url.Values{
+			"access_token": {*t.Token},
+			"token_type":   {"bearer"},
+			"expires_in":   {strconv.Itoa(int(h.SessionTTL.Seconds()))},
+			"scope":        {scope},
+		})
+	}
+}
+
+// fail is writeError, or with a UIURL the error sent on to the ui as error and error_description, the same as an
+// oauth implicit grant would.
+func (h *OIDCHandlers) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if h.UIURL == "" {
+		writeError(w, err)
+		return
+	}
+	v, ok := err.(*terr.TodoError)
+	if !ok {
+		v = terr.InternalError()
+	}
+	h.toUI(w, r, url.Values{"error": {v.Message()}, "error_description": {v.Details()}})
+}
+
+func (h *OIDCHandlers) toUI(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	u, err := url.Parse(h.UIURL)
+	if err != nil {
+		//checked when the server starts
+		writeError(w, terr.InternalError())
+		return
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	//no-referrer as well, so the token can't leak from the ui's first page load
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String()+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func (h *OIDCHandlers) cookie(prefix, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     fmt.Sprintf("%s/oidc", prefix),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.Provider.RedirectURL(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (h *OIDCHandlers) flow(r *http.Request) (oidcFlow, error) {
+	invalid := terr.ErrorWithCode("unauthorized", "login failed: no login was started from this browser, or it took too long", 401)
+	c, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return oidcFlow{}, invalid
+	}
+	var f oidcFlow
+	if !h.key.open(c.Value, &f) || time.Now().Unix() > f.Expires {
+		return oidcFlow{}, invalid
+	}
+	return f, nil
+}
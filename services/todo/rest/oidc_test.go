@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/oidc"
+	"github.com/stumacwastaken/todo/oidc/oidctest"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// newOIDCRouter serves oidc logins against a mock identity provider, with password logins as well. change can set the
+// handlers up differently.
+func newOIDCRouter(t *testing.T, change ...func(*OIDCHandlers)) (*chi.Mux, *oidctest.Server) {
+	idp, err := oidctest.NewServer("todo", "shh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(idp.Close)
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{Issuer: idp.URL, ClientId: "todo", ClientSecret: "shh",
+		RedirectURL: "http://localhost:9000/api/oidc/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := user.NewCore(memdb.NewUserStore())
+	tokens := token.NewCore(memdb.NewTokenStore())
+	router := chi.NewRouter()
+	router.Use(Authenticate(auth.Schemes{"basic": users, "bearer": tokens.Bearer(nil)}, true,
+		append(UserPublicPaths("/api"), OIDCPublicPaths("/api")...)...))
+	oh, err := NewOIDCHandlers(provider, users, tokens, time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range change {
+		c(&oh)
+	}
+	oh.RegisterOIDCEndpoints(router, "/api")
+	ush := NewUserHandlers(users)
+	ush.RegisterUserEndpoints(router, "/api")
+	tkh := NewTokenHandlers(tokens)
+	tkh.RegisterTokenEndpoints(router, "/api")
+	tdh := NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(router, "/api")
+	return router, idp
+}
+
+// startOIDCLogin starts a login, returning the flow cookie and where the provider sends the browser back to.
+func startOIDCLogin(t *testing.T, router http.Handler, idp *oidctest.Server, claims map[string]any) (*http.Cookie, string) {
+	rr := serve(router, "GET", "/api/oidc/login", "", nil)
+	if !assert.Equal(t, 302, rr.Code) {
+		t.FailNow()
+	}
+	cookies := rr.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		t.FailNow()
+	}
+	assert.True(t, cookies[0].HttpOnly)
+	callback, err := idp.Authorize(rr.Header().Get("Location"), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(callback)
+	return cookies[0], u.RequestURI()
+}
+
+func callback(router http.Handler, cookie *http.Cookie, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOIDCLogin(t *testing.T) {
+	router, idp := newOIDCRouter(t)
+	sam := map[string]any{"sub": "123", "email": "sam@example.com", "email_verified": true, "name": "Sam"}
+
+	cookie, path := startOIDCLogin(t, router, idp, sam)
+	rr := callback(router, cookie, path)
+	if !assert.Equal(t, 200, rr.Code, rr.Body.String()) {
+		t.FailNow()
+	}
+	var session oidcSession
+	json.Unmarshal(rr.Body.Bytes(), &session)
+	assert.Equal(t, "sam@example.com", *session.User.Email)
+	assert.Equal(t, auth.ScopeWrite, *session.Token.Scope, "more than enough for a browser")
+	bearer := "Bearer " + *session.Token.Token
+	assert.Equal(t, 200, serve(router, "GET", "/api/todo", bearer, nil).Code)
+	rr = serve(router, "GET", "/api/users/me", bearer, nil)
+	var me user.User
+	json.Unmarshal(rr.Body.Bytes(), &me)
+	assert.Equal(t, session.User.Id, me.Id)
+
+	//logging in again is the same user
+	cookie, path = startOIDCLogin(t, router, idp, sam)
+	rr = callback(router, cookie, path)
+	json.Unmarshal(rr.Body.Bytes(), &session)
+	assert.Equal(t, me.Id, session.User.Id)
+
+	//someone who registered with a password is linked by a verified email
+	register(t, router, "alex@example.com")
+	cookie, path = startOIDCLogin(t, router, idp, map[string]any{"sub": "456", "email": "alex@example.com", "email_verified": true})
+	rr = callback(router, cookie, path)
+	assert.Equal(t, 200, rr.Code)
+	json.Unmarshal(rr.Body.Bytes(), &session)
+	rr = serve(router, "POST", "/api/login", "", user.Credentials{Email: "alex@example.com", Password: "correct horse"})
+	var alex user.User
+	json.Unmarshal(rr.Body.Bytes(), &alex)
+	assert.Equal(t, alex.Id, session.User.Id)
+
+	//but not an unverified one
+	register(t, router, "jo@example.com")
+	cookie, path = startOIDCLogin(t, router, idp, map[string]any{"sub": "789", "email": "jo@example.com"})
+	assert.Equal(t, 409, callback(router, cookie, path).Code)
+}
+
+func TestOIDCCallback(t *testing.T) {
+	router, idp := newOIDCRouter(t)
+	sam := map[string]any{"sub": "123", "email": "sam@example.com", "email_verified": true}
+	type test struct {
+		name string
+		//change what the browser comes back with
+		change   func(c *http.Cookie, q url.Values)
+		noCookie bool
+	}
+	tests := []test{
+		{name: "no cookie", noCookie: true},
+		{name: "tampered cookie", change: func(c *http.Cookie, q url.Values) { c.Value = "x" + c.Value }},
+		{name: "wrong state", change: func(c *http.Cookie, q url.Values) { q.Set("state", "guess") }},
+		{name: "wrong code", change: func(c *http.Cookie, q url.Values) { q.Set("code", "guess") }},
+		{name: "provider error", change: func(c *http.Cookie, q url.Values) { q.Set("error", "access_denied") }},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			cookie, path := startOIDCLogin(t, router, idp, sam)
+			u, _ := url.Parse(path)
+			q := u.Query()
+			if tt.change != nil {
+				tt.change(cookie, q)
+			}
+			if tt.noCookie {
+				cookie = nil
+			}
+			u.RawQuery = q.Encode()
+			rr := callback(router, cookie, u.String())
+			assert.Equal(t, 401, rr.Code, rr.Body.String())
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestOIDCLoginToUI(t *testing.T) {
+	router, idp := newOIDCRouter(t, func(h *OIDCHandlers) { h.UIURL = "https://todo.example.com/app#old" })
+	sam := map[string]any{"sub": "123", "email": "sam@example.com", "email_verified": true}
+
+	cookie, path := startOIDCLogin(t, router, idp, sam)
+	rr := callback(router, cookie, path)
+	if !assert.Equal(t, 302, rr.Code, rr.Body.String()) {
+		t.FailNow()
+	}
+	assert.Equal(t, "no-referrer", rr.Header().Get("Referrer-Policy"))
+	to, _ := url.Parse(rr.Header().Get("Location"))
+	assert.Equal(t, "https://todo.example.com/app", to.Scheme+"://"+to.Host+to.Path)
+	assert.Empty(t, to.RawQuery, "the token only goes in the fragment, which never leaves the browser")
+	fragment, _ := url.ParseQuery(to.Fragment)
+	assert.Equal(t, "bearer", fragment.Get("token_type"))
+	assert.Equal(t, "3600", fragment.Get("expires_in"))
+	assert.Equal(t, auth.ScopeWrite, fragment.Get("scope"))
+	bearer := "Bearer " + fragment.Get("access_token")
+	assert.Equal(t, 200, serve(router, "GET", "/api/todo", bearer, nil).Code)
+	assert.Equal(t, 403, serve(router, "GET", "/api/tokens", bearer, nil).Code, "not an admin token")
+
+	cookie, path = startOIDCLogin(t, router, idp, sam)
+	rr = callback(router, cookie, path+"&error=access_denied")
+	assert.Equal(t, 302, rr.Code)
+	to, _ = url.Parse(rr.Header().Get("Location"))
+	fragment, _ = url.ParseQuery(to.Fragment)
+	assert.Equal(t, "unauthorized", fragment.Get("error"))
+	assert.Contains(t, fragment.Get("error_description"), "access_denied")
+	assert.Empty(t, fragment.Get("access_token"))
+}
@@ -0,0 +1,22 @@
+package rest
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// OpenAPISpec describes the rest api for generating clients. It's written by hand, TestOpenAPISpec fails if it drifts
+// from the routes or models.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+func RegisterOpenAPIEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/openapi.json", prefix), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write(OpenAPISpec)
+	})
+}
@@ -0,0 +1,188 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/user"
+	"github.com/stumacwastaken/todo/webhook"
+)
+
+type openAPI struct {
+	Servers []struct {
+		Url string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Enum       []string                   `json:"enum"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) openAPI {
+	var spec openAPI
+	if err := json.Unmarshal(OpenAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// undocumented are routes the spec leaves out on purpose: the websocket and graphql have their own protocols, and the
+// spec doesn't describe itself.
+var undocumented = map[string]bool{
+	"GET /api/todo/live":    true,
+	"POST /graphql":         true,
+	"GET /api/openapi.json": true,
+}
+
+// TestOpenAPISpec checks the spec against the routes as the server registers them, and the schemas against the json
+// the models marshal to. If it fails, update openapi.json.
+func TestOpenAPISpec(t *testing.T) {
+	spec := loadSpec(t)
+	if !assert.Len(t, spec.Servers, 1) {
+		return
+	}
+	base := spec.Servers[0].Url
+
+	router := chi.NewRouter()
+	tdh := NewTodoHandlers(nil)
+	tdh.RegisterTodoEndpoints(router, "/api")
+	tdh.RegisterStatsEndpoints(router, "/api")
+	tdh.RegisterSyncEndpoints(router, "/api")
+	tdh.RegisterExportEndpoints(router, "/api")
+	evh := NewEventHandlers(nil, nil)
+	evh.RegisterEventEndpoints(router, "/api")
+	slh := NewSmartListHandlers(nil)
+	slh.RegisterSmartListEndpoints(router, "/api")
+	lvh := NewLiveHandlers(nil, nil, nil)
+	lvh.RegisterLiveEndpoints(router, "/api")
+	whh := NewWebhookHandlers(nil)
+	whh.RegisterWebhookEndpoints(router, "/api")
+	cah := NewCalendarHandlers(nil, nil)
+	cah.RegisterCalendarEndpoints(router, "/api")
+	ush := NewUserHandlers(nil)
+	ush.RegisterUserEndpoints(router, "/api")
+	tkh := NewTokenHandlers(nil)
+	tkh.RegisterTokenEndpoints(router, "/api")
+	mbh := NewMemberHandlers(nil)
+	mbh.RegisterMemberEndpoints(router, "/api")
+	oh := OIDCHandlers{}
+	oh.RegisterOIDCEndpoints(router, "/api")
+	gqh := NewGraphQLHandlers(nil)
+	gqh.RegisterGraphQLEndpoints(router, "")
+	RegisterOpenAPIEndpoints(router, "/api")
+
+	registered := []string{}
+	chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		//mounted routers register their root as /prefix/ and serve it without the slash too
+		route = strings.TrimSuffix(strings.ReplaceAll(route, "/*/", "/"), "/")
+		if key := method + " " + route; !undocumented[key] {
+			registered = append(registered, key)
+		}
+		return nil
+	})
+	documented := []string{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented = append(documented, strings.ToUpper(method)+" "+base+path)
+		}
+	}
+	sort.Strings(registered)
+	sort.Strings(documented)
+	assert.Equal(t, registered, documented, "routes and openapi.json paths differ")
+
+	type test struct {
+		name   string
+		schema string
+		model  any
+	}
+	tests := []test{
+		{name: "todo item", schema: "TodoItem", model: todoitem.TodoItem{}},
+		{name: "event", schema: "Event", model: todoitem.Event{}},
+		{name: "stats", schema: "Stats", model: todoitem.Stats{}},
+		{name: "day stats", schema: "DayStats", model: todoitem.DayStats{}},
+		{name: "change set", schema: "ChangeSet", model: todoitem.ChangeSet{}},
+		{name: "change", schema: "Change", model: todoitem.Change{}},
+		{name: "change result", schema: "ChangeResult", model: todoitem.ChangeResult{}},
+		{name: "sync request", schema: "SyncRequest", model: syncRequest{}},
+		{name: "sync response", schema: "SyncResponse", model: syncResponse{}},
+		{name: "smart list", schema: "SmartList", model: smartlist.SmartList{}},
+		{name: "webhook", schema: "Webhook", model: webhook.Webhook{}},
+		{name: "delivery", schema: "Delivery", model: webhook.Delivery{}},
+		{name: "user", schema: "User", model: user.User{}},
+		{name: "new user", schema: "NewUser", model: user.NewUser{}},
+		{name: "credentials", schema: "Credentials", model: user.Credentials{}},
+		{name: "token", schema: "Token", model: token.Token{}},
+		{name: "ticket", schema: "Ticket", model: Ticket{}},
+		{name: "session", schema: "Session", model: oidcSession{}},
+		{name: "member", schema: "Member", model: member.Member{}},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			schema, ok := spec.Components.Schemas[tt.schema]
+			if !assert.True(t, ok, "no %s schema", tt.schema) {
+				return
+			}
+			props := []string{}
+			for p := range schema.Properties {
+				props = append(props, p)
+			}
+			sort.Strings(props)
+			assert.Equal(t, jsonFields(reflect.TypeOf(tt.model)), props)
+		}
+		t.Run(tt.name, tf)
+	}
+
+	priorities := []string{}
+	for _, f := range todoitem.FilterSchema.Fields {
+		if f.Name == "priority" {
+			priorities = f.Values
+		}
+	}
+	assert.Equal(t, priorities, spec.Components.Schemas["Priority"].Enum)
+}
+
+// jsonFields is the sorted names of the fields encoding/json would write for the struct type.
+func jsonFields(typ reflect.Type) []string {
+	fields := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("json"); tag != "" {
+			name, _, _ = strings.Cut(tag, ",")
+		}
+		if name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestGetOpenAPISpec(t *testing.T) {
+	router := chi.NewRouter()
+	RegisterOpenAPIEndpoints(router, "/api")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.JSONEq(t, string(OpenAPISpec), rr.Body.String())
+}
@@ -0,0 +1,233 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// RateLimit is how many requests each client can make to the paths under Prefix, refilled evenly over a minute. Burst
+// is how many can be made back to back, PerMinute if it's 0. When groups overlap the longest prefix wins, so "/" is a
+// default for everything the others don't cover.
+type RateLimit struct {
+	Prefix    string
+	PerMinute int
+	Burst     int
+}
+
+// ParseRateLimit reads a limit the way it's given on the command line, prefix=perMinute or prefix=perMinute/burst,
+// i.e: /api/todo=120/20.
+func ParseRateLimit(v string) (RateLimit, error) {
+	prefix, rate, ok := strings.Cut(v, "=")
+	if !ok || !strings.HasPrefix(prefix, "/") {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %s, use /path=requests a minute[/burst]", v)
+	}
+	perMinute, burst, hasBurst := strings.Cut(rate, "/")
+	l := RateLimit{Prefix: prefix}
+	var err error
+	if l.PerMinute, err = strconv.Atoi(perMinute); err != nil || l.PerMinute < 1 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %s, requests a minute must be a positive number", v)
+	}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst < 1 {
+			return RateLimit{}, fmt.Errorf("invalid rate limit %s, burst must be a positive number", v)
+		}
+	}
+	return l, nil
+}
+
+// Times is the limit with n times the rate and burst, for clients that stand in for more than one person.
+func (l RateLimit) Times(n int) RateLimit {
+	l.PerMinute *= n
+	l.Burst *= n
+	return l
+}
+
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.PerMinute)
+}
+
+// perSecond is how fast the bucket refills.
+func (l RateLimit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// covers reports if a cleaned path is in the group, on whole path segments so /api/todo doesn't cover /api/todos.
+func (l RateLimit) covers(path string) bool {
+	prefix := strings.TrimSuffix(l.Prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// pulled out so tests can move the clock
+var limitNowFn = time.Now
+
+// sweepEvery is how often buckets nobody has used in a while are dropped. A full bucket is the same as no bucket.
+const sweepEvery = time.Minute
+
+// maxBuckets is the most clients a limiter keeps buckets for between sweeps, so a flood of new ones can't run the
+// server out of memory. Past it new clients share one bucket per group until the sweep frees some up.
+var maxBuckets = 100000
+
+// overflow is the client everyone past maxBuckets counts as.
+const overflow = "overflow"
+
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	at     time.Time
+}
+
+// refill tops the bucket up for the time since it was last used.
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.limit.burst(), b.tokens+now.Sub(b.at).Seconds()*b.limit.perSecond())
+	b.at = now
+}
+
+type limiter struct {
+	limits []RateLimit
+	//client is who a request counts against, requests it returns false for aren't limited
+	client  func(*http.Request) (string, bool)
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func newLimiter(client func(*http.Request) (string, bool), limits []RateLimit) *limiter {
+	l := &limiter{limits: append([]RateLimit{}, limits...), client: client, buckets: map[string]*bucket{}, swept: limitNowFn()}
+	sort.SliceStable(l.limits, func(i, j int) bool {
+		return len(l.limits[i].Prefix) > len(l.limits[j].Prefix)
+	})
+	return l
+}
+
+// Limit is middleware that rate limits every address separately for each group of paths, with a token bucket. Paths
+// outside every group aren't limited. It goes before authentication, so it's what keeps a flood of requests from
+// costing a credential check each. Everyone behind a NAT or proxy shares an address, so its limits want to be
+// bigger than LimitUsers'. Responses in a group say how much of the limit is left with RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset (seconds until it's all back) and RateLimit-Policy headers. Going over is a 429
+// with a Retry-After of the seconds until the next request will go through.
+func Limit(limits ...RateLimit) func(http.Handler) http.Handler {
+	return newLimiter(address, limits).middleware
+}
+
+// LimitUsers is Limit for each signed in user rather than each address, so it has to come after Authenticate. A user
+// gets the same limits wherever their requests come from. Requests without a user have already been limited on their
+// address and are let through. The headers are the user's bucket, the one a signed in client is up against.
+func LimitUsers(limits ...RateLimit) func(http.Handler) http.Handler {
+	return newLimiter(principal, limits).middleware
+}
+
+func (l *limiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//the path as it's routed, so /api//todo and /api/./todo count against /api/todo
+		limit, ok := l.group(path.Clean(r.URL.Path))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client, ok := l.client(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed, b := l.take(limit, client, limitNowFn())
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(int(limit.burst())))
+		h.Set("RateLimit-Remaining", strconv.Itoa(int(b.tokens)))
+		h.Set("RateLimit-Reset", strconv.Itoa(seconds((limit.burst()-b.tokens)/limit.perSecond())))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=60;burst=%d", limit.PerMinute, int(limit.burst())))
+		if !allowed {
+			retry := seconds((1 - b.tokens) / limit.perSecond())
+			h.Set("Retry-After", strconv.Itoa(retry))
+			writeError(w, terr.ErrorWithCode("too many requests",
+				fmt.Sprintf("rate limit of %d requests a minute reached, try again in %ds", limit.PerMinute, retry), 429))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *limiter) group(p string) (RateLimit, bool) {
+	for _, limit := range l.limits {
+		if limit.covers(p) {
+			return limit, true
+		}
+	}
+	return RateLimit{}, false
+}
+
+// take spends a token from the client's bucket for the group if there's one left. It returns a copy of the bucket
+// as it was left, for the headers.
+func (l *limiter) take(limit RateLimit, client string, now time.Time) (bool, bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) >= sweepEvery {
+		l.sweep(now)
+	}
+	key := limit.Prefix + " " + client
+	b, ok := l.buckets[key]
+	if !ok && len(l.buckets) >= maxBuckets {
+		key = limit.Prefix + " " + overflow
+		b, ok = l.buckets[key]
+	}
+	if !ok {
+		b = &bucket{limit: limit, tokens: limit.burst(), at: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	if b.tokens < 1 {
+		return false, *b
+	}
+	b.tokens--
+	return true, *b
+}
+
+func (l *limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.refill(now); b.tokens >= b.limit.burst() {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// address is where a request came from. Anything it says about who sent it hasn't been checked yet, and a client
+// could make up a new credential for every request. IPv6 addresses count by their /64, which a host usually has to
+// itself.
+func address(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "ip:" + ip.Mask(net.CIDRMask(64, 128)).String() + "/64", true
+	}
+	return "ip:" + host, true
+}
+
+// principal is the user a request was authenticated as, if it was.
+func principal(r *http.Request) (string, bool) {
+	p, ok := auth.FromContext(r.Context())
+	if !ok {
+		return "", false
+	}
+	return "user:" + p.UserId, true
+}
+
+// seconds rounds up to a whole number of seconds, the way the headers want them.
+func seconds(s float64) int {
+	return int(math.Ceil(s))
+}
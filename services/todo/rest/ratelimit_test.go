@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	type test struct {
+		name   string
+		value  string
+		expect RateLimit
+		err    error
+	}
+	tests := []test{
+		{name: "rate", value: "/api=600", expect: RateLimit{Prefix: "/api", PerMinute: 600}},
+		{name: "rate and burst", value: "/api/todo=120/20", expect: RateLimit{Prefix: "/api/todo", PerMinute: 120, Burst: 20}},
+		{name: "no path", value: "api=600", err: errors.New("invalid rate limit api=600, use /path=requests a minute[/burst]")},
+		{name: "no rate", value: "/api", err: errors.New("invalid rate limit /api, use /path=requests a minute[/burst]")},
+		{name: "zero rate", value: "/api=0", err: errors.New("invalid rate limit /api=0, requests a minute must be a positive number")},
+		{name: "bad burst", value: "/api=60/lots", err: errors.New("invalid rate limit /api=60/lots, burst must be a positive number")},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			limit, err := ParseRateLimit(tt.value)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.expect, limit)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestRateLimitTimes(t *testing.T) {
+	assert.Equal(t, RateLimit{Prefix: "/api", PerMinute: 6000}, RateLimit{Prefix: "/api", PerMinute: 600}.Times(10))
+	assert.Equal(t, RateLimit{Prefix: "/api/todo", PerMinute: 1200, Burst: 200}, RateLimit{Prefix: "/api/todo", PerMinute: 120, Burst: 20}.Times(10))
+}
+
+func TestLimit(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	prev := limitNowFn
+	t.Cleanup(func() { limitNowFn = prev })
+	limitNowFn = func() time.Time { return now }
+
+	srv := NewServer("", "", RateLimit{Prefix: "/", PerMinute: 600}, RateLimit{Prefix: "/api/todo", PerMinute: 60, Burst: 2})
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) }
+	srv.Router.Get("/api/todo", ok)
+	srv.Router.Get("/api/todos", ok)
+	get := func(path string, from string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = from + ":4321"
+		if len(header) > 0 {
+			req.Header.Set("Authorization", header[0])
+		}
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/api/todo", "10.0.0.1")
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "60;w=60;burst=2", rr.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, 200, get("/api/todo", "10.0.0.1").Code)
+
+	rr = get("/api/todo", "10.0.0.1")
+	assert.Equal(t, 429, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, []string{"application/json"}, rr.Header()["Content-Type"])
+	assert.JSONEq(t, `{"message":"too many requests","code":429,"details":"rate limit of 60 requests a minute reached, try again in 1s"}`,
+		rr.Body.String())
+
+	assert.Equal(t, 200, get("/api/todo", "10.0.0.2").Code, "other addresses have their own bucket")
+	assert.Equal(t, 200, get("/api/todos", "10.0.0.1").Code, "other groups have their own bucket too")
+	assert.Equal(t, "600", get("/api/todos", "10.0.0.1").Header().Get("RateLimit-Limit"), "/api/todo doesn't cover /api/todos")
+
+	assert.Equal(t, 429, get("/api/todo", "10.0.0.1", "Bearer abc").Code, "credentials aren't checked yet, so they don't get a bucket")
+	assert.Equal(t, 429, get("/api/todo", "10.0.0.1", "Bearer "+time.Now().String()).Code)
+	for _, path := range []string{"/api//todo", "/api/./todo", "/api/x/../todo", "/api/todo/"} {
+		assert.Equal(t, 429, get(path, "10.0.0.1").Code, "%s is routed to /api/todo, so it counts against it", path)
+	}
+
+	now = now.Add(time.Second)
+	assert.Equal(t, 200, get("/api/todo", "10.0.0.1").Code, "a token a second comes back")
+	assert.Equal(t, 429, get("/api/todo", "10.0.0.1").Code)
+}
+
+func TestLimitUsers(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	prev := limitNowFn
+	t.Cleanup(func() { limitNowFn = prev })
+	limitNowFn = func() time.Time { return now }
+
+	limit := RateLimit{Prefix: "/api", PerMinute: 60, Burst: 1}
+	srv := NewServer("", "", limit)
+	srv.Router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := r.Header.Get("X-User"); user != "" {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{UserId: user}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	srv.Router.Use(LimitUsers(limit))
+	srv.Router.Get("/api/todo", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) })
+	get := func(from, user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/todo", nil)
+		req.RemoteAddr = from + ":4321"
+		req.Header.Set("X-User", user)
+		rr := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, 200, get("10.0.0.1", "sam"))
+	assert.Equal(t, 429, get("10.0.0.2", "sam"), "a user has the one bucket wherever they are")
+	assert.Equal(t, 200, get("10.0.0.3", ""), "requests without a user only count against their address")
+	assert.Equal(t, 429, get("10.0.0.1", "alex"), "and still count against it after")
+}
+
+func TestLimitAddress(t *testing.T) {
+	type test struct {
+		name   string
+		remote string
+		expect string
+	}
+	tests := []test{
+		{name: "ipv4", remote: "10.0.0.1:4321", expect: "ip:10.0.0.1"},
+		{name: "ipv6 by its /64", remote: "[2001:db8:1:2:3:4:5:6]:4321", expect: "ip:2001:db8:1:2::/64"},
+		{name: "no port", remote: "10.0.0.1", expect: "ip:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			req.Header.Set("Authorization", "Bearer todo_secret")
+			key, ok := address(req)
+			assert.True(t, ok)
+			assert.Equal(t, tt.expect, key)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestLimitSweep(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	limit := RateLimit{Prefix: "/", PerMinute: 60}
+	l := &limiter{limits: []RateLimit{limit}, client: address, buckets: map[string]*bucket{}, swept: now}
+	l.take(limit, "ip:10.0.0.1", now)
+	l.take(limit, "ip:10.0.0.2", now.Add(sweepEvery-time.Second/2))
+	assert.Len(t, l.buckets, 2)
+	l.take(limit, "ip:10.0.0.3", now.Add(sweepEvery))
+	assert.Len(t, l.buckets, 2, "10.0.0.1's bucket is full again, so it's dropped")
+	assert.NotContains(t, l.buckets, "/ ip:10.0.0.1")
+}
+
+func TestLimitOverflow(t *testing.T) {
+	prev := maxBuckets
+	t.Cleanup(func() { maxBuckets = prev })
+	maxBuckets = 2
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	limit := RateLimit{Prefix: "/", PerMinute: 60, Burst: 1}
+	l := &limiter{limits: []RateLimit{limit}, client: address, buckets: map[string]*bucket{}, swept: now}
+	ok, _ := l.take(limit, "ip:10.0.0.1", now)
+	assert.True(t, ok)
+	l.take(limit, "ip:10.0.0.2", now)
+	ok, _ = l.take(limit, "ip:10.0.0.3", now)
+	assert.True(t, ok)
+	ok, _ = l.take(limit, "ip:10.0.0.4", now)
+	assert.False(t, ok, "past the cap new clients share a bucket")
+	assert.Len(t, l.buckets, 3)
+	ok, _ = l.take(limit, "ip:10.0.0.1", now)
+	assert.False(t, ok, "clients that already had a bucket keep it")
+}
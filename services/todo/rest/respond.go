@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// writeError writes a TodoError as-is, and anything else as a generic internal error so we don't leak details.
+func writeError(w http.ResponseWriter, err error) {
+	v, ok := err.(*terr.TodoError)
+	if !ok {
+		v = terr.InternalError()
+	}
+	w.WriteHeader(v.HttpCode)
+	w.Write([]byte(v.Error()))
+}
+
+// writeJSON marshals and writes a response body with the given status code.
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	jsn, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, terr.InternalError())
+		return
+	}
+	w.WriteHeader(code)
+	w.Write(jsn)
+}
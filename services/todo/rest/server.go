@@ -19,9 +19,13 @@ type HttpServer struct {
 	http.Server
 }
 
-func NewServer(addr, port string) *HttpServer {
+// NewServer sets up the router with the middleware every route shares. Each address is rate limited on each group of
+// paths in limits (see Limit), nothing is limited without any. Limiting each user as well takes LimitUsers once
+// authentication is in place.
+func NewServer(addr, port string, limits ...RateLimit) *HttpServer {
 	router := chi.NewRouter()
-	router.Use(middleware.AllowContentType("application/json"))
+	//calendar imports are .ics files, sent as they are or from a form
+	router.Use(middleware.AllowContentType("application/json", "text/calendar", "multipart/form-data"))
 	router.Use(middleware.CleanPath)
 	// Basic CORS
 	// for more ideas, see: https://developer.github.com/v3/#cross-origin-resource-sharing
@@ -29,7 +33,7 @@ func NewServer(addr, port string) *HttpServer {
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
@@ -40,6 +44,10 @@ func NewServer(addr, port string) *HttpServer {
 			next.ServeHTTP(w, r)
 		})
 	})
+	//last, so a 429 is json and still has the cors headers for browsers to read it
+	if len(limits) > 0 {
+		router.Use(Limit(limits...))
+	}
 
 	hs := &HttpServer{
 		Router: router,
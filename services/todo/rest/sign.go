@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// signer keeps short lived state with the client instead of on the server, so it works on any replica.
+type signer []byte
+
+// newSigner signs with key. Every replica needs the same one, an empty key makes a random one which only works with a
+// single replica.
+func newSigner(key string) (signer, error) {
+	k := []byte(key)
+	if len(k) == 0 {
+		k = make([]byte, 32)
+		if _, err := rand.Read(k); err != nil {
+			return nil, err
+		}
+	}
+	return signer(k), nil
+}
+
+// sign is v as base64 json, with its hmac after a dot.
+func (s signer) sign(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload)), nil
+}
+
+// open reads a value made by sign into v, false if it wasn't signed with our key.
+func (s signer) open(value string, v any) bool {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+func (s signer) mac(payload string) []byte {
+	m := hmac.New(sha256.New, s)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+type SmartListHandlers struct {
+	SmartList *smartlist.Core
+}
+
+func NewSmartListHandlers(core *smartlist.Core) SmartListHandlers {
+	return SmartListHandlers{
+		SmartList: core,
+	}
+}
+
+// RegisterSmartListEndpoints mounts saved filters under <prefix>/filters.
+func (h *SmartListHandlers) RegisterSmartListEndpoints(parent *chi.Mux, prefix string) {
+	router := chi.NewRouter()
+
+	router.Get("/", h.GetSmartLists)
+	router.Post("/", h.CreateSmartList)
+	router.Get("/{id}", h.GetSmartList)
+	router.Patch("/{id}", h.UpdateSmartList)
+	router.Delete("/{id}", h.DeleteSmartList)
+	router.Get("/{id}/todo", h.GetSmartListItems)
+	parent.Mount(fmt.Sprintf("%s/filters", prefix), router)
+}
+
+func (h *SmartListHandlers) GetSmartLists(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetSmartLists")
+	defer span.End()
+	lists, err := h.SmartList.GetAll(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, lists)
+}
+
+func (h *SmartListHandlers) GetSmartList(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetSmartList")
+	defer span.End()
+	list, err := h.SmartList.GetById(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, list)
+}
+
+func (h *SmartListHandlers) CreateSmartList(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "CreateSmartList")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var l smartlist.SmartList
+	if err := dec.Decode(&l); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	created, err := h.SmartList.Create(ctx, l)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 201, created)
+}
+
+func (h *SmartListHandlers) UpdateSmartList(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "UpdateSmartList")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var l smartlist.SmartList
+	if err := dec.Decode(&l); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	updated, err := h.SmartList.Update(ctx, l, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, updated)
+}
+
+func (h *SmartListHandlers) DeleteSmartList(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "DeleteSmartList")
+	defer span.End()
+	if err := h.SmartList.Delete(ctx, chi.URLParam(r, "id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// GetSmartListItems runs the smart list's query and returns the matching todo items.
+func (h *SmartListHandlers) GetSmartListItems(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetSmartListItems")
+	defer span.End()
+	items, err := h.SmartList.Items(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, items)
+}
@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// mockSmartListStorer keeps smart lists in a map, which is plenty for handler tests.
+type mockSmartListStorer struct {
+	lists map[string]smartlist.SmartList
+}
+
+func (m *mockSmartListStorer) Create(ctx context.Context, l smartlist.SmartList) (smartlist.SmartList, error) {
+	l.Id = newId(memdb.NewId())
+	m.lists[*l.Id] = l
+	return l, nil
+}
+
+func (m *mockSmartListStorer) GetAll(context.Context) ([]smartlist.SmartList, error) {
+	all := []smartlist.SmartList{}
+	for _, l := range m.lists {
+		all = append(all, l)
+	}
+	return all, nil
+}
+
+func (m *mockSmartListStorer) GetById(ctx context.Context, id string) (smartlist.SmartList, error) {
+	l, ok := m.lists[id]
+	if !ok {
+		return smartlist.SmartList{}, terr.ErrorWithCode("not found", "Smart list with id "+id+" not found", 404)
+	}
+	return l, nil
+}
+
+func (m *mockSmartListStorer) Update(ctx context.Context, l smartlist.SmartList) (smartlist.SmartList, error) {
+	m.lists[*l.Id] = l
+	return l, nil
+}
+
+func (m *mockSmartListStorer) Delete(ctx context.Context, id string) error {
+	if _, ok := m.lists[id]; !ok {
+		return terr.ErrorWithCode("not found", "Smart list with id "+id+" not found", 404)
+	}
+	delete(m.lists, id)
+	return nil
+}
+
+func newSmartListRouter(t *testing.T) *chi.Mux {
+	todos := todoitem.NewCore(memdb.NewStore())
+	for _, s := range []string{"write report", "buy milk"} {
+		_, err := todos.Create(context.Background(), todoitem.TodoItem{Summary: newSummary(s), Tags: []string{"work"}})
+		assert.Nil(t, err)
+	}
+	parent := chi.NewRouter()
+	subject := NewSmartListHandlers(smartlist.NewCore(&mockSmartListStorer{lists: map[string]smartlist.SmartList{}}, todos))
+	subject.RegisterSmartListEndpoints(parent, "/api")
+	return parent
+}
+
+func TestSmartListLifecycle(t *testing.T) {
+	parent := newSmartListRouter(t)
+
+	rr := httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/filters", bytes.NewReader([]byte(`{"name":"reports","query":"tag:work report"}`))))
+	assert.Equal(t, 201, rr.Result().StatusCode)
+	var created smartlist.SmartList
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&created))
+	assert.NotNil(t, created.Id)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/filters/"+*created.Id+"/todo", nil))
+	assert.Equal(t, 200, rr.Result().StatusCode)
+	var items []todoitem.TodoItem
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&items))
+	assert.Len(t, items, 1)
+	assert.Equal(t, "write report", *items[0].Summary)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/api/filters/"+*created.Id, bytes.NewReader([]byte(`{"query":"tag:work"}`))))
+	assert.Equal(t, 200, rr.Result().StatusCode)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/filters/"+*created.Id+"/todo", nil))
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&items))
+	assert.Len(t, items, 2)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/filters", nil))
+	var all []smartlist.SmartList
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&all))
+	assert.Len(t, all, 1)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/filters/"+*created.Id, nil))
+	assert.Equal(t, 204, rr.Result().StatusCode)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/filters/"+*created.Id, nil))
+	assert.Equal(t, 404, rr.Result().StatusCode)
+}
+
+func TestCreateSmartListErrors(t *testing.T) {
+	type slTest struct {
+		name       string
+		body       string
+		statusCode int
+		contains   string
+	}
+	tests := []slTest{
+		{name: "bad query", body: `{"name":"soon","query":"due<soonish"}`, statusCode: 400, contains: "(at position 4)"},
+		{name: "no name", body: `{"query":"tag:work"}`, statusCode: 400, contains: "name cannot be empty"},
+		{name: "unknown field", body: `{"name":"soon","filter":"tag:work"}`, statusCode: 400, contains: "unknown field"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			parent := newSmartListRouter(t)
+			rr := httptest.NewRecorder()
+			parent.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/filters", bytes.NewReader([]byte(tt.body))))
+			assert.Equal(t, tt.statusCode, rr.Result().StatusCode)
+			b, _ := io.ReadAll(rr.Result().Body)
+			assert.Contains(t, string(b), tt.contains)
+		}
+		t.Run(tt.name, tf)
+	}
+}
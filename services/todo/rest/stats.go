@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+// defaultStatsRange is four whole weeks, so the weekly throughput isn't skewed by a partial week.
+const defaultStatsRange = 28 * 24 * time.Hour
+
+// pull out so tests can pin the default range
+var statsNowFn = time.Now
+
+func (h *TodoHandlers) RegisterStatsEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/stats", prefix), h.GetStats)
+}
+
+// GetStats returns completion stats. from and to take a date (2006-01-02) or an RFC3339 timestamp. A date for to
+// includes that whole day. Without them you get the four weeks up to and including today (UTC).
+func (h *TodoHandlers) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetStats")
+	defer span.End()
+	now := statsNowFn().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := parseStatsTime(v, true)
+		if err != nil {
+			writeError(w, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid to %s, use a date (2006-01-02) or RFC3339 timestamp", v), 400))
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultStatsRange)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := parseStatsTime(v, false)
+		if err != nil {
+			writeError(w, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid from %s, use a date (2006-01-02) or RFC3339 timestamp", v), 400))
+			return
+		}
+		from = t
+	}
+	stats, err := h.TodoItem.Stats(ctx, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, stats)
+}
+
+func parseStatsTime(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
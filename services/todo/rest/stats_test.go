@@ -0,0 +1,61 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func TestGetStats(t *testing.T) {
+	type statsTest struct {
+		name       string
+		query      string
+		statusCode int
+		from       string
+		days       int
+		contains   string
+	}
+	tests := []statsTest{
+		{name: "default four weeks", statusCode: 200, from: "2023-01-05T00:00:00Z", days: 28},
+		{name: "dates include the to day", query: "?from=2023-01-01&to=2023-01-07", statusCode: 200, from: "2023-01-01T00:00:00Z", days: 7},
+		{name: "timestamps", query: "?from=2023-01-01T00:00:00Z&to=2023-01-03T12:00:00Z", statusCode: 200, from: "2023-01-01T00:00:00Z", days: 3},
+		{name: "bad from", query: "?from=last-week", statusCode: 400, contains: "invalid from last-week"},
+		{name: "backwards", query: "?from=2023-01-07&to=2023-01-01", statusCode: 400, contains: "from must be before to"},
+	}
+	defer func() { statsNowFn = time.Now }()
+	statsNowFn = func() time.Time { return time.Date(2023, time.February, 1, 15, 0, 0, 0, time.UTC) }
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			core := todoitem.NewCore(memdb.NewStore())
+			_, err := core.Create(context.Background(), todoitem.TodoItem{Summary: newSummary("test summary")})
+			assert.Nil(t, err)
+			parent := chi.NewRouter()
+			subject := NewTodoHandlers(core)
+			subject.RegisterStatsEndpoints(parent, "/api")
+			rr := httptest.NewRecorder()
+			parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats"+tt.query, nil))
+
+			assert.Equal(t, tt.statusCode, rr.Result().StatusCode)
+			if tt.statusCode != 200 {
+				b, _ := io.ReadAll(rr.Result().Body)
+				assert.Contains(t, string(b), tt.contains)
+				return
+			}
+			var stats todoitem.Stats
+			assert.Nil(t, json.NewDecoder(rr.Body).Decode(&stats))
+			assert.Equal(t, tt.from, stats.From.Format(time.RFC3339))
+			assert.Len(t, stats.Days, tt.days)
+			assert.Equal(t, 1, stats.Open)
+		}
+		t.Run(tt.name, tf)
+	}
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+type syncRequest struct {
+	Changes []todoitem.Change `json:"changes"`
+}
+
+type syncResponse struct {
+	Results []todoitem.ChangeResult `json:"results"`
+}
+
+func (h *TodoHandlers) RegisterSyncEndpoints(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/sync", prefix), h.GetChanges)
+	parent.Post(fmt.Sprintf("%s/sync", prefix), h.PostChanges)
+}
+
+// GetChanges returns what's changed since the since token, deleted items included. Leave since off for everything.
+func (h *TodoHandlers) GetChanges(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetChanges")
+	defer span.End()
+	set, err := h.TodoItem.Changes(ctx, r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, set)
+}
+
+// PostChanges applies a batch of changes made on a client, returning what happened to each of them.
+func (h *TodoHandlers) PostChanges(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "PostChanges")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var req syncRequest
+	if err := dec.Decode(&req); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	results, err := h.TodoItem.Sync(ctx, req.Changes)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, syncResponse{Results: results})
+}
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newSyncRouter(core *todoitem.Core) *chi.Mux {
+	parent := chi.NewRouter()
+	subject := NewTodoHandlers(core)
+	subject.RegisterSyncEndpoints(parent, "/api")
+	return parent
+}
+
+func getChanges(t *testing.T, router *chi.Mux, since string) todoitem.ChangeSet {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/sync?since="+since, nil))
+	assert.Equal(t, 200, rr.Result().StatusCode)
+	var set todoitem.ChangeSet
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&set))
+	return set
+}
+
+func TestGetChanges(t *testing.T) {
+	ctx := context.Background()
+	core := todoitem.NewCore(memdb.NewStore())
+	router := newSyncRouter(core)
+	milk, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk")})
+	core.Create(ctx, todoitem.TodoItem{Summary: newSummary("walk dog")})
+
+	set := getChanges(t, router, "")
+	assert.Len(t, set.Items, 2)
+	assert.False(t, set.More)
+
+	_, err := core.Delete(ctx, *milk.Id)
+	assert.Nil(t, err)
+	next := getChanges(t, router, set.Token)
+	if assert.Len(t, next.Items, 1, "only what changed since the token") {
+		assert.Equal(t, *milk.Id, *next.Items[0].Id)
+		assert.True(t, *next.Items[0].Deleted, "deletes come back as tombstones")
+	}
+	assert.Empty(t, getChanges(t, router, next.Token).Items)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/sync?since=nope", nil))
+	assert.Equal(t, 400, rr.Result().StatusCode)
+}
+
+func TestPostChanges(t *testing.T) {
+	ctx := context.Background()
+	core := todoitem.NewCore(memdb.NewStore())
+	router := newSyncRouter(core)
+	//the client saw both of these, then the server changed bread while it was offline
+	milk, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk")})
+	bread, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy bread")})
+	eggs, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy eggs")})
+	seenBread := *bread.Version
+	seenEggs := *eggs.Version
+	core.Update(ctx, todoitem.TodoItem{Summary: newSummary("buy sourdough")}, *bread.Id)
+	core.Update(ctx, todoitem.TodoItem{Summary: newSummary("buy free range eggs")}, *eggs.Id)
+
+	longAgo := time.Now().Add(-time.Hour)
+	justNow := time.Now().Add(time.Minute)
+	changes := []todoitem.Change{
+		{Ref: "new", Item: todoitem.TodoItem{Summary: newSummary("buy cheese")}},
+		{Ref: "milk", BaseVersion: milk.Version, Item: todoitem.TodoItem{Id: milk.Id, Completed: newBool(true), Updated: &longAgo}},
+		{Ref: "bread", BaseVersion: &seenBread, Item: todoitem.TodoItem{Id: bread.Id, Deleted: newBool(true), Updated: &longAgo}},
+		{Ref: "eggs", BaseVersion: &seenEggs, Item: todoitem.TodoItem{Id: eggs.Id, Summary: newSummary("buy duck eggs"), Updated: &justNow}},
+		{Ref: "gone", Item: todoitem.TodoItem{Id: newId("nope"), Deleted: newBool(true)}},
+		{Ref: "empty", Item: todoitem.TodoItem{Id: milk.Id, Summary: newSummary("")}},
+	}
+	body, _ := json.Marshal(syncRequest{Changes: changes})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader(string(body))))
+	assert.Equal(t, 200, rr.Result().StatusCode)
+	var resp syncResponse
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&resp))
+	if !assert.Len(t, resp.Results, len(changes)) {
+		return
+	}
+	results := map[string]todoitem.ChangeResult{}
+	for _, r := range resp.Results {
+		results[r.Ref] = r
+	}
+
+	assert.Equal(t, todoitem.ChangeApplied, results["new"].Status)
+	assert.NotNil(t, results["new"].Item.Id)
+
+	assert.Equal(t, todoitem.ChangeApplied, results["milk"].Status, "unchanged since the client saw it, so age doesn't matter")
+	assert.True(t, *results["milk"].Item.Completed)
+	assert.Equal(t, "buy milk", *results["milk"].Item.Summary, "fields the client didn't send are left alone")
+
+	assert.Equal(t, todoitem.ChangeConflict, results["bread"].Status, "the server's edit is newer")
+	assert.Equal(t, "buy sourdough", *results["bread"].Item.Summary)
+	assert.False(t, *results["bread"].Item.Deleted)
+
+	assert.Equal(t, todoitem.ChangeApplied, results["eggs"].Status, "the client's edit is newer")
+	assert.Equal(t, "buy duck eggs", *results["eggs"].Item.Summary)
+
+	assert.Equal(t, todoitem.ChangeRejected, results["gone"].Status)
+	assert.Contains(t, string(results["gone"].Error), "not found")
+	assert.Equal(t, todoitem.ChangeRejected, results["empty"].Status)
+
+	current, _ := core.GetAll(ctx)
+	assert.Len(t, current, 4)
+}
+
+func TestPostChangesBadBody(t *testing.T) {
+	router := newSyncRouter(todoitem.NewCore(memdb.NewStore()))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader(`{"edits":[]}`)))
+	assert.Equal(t, 400, rr.Result().StatusCode)
+	b, _ := io.ReadAll(rr.Body)
+	assert.Contains(t, string(b), "unknown field")
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// ticketTTL is how long a ticket can be used for. A stream opened with one stays open after, but reconnecting needs a
+// new one.
+const ticketTTL = time.Minute
+
+// Tickets stand in for someone's credentials on the requests a browser can't put an Authorization header on, like
+// opening an EventSource. They're signed rather than stored, only ever read, and don't last long, so it doesn't
+// matter much that they end up in urls and logs.
+type Tickets struct {
+	key signer
+}
+
+// NewTickets signs tickets with key. Every replica needs the same one, an empty key makes a random one which only
+// works with a single replica.
+func NewTickets(key string) (*Tickets, error) {
+	k, err := newSigner(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Tickets{key: k}, nil
+}
+
+// Ticket is a ticket as it's handed out.
+type Ticket struct {
+	Ticket  string    `json:"ticket"`
+	Expires time.Time `json:"expires"`
+}
+
+type ticketClaims struct {
+	UserId      string `json:"userId"`
+	WorkspaceId string `json:"workspaceId"`
+	Expires     int64  `json:"expires"`
+}
+
+// Issue makes a ticket for p, which reads as them until it expires.
+func (t *Tickets) Issue(p auth.Principal) (Ticket, error) {
+	expires := time.Now().Add(ticketTTL)
+	value, err := t.key.sign(ticketClaims{UserId: p.UserId, WorkspaceId: p.WorkspaceId, Expires: expires.Unix()})
+	if err != nil {
+		return Ticket{}, err
+	}
+	return Ticket{Ticket: value, Expires: expires.Truncate(time.Second)}, nil
+}
+
+// Authenticate checks an Authorization header of the form "Ticket <ticket>". The principal it gives back only has
+// the read scope.
+func (t *Tickets) Authenticate(ctx context.Context, authorization string) (auth.Principal, error) {
+	scheme, value, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "ticket") {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", fmt.Sprintf("unsupported authorization scheme %s", scheme), 401)
+	}
+	var c ticketClaims
+	if !t.key.open(value, &c) || c.UserId == "" {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", "invalid ticket", 401)
+	}
+	if time.Now().Unix() > c.Expires {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", "the ticket has expired, get a new one", 401)
+	}
+	return auth.Principal{UserId: c.UserId, Scope: auth.ScopeRead, WorkspaceId: c.WorkspaceId}, nil
+}
+
+// TicketQuery is middleware that lets requests to paths carry a ticket as ?ticket= instead of in the Authorization
+// header. It has to come before Authenticate, which needs to have been given Tickets for the ticket scheme.
+func TicketQuery(paths ...string) func(http.Handler) http.Handler {
+	allowed := map[string]bool{}
+	for _, p := range paths {
+		allowed[p] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := r.URL.Query().Get("ticket")
+			if t == "" || !allowed[r.URL.Path] || r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Ticket "+t)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
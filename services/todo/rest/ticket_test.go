@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/user"
+)
+
+func newTicketRouter(t *testing.T, tickets *Tickets) (*chi.Mux, *todoitem.Core) {
+	users := user.NewCore(memdb.NewUserStore())
+	_, err := users.Register(context.Background(), user.NewUser{Email: "sam@example.com", Password: "correct horse"})
+	assert.Nil(t, err)
+	hub := events.NewHub(10)
+	t.Cleanup(hub.Close)
+	core := todoitem.NewCore(memdb.NewStore(), hub)
+	router := chi.NewRouter()
+	router.Use(TicketQuery(EventTicketPaths("/api")...))
+	router.Use(Authenticate(auth.Schemes{"basic": users, "ticket": tickets}, true))
+	tdh := NewTodoHandlers(core)
+	tdh.RegisterTodoEndpoints(router, "/api")
+	evh := NewEventHandlers(core, hub)
+	evh.Tickets = tickets
+	evh.RegisterEventEndpoints(router, "/api")
+	return router, core
+}
+
+func TestTickets(t *testing.T) {
+	tickets, err := NewTickets("not very secret")
+	assert.Nil(t, err)
+	router, _ := newTicketRouter(t, tickets)
+	sam := basicAuth("sam@example.com", "correct horse")
+
+	rr := serve(router, "POST", "/api/todo/events/ticket", "", nil)
+	assert.Equal(t, 401, rr.Code, "tickets are for people who are logged in")
+	rr = serve(router, "POST", "/api/todo/events/ticket", sam, nil)
+	assert.Equal(t, 201, rr.Code, rr.Body.String())
+	var ticket Ticket
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &ticket))
+	assert.WithinDuration(t, time.Now().Add(ticketTTL), ticket.Expires, 2*time.Second)
+
+	rr = serve(router, "POST", "/api/todo", "Ticket "+ticket.Ticket, todoitem.TodoItem{Summary: newId("buy milk")})
+	assert.Equal(t, 403, rr.Code, "tickets only read")
+	rr = serve(router, "GET", "/api/todo", "Ticket "+ticket.Ticket, nil)
+	assert.Equal(t, 200, rr.Code, rr.Body.String())
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	openStream(t, srv.URL+"/api/todo/events?ticket="+url.QueryEscape(ticket.Ticket), "")
+}
+
+func TestTicketsRejected(t *testing.T) {
+	tickets, err := NewTickets("not very secret")
+	assert.Nil(t, err)
+	router, _ := newTicketRouter(t, tickets)
+	valid, err := tickets.Issue(auth.Principal{UserId: "sam"})
+	assert.Nil(t, err)
+	expired, err := tickets.key.sign(ticketClaims{UserId: "sam", Expires: time.Now().Add(-time.Second).Unix()})
+	assert.Nil(t, err)
+	other, err := NewTickets("someone else's secret")
+	assert.Nil(t, err)
+	forged, err := other.Issue(auth.Principal{UserId: "sam"})
+	assert.Nil(t, err)
+
+	type test struct {
+		name   string
+		path   string
+		ticket string
+	}
+	tests := []test{
+		{name: "expired", path: "/api/todo/events", ticket: expired},
+		{name: "signed with another key", path: "/api/todo/events", ticket: forged.Ticket},
+		{name: "tampered", path: "/api/todo/events", ticket: valid.Ticket[1:]},
+		{name: "only the stream takes them in the url", path: "/api/todo", ticket: valid.Ticket},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := serve(router, "GET", tt.path+"?ticket="+url.QueryEscape(tt.ticket), "", nil)
+			assert.Equal(t, 401, rr.Code, rr.Body.String())
+		}
+		t.Run(tt.name, tf)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
@@ -32,17 +34,31 @@ func (h *TodoHandlers) RegisterTodoEndpoints(parent *chi.Mux, prefix string) {
 	todoRouter.Get("/{id}", h.GetTodo)
 	todoRouter.Post("/", h.CreateTodo)
 	todoRouter.Patch("/{id}", h.UpdateTodo)
+	todoRouter.Delete("/{id}", h.DeleteTodo)
 	parent.Mount(fmt.Sprintf("%s/todo", prefix), todoRouter)
 }
 
 func (h *TodoHandlers) GetTodos(w http.ResponseWriter, r *http.Request) {
 	ctx, span := tracing.Tracer().Start(r.Context(), "GetAll")
 	defer span.End()
-	todos, err := h.TodoItem.GetAll(ctx)
+	var todos []todoitem.TodoItem
+	var err error
+	//q holds a filter language expression, i.e: ?q=completed:false tag:work. ?assignee=me narrows it to what's
+	//assigned to the caller, or a user id to someone else
+	q, assignee := r.URL.Query().Get("q"), r.URL.Query().Get("assignee")
+	switch {
+	case assignee != "":
+		todos, err = h.TodoItem.Assigned(ctx, assignee, q)
+	case q != "":
+		todos, err = h.TodoItem.Find(ctx, q)
+	default:
+		todos, err = h.TodoItem.GetAll(ctx)
+	}
 	if err != nil {
 		if v, ok := err.(*terr.TodoError); ok {
 			w.WriteHeader(v.HttpCode)
 			w.Write([]byte(err.Error()))
+			return
 		} else {
 			w.WriteHeader(500)
 			v = terr.InternalError()
@@ -50,6 +66,11 @@ func (h *TodoHandlers) GetTodos(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	todos, err = page(w, r, todos)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	jsn, err := json.Marshal(todos)
 	if err != nil {
 		w.WriteHeader(500)
@@ -61,10 +82,59 @@ func (h *TodoHandlers) GetTodos(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsn)
 }
 
+// page applies the optional ?limit= and ?offset= params to a list. When there's more to come the next page's url is
+// in a Link header, rel="next". Without a limit everything is returned, which is what the UI expects.
+func page(w http.ResponseWriter, r *http.Request, todos []todoitem.TodoItem) ([]todoitem.TodoItem, error) {
+	q := r.URL.Query()
+	if q.Get("limit") == "" {
+		return todos, nil
+	}
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit < 1 {
+		return nil, terr.ErrorWithCode("invalid param", "limit must be a positive number", 400)
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return nil, terr.ErrorWithCode("invalid param", "offset must be a number, zero or more", 400)
+		}
+	}
+	if offset >= len(todos) {
+		return []todoitem.TodoItem{}, nil
+	}
+	end := offset + limit
+	if end < len(todos) {
+		q.Set("offset", strconv.Itoa(end))
+		next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	} else {
+		end = len(todos)
+	}
+	return todos[offset:end], nil
+}
+
 func (h *TodoHandlers) GetTodo(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	w.WriteHeader(405)
-	w.Write([]byte(fmt.Sprintf(`{"hello":"%s but this method isn't implemented for this demo as it's currently unused"}`, id)))
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetTodo")
+	defer span.End()
+	item, err := h.TodoItem.GetById(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, item)
+}
+
+// DeleteTodo soft deletes an item, the same as a PATCH with deleted set, and returns it.
+func (h *TodoHandlers) DeleteTodo(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "DeleteTodo")
+	defer span.End()
+	item, err := h.TodoItem.Delete(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, item)
 }
 
 func (h *TodoHandlers) CreateTodo(w http.ResponseWriter, r *http.Request) {
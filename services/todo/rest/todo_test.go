@@ -14,7 +14,10 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
 	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/stores/memdb"
 	"github.com/stumacwastaken/todo/todoitem"
 )
 
@@ -48,6 +51,27 @@ func (m *MockStorer) Update(context.Context, todoitem.TodoItem) (todoitem.TodoIt
 	return todoitem.TodoItem{}, err
 }
 
+func (m *MockStorer) Find(context.Context, filter.Predicate) ([]todoitem.TodoItem, error) {
+	return m.resp("Find")
+}
+
+func (m *MockStorer) Stats(context.Context, todoitem.StatsRange) (todoitem.Stats, error) {
+	_, err := m.resp("Stats")
+	return todoitem.Stats{}, err
+}
+
+func (m *MockStorer) Changes(context.Context, int64, int, []string) ([]todoitem.TodoItem, error) {
+	return m.resp("Changes")
+}
+
+func (m *MockStorer) Put(context.Context, todoitem.TodoItem) (todoitem.TodoItem, error) {
+	res, err := m.resp("Put")
+	if len(res) > 0 {
+		return res[0], err
+	}
+	return todoitem.TodoItem{}, err
+}
+
 func (m *MockStorer) GetById(context.Context, string) (todoitem.TodoItem, error) {
 	res, err := m.resp("GetById")
 	if len(res) > 0 {
@@ -432,3 +456,154 @@ func TestFigureDecodeError(t *testing.T) {
 	}
 
 }
+
+func TestGetTodosFilter(t *testing.T) {
+	type filterTest struct {
+		name       string
+		query      string
+		statusCode int
+		contains   string
+		position   *int
+	}
+	pos := 16
+	tests := []filterTest{
+		{name: "happy filter", query: "completed:false+tag:work", statusCode: 200, contains: "filtered summary"},
+		{name: "bad filter", query: "completed:false+prio:high", statusCode: 400, contains: "unknown field prio (at position 16)", position: &pos},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			parent := chi.NewRouter()
+			mocks := &MockStorer{resp: func(method string) ([]todoitem.TodoItem, error) {
+				if method != "Find" {
+					return nil, errors.New("should have used find")
+				}
+				return []todoitem.TodoItem{{Id: newId("343434"), Summary: newSummary("filtered summary")}}, nil
+			}}
+			subject := NewTodoHandlers(NewCore(mocks))
+			subject.RegisterTodoEndpoints(parent, "/api")
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/todo?q="+tt.query, nil)
+			parent.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.statusCode, rr.Result().StatusCode)
+			b, _ := io.ReadAll(rr.Result().Body)
+			assert.Contains(t, string(b), tt.contains)
+			if tt.position != nil {
+				var body struct {
+					Position *int `json:"position"`
+				}
+				assert.Nil(t, json.Unmarshal(b, &body))
+				assert.Equal(t, tt.position, body.Position, "clients can point at the mistake without reading details")
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetTodosPaging(t *testing.T) {
+	ctx := context.Background()
+	core := todoitem.NewCore(memdb.NewStore())
+	for _, s := range []string{"one", "two", "three"} {
+		core.Create(ctx, todoitem.TodoItem{Summary: newSummary(s)})
+	}
+	parent := chi.NewRouter()
+	subject := NewTodoHandlers(core)
+	subject.RegisterTodoEndpoints(parent, "/api")
+
+	type test struct {
+		name     string
+		query    string
+		status   int
+		count    int
+		nextLink string
+	}
+	tests := []test{
+		{name: "no limit", query: "", status: 200, count: 3},
+		{name: "first page", query: "?limit=2", status: 200, count: 2, nextLink: `</api/todo?limit=2&offset=2>; rel="next"`},
+		{name: "last page", query: "?limit=2&offset=2", status: 200, count: 1},
+		{name: "past the end", query: "?limit=2&offset=10", status: 200, count: 0},
+		{name: "keeps the filter", query: "?q=t&limit=1", status: 200, count: 1, nextLink: `</api/todo?limit=1&offset=1&q=t>; rel="next"`},
+		{name: "bad limit", query: "?limit=0", status: 400},
+		{name: "bad offset", query: "?limit=1&offset=-1", status: 400},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/todo"+tt.query, nil))
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status != 200 {
+				return
+			}
+			var items []todoitem.TodoItem
+			assert.Nil(t, json.NewDecoder(rr.Body).Decode(&items))
+			assert.Len(t, items, tt.count)
+			assert.Equal(t, tt.nextLink, rr.Header().Get("Link"))
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetTodosAssigned(t *testing.T) {
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+	core := todoitem.NewCore(memdb.NewStore())
+	core.Create(sam, todoitem.TodoItem{Summary: newSummary("buy milk"), AssigneeIds: []string{"sam"}})
+	core.Create(sam, todoitem.TodoItem{Summary: newSummary("walk dog")})
+	parent := chi.NewRouter()
+	subject := NewTodoHandlers(core)
+	subject.RegisterTodoEndpoints(parent, "/api")
+
+	type test struct {
+		name   string
+		ctx    context.Context
+		query  string
+		status int
+		count  int
+	}
+	tests := []test{
+		{name: "me", ctx: sam, query: "?assignee=me", status: 200, count: 1},
+		{name: "by id", ctx: sam, query: "?assignee=sam", status: 200, count: 1},
+		{name: "with a filter", ctx: sam, query: "?assignee=me&q=walk", status: 200, count: 0},
+		{name: "nobody", ctx: sam, query: "?assignee=alex", status: 200, count: 0},
+		{name: "not logged in", ctx: context.Background(), query: "?assignee=me", status: 401},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/todo"+tt.query, nil).WithContext(tt.ctx))
+			assert.Equal(t, tt.status, rr.Code)
+			if tt.status != 200 {
+				return
+			}
+			var items []todoitem.TodoItem
+			assert.Nil(t, json.NewDecoder(rr.Body).Decode(&items))
+			assert.Len(t, items, tt.count)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetAndDeleteTodo(t *testing.T) {
+	ctx := context.Background()
+	core := todoitem.NewCore(memdb.NewStore())
+	item, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk")})
+	parent := chi.NewRouter()
+	subject := NewTodoHandlers(core)
+	subject.RegisterTodoEndpoints(parent, "/api")
+
+	rr := httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/todo/"+*item.Id, nil))
+	assert.Equal(t, 200, rr.Code)
+	var got todoitem.TodoItem
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Equal(t, "buy milk", *got.Summary)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/todo/"+*item.Id, nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.True(t, *got.Deleted)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/todo/nope", nil))
+	assert.Equal(t, 404, rr.Code)
+}
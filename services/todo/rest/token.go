@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/tracing"
+)
+
+type TokenHandlers struct {
+	Token *token.Core
+}
+
+func NewTokenHandlers(core *token.Core) TokenHandlers {
+	return TokenHandlers{
+		Token: core,
+	}
+}
+
+// RegisterTokenEndpoints mounts personal access tokens under <prefix>/tokens.
+func (h *TokenHandlers) RegisterTokenEndpoints(parent *chi.Mux, prefix string) {
+	router := chi.NewRouter()
+
+	router.Get("/", h.GetTokens)
+	router.Post("/", h.CreateToken)
+	router.Delete("/{id}", h.RevokeToken)
+	parent.Mount(fmt.Sprintf("%s/tokens", prefix), router)
+}
+
+func (h *TokenHandlers) GetTokens(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetTokens")
+	defer span.End()
+	tokens, err := h.Token.GetAll(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, tokens)
+}
+
+// CreateToken returns the new token with its secret, the only time it's given out.
+func (h *TokenHandlers) CreateToken(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "CreateToken")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var t token.Token
+	if err := dec.Decode(&t); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	created, err := h.Token.Create(ctx, t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 201, created)
+}
+
+// RevokeToken returns the token as it now stands. Revoked tokens are kept, so they still show up in the list.
+func (h *TokenHandlers) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "RevokeToken")
+	defer span.End()
+	revoked, err := h.Token.Revoke(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, revoked)
+}
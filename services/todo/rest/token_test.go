@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/user"
+	"github.com/stumacwastaken/todo/webhook"
+)
+
+func TestAccessTokens(t *testing.T) {
+	users := user.NewCore(memdb.NewUserStore())
+	tokens := token.NewCore(memdb.NewTokenStore())
+	router := chi.NewRouter()
+	router.Use(Authenticate(auth.Schemes{"basic": users, "bearer": tokens.Bearer(nil)}, true, UserPublicPaths("/api")...))
+	ush := NewUserHandlers(users)
+	ush.RegisterUserEndpoints(router, "/api")
+	tkh := NewTokenHandlers(tokens)
+	tkh.RegisterTokenEndpoints(router, "/api")
+	tdh := NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(router, "/api")
+	whh := NewWebhookHandlers(webhook.NewCore(&mockWebhookStorer{hooks: map[string]webhook.Webhook{}}))
+	whh.RegisterWebhookEndpoints(router, "/api")
+	sam := register(t, router, "sam@example.com")
+
+	create := func(authorization, scope string) (token.Token, int) {
+		rr := serve(router, "POST", "/api/tokens", authorization, token.Token{Name: newId(scope), Scope: newId(scope)})
+		var created token.Token
+		json.Unmarshal(rr.Body.Bytes(), &created)
+		return created, rr.Code
+	}
+	read, code := create(sam, "read")
+	assert.Equal(t, 201, code)
+	assert.NotNil(t, read.Token)
+	write, _ := create(sam, "write")
+	admin, _ := create(sam, "admin")
+	bearer := func(tok token.Token) string { return "Bearer " + *tok.Token }
+
+	type test struct {
+		name          string
+		authorization string
+		method, path  string
+		body          any
+		code          int
+	}
+	tests := []test{
+		{name: "read can read", authorization: bearer(read), method: "GET", path: "/api/todo", code: 200},
+		{name: "read can't write", authorization: bearer(read), method: "POST", path: "/api/todo", body: todoitem.TodoItem{Summary: newId("x")}, code: 403},
+		{name: "write can write", authorization: bearer(write), method: "POST", path: "/api/todo", body: todoitem.TodoItem{Summary: newId("x")}, code: 201},
+		{name: "write can't add webhooks", authorization: bearer(write), method: "POST", path: "/api/webhooks", body: webhook.Webhook{Url: newId("https://example.com")}, code: 403},
+		{name: "admin can add webhooks", authorization: bearer(admin), method: "POST", path: "/api/webhooks", body: webhook.Webhook{Url: newId("https://example.com")}, code: 201},
+		{name: "write can't make tokens", authorization: bearer(write), method: "POST", path: "/api/tokens", body: token.Token{Name: newId("more")}, code: 403},
+		{name: "admin can make tokens", authorization: bearer(admin), method: "POST", path: "/api/tokens", body: token.Token{Name: newId("more")}, code: 201},
+		{name: "made up token", authorization: "Bearer " + token.Prefix + "nope", method: "GET", path: "/api/todo", code: 401},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			rr := serve(router, tt.method, tt.path, tt.authorization, tt.body)
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+		}
+		t.Run(tt.name, tf)
+	}
+
+	rr := serve(router, "POST", "/api/todo", bearer(write), todoitem.TodoItem{Summary: newId("from ci")})
+	var item todoitem.TodoItem
+	json.Unmarshal(rr.Body.Bytes(), &item)
+	assert.Equal(t, 200, serve(router, "GET", "/api/todo/"+*item.Id, sam, nil).Code, "the token's user owns what it makes")
+
+	rr = serve(router, "GET", "/api/tokens", sam, nil)
+	assert.NotContains(t, rr.Body.String(), *read.Token, "secrets aren't listed")
+	assert.Equal(t, 200, serve(router, "DELETE", "/api/tokens/"+*read.Id, sam, nil).Code)
+	assert.Equal(t, 401, serve(router, "GET", "/api/todo", bearer(read), nil).Code, "revoked")
+}
@@ -5,13 +5,17 @@ import (
 	"net/http"
 
 	"github.com/stumacwastaken/todo/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // TraceRequest a middlware to our http calls to provide a super basic trace should we ever forget to add in a trace
 // span at the handler level.
 func TraceRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := tracing.Tracer().Start(r.Context(), fmt.Sprintf("%s:%s", r.Method, r.URL.Path))
+		//carry on the caller's trace if they sent one along
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, fmt.Sprintf("%s:%s", r.Method, r.URL.Path))
 		defer span.End()
 
 		next.ServeHTTP(w, r.WithContext(ctx))
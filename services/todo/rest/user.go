@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/user"
+)
+
+type UserHandlers struct {
+	User *user.Core
+}
+
+func NewUserHandlers(core *user.Core) UserHandlers {
+	return UserHandlers{
+		User: core,
+	}
+}
+
+// RegisterUserEndpoints mounts accounts under <prefix>/users, and logging in at <prefix>/login. Registering and
+// logging in have to be left out of Authenticate's checks, see UserPublicPaths.
+func (h *UserHandlers) RegisterUserEndpoints(parent *chi.Mux, prefix string) {
+	parent.Post(fmt.Sprintf("%s/users", prefix), h.Register)
+	parent.Post(fmt.Sprintf("%s/login", prefix), h.Login)
+	h.RegisterMeEndpoint(parent, prefix)
+}
+
+// RegisterMeEndpoint mounts only <prefix>/users/me, for when people can't register or log in with a password.
+func (h *UserHandlers) RegisterMeEndpoint(parent *chi.Mux, prefix string) {
+	parent.Get(fmt.Sprintf("%s/users/me", prefix), h.Me)
+}
+
+// UserPublicPaths are the user endpoints that can't need credentials, since they're how people get them.
+func UserPublicPaths(prefix string) []string {
+	return []string{fmt.Sprintf("%s/users", prefix), fmt.Sprintf("%s/login", prefix)}
+}
+
+func (h *UserHandlers) Register(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Register")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var nu user.NewUser
+	if err := dec.Decode(&nu); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	created, err := h.User.Register(ctx, nu)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 201, created)
+}
+
+// Login checks an email and password. Requests after that send them again as basic auth.
+func (h *UserHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Login")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var creds user.Credentials
+	if err := dec.Decode(&creds); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	u, err := h.User.Login(ctx, creds)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, u)
+}
+
+// Me is whoever the request was authenticated as.
+func (h *UserHandlers) Me(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "Me")
+	defer span.End()
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		writeError(w, terr.ErrorWithCode("unauthorized", "not logged in", 401))
+		return
+	}
+	u, err := h.User.GetById(ctx, p.UserId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, u)
+}
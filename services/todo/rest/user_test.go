@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// newAuthRouter serves users and todos the way the server does, behind Authenticate.
+func newAuthRouter(t *testing.T, required bool) *chi.Mux {
+	router := chi.NewRouter()
+	users := user.NewCore(memdb.NewUserStore())
+	router.Use(Authenticate(users, required, UserPublicPaths("/api")...))
+	ush := NewUserHandlers(users)
+	ush.RegisterUserEndpoints(router, "/api")
+	tdh := NewTodoHandlers(todoitem.NewCore(memdb.NewStore()))
+	tdh.RegisterTodoEndpoints(router, "/api")
+	return router
+}
+
+func basicAuth(email, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(email+":"+password))
+}
+
+func serve(router http.Handler, method, path, authorization string, body any) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func register(t *testing.T, router http.Handler, email string) string {
+	rr := serve(router, "POST", "/api/users", "", user.NewUser{Email: email, Password: "correct horse"})
+	if !assert.Equal(t, 201, rr.Code, rr.Body.String()) {
+		t.FailNow()
+	}
+	assert.NotContains(t, rr.Body.String(), "correct horse")
+	assert.NotContains(t, rr.Body.String(), "password")
+	return basicAuth(email, "correct horse")
+}
+
+func TestUsers(t *testing.T) {
+	router := newAuthRouter(t, true)
+	sam := register(t, router, "sam@example.com")
+
+	rr := serve(router, "POST", "/api/users", "", user.NewUser{Email: "sam@example.com", Password: "correct horse"})
+	assert.Equal(t, 409, rr.Code)
+
+	rr = serve(router, "POST", "/api/login", "", user.Credentials{Email: "sam@example.com", Password: "correct horse"})
+	assert.Equal(t, 200, rr.Code)
+	var loggedIn user.User
+	json.Unmarshal(rr.Body.Bytes(), &loggedIn)
+	rr = serve(router, "POST", "/api/login", "", user.Credentials{Email: "sam@example.com", Password: "nope"})
+	assert.Equal(t, 401, rr.Code)
+
+	rr = serve(router, "GET", "/api/users/me", sam, nil)
+	assert.Equal(t, 200, rr.Code)
+	var me user.User
+	json.Unmarshal(rr.Body.Bytes(), &me)
+	assert.Equal(t, loggedIn.Id, me.Id)
+	assert.Equal(t, "sam@example.com", *me.Email)
+}
+
+func TestAuthenticate(t *testing.T) {
+	type test struct {
+		name          string
+		required      bool
+		authorization string
+		code          int
+	}
+	tests := []test{
+		{name: "required and missing", required: true, code: 401},
+		{name: "required and given", required: true, authorization: basicAuth("sam@example.com", "correct horse"), code: 200},
+		{name: "optional and missing", code: 200},
+		{name: "wrong password", authorization: basicAuth("sam@example.com", "nope"), code: 401},
+		{name: "unknown scheme", authorization: "Token abc", code: 401},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			router := newAuthRouter(t, tt.required)
+			register(t, router, "sam@example.com")
+			rr := serve(router, "GET", "/api/todo", tt.authorization, nil)
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+			if tt.code == 401 {
+				assert.Equal(t, `Basic realm="todo"`, rr.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, rr.Body.String(), `"message": "unauthorized"`)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestItemsAreScopedToTheirOwner(t *testing.T) {
+	router := newAuthRouter(t, true)
+	sam := register(t, router, "sam@example.com")
+	alex := register(t, router, "alex@example.com")
+
+	rr := serve(router, "POST", "/api/todo", sam, todoitem.TodoItem{Summary: newId("sam's taxes")})
+	assert.Equal(t, 201, rr.Code)
+	var item todoitem.TodoItem
+	json.Unmarshal(rr.Body.Bytes(), &item)
+	assert.NotNil(t, item.OwnerId)
+	serve(router, "POST", "/api/todo", alex, todoitem.TodoItem{Summary: newId("alex's groceries")})
+
+	var items []todoitem.TodoItem
+	json.Unmarshal(serve(router, "GET", "/api/todo", alex, nil).Body.Bytes(), &items)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "alex's groceries", *items[0].Summary)
+	}
+	json.Unmarshal(serve(router, "GET", "/api/todo?q=taxes", alex, nil).Body.Bytes(), &items)
+	assert.Empty(t, items)
+
+	missing := serve(router, "GET", "/api/todo/nope", alex, nil)
+	for _, rr := range []*httptest.ResponseRecorder{
+		serve(router, "GET", "/api/todo/"+*item.Id, alex, nil),
+		serve(router, "PATCH", "/api/todo/"+*item.Id, alex, todoitem.TodoItem{Summary: newId("mine now")}),
+		serve(router, "DELETE", "/api/todo/"+*item.Id, alex, nil),
+	} {
+		assert.Equal(t, 404, rr.Code)
+		assert.Equal(t, missing.Body.String(), strings.ReplaceAll(rr.Body.String(), *item.Id, "nope"),
+			"the same as an item that doesn't exist")
+	}
+	assert.Equal(t, 200, serve(router, "GET", "/api/todo/"+*item.Id, sam, nil).Code)
+}
@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/webhook"
+)
+
+type WebhookHandlers struct {
+	Webhook *webhook.Core
+}
+
+func NewWebhookHandlers(core *webhook.Core) WebhookHandlers {
+	return WebhookHandlers{
+		Webhook: core,
+	}
+}
+
+// RegisterWebhookEndpoints mounts webhooks and their delivery logs under <prefix>/webhooks.
+func (h *WebhookHandlers) RegisterWebhookEndpoints(parent *chi.Mux, prefix string) {
+	router := chi.NewRouter()
+
+	router.Get("/", h.GetWebhooks)
+	router.Post("/", h.CreateWebhook)
+	router.Get("/{id}", h.GetWebhook)
+	router.Delete("/{id}", h.DeleteWebhook)
+	router.Get("/{id}/deliveries", h.GetDeliveries)
+	parent.Mount(fmt.Sprintf("%s/webhooks", prefix), router)
+}
+
+func (h *WebhookHandlers) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetWebhooks")
+	defer span.End()
+	hooks, err := h.Webhook.GetAll(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, hooks)
+}
+
+func (h *WebhookHandlers) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetWebhook")
+	defer span.End()
+	hook, err := h.Webhook.GetById(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, hook)
+}
+
+func (h *WebhookHandlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "CreateWebhook")
+	defer span.End()
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var hook webhook.Webhook
+	if err := dec.Decode(&hook); err != nil {
+		figureDecodeError(err, w, r)
+		return
+	}
+	created, err := h.Webhook.Create(ctx, hook)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 201, created)
+}
+
+func (h *WebhookHandlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "DeleteWebhook")
+	defer span.End()
+	if err := h.Webhook.Delete(ctx, chi.URLParam(r, "id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// GetDeliveries is the delivery log for debugging a webhook, newest first. ?limit= defaults to 20.
+func (h *WebhookHandlers) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "GetDeliveries")
+	defer span.End()
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, terr.ErrorWithCode("invalid param", "limit must be a number", 400))
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.Webhook.Deliveries(ctx, chi.URLParam(r, "id"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, 200, deliveries)
+}
@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/webhook"
+)
+
+// mockWebhookStorer keeps webhooks and their deliveries in memory, enough for handler tests.
+type mockWebhookStorer struct {
+	hooks      map[string]webhook.Webhook
+	deliveries []webhook.Delivery
+}
+
+func (m *mockWebhookStorer) Create(ctx context.Context, h webhook.Webhook) (webhook.Webhook, error) {
+	h.Id = newId(memdb.NewId())
+	m.hooks[*h.Id] = h
+	return h, nil
+}
+
+func (m *mockWebhookStorer) GetAll(context.Context) ([]webhook.Webhook, error) {
+	all := []webhook.Webhook{}
+	for _, h := range m.hooks {
+		all = append(all, h)
+	}
+	return all, nil
+}
+
+func (m *mockWebhookStorer) GetById(ctx context.Context, id string) (webhook.Webhook, error) {
+	h, ok := m.hooks[id]
+	if !ok {
+		return webhook.Webhook{}, terr.ErrorWithCode("not found", "Webhook with id "+id+" not found", 404)
+	}
+	return h, nil
+}
+
+func (m *mockWebhookStorer) Delete(ctx context.Context, id string) error {
+	if _, ok := m.hooks[id]; !ok {
+		return terr.ErrorWithCode("not found", "Webhook with id "+id+" not found", 404)
+	}
+	delete(m.hooks, id)
+	return nil
+}
+
+func (m *mockWebhookStorer) Enqueue(ctx context.Context, ds []webhook.Delivery) error {
+	for _, d := range ds {
+		d.Id = memdb.NewId()
+		m.deliveries = append([]webhook.Delivery{d}, m.deliveries...)
+	}
+	return nil
+}
+
+func (m *mockWebhookStorer) Claim(context.Context, time.Time, time.Duration, int) ([]webhook.Delivery, error) {
+	return nil, nil
+}
+
+func (m *mockWebhookStorer) SaveAttempt(context.Context, webhook.Delivery) error {
+	return nil
+}
+
+func (m *mockWebhookStorer) Deliveries(ctx context.Context, id string, limit int) ([]webhook.Delivery, error) {
+	res := []webhook.Delivery{}
+	for _, d := range m.deliveries {
+		if d.WebhookId == id && len(res) < limit {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+func TestWebhookLifecycle(t *testing.T) {
+	core := webhook.NewCore(&mockWebhookStorer{hooks: map[string]webhook.Webhook{}})
+	todos := todoitem.NewCore(memdb.NewStore(), core)
+	parent := chi.NewRouter()
+	subject := NewWebhookHandlers(core)
+	subject.RegisterWebhookEndpoints(parent, "/api")
+
+	rr := httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/webhooks", bytes.NewReader([]byte(`{"url":"https://example.com/hook"}`))))
+	assert.Equal(t, 201, rr.Result().StatusCode)
+	var created webhook.Webhook
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&created))
+	assert.NotNil(t, created.Secret)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/webhooks/"+*created.Id, nil))
+	assert.Equal(t, 200, rr.Result().StatusCode)
+	assert.NotContains(t, rr.Body.String(), "secret")
+
+	_, err := todos.Create(context.Background(), todoitem.TodoItem{Summary: newSummary("hooked")})
+	assert.Nil(t, err)
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/webhooks/"+*created.Id+"/deliveries?limit=5", nil))
+	assert.Equal(t, 200, rr.Result().StatusCode)
+	var deliveries []webhook.Delivery
+	assert.Nil(t, json.NewDecoder(rr.Body).Decode(&deliveries))
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, todoitem.EventCreated, deliveries[0].Event)
+		assert.Equal(t, webhook.StatusPending, deliveries[0].Status)
+	}
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/webhooks/"+*created.Id+"/deliveries?limit=lots", nil))
+	assert.Equal(t, 400, rr.Result().StatusCode)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/webhooks/"+*created.Id, nil))
+	assert.Equal(t, 204, rr.Result().StatusCode)
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/webhooks/"+*created.Id, nil))
+	assert.Equal(t, 404, rr.Result().StatusCode)
+
+	rr = httptest.NewRecorder()
+	parent.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/webhooks", bytes.NewReader([]byte(`{"url":"not a url"}`))))
+	assert.Equal(t, 400, rr.Result().StatusCode)
+}
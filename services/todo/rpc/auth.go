@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Authenticate is the grpc version of rest.Authenticate, checking the authorization metadata of every call. Pass the
+// options to NewServer.
+func Authenticate(a auth.Authenticator, required bool) []grpc.ServerOption {
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, a, required)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), a, required)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tracedStream{ServerStream: ss, ctx: ctx})
+	}
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary), grpc.ChainStreamInterceptor(stream)}
+}
+
+func authenticate(ctx context.Context, a auth.Authenticator, required bool) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		if required {
+			return nil, toStatus(terr.ErrorWithCode("unauthorized", "authentication required", 401))
+		}
+		return auth.Anonymous(ctx, true), nil
+	}
+	p, err := a.Authenticate(ctx, values[0])
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"time"
+
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/rpc/todopb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var priorities = map[todoitem.Priority]todopb.Priority{
+	todoitem.PriorityNone:   todopb.Priority_PRIORITY_NONE,
+	todoitem.PriorityLow:    todopb.Priority_PRIORITY_LOW,
+	todoitem.PriorityMedium: todopb.Priority_PRIORITY_MEDIUM,
+	todoitem.PriorityHigh:   todopb.Priority_PRIORITY_HIGH,
+}
+
+var eventTypes = map[todoitem.EventType]todopb.EventType{
+	todoitem.EventCreated:   todopb.EventType_EVENT_TYPE_CREATED,
+	todoitem.EventUpdated:   todopb.EventType_EVENT_TYPE_UPDATED,
+	todoitem.EventCompleted: todopb.EventType_EVENT_TYPE_COMPLETED,
+	todoitem.EventDeleted:   todopb.EventType_EVENT_TYPE_DELETED,
+}
+
+// watchable is whether an event has a proto type to be sent as. Assignments don't yet, they're only on the rest event
+// stream and webhooks.
+func watchable(e todoitem.Event) bool {
+	_, ok := eventTypes[e.Type]
+	return ok
+}
+
+func toProtoItem(item todoitem.TodoItem) *todopb.TodoItem {
+	pb := &todopb.TodoItem{
+		Id:          deref(item.Id),
+		Created:     toTimestamp(item.Created),
+		Updated:     toTimestamp(item.Updated),
+		Deleted:     deref(item.Deleted),
+		Completed:   deref(item.Completed),
+		Summary:     deref(item.Summary),
+		Due:         toTimestamp(item.Due),
+		Tags:        item.Tags,
+		CompletedAt: toTimestamp(item.CompletedAt),
+		Version:     deref(item.Version),
+	}
+	if item.Priority != nil {
+		pb.Priority = priorities[*item.Priority]
+	}
+	return pb
+}
+
+func toProtoItems(items []todoitem.TodoItem) []*todopb.TodoItem {
+	pbs := make([]*todopb.TodoItem, 0, len(items))
+	for _, item := range items {
+		pbs = append(pbs, toProtoItem(item))
+	}
+	return pbs
+}
+
+// fromProtoPriority returns nil for unspecified, which leaves the priority alone. Anything we don't know about is
+// an invalid argument rather than quietly dropped.
+func fromProtoPriority(p todopb.Priority) (*todoitem.Priority, error) {
+	if p == todopb.Priority_PRIORITY_UNSPECIFIED {
+		return nil, nil
+	}
+	for k, v := range priorities {
+		if v == p {
+			k := k
+			return &k, nil
+		}
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "unknown priority %d", p)
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// toStatus turns a TodoError into the closest grpc status. Anything else is an internal error, same as the rest
+// api, so we don't leak details.
+func toStatus(err error) error {
+	v, ok := err.(*terr.TodoError)
+	if !ok {
+		v = terr.InternalError()
+	}
+	code := codes.Unknown
+	switch v.HttpCode {
+	case 400:
+		code = codes.InvalidArgument
+	case 401:
+		code = codes.Unauthenticated
+	case 403:
+		code = codes.PermissionDenied
+	case 404:
+		code = codes.NotFound
+	case 409:
+		code = codes.Aborted
+	case 500:
+		code = codes.Internal
+	}
+	return status.Error(code, v.Details())
+}
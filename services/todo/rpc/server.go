@@ -0,0 +1,168 @@
+// Package rpc serves the todo api over gRPC, see todopb/todo.proto for the service definition. It sits alongside the
+// rest package and wraps the same cores, so anything changed through one shows up in the other.
+package rpc
+
+import (
+	"context"
+
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/rpc/todopb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type TodoServer struct {
+	todopb.UnimplementedTodoServiceServer
+	TodoItem *todoitem.Core
+	Hub      *events.Hub
+}
+
+func NewTodoServer(todoItem *todoitem.Core, hub *events.Hub) *TodoServer {
+	return &TodoServer{
+		TodoItem: todoItem,
+		Hub:      hub,
+	}
+}
+
+// NewServer makes a grpc server with the todo service registered and every call traced. Any options (such as
+// Authenticate's interceptors) are added after tracing.
+func NewServer(todo *TodoServer, opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(TraceUnary),
+		grpc.ChainStreamInterceptor(TraceStream),
+	}, opts...)...)
+	todopb.RegisterTodoServiceServer(srv, todo)
+	return srv
+}
+
+func (s *TodoServer) Create(ctx context.Context, req *todopb.CreateRequest) (*todopb.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rpc-Create")
+	defer span.End()
+	priority, err := fromProtoPriority(req.Priority)
+	if err != nil {
+		return nil, err
+	}
+	item := todoitem.TodoItem{Summary: &req.Summary, Priority: priority, Due: fromTimestamp(req.Due), Tags: req.Tags}
+	created, err := s.TodoItem.Create(ctx, item)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoItem(created), nil
+}
+
+func (s *TodoServer) Get(ctx context.Context, req *todopb.GetRequest) (*todopb.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rpc-Get")
+	defer span.End()
+	item, err := s.TodoItem.GetById(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoItem(item), nil
+}
+
+func (s *TodoServer) List(ctx context.Context, req *todopb.ListRequest) (*todopb.ListResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rpc-List")
+	defer span.End()
+	var items []todoitem.TodoItem
+	var err error
+	if req.Query != "" {
+		items, err = s.TodoItem.Find(ctx, req.Query)
+	} else {
+		items, err = s.TodoItem.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &todopb.ListResponse{Items: toProtoItems(items)}, nil
+}
+
+func (s *TodoServer) Update(ctx context.Context, req *todopb.UpdateRequest) (*todopb.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rpc-Update")
+	defer span.End()
+	priority, err := fromProtoPriority(req.Priority)
+	if err != nil {
+		return nil, err
+	}
+	item := todoitem.TodoItem{
+		Summary:   &req.Summary,
+		Completed: req.Completed,
+		Deleted:   req.Deleted,
+		Priority:  priority,
+		Due:       fromTimestamp(req.Due),
+	}
+	if req.Tags != nil {
+		//non nil so the merge knows to replace them, even with nothing
+		item.Tags = append([]string{}, req.Tags.Values...)
+	}
+	updated, err := s.TodoItem.Update(ctx, item, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoItem(updated), nil
+}
+
+func (s *TodoServer) Delete(ctx context.Context, req *todopb.DeleteRequest) (*todopb.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "rpc-Delete")
+	defer span.End()
+	deleted, err := s.TodoItem.Delete(ctx, req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProtoItem(deleted), nil
+}
+
+// Watch follows the same rules as the server sent events feed: resume with the id of the last event seen, and if
+// that can't be done a reset event comes first. The stream ends with UNAVAILABLE if the client falls too far behind
+// or the server is shutting down, either way reconnect with the last id.
+func (s *TodoServer) Watch(req *todopb.WatchRequest, stream todopb.TodoService_WatchServer) error {
+	ctx, span := tracing.Tracer().Start(stream.Context(), "rpc-Watch")
+	defer span.End()
+	audience, err := s.TodoItem.Audience(ctx)
+	if err != nil {
+		return toStatus(err)
+	}
+	sub, replay, missed := s.Hub.Subscribe(req.LastEventId)
+	defer sub.Close()
+	if missed {
+		if err := stream.Send(&todopb.WatchEvent{Reset_: true}); err != nil {
+			return err
+		}
+	}
+	for _, env := range replay {
+		if !audience.Sees(env.Event) || !watchable(env.Event) {
+			continue
+		}
+		if err := stream.Send(toProtoEvent(env)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case env, ok := <-sub.C():
+			if !ok {
+				return status.Error(codes.Unavailable, "event stream closed, reconnect with the last event id")
+			}
+			if !audience.Sees(env.Event) || !watchable(env.Event) {
+				continue
+			}
+			if err := stream.Send(toProtoEvent(env)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProtoEvent(env events.Envelope) *todopb.WatchEvent {
+	return &todopb.WatchEvent{
+		Id:   env.Id,
+		Type: eventTypes[env.Event.Type],
+		Item: toProtoItem(env.Event.Item),
+		Time: timestamppb.New(env.Event.Time),
+	}
+}
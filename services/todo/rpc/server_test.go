@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/events"
+	"github.com/stumacwastaken/todo/rpc/todopb"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T, opts ...grpc.ServerOption) (todopb.TodoServiceClient, *events.Hub) {
+	hub := events.NewHub(10)
+	core := todoitem.NewCore(memdb.NewStore(), hub)
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(NewTodoServer(core, hub), opts...)
+	go srv.Serve(lis)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		hub.Close()
+		srv.Stop()
+	})
+	return todopb.NewTodoServiceClient(conn), hub
+}
+
+func TestTodoLifecycle(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+	created, err := client.Create(ctx, &todopb.CreateRequest{Summary: "buy milk", Priority: todopb.Priority_PRIORITY_HIGH, Tags: []string{"home"}})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, created.Id)
+	assert.Equal(t, todopb.Priority_PRIORITY_HIGH, created.Priority)
+	client.Create(ctx, &todopb.CreateRequest{Summary: "walk dog"})
+
+	got, err := client.Get(ctx, &todopb.GetRequest{Id: created.Id})
+	assert.Nil(t, err)
+	assert.Equal(t, "buy milk", got.Summary)
+
+	done := true
+	updated, err := client.Update(ctx, &todopb.UpdateRequest{Id: created.Id, Summary: "buy milk", Completed: &done, Tags: &todopb.Tags{}})
+	assert.Nil(t, err)
+	assert.True(t, updated.Completed)
+	assert.NotNil(t, updated.CompletedAt)
+	assert.Empty(t, updated.Tags, "an empty Tags clears them")
+	assert.Equal(t, todopb.Priority_PRIORITY_HIGH, updated.Priority, "unspecified leaves it alone")
+
+	list, err := client.List(ctx, &todopb.ListRequest{Query: "completed:true"})
+	assert.Nil(t, err)
+	assert.Len(t, list.Items, 1)
+
+	deleted, err := client.Delete(ctx, &todopb.DeleteRequest{Id: created.Id})
+	assert.Nil(t, err)
+	assert.True(t, deleted.Deleted)
+	list, _ = client.List(ctx, &todopb.ListRequest{})
+	assert.Len(t, list.Items, 1)
+}
+
+func TestErrorCodes(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+	type test struct {
+		name string
+		call func() error
+		code codes.Code
+	}
+	tests := []test{
+		{name: "empty summary", code: codes.InvalidArgument, call: func() error {
+			_, err := client.Create(ctx, &todopb.CreateRequest{})
+			return err
+		}},
+		{name: "unknown priority", code: codes.InvalidArgument, call: func() error {
+			_, err := client.Create(ctx, &todopb.CreateRequest{Summary: "x", Priority: todopb.Priority(42)})
+			return err
+		}},
+		{name: "missing", code: codes.NotFound, call: func() error {
+			_, err := client.Get(ctx, &todopb.GetRequest{Id: "nope"})
+			return err
+		}},
+		{name: "bad filter", code: codes.InvalidArgument, call: func() error {
+			_, err := client.List(ctx, &todopb.ListRequest{Query: "priority:urgent"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			assert.Equal(t, tt.code, status.Code(tt.call()))
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	client, hub := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Watch(ctx, &todopb.WatchRequest{LastEventId: "some-old-id"})
+	assert.Nil(t, err)
+	first, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.True(t, first.Reset_, "can't resume from an id we don't know")
+
+	created, _ := client.Create(ctx, &todopb.CreateRequest{Summary: "buy milk"})
+	e, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, todopb.EventType_EVENT_TYPE_CREATED, e.Type)
+	assert.Equal(t, created.Id, e.Item.Id)
+	assert.NotEmpty(t, e.Id)
+
+	hub.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestAuthenticate(t *testing.T) {
+	users := user.NewCore(memdb.NewUserStore())
+	ctx := context.Background()
+	for _, email := range []string{"sam@example.com", "alex@example.com"} {
+		if _, err := users.Register(ctx, user.NewUser{Email: email, Password: "correct horse"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, _ := newClient(t, Authenticate(users, true)...)
+	as := func(email, password string) context.Context {
+		basic := base64.StdEncoding.EncodeToString([]byte(email + ":" + password))
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+basic)
+	}
+
+	_, err := client.List(ctx, &todopb.ListRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.List(as("sam@example.com", "nope"), &todopb.ListRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	stream, _ := client.Watch(ctx, &todopb.WatchRequest{})
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	sam := as("sam@example.com", "correct horse")
+	created, err := client.Create(sam, &todopb.CreateRequest{Summary: "buy milk"})
+	assert.Nil(t, err)
+	_, err = client.Get(as("alex@example.com", "correct horse"), &todopb.GetRequest{Id: created.Id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	list, err := client.List(sam, &todopb.ListRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, list.Items, 1)
+}
@@ -0,0 +1,5 @@
+// Package todopb is generated from todo.proto. Regenerate with go generate after changing it, which needs buf,
+// protoc-gen-go and protoc-gen-go-grpc on your path.
+package todopb
+
+//go:generate buf generate --template buf.gen.yaml
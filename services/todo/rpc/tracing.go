@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/stumacwastaken/todo/tracing"
+	"google.golang.org/grpc"
+)
+
+// TraceUnary is the grpc version of rest.TraceRequest, a basic span around every call in case a method forgets its own.
+func TraceUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, span := tracing.Tracer().Start(ctx, info.FullMethod)
+	defer span.End()
+	return handler(ctx, req)
+}
+
+// TraceStream does the same as TraceUnary for streaming calls.
+func TraceStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := tracing.Tracer().Start(ss.Context(), info.FullMethod)
+	defer span.End()
+	return handler(srv, &tracedStream{ServerStream: ss, ctx: ctx})
+}
+
+// tracedStream hands the handler a context with the span in it. Authenticate uses it for the principal too.
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,13 @@
+package smartlist
+
+import "time"
+
+// SmartList is a saved, named filter expression. Its items are worked out every time it is read, so relative dates
+// like `due<7d` keep moving with the calendar.
+type SmartList struct {
+	Id      *string    `json:"id,omitempty"`
+	Name    *string    `json:"name,omitempty"`
+	Query   *string    `json:"query,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+	Updated *time.Time `json:"updated,omitempty"`
+}
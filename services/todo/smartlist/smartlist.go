@@ -0,0 +1,120 @@
+package smartlist
+
+import (
+	"context"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type Storer interface {
+	Create(context.Context, SmartList) (SmartList, error)
+	GetAll(context.Context) ([]SmartList, error)
+	GetById(context.Context, string) (SmartList, error)
+	Update(context.Context, SmartList) (SmartList, error)
+	Delete(context.Context, string) error
+}
+
+// Todos is the part of the todo item domain smart lists need. It's satisfied by *todoitem.Core.
+type Todos interface {
+	Compile(expr string) (filter.Predicate, error)
+	Find(ctx context.Context, expr string) ([]todoitem.TodoItem, error)
+}
+
+type Core struct {
+	storer Storer
+	todos  Todos
+}
+
+func NewCore(storer Storer, todos Todos) *Core {
+	return &Core{
+		storer: storer,
+		todos:  todos,
+	}
+}
+
+// Create validates and saves a new smart list. The query has to compile, otherwise it'd only blow up once someone
+// opened the list.
+func (c *Core) Create(ctx context.Context, list SmartList) (SmartList, error) {
+	if err := auth.Require(ctx, auth.ScopeWrite); err != nil {
+		return SmartList{}, err
+	}
+	if list.Id != nil {
+		return SmartList{}, terr.ErrorWithCode("invalid param", "cannot create a smart list with an already existing id", 400)
+	}
+	if err := c.validate(list); err != nil {
+		return SmartList{}, err
+	}
+	return c.storer.Create(ctx, list)
+}
+
+func (c *Core) Update(ctx context.Context, newList SmartList, id string) (SmartList, error) {
+	if err := auth.Require(ctx, auth.ScopeWrite); err != nil {
+		return SmartList{}, err
+	}
+	if id == "" {
+		return SmartList{}, terr.ErrorWithCode("no id", "no id found in request", 404)
+	}
+	old, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return SmartList{}, asTodoError(err)
+	}
+	if newList.Name != nil {
+		old.Name = newList.Name
+	}
+	if newList.Query != nil {
+		old.Query = newList.Query
+	}
+	if err := c.validate(old); err != nil {
+		return SmartList{}, err
+	}
+	saved, err := c.storer.Update(ctx, old)
+	if err != nil {
+		return SmartList{}, asTodoError(err)
+	}
+	return saved, nil
+}
+
+func (c *Core) GetAll(ctx context.Context) ([]SmartList, error) {
+	return c.storer.GetAll(ctx)
+}
+
+func (c *Core) GetById(ctx context.Context, id string) (SmartList, error) {
+	return c.storer.GetById(ctx, id)
+}
+
+func (c *Core) Delete(ctx context.Context, id string) error {
+	if err := auth.Require(ctx, auth.ScopeWrite); err != nil {
+		return err
+	}
+	return c.storer.Delete(ctx, id)
+}
+
+// Items evaluates the smart list's query, returning the todo items currently in it.
+func (c *Core) Items(ctx context.Context, id string) ([]todoitem.TodoItem, error) {
+	list, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	return c.todos.Find(ctx, *list.Query)
+}
+
+func (c *Core) validate(list SmartList) error {
+	if list.Name == nil || *list.Name == "" {
+		return terr.ErrorWithCode("invalid param", "name cannot be empty", 400)
+	}
+	if list.Query == nil {
+		return terr.ErrorWithCode("invalid param", "query cannot be empty", 400)
+	}
+	_, err := c.todos.Compile(*list.Query)
+	return err
+}
+
+func asTodoError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
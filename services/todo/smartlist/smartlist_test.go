@@ -0,0 +1,144 @@
+package smartlist
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type MockStorer struct {
+	resp func(method string) ([]SmartList, error)
+}
+
+func (m *MockStorer) Create(ctx context.Context, l SmartList) (SmartList, error) {
+	res, err := m.resp("Create")
+	if len(res) > 0 {
+		return res[0], err
+	}
+	return SmartList{}, err
+}
+
+func (m *MockStorer) GetAll(context.Context) ([]SmartList, error) {
+	return m.resp("GetAll")
+}
+
+func (m *MockStorer) GetById(context.Context, string) (SmartList, error) {
+	res, err := m.resp("GetById")
+	if len(res) > 0 {
+		return res[0], err
+	}
+	return SmartList{}, err
+}
+
+func (m *MockStorer) Update(ctx context.Context, l SmartList) (SmartList, error) {
+	_, err := m.resp("Update")
+	return l, err
+}
+
+func (m *MockStorer) Delete(context.Context, string) error {
+	_, err := m.resp("Delete")
+	return err
+}
+
+func newString(s string) *string {
+	return &s
+}
+
+func TestCreate(t *testing.T) {
+	type test struct {
+		name string
+		req  SmartList
+		err  error
+	}
+	tests := []test{
+		{
+			name: "happy path",
+			req:  SmartList{Name: newString("work"), Query: newString("tag:work completed:false")},
+		},
+		{
+			name: "existing id",
+			req:  SmartList{Id: newString("1111"), Name: newString("work"), Query: newString("tag:work")},
+			err:  terr.ErrorWithCode("invalid param", "cannot create a smart list with an already existing id", 400),
+		},
+		{
+			name: "no name",
+			req:  SmartList{Query: newString("tag:work")},
+			err:  terr.ErrorWithCode("invalid param", "name cannot be empty", 400),
+		},
+		{
+			name: "no query",
+			req:  SmartList{Name: newString("work")},
+			err:  terr.ErrorWithCode("invalid param", "query cannot be empty", 400),
+		},
+		{
+			name: "bad query",
+			req:  SmartList{Name: newString("work"), Query: newString("tag:work priority>urgent")},
+			err:  terr.ErrorWithCode("invalid filter", "priority expects one of none, low, medium, high (at position 18)", 400).AtPosition(18),
+		},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mocks := &MockStorer{resp: func(method string) ([]SmartList, error) {
+				return []SmartList{tt.req}, nil
+			}}
+			subject := NewCore(mocks, todoitem.NewCore(memdb.NewStore()))
+			res, err := subject.Create(context.Background(), tt.req)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, tt.req, res)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	existing := SmartList{Id: newString("1111"), Name: newString("work"), Query: newString("tag:work")}
+	mocks := &MockStorer{resp: func(method string) ([]SmartList, error) {
+		return []SmartList{existing}, nil
+	}}
+	subject := NewCore(mocks, todoitem.NewCore(memdb.NewStore()))
+
+	res, err := subject.Update(context.Background(), SmartList{Query: newString("tag:work due<7d")}, "1111")
+	assert.Nil(t, err)
+	assert.Equal(t, "work", *res.Name, "name should be kept")
+	assert.Equal(t, "tag:work due<7d", *res.Query)
+
+	_, err = subject.Update(context.Background(), SmartList{Query: newString("due<whenever")}, "1111")
+	assert.Equal(t, 400, err.(*terr.TodoError).HttpCode)
+
+	mocks.resp = func(method string) ([]SmartList, error) {
+		return nil, errors.New("random store error")
+	}
+	_, err = subject.Update(context.Background(), SmartList{Query: newString("tag:work")}, "1111")
+	assert.Equal(t, terr.InternalError(), err)
+}
+
+func TestItems(t *testing.T) {
+	store := memdb.NewStore()
+	todos := todoitem.NewCore(store)
+	ctx := context.Background()
+	for _, s := range []string{"buy milk", "write report"} {
+		_, err := todos.Create(ctx, todoitem.TodoItem{Summary: newString(s), Tags: []string{"home"}})
+		assert.Nil(t, err)
+	}
+	mocks := &MockStorer{resp: func(method string) ([]SmartList, error) {
+		return []SmartList{{Id: newString("1111"), Name: newString("shopping"), Query: newString("tag:home buy")}}, nil
+	}}
+	subject := NewCore(mocks, todos)
+	items, err := subject.Items(ctx, "1111")
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "buy milk", *items[0].Summary)
+
+	mocks.resp = func(method string) ([]SmartList, error) {
+		return nil, terr.ErrorWithCode("not found", "Smart list with id 1111 not found", 404)
+	}
+	_, err = subject.Items(ctx, "1111")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Smart list with id 1111 not found", 404), err)
+}
@@ -0,0 +1,190 @@
+package memberdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// duplicateEntry is mysql's error number for breaking a unique index
+const duplicateEntry = 1062
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, m member.Member) (member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-create")
+	defer span.End()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return member.Member{}, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var id string
+	if err := tx.GetContext(ctx, &id, `SELECT UUID()`); err != nil {
+		log.Default().Error("failed to generate member id", zap.Error(err))
+		return member.Member{}, errors.UnknownError()
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO list_member (id, list_id, user_id, email, role, invited_by, workspace_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, m.ListId, m.UserId, m.Email, m.Role, m.InvitedBy, auth.Workspace(ctx))
+	if err != nil {
+		if merr, ok := err.(*mysql.MySQLError); ok && merr.Number == duplicateEntry {
+			return member.Member{}, errors.ErrorWithCode("conflict", "Member already exists", 409)
+		}
+		log.Default().Warn("error creating new member in database", zap.Error(err))
+		return member.Member{}, errors.UnknownError()
+	}
+	v := new(dbMember)
+	if err := tx.GetContext(ctx, v, `SELECT * FROM list_member WHERE id=?`, id); err != nil {
+		log.Default().Warn("error reading back new member", zap.Error(err))
+		return member.Member{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit member", zap.Error(err))
+		return member.Member{}, errors.UnknownError()
+	}
+	return toCoreMember(*v), nil
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-getById")
+	defer span.End()
+	v := new(dbMember)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM list_member WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return member.Member{}, notFound(id)
+		}
+		log.Default().Error("unknown error querying member by id", zap.Error(err), zap.String("req id", id))
+		return member.Member{}, errors.UnknownError()
+	}
+	return toCoreMember(*v), nil
+}
+
+func (s *Store) Get(ctx context.Context, listId, userId string) (member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-get")
+	defer span.End()
+	v := new(dbMember)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM list_member WHERE list_id=? AND user_id=? AND workspace_id=?`, listId, userId,
+		auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return member.Member{}, errors.ErrorWithCode("not found", "Member not found", 404)
+		}
+		log.Default().Error("unknown error querying member", zap.Error(err))
+		return member.Member{}, errors.UnknownError()
+	}
+	return toCoreMember(*v), nil
+}
+
+func (s *Store) GetByEmail(ctx context.Context, listId, email string) (member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-getByEmail")
+	defer span.End()
+	v := new(dbMember)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM list_member WHERE list_id=? AND email=? AND workspace_id=?`, listId, email,
+		auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return member.Member{}, errors.ErrorWithCode("not found", "Member not found", 404)
+		}
+		log.Default().Error("unknown error querying member by email", zap.Error(err))
+		return member.Member{}, errors.UnknownError()
+	}
+	return toCoreMember(*v), nil
+}
+
+func (s *Store) GetByList(ctx context.Context, listId string) ([]member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-getByList")
+	defer span.End()
+	return s.selectMembers(ctx, `SELECT * FROM list_member WHERE list_id=? AND workspace_id=? ORDER BY date_created`, listId,
+		auth.Workspace(ctx))
+}
+
+func (s *Store) GetByUser(ctx context.Context, userId string) ([]member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-getByUser")
+	defer span.End()
+	return s.selectMembers(ctx, `SELECT * FROM list_member WHERE user_id=? AND workspace_id=? ORDER BY date_created`, userId,
+		auth.Workspace(ctx))
+}
+
+func (s *Store) Update(ctx context.Context, m member.Member) (member.Member, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-update")
+	defer span.End()
+	if _, err := s.db.ExecContext(ctx, `UPDATE list_member SET role=?, date_accepted=? WHERE id=? AND workspace_id=?`,
+		m.Role, m.Accepted, m.Id, auth.Workspace(ctx)); err != nil {
+		log.Default().Error("error updating member", zap.Error(err), zap.Stringp("req id", m.Id))
+		return member.Member{}, errors.UnknownError()
+	}
+	//mysql counts rows changed rather than matched, so reading it back is what tells us if it's there
+	return s.GetById(ctx, *m.Id)
+}
+
+func (s *Store) Claim(ctx context.Context, email, userId string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-claim")
+	defer span.End()
+	if _, err := s.db.ExecContext(ctx, `UPDATE list_member SET user_id=? WHERE email=? AND user_id IS NULL AND workspace_id=?`,
+		userId, email, auth.Workspace(ctx)); err != nil {
+		log.Default().Error("error claiming invitations", zap.Error(err))
+		return errors.UnknownError()
+	}
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-member-delete")
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM list_member WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx))
+	if err != nil {
+		log.Default().Error("error deleting member", zap.Error(err), zap.String("req id", id))
+		return errors.UnknownError()
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFound(id)
+	}
+	return nil
+}
+
+func (s *Store) selectMembers(ctx context.Context, q string, args ...any) ([]member.Member, error) {
+	rows := []dbMember{}
+	if err := s.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		log.Default().Error("unknown error querying members", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	members := make([]member.Member, 0, len(rows))
+	for _, r := range rows {
+		members = append(members, toCoreMember(r))
+	}
+	return members, nil
+}
+
+func notFound(id string) error {
+	return errors.ErrorWithCode("not found", fmt.Sprintf("Member with id %s not found", id), 404)
+}
+
+func toCoreMember(m dbMember) member.Member {
+	role := member.Role(m.Role)
+	return member.Member{
+		Id:        &m.Id,
+		ListId:    &m.ListId,
+		UserId:    m.UserId,
+		Email:     &m.Email,
+		Role:      &role,
+		InvitedBy: &m.InvitedBy,
+		Created:   &m.DateCreated,
+		Accepted:  m.DateAccepted,
+	}
+}
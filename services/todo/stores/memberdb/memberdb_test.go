@@ -0,0 +1,154 @@
+package memberdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/member"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+func newRole(r member.Role) *member.Role {
+	return &r
+}
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local)
+
+var memberColumns = []string{"id", "list_id", "user_id", "email", "role", "invited_by", "date_created", "date_accepted"}
+
+func newStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	return NewStore(sqlx.NewDb(mockDB, "sqlmock")), mock
+}
+
+func TestCreate(t *testing.T) {
+	type test struct {
+		name      string
+		insertErr error
+		expectErr error
+	}
+	tests := []test{
+		{name: "happy path"},
+		{name: "already invited", insertErr: &mysql.MySQLError{Number: 1062}, expectErr: terr.ErrorWithCode("conflict", "Member already exists", 409)},
+		{name: "database error", insertErr: errors.New("boom"), expectErr: terr.UnknownError()},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			store, mock := newStore(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT UUID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"UUID()"}).AddRow("1111"))
+			insert := mock.ExpectExec(`INSERT INTO list_member \(id, list_id, user_id, email, role, invited_by, workspace_id\) VALUES \(\?, \?, \?, \?, \?, \?, \?\)`).
+				WithArgs("1111", "sam", "alex", "alex@example.com", "editor", "sam", "default")
+			if tt.insertErr != nil {
+				insert.WillReturnError(tt.insertErr)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT \* FROM list_member WHERE id=\?`).WithArgs("1111").
+					WillReturnRows(sqlmock.NewRows(memberColumns).AddRow("1111", "sam", "alex", "alex@example.com", "editor", "sam", testTime, nil))
+				mock.ExpectCommit()
+			}
+
+			val, err := store.Create(context.Background(), member.Member{ListId: newString("sam"), UserId: newString("alex"),
+				Email: newString("alex@example.com"), Role: newRole(member.RoleEditor), InvitedBy: newString("sam")})
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				assert.Equal(t, member.Member{Id: newString("1111"), ListId: newString("sam"), UserId: newString("alex"),
+					Email: newString("alex@example.com"), Role: newRole(member.RoleEditor), InvitedBy: newString("sam"), Created: &testTime}, val)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGet(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectQuery(`SELECT \* FROM list_member WHERE list_id=\? AND user_id=\? AND workspace_id=\?`).WithArgs("sam", "alex", "default").
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow("1111", "sam", "alex", "alex@example.com", "viewer", "sam", testTime, testTime))
+	mock.ExpectQuery(`SELECT \* FROM list_member WHERE list_id=\? AND user_id=\? AND workspace_id=\?`).WithArgs("sam", "jo", "default").
+		WillReturnRows(sqlmock.NewRows(memberColumns))
+
+	val, err := store.Get(context.Background(), "sam", "alex")
+	assert.Nil(t, err)
+	assert.Equal(t, &testTime, val.Accepted)
+	_, err = store.Get(context.Background(), "sam", "jo")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Member not found", 404), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByEmail(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectQuery(`SELECT \* FROM list_member WHERE list_id=\? AND email=\? AND workspace_id=\?`).WithArgs("sam", "lee@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow("1111", "sam", nil, "lee@example.com", "viewer", "sam", testTime, nil))
+	mock.ExpectQuery(`SELECT \* FROM list_member WHERE list_id=\? AND email=\? AND workspace_id=\?`).WithArgs("sam", "jo@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(memberColumns))
+
+	val, err := store.GetByEmail(context.Background(), "sam", "lee@example.com")
+	assert.Nil(t, err)
+	assert.Nil(t, val.UserId, "nobody has registered with the email yet")
+	_, err = store.GetByEmail(context.Background(), "sam", "jo@example.com")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Member not found", 404), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectExec(`UPDATE list_member SET user_id=\? WHERE email=\? AND user_id IS NULL AND workspace_id=\?`).WithArgs("lee", "lee@example.com", "ops").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE list_member`).WillReturnError(errors.New("boom"))
+	ops := auth.WithWorkspace(context.Background(), "ops")
+	assert.Nil(t, store.Claim(ops, "lee@example.com", "lee"))
+	assert.Equal(t, terr.UnknownError(), store.Claim(ops, "lee@example.com", "lee"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetByUser(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectQuery(`SELECT \* FROM list_member WHERE user_id=\? AND workspace_id=\? ORDER BY date_created`).WithArgs("alex", "ops").
+		WillReturnRows(sqlmock.NewRows(memberColumns).
+			AddRow("1111", "sam", "alex", "alex@example.com", "viewer", "sam", testTime, testTime).
+			AddRow("2222", "jo", "alex", "alex@example.com", "owner", "jo", testTime, nil))
+	val, err := store.GetByUser(auth.WithWorkspace(context.Background(), "ops"), "alex")
+	assert.Nil(t, err)
+	if assert.Len(t, val, 2) {
+		assert.Nil(t, val[1].Accepted, "pending invitations are included")
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdate(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectExec(`UPDATE list_member SET role=\?, date_accepted=\? WHERE id=\? AND workspace_id=\?`).WithArgs("editor", &testTime, "1111", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM list_member WHERE id=\? AND workspace_id=\?`).WithArgs("1111", "default").
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow("1111", "sam", "alex", "alex@example.com", "editor", "sam", testTime, testTime))
+	val, err := store.Update(context.Background(), member.Member{Id: newString("1111"), Role: newRole(member.RoleEditor), Accepted: &testTime})
+	assert.Nil(t, err)
+	assert.Equal(t, newRole(member.RoleEditor), val.Role)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectExec(`DELETE FROM list_member WHERE id=\? AND workspace_id=\?`).WithArgs("1111", "default").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM list_member WHERE id=\? AND workspace_id=\?`).WithArgs("nope", "default").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.Nil(t, store.Delete(context.Background(), "1111"))
+	assert.Equal(t, notFound("nope"), store.Delete(context.Background(), "nope"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,15 @@
+package memberdb
+
+import "time"
+
+type dbMember struct {
+	Id           string     `db:"id"`
+	ListId       string     `db:"list_id"`
+	UserId       *string    `db:"user_id"`
+	Email        string     `db:"email"`
+	Role         string     `db:"role"`
+	InvitedBy    string     `db:"invited_by"`
+	WorkspaceId  string     `db:"workspace_id"`
+	DateCreated  time.Time  `db:"date_created"`
+	DateAccepted *time.Time `db:"date_accepted"`
+}
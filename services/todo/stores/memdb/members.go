@@ -0,0 +1,151 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/member"
+)
+
+// MemberStore is an in memory member.Storer, for tests and local tooling like Store.
+type MemberStore struct {
+	mu      sync.RWMutex
+	members map[string]member.Member
+}
+
+func NewMemberStore() *MemberStore {
+	return &MemberStore{
+		members: map[string]member.Member{},
+	}
+}
+
+func (s *MemberStore) Create(ctx context.Context, m member.Member) (member.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.members {
+		if *v.ListId == *m.ListId && (*v.Email == *m.Email || (m.UserId != nil && isUser(v.UserId, *m.UserId))) {
+			return member.Member{}, errors.ErrorWithCode("conflict", "Member already exists", 409)
+		}
+	}
+	id := NewId()
+	now := nowFn()
+	m.Id = &id
+	m.Created = &now
+	m.Accepted = nil
+	s.members[id] = copyMember(m)
+	return copyMember(m), nil
+}
+
+func (s *MemberStore) GetById(ctx context.Context, id string) (member.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.members[id]
+	if !ok {
+		return member.Member{}, memberNotFound(id)
+	}
+	return copyMember(m), nil
+}
+
+func (s *MemberStore) Get(ctx context.Context, listId, userId string) (member.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.members {
+		if *m.ListId == listId && isUser(m.UserId, userId) {
+			return copyMember(m), nil
+		}
+	}
+	return member.Member{}, errors.ErrorWithCode("not found", "Member not found", 404)
+}
+
+func (s *MemberStore) GetByEmail(ctx context.Context, listId, email string) (member.Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.members {
+		if *m.ListId == listId && *m.Email == email {
+			return copyMember(m), nil
+		}
+	}
+	return member.Member{}, errors.ErrorWithCode("not found", "Member not found", 404)
+}
+
+func (s *MemberStore) GetByList(ctx context.Context, listId string) ([]member.Member, error) {
+	return s.where(func(m member.Member) bool { return *m.ListId == listId }), nil
+}
+
+func (s *MemberStore) GetByUser(ctx context.Context, userId string) ([]member.Member, error) {
+	return s.where(func(m member.Member) bool { return isUser(m.UserId, userId) }), nil
+}
+
+func (s *MemberStore) Update(ctx context.Context, m member.Member) (member.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.members[*m.Id]
+	if !ok {
+		return member.Member{}, memberNotFound(*m.Id)
+	}
+	existing.Role = copyPtr(m.Role)
+	existing.Accepted = copyPtr(m.Accepted)
+	s.members[*m.Id] = existing
+	return copyMember(existing), nil
+}
+
+func (s *MemberStore) Claim(ctx context.Context, email, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, m := range s.members {
+		if m.UserId == nil && *m.Email == email {
+			m.UserId = &userId
+			s.members[id] = m
+		}
+	}
+	return nil
+}
+
+func (s *MemberStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[id]; !ok {
+		return memberNotFound(id)
+	}
+	delete(s.members, id)
+	return nil
+}
+
+// where is the members matching keep, oldest first.
+func (s *MemberStore) where(keep func(member.Member) bool) []member.Member {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []member.Member{}
+	for _, m := range s.members {
+		if keep(m) {
+			res = append(res, copyMember(m))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.Before(*res[j].Created) })
+	return res
+}
+
+// isUser reports if a member's user id, which pending invitations don't have, is id.
+func isUser(userId *string, id string) bool {
+	return userId != nil && *userId == id
+}
+
+func memberNotFound(id string) error {
+	return errors.ErrorWithCode("not found", fmt.Sprintf("Member with id %s not found", id), 404)
+}
+
+func copyMember(m member.Member) member.Member {
+	return member.Member{
+		Id:        copyPtr(m.Id),
+		ListId:    copyPtr(m.ListId),
+		UserId:    copyPtr(m.UserId),
+		Email:     copyPtr(m.Email),
+		Role:      copyPtr(m.Role),
+		InvitedBy: copyPtr(m.InvitedBy),
+		Created:   copyPtr(m.Created),
+		Accepted:  copyPtr(m.Accepted),
+	}
+}
@@ -0,0 +1,255 @@
+// Package memdb is an in memory todoitem.Storer (and user, token and member Storers). It's handy for tests and local
+// tooling where standing up mysql is overkill, and doubles as the reference for how a store should evaluate filters
+// without a query language.
+package memdb
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type Store struct {
+	mu    sync.RWMutex
+	items map[string]todoitem.TodoItem
+	//workspaces is the workspace of each item by id, same as the workspace_id column. Items are only ever seen from
+	//their own workspace.
+	workspaces map[string]string
+	//version is the last version handed out, same as the sync_clock table
+	version int64
+}
+
+func NewStore() *Store {
+	return &Store{
+		items:      map[string]todoitem.TodoItem{},
+		workspaces: map[string]string{},
+	}
+}
+
+// pull out so tests can pin timestamps
+var nowFn = time.Now
+
+func (s *Store) Create(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := NewId()
+	now := nowFn()
+	f := false
+	item.Id = &id
+	item.Created = &now
+	item.Updated = &now
+	item.Deleted = &f
+	item.Completed = &f
+	s.version++
+	item.Version = &s.version
+	s.items[id] = copyItem(item)
+	s.workspaces[id] = auth.Workspace(ctx)
+	return copyItem(item), nil
+}
+
+func (s *Store) Update(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
+	if item.Id == nil {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("not found", "Item with no id not found", 404)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.items[*item.Id]
+	if !ok || !s.in(ctx, *item.Id) {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("not found", fmt.Sprintf("Item with id %s not found", *item.Id), 404)
+	}
+	if item.Version != nil && *item.Version != *old.Version {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("conflict", fmt.Sprintf("Item with id %s was changed by someone else, fetch it and try again", *item.Id), 409)
+	}
+	//created is owned by the store, same as the date_created column
+	item.Created = old.Created
+	s.version++
+	item.Version = &s.version
+	s.items[*item.Id] = copyItem(item)
+	return copyItem(item), nil
+}
+
+func (s *Store) Put(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
+	if item.Id == nil {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("bad request", "Items need an id to be put", 400)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[*item.Id]; ok && !s.in(ctx, *item.Id) {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("conflict", fmt.Sprintf("Item id %s is already taken", *item.Id), 409)
+	}
+	s.version++
+	item.Version = &s.version
+	s.items[*item.Id] = copyItem(item)
+	s.workspaces[*item.Id] = auth.Workspace(ctx)
+	return copyItem(item), nil
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (todoitem.TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[id]
+	if !ok || !s.in(ctx, id) {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("not found", fmt.Sprintf("Item with id %s not found", id), 404)
+	}
+	return copyItem(item), nil
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]todoitem.TodoItem, error) {
+	return s.Find(ctx, filter.Predicate{Conds: []filter.Cond{
+		{Field: filter.Field{Name: "deleted", Kind: filter.Bool}, Op: filter.Eq, Value: false},
+	}})
+}
+
+func (s *Store) Find(ctx context.Context, pred filter.Predicate) ([]todoitem.TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found []todoitem.TodoItem
+	for id, item := range s.items {
+		if s.in(ctx, id) && pred.Match(item) {
+			found = append(found, copyItem(item))
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Created.After(*found[j].Created)
+	})
+	return found, nil
+}
+
+func (s *Store) Changes(ctx context.Context, since int64, limit int, lists []string) ([]todoitem.TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var changed []todoitem.TodoItem
+	for id, item := range s.items {
+		if s.in(ctx, id) && *item.Version > since && inLists(lists, item) {
+			changed = append(changed, copyItem(item))
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return *changed[i].Version < *changed[j].Version
+	})
+	if len(changed) > limit {
+		changed = changed[:limit]
+	}
+	return changed, nil
+}
+
+// Stats walks every item, there's no avoiding it in memory.
+func (s *Store) Stats(ctx context.Context, r todoitem.StatsRange) (todoitem.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var stats todoitem.Stats
+	days := map[string]*todoitem.DayStats{}
+	day := func(t time.Time) *todoitem.DayStats {
+		date := t.UTC().Format(todoitem.DayFormat)
+		if _, ok := days[date]; !ok {
+			days[date] = &todoitem.DayStats{Date: date}
+		}
+		return days[date]
+	}
+	inRange := func(t *time.Time) bool {
+		return t != nil && !t.Before(r.From) && t.Before(r.To)
+	}
+	var totalSeconds float64
+	var completedInRange int
+	for id, item := range s.items {
+		if !s.in(ctx, id) || !todoitem.VisibleTo(r.Owner, item) {
+			continue
+		}
+		deleted := item.Deleted != nil && *item.Deleted
+		completed := item.Completed != nil && *item.Completed
+		switch {
+		case deleted:
+			stats.Deleted++
+		case completed:
+			stats.Completed++
+		default:
+			stats.Open++
+			if item.Due != nil && item.Due.Before(r.Now) {
+				stats.Overdue++
+			}
+		}
+		if inRange(item.Created) {
+			day(*item.Created).Created++
+		}
+		if inRange(item.CompletedAt) {
+			day(*item.CompletedAt).Completed++
+			completedInRange++
+			totalSeconds += item.CompletedAt.Sub(*item.Created).Truncate(time.Second).Seconds()
+		}
+	}
+	if completedInRange > 0 {
+		avg := totalSeconds / float64(completedInRange)
+		stats.AverageSecondsToComplete = &avg
+	}
+	for _, d := range days {
+		stats.Days = append(stats.Days, *d)
+	}
+	return stats, nil
+}
+
+// in reports if an item is in the workspace of the request in ctx.
+func (s *Store) in(ctx context.Context, id string) bool {
+	return s.workspaces[id] == auth.Workspace(ctx)
+}
+
+// inLists reports if an item is in one of lists, nil being every list.
+func inLists(lists []string, item todoitem.TodoItem) bool {
+	if lists == nil {
+		return true
+	}
+	for _, l := range lists {
+		if item.OwnerId != nil && *item.OwnerId == l {
+			return true
+		}
+	}
+	return false
+}
+
+// NewId returns a random (v4) uuid, matching what mysql's uuid() gives us for ids.
+func NewId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// copyItem stops callers from reaching into the store through the item's pointers.
+func copyItem(item todoitem.TodoItem) todoitem.TodoItem {
+	c := todoitem.TodoItem{
+		Id:          copyPtr(item.Id),
+		Created:     copyPtr(item.Created),
+		Updated:     copyPtr(item.Updated),
+		Deleted:     copyPtr(item.Deleted),
+		Completed:   copyPtr(item.Completed),
+		Summary:     copyPtr(item.Summary),
+		Priority:    copyPtr(item.Priority),
+		Due:         copyPtr(item.Due),
+		CompletedAt: copyPtr(item.CompletedAt),
+		Version:     copyPtr(item.Version),
+		OwnerId:     copyPtr(item.OwnerId),
+	}
+	if item.Tags != nil {
+		c.Tags = append([]string{}, item.Tags...)
+	}
+	if item.AssigneeIds != nil {
+		c.AssigneeIds = append([]string{}, item.AssigneeIds...)
+	}
+	return c
+}
+
+func copyPtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -0,0 +1,211 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newSummary(summary string) *string {
+	return &summary
+}
+func newBool(b bool) *bool {
+	return &b
+}
+
+func TestCreateAndGet(t *testing.T) {
+	store := NewStore()
+	created, err := store.Create(context.Background(), todoitem.TodoItem{Summary: newSummary("test summary")})
+	assert.Nil(t, err)
+	assert.NotNil(t, created.Id)
+	assert.Len(t, *created.Id, 36)
+	assert.False(t, *created.Completed)
+
+	got, err := store.GetById(context.Background(), *created.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, created, got)
+
+	//changing what we got back shouldn't change what's stored
+	*got.Summary = "changed"
+	again, _ := store.GetById(context.Background(), *created.Id)
+	assert.Equal(t, "test summary", *again.Summary)
+
+	_, err = store.GetById(context.Background(), "nope")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Item with id nope not found", 404), err)
+}
+
+func TestUpdate(t *testing.T) {
+	store := NewStore()
+	created, _ := store.Create(context.Background(), todoitem.TodoItem{Summary: newSummary("test summary")})
+	created.Completed = newBool(true)
+	updated, err := store.Update(context.Background(), created)
+	assert.Nil(t, err)
+	assert.True(t, *updated.Completed)
+
+	assert.Equal(t, *created.Version+1, *updated.Version)
+
+	//created still holds the version it was read at, which has moved on
+	_, err = store.Update(context.Background(), created)
+	assert.Equal(t, terr.ErrorWithCode("conflict", fmt.Sprintf("Item with id %s was changed by someone else, fetch it and try again", *created.Id), 409), err)
+
+	_, err = store.Update(context.Background(), todoitem.TodoItem{Id: newSummary("nope")})
+	assert.Equal(t, terr.ErrorWithCode("not found", "Item with id nope not found", 404), err)
+}
+
+func TestPut(t *testing.T) {
+	store := NewStore()
+	created := time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+	item := todoitem.TodoItem{Id: newSummary("1111"), Summary: newSummary("test summary"), Created: &created,
+		Updated: &created, Deleted: newBool(true), Completed: newBool(false)}
+	put, err := store.Put(context.Background(), item)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), *put.Version)
+	got, _ := store.GetById(context.Background(), "1111")
+	assert.Equal(t, created, *got.Created, "kept as given")
+	assert.True(t, *got.Deleted)
+
+	item.Summary = newSummary("replaced")
+	put, _ = store.Put(context.Background(), item)
+	assert.Equal(t, int64(2), *put.Version)
+	got, _ = store.GetById(context.Background(), "1111")
+	assert.Equal(t, "replaced", *got.Summary)
+
+	_, err = store.Put(context.Background(), todoitem.TodoItem{Summary: newSummary("no id")})
+	assert.Equal(t, terr.ErrorWithCode("bad request", "Items need an id to be put", 400), err)
+}
+
+func TestChanges(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	first, _ := store.Create(ctx, todoitem.TodoItem{Summary: newSummary("first")})
+	second, _ := store.Create(ctx, todoitem.TodoItem{Summary: newSummary("second")})
+	first.Deleted = newBool(true)
+	store.Update(ctx, first)
+
+	changes, err := store.Changes(ctx, 0, 10, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, *second.Id, *changes[0].Id, "lowest version first")
+		assert.True(t, *changes[1].Deleted, "tombstones are included")
+	}
+	changes, _ = store.Changes(ctx, *second.Version, 10, nil)
+	assert.Len(t, changes, 1)
+	changes, _ = store.Changes(ctx, 0, 1, nil)
+	assert.Len(t, changes, 1)
+}
+
+func TestChangesAndStatsForOwner(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	sam, alex := "sam", "alex"
+	store.Create(ctx, todoitem.TodoItem{Summary: newSummary("sam's"), OwnerId: &sam})
+	store.Create(ctx, todoitem.TodoItem{Summary: newSummary("alex's"), OwnerId: &alex})
+	store.Create(ctx, todoitem.TodoItem{Summary: newSummary("nobody's")})
+
+	changes, err := store.Changes(ctx, 0, 10, []string{sam})
+	assert.Nil(t, err)
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, "sam's", *changes[0].Summary)
+	}
+	changes, _ = store.Changes(ctx, 0, 10, []string{sam, alex})
+	assert.Len(t, changes, 2, "lists shared with sam")
+	changes, _ = store.Changes(ctx, 0, 10, []string{})
+	assert.Empty(t, changes)
+	stats, err := store.Stats(ctx, todoitem.StatsRange{Owner: &sam})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stats.Open)
+	stats, _ = store.Stats(ctx, todoitem.StatsRange{})
+	assert.Equal(t, 3, stats.Open, "unscoped")
+}
+
+func TestWorkspaces(t *testing.T) {
+	store := NewStore()
+	def := context.Background()
+	ops := auth.WithWorkspace(context.Background(), "ops")
+	mine, _ := store.Create(ops, todoitem.TodoItem{Summary: newSummary("ops")})
+	theirs, _ := store.Create(def, todoitem.TodoItem{Summary: newSummary("default")})
+
+	all, err := store.GetAll(ops)
+	assert.Nil(t, err)
+	if assert.Len(t, all, 1) {
+		assert.Equal(t, *mine.Id, *all[0].Id)
+	}
+	_, err = store.GetById(ops, *theirs.Id)
+	assert.Equal(t, terr.ErrorWithCode("not found", fmt.Sprintf("Item with id %s not found", *theirs.Id), 404), err)
+	_, err = store.Update(ops, theirs)
+	assert.Equal(t, terr.ErrorWithCode("not found", fmt.Sprintf("Item with id %s not found", *theirs.Id), 404), err)
+	_, err = store.Put(ops, theirs)
+	assert.Equal(t, terr.ErrorWithCode("conflict", fmt.Sprintf("Item id %s is already taken", *theirs.Id), 409), err)
+	changes, _ := store.Changes(def, 0, 10, nil)
+	assert.Len(t, changes, 1, "unscoped is still only the one workspace")
+	stats, _ := store.Stats(ops, todoitem.StatsRange{})
+	assert.Equal(t, 1, stats.Open)
+}
+
+func TestFindAndGetAll(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	base := time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+	defer func() { nowFn = time.Now }()
+	for i, s := range []string{"buy milk", "walk dog", "buy bread"} {
+		nowFn = func() time.Time { return base.Add(time.Duration(i) * time.Minute) }
+		item, _ := store.Create(ctx, todoitem.TodoItem{Summary: newSummary(s), Tags: []string{"home"}})
+		if s == "walk dog" {
+			item.Deleted = newBool(true)
+			store.Update(ctx, item)
+		}
+	}
+
+	all, err := store.GetAll(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, all, 2)
+	assert.Equal(t, "buy bread", *all[0].Summary, "newest first")
+
+	pred, _ := filter.Compile("buy tag:home", todoitem.FilterSchema, base)
+	found, err := store.Find(ctx, pred)
+	assert.Nil(t, err)
+	assert.Len(t, found, 2)
+
+	pred, _ = filter.Compile("deleted:true", todoitem.FilterSchema, base)
+	found, _ = store.Find(ctx, pred)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "walk dog", *found[0].Summary)
+}
+
+func TestStats(t *testing.T) {
+	store := NewStore()
+	ctx := context.Background()
+	base := time.Date(2023, time.January, 2, 9, 0, 0, 0, time.UTC)
+	defer func() { nowFn = time.Now }()
+	nowFn = func() time.Time { return base }
+
+	done, _ := store.Create(ctx, todoitem.TodoItem{Summary: newSummary("done")})
+	completedAt := base.Add(2 * time.Hour)
+	done.Completed, done.CompletedAt = newBool(true), &completedAt
+	store.Update(ctx, done)
+
+	overdue, _ := store.Create(ctx, todoitem.TodoItem{Summary: newSummary("overdue")})
+	due := base.Add(time.Hour)
+	overdue.Due = &due
+	store.Update(ctx, overdue)
+
+	gone, _ := store.Create(ctx, todoitem.TodoItem{Summary: newSummary("gone")})
+	gone.Deleted = newBool(true)
+	store.Update(ctx, gone)
+
+	stats, err := store.Stats(ctx, todoitem.StatsRange{From: base.AddDate(0, 0, -1), To: base.AddDate(0, 0, 1), Now: base.Add(3 * time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stats.Open)
+	assert.Equal(t, 1, stats.Completed)
+	assert.Equal(t, 1, stats.Deleted)
+	assert.Equal(t, 1, stats.Overdue)
+	assert.Equal(t, 7200.0, *stats.AverageSecondsToComplete)
+	assert.Equal(t, []todoitem.DayStats{{Date: "2023-01-02", Created: 3, Completed: 1}}, stats.Days)
+}
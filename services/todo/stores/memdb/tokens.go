@@ -0,0 +1,126 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/token"
+)
+
+// TokenStore is an in memory token.Storer, for tests and local tooling like Store.
+type TokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]token.Token
+}
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		tokens: map[string]token.Token{},
+	}
+}
+
+func (s *TokenStore) Create(ctx context.Context, t token.Token) (token.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := NewId()
+	now := nowFn()
+	t.Id = &id
+	t.Created = &now
+	s.tokens[id] = copyToken(t)
+	return copyToken(t), nil
+}
+
+func (s *TokenStore) GetById(ctx context.Context, id string) (token.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return token.Token{}, tokenNotFound(id)
+	}
+	return copyToken(t), nil
+}
+
+func (s *TokenStore) GetByHash(ctx context.Context, hash string) (token.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.tokens {
+		if *t.Hash == hash {
+			return copyToken(t), nil
+		}
+	}
+	return token.Token{}, errors.ErrorWithCode("not found", "Token not found", 404)
+}
+
+func (s *TokenStore) GetAll(ctx context.Context, userId string) ([]token.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := []token.Token{}
+	for _, t := range s.tokens {
+		if *t.UserId == userId {
+			res = append(res, copyToken(t))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.After(*res[j].Created) })
+	return res, nil
+}
+
+func (s *TokenStore) Revoke(ctx context.Context, id string, at time.Time) (token.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return token.Token{}, tokenNotFound(id)
+	}
+	t.Revoked = &at
+	s.tokens[id] = t
+	return copyToken(t), nil
+}
+
+func (s *TokenStore) Used(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return tokenNotFound(id)
+	}
+	t.LastUsed = &at
+	s.tokens[id] = t
+	return nil
+}
+
+func (s *TokenStore) Prune(ctx context.Context, userId, name string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.tokens {
+		if *t.UserId != userId || *t.Name != name {
+			continue
+		}
+		if (t.Expires != nil && t.Expires.Before(before)) || (t.Revoked != nil && t.Revoked.Before(before)) {
+			delete(s.tokens, id)
+		}
+	}
+	return nil
+}
+
+func tokenNotFound(id string) error {
+	return errors.ErrorWithCode("not found", fmt.Sprintf("Token with id %s not found", id), 404)
+}
+
+func copyToken(t token.Token) token.Token {
+	return token.Token{
+		Id:          copyPtr(t.Id),
+		Name:        copyPtr(t.Name),
+		Scope:       copyPtr(t.Scope),
+		UserId:      copyPtr(t.UserId),
+		Created:     copyPtr(t.Created),
+		Expires:     copyPtr(t.Expires),
+		LastUsed:    copyPtr(t.LastUsed),
+		Revoked:     copyPtr(t.Revoked),
+		Hash:        copyPtr(t.Hash),
+		WorkspaceId: copyPtr(t.WorkspaceId),
+	}
+}
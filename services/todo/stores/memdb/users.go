@@ -0,0 +1,94 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/user"
+)
+
+// UserStore is an in memory user.Storer, for tests and local tooling like Store.
+type UserStore struct {
+	mu    sync.RWMutex
+	users map[string]user.User
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		users: map[string]user.User{},
+	}
+}
+
+func (s *UserStore) Create(ctx context.Context, u user.User) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.users {
+		if *existing.Email == *u.Email {
+			return user.User{}, errors.ErrorWithCode("conflict", fmt.Sprintf("A user with email %s already exists", *u.Email), 409)
+		}
+	}
+	id := NewId()
+	now := nowFn()
+	u.Id = &id
+	u.Created = &now
+	s.users[id] = copyUser(u)
+	return copyUser(u), nil
+}
+
+func (s *UserStore) GetById(ctx context.Context, id string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	if !ok {
+		return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with id %s not found", id), 404)
+	}
+	return copyUser(u), nil
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if *u.Email == email {
+			return copyUser(u), nil
+		}
+	}
+	return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with email %s not found", email), 404)
+}
+
+func (s *UserStore) GetByExternalId(ctx context.Context, externalId string) (user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if u.ExternalId != nil && *u.ExternalId == externalId {
+			return copyUser(u), nil
+		}
+	}
+	return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with external id %s not found", externalId), 404)
+}
+
+func (s *UserStore) SetExternalId(ctx context.Context, id, externalId string) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with id %s not found", id), 404)
+	}
+	u.ExternalId = &externalId
+	s.users[id] = u
+	return copyUser(u), nil
+}
+
+func copyUser(u user.User) user.User {
+	return user.User{
+		Id:           copyPtr(u.Id),
+		Email:        copyPtr(u.Email),
+		Name:         copyPtr(u.Name),
+		Created:      copyPtr(u.Created),
+		PasswordHash: copyPtr(u.PasswordHash),
+		ExternalId:   copyPtr(u.ExternalId),
+		WorkspaceId:  copyPtr(u.WorkspaceId),
+	}
+}
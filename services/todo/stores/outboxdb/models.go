@@ -0,0 +1,9 @@
+package outboxdb
+
+type dbMessage struct {
+	Id       string `db:"id"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+	//WorkspaceId isn't in the payload, see tododb.writeOutbox
+	WorkspaceId string `db:"workspace_id"`
+}
@@ -0,0 +1,98 @@
+package outboxdb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/outbox"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// Store reads the outbox back out for the relay. Rows are written by the stores making the changes, see
+// tododb.writeOutbox.
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Claim locks the due rows with SKIP LOCKED so other replicas claiming at the same time get different ones, then
+// pushes them back by the lease before letting go.
+func (s *Store) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]outbox.Message, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-outbox-claim")
+	defer span.End()
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return nil, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var rows []dbMessage
+	err = tx.SelectContext(ctx, &rows, `SELECT id, payload, attempts, workspace_id FROM outbox WHERE next_attempt <= ? ORDER BY seq LIMIT ? FOR UPDATE SKIP LOCKED`, now, limit)
+	if err != nil {
+		log.Default().Error("failed to select due outbox messages", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.Id)
+	}
+	query, args, err := sqlx.In(`UPDATE outbox SET next_attempt = ? WHERE id IN (?)`, now.Add(lease), ids)
+	if err != nil {
+		log.Default().Error("failed to build claim query", zap.Error(err))
+		return nil, errors.InternalError()
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		log.Default().Error("failed to lease outbox messages", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit outbox claim", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	messages := make([]outbox.Message, 0, len(rows))
+	for _, r := range rows {
+		m := outbox.Message{Id: r.Id, Attempts: r.Attempts}
+		if err := json.Unmarshal(r.Payload, &m.Event); err != nil {
+			//nothing is going to make this row readable, it will keep being logged until someone looks at it
+			log.Default().Error("unreadable outbox message", zap.String("id", r.Id), zap.Error(err))
+			continue
+		}
+		m.Event.Workspace = r.WorkspaceId
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (s *Store) Done(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-outbox-done")
+	defer span.End()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id); err != nil {
+		log.Default().Error("error removing sent outbox message", zap.Error(err), zap.String("id", id))
+		return errors.UnknownError()
+	}
+	return nil
+}
+
+func (s *Store) Retry(ctx context.Context, id string, next time.Time, lastError string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-outbox-retry")
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, next_attempt = ?, last_error = ? WHERE id = ?`, next, lastError, id)
+	if err != nil {
+		log.Default().Error("error recording outbox retry", zap.Error(err), zap.String("id", id))
+		return errors.UnknownError()
+	}
+	return nil
+}
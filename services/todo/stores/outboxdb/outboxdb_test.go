@@ -0,0 +1,69 @@
+package outboxdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+
+func newMock(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	return NewStore(sqlx.NewDb(mockDB, "sqlmock")), mock
+}
+
+func TestClaim(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, payload, attempts, workspace_id FROM outbox WHERE next_attempt <= \? ORDER BY seq LIMIT \? FOR UPDATE SKIP LOCKED`).
+		WithArgs(testTime, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload", "attempts", "workspace_id"}).
+			AddRow("o1", []byte(`{"type":"created","item":{"id":"1111"},"time":"2023-01-12T12:12:12Z"}`), 0, "ops").
+			AddRow("o2", []byte(`not json`), 3, "default"))
+	mock.ExpectExec(`UPDATE outbox SET next_attempt = \? WHERE id IN \(\?, \?\)`).
+		WithArgs(testTime.Add(time.Minute), "o1", "o2").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	due, err := store.Claim(context.Background(), testTime, time.Minute, 50)
+	assert.Nil(t, err)
+	if assert.Len(t, due, 1, "unreadable rows are skipped") {
+		assert.Equal(t, "o1", due[0].Id)
+		assert.Equal(t, todoitem.EventCreated, due[0].Event.Type)
+		assert.Equal(t, "1111", *due[0].Event.Item.Id)
+		assert.Equal(t, testTime, due[0].Event.Time)
+		assert.Equal(t, "ops", due[0].Event.Workspace, "the workspace comes from its column")
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimError(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, payload, attempts, workspace_id FROM outbox`).WillReturnError(errors.New("a random sql test error"))
+	mock.ExpectRollback()
+	_, err := store.Claim(context.Background(), testTime, time.Minute, 50)
+	assert.Equal(t, terr.UnknownError(), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDoneAndRetry(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectExec(`DELETE FROM outbox WHERE id = \?`).WithArgs("o1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox SET attempts = attempts \+ 1, next_attempt = \?, last_error = \? WHERE id = \?`).
+		WithArgs(testTime, "webhook: boom", "o2").WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, store.Done(context.Background(), "o1"))
+	assert.Nil(t, store.Retry(context.Background(), "o2", testTime, "webhook: boom"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
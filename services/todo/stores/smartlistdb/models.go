@@ -0,0 +1,12 @@
+package smartlistdb
+
+import "time"
+
+type dbSmartList struct {
+	Id          string    `db:"id"`
+	Name        string    `db:"name"`
+	Query       string    `db:"query"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+	WorkspaceId string    `db:"workspace_id"`
+}
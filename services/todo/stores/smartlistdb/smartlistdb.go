@@ -0,0 +1,122 @@
+package smartlistdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/smartlist"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// Store keeps smart lists to the workspace of the request they're for, every query is held to it.
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, list smartlist.SmartList) (smartlist.SmartList, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-smartlist-create")
+	defer span.End()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return smartlist.SmartList{}, errors.InternalError()
+	}
+	defer tx.Rollback()
+	//grab the id up front so we can read the row back without guessing which one is ours
+	var id string
+	if err := tx.GetContext(ctx, &id, `SELECT UUID()`); err != nil {
+		log.Default().Error("failed to generate smart list id", zap.Error(err))
+		return smartlist.SmartList{}, errors.UnknownError()
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO smart_list (id, name, query, workspace_id) VALUES (?, ?, ?, ?)`, id, list.Name, list.Query, auth.Workspace(ctx)); err != nil {
+		log.Default().Warn("error creating new smart list in database", zap.Error(err))
+		return smartlist.SmartList{}, errors.UnknownError()
+	}
+	v := new(dbSmartList)
+	if err := tx.GetContext(ctx, v, `SELECT * FROM smart_list WHERE id=?`, id); err != nil {
+		log.Default().Warn("error reading back new smart list", zap.Error(err))
+		return smartlist.SmartList{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit smart list", zap.Error(err))
+		return smartlist.SmartList{}, errors.UnknownError()
+	}
+	return toCoreList(*v), nil
+}
+
+func (s *Store) Update(ctx context.Context, list smartlist.SmartList) (smartlist.SmartList, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-smartlist-update")
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, `UPDATE smart_list SET name = ?, query = ? WHERE id = ? AND workspace_id = ?`, list.Name, list.Query, list.Id, auth.Workspace(ctx))
+	if err != nil {
+		log.Default().Error("error updating smart list", zap.Error(err), zap.String("id", *list.Id))
+		return smartlist.SmartList{}, errors.UnknownError()
+	}
+	//read it back rather than trusting rows affected, mysql reports 0 for updates that don't change anything
+	return s.GetById(ctx, *list.Id)
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (smartlist.SmartList, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-smartlist-getById")
+	defer span.End()
+	v := new(dbSmartList)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM smart_list WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return smartlist.SmartList{}, errors.ErrorWithCode("not found", fmt.Sprintf("Smart list with id %s not found", id), 404)
+		}
+		log.Default().Error("unknown error querying smart list by id", zap.Error(err), zap.String("req id", id))
+		return smartlist.SmartList{}, errors.UnknownError()
+	}
+	return toCoreList(*v), nil
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]smartlist.SmartList, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-smartlist-getall")
+	defer span.End()
+	var rows []dbSmartList
+	if err := s.db.SelectContext(ctx, &rows, `SELECT * FROM smart_list WHERE workspace_id=? ORDER BY name`, auth.Workspace(ctx)); err != nil {
+		log.Default().Error("database query failed", zap.Error(err))
+		return nil, errors.ErrorWithCode("internal error", "Could not query for smart lists", 500)
+	}
+	lists := []smartlist.SmartList{}
+	for _, r := range rows {
+		lists = append(lists, toCoreList(r))
+	}
+	return lists, nil
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-smartlist-delete")
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM smart_list WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx))
+	if err != nil {
+		log.Default().Error("error deleting smart list", zap.Error(err), zap.String("id", id))
+		return errors.UnknownError()
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrorWithCode("not found", fmt.Sprintf("Smart list with id %s not found", id), 404)
+	}
+	return nil
+}
+
+func toCoreList(l dbSmartList) smartlist.SmartList {
+	return smartlist.SmartList{
+		Id:      &l.Id,
+		Name:    &l.Name,
+		Query:   &l.Query,
+		Created: &l.DateCreated,
+		Updated: &l.DateUpdated,
+	}
+}
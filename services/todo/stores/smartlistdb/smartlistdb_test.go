@@ -0,0 +1,119 @@
+package smartlistdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/smartlist"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local)
+
+func TestCreate(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT UUID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"UUID()"}).AddRow("1111"))
+	mock.ExpectExec(`INSERT INTO smart_list \(id, name, query, workspace_id\) VALUES \(\?, \?, \?, \?\)`).
+		WithArgs("1111", "work", "tag:work", "default").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM smart_list WHERE id=\?`).WithArgs("1111").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "query", "date_created", "date_updated"}).
+			AddRow("1111", "work", "tag:work", testTime, testTime))
+	mock.ExpectCommit()
+
+	val, err := store.Create(context.Background(), smartlist.SmartList{Name: newString("work"), Query: newString("tag:work")})
+	assert.Nil(t, err)
+	assert.Equal(t, smartlist.SmartList{
+		Id: newString("1111"), Name: newString("work"), Query: newString("tag:work"), Created: &testTime, Updated: &testTime,
+	}, val)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetById(t *testing.T) {
+	type test struct {
+		name      string
+		expectErr error
+		mockErr   error
+	}
+	tests := []test{
+		{name: "happy path"},
+		{name: "no rows found", mockErr: sql.ErrNoRows, expectErr: terr.ErrorWithCode("not found", "Smart list with id 1111 not found", 404)},
+		{name: "unknown error", mockErr: errors.New("some random mysql error"), expectErr: terr.UnknownError()},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mockDB.Close()
+			store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+			query := mock.ExpectQuery(`SELECT \* FROM smart_list WHERE id=\? AND workspace_id=\?`).WithArgs("1111", "default")
+			if tt.mockErr != nil {
+				query.WillReturnError(tt.mockErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"id", "name", "query", "date_created", "date_updated"}).
+					AddRow("1111", "work", "tag:work", testTime, testTime))
+			}
+			val, err := store.GetById(context.Background(), "1111")
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				assert.Equal(t, "tag:work", *val.Query)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectExec(`DELETE FROM smart_list WHERE id=\? AND workspace_id=\?`).WithArgs("1111", "default").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM smart_list WHERE id=\? AND workspace_id=\?`).WithArgs("2222", "default").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.Nil(t, store.Delete(context.Background(), "1111"))
+	assert.Equal(t, terr.ErrorWithCode("not found", "Smart list with id 2222 not found", 404), store.Delete(context.Background(), "2222"))
+}
+
+func TestWorkspaces(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam", WorkspaceId: "ops"})
+	mock.ExpectQuery(`SELECT \* FROM smart_list WHERE workspace_id=\? ORDER BY name`).WithArgs("ops").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "query", "date_created", "date_updated", "workspace_id"}).
+			AddRow("1111", "work", "tag:work", testTime, testTime, "ops"))
+	mock.ExpectExec(`UPDATE smart_list SET name = \?, query = \? WHERE id = \? AND workspace_id = \?`).WithArgs("home", "tag:home", "2222", "ops").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM smart_list WHERE id=\? AND workspace_id=\?`).WithArgs("2222", "ops").WillReturnError(sql.ErrNoRows)
+
+	lists, err := store.GetAll(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, lists, 1)
+	_, err = store.Update(ctx, smartlist.SmartList{Id: newString("2222"), Name: newString("home"), Query: newString("tag:home")})
+	assert.Equal(t, terr.ErrorWithCode("not found", "Smart list with id 2222 not found", 404), err, "another workspace's list")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -1,12 +1,55 @@
 package tododb
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type dbTodoItem struct {
-	Id          string    `db:"id"`
-	Summary     string    `db:"summary"`
-	DateCreated time.Time `db:"date_created"`
-	DateUpdated time.Time `db:"date_updated"`
-	Deleted     bool      `db:"deleted"`
-	Completed   bool      `db:"completed"`
+	Id            string     `db:"id"`
+	Summary       string     `db:"summary"`
+	DateCreated   time.Time  `db:"date_created"`
+	DateUpdated   time.Time  `db:"date_updated"`
+	Deleted       bool       `db:"deleted"`
+	Completed     bool       `db:"completed"`
+	Priority      int        `db:"priority"`
+	Due           *time.Time `db:"due"`
+	Tags          dbTags     `db:"tags"`
+	DateCompleted *time.Time `db:"date_completed"`
+	Version       int64      `db:"version"`
+	OwnerId       *string    `db:"owner_id"`
+	WorkspaceId   string     `db:"workspace_id"`
+	AssigneeIds   dbTags     `db:"assignee_ids"`
+}
+
+type dbDayCount struct {
+	Day   string `db:"day"`
+	Count int    `db:"n"`
+}
+
+// dbTags is stored as a json array column so we can use JSON_CONTAINS when filtering on tags. Assignees are kept the
+// same way.
+type dbTags []string
+
+func (t dbTags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(t))
+	return string(b), err
+}
+
+func (t *dbTags) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]string)(t))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]string)(t))
+	}
+	return fmt.Errorf("cannot scan %T into tags", src)
 }
@@ -0,0 +1,32 @@
+package tododb
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// writeOutbox records an event in the same transaction as the change it describes, so either both happen or neither
+// does. The outbox relay (see the outbox package) takes it from there. The workspace isn't in the payload, it's kept
+// alongside it.
+func writeOutbox(ctx context.Context, tx *sqlx.Tx, e todoitem.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO outbox (event, payload, workspace_id) VALUES (?, ?, ?)`, string(e.Type), string(payload), auth.Workspace(ctx))
+	return err
+}
+
+// writeAssigned records an EventAssigned for anyone saved is assigned to that old wasn't, if there is anyone.
+func writeAssigned(ctx context.Context, tx *sqlx.Tx, old, saved todoitem.TodoItem, at time.Time) error {
+	added := todoitem.NewAssignees(old, saved)
+	if len(added) == 0 {
+		return nil
+	}
+	return writeOutbox(ctx, tx, todoitem.Event{Type: todoitem.EventAssigned, Item: saved, Time: at, Assignees: added})
+}
@@ -0,0 +1,118 @@
+package tododb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// The queries below are kept to a workspace, and maybe an owner, by the condition scoped fills in for their %s.
+const (
+	totalsQuery = `SELECT
+	COALESCE(SUM(deleted = false AND completed = false), 0) AS open,
+	COALESCE(SUM(deleted = false AND completed = true), 0) AS completed,
+	COALESCE(SUM(deleted = true), 0) AS deleted,
+	COALESCE(SUM(deleted = false AND completed = false AND due IS NOT NULL AND due < ?), 0) AS overdue
+FROM todo_item WHERE %s`
+	createdPerDayQuery = `SELECT DATE_FORMAT(date_created, '%%Y-%%m-%%d') AS day, COUNT(*) AS n FROM todo_item
+WHERE date_created >= ? AND date_created < ? AND %s GROUP BY day`
+	completedPerDayQuery = `SELECT DATE_FORMAT(date_completed, '%%Y-%%m-%%d') AS day, COUNT(*) AS n FROM todo_item
+WHERE date_completed >= ? AND date_completed < ? AND %s GROUP BY day`
+	timeToCompleteQuery = `SELECT AVG(TIMESTAMPDIFF(SECOND, date_created, date_completed)) FROM todo_item
+WHERE date_completed >= ? AND date_completed < ? AND %s`
+)
+
+// Stats works everything out with aggregate queries, in a read only transaction so the numbers agree with each other.
+func (s *Store) Stats(ctx context.Context, r todoitem.StatsRange) (todoitem.Stats, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-stats")
+	defer span.End()
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return todoitem.Stats{}, errors.ErrorWithCode("internal error", "Could not query for stats", 500)
+	}
+	defer tx.Rollback()
+
+	var totals struct {
+		Open      int `db:"open"`
+		Completed int `db:"completed"`
+		Deleted   int `db:"deleted"`
+		Overdue   int `db:"overdue"`
+	}
+	q, args := scoped(ctx, r.Owner, totalsQuery, r.Now)
+	if err := tx.GetContext(ctx, &totals, q, args...); err != nil {
+		log.Default().Error("failed to query todo totals", zap.Error(err))
+		return todoitem.Stats{}, errors.ErrorWithCode("internal error", "Could not query for stats", 500)
+	}
+	var created, completed []dbDayCount
+	q, args = scoped(ctx, r.Owner, createdPerDayQuery, r.From, r.To)
+	if err := tx.SelectContext(ctx, &created, q, args...); err != nil {
+		log.Default().Error("failed to query created per day", zap.Error(err))
+		return todoitem.Stats{}, errors.ErrorWithCode("internal error", "Could not query for stats", 500)
+	}
+	q, args = scoped(ctx, r.Owner, completedPerDayQuery, r.From, r.To)
+	if err := tx.SelectContext(ctx, &completed, q, args...); err != nil {
+		log.Default().Error("failed to query completed per day", zap.Error(err))
+		return todoitem.Stats{}, errors.ErrorWithCode("internal error", "Could not query for stats", 500)
+	}
+	var avg sql.NullFloat64
+	q, args = scoped(ctx, r.Owner, timeToCompleteQuery, r.From, r.To)
+	if err := tx.GetContext(ctx, &avg, q, args...); err != nil {
+		log.Default().Error("failed to query time to complete", zap.Error(err))
+		return todoitem.Stats{}, errors.ErrorWithCode("internal error", "Could not query for stats", 500)
+	}
+
+	stats := todoitem.Stats{
+		Open:      totals.Open,
+		Completed: totals.Completed,
+		Deleted:   totals.Deleted,
+		Overdue:   totals.Overdue,
+		Days:      mergeDays(created, completed),
+	}
+	if avg.Valid {
+		stats.AverageSecondsToComplete = &avg.Float64
+	}
+	return stats, nil
+}
+
+// scoped keeps one of the queries above to the workspace, and to a user's items when they're asked for. The condition
+// comes after the query's own placeholders, so its args go on the end.
+func scoped(ctx context.Context, owner *string, q string, args ...any) (string, []any) {
+	cond := "workspace_id = ?"
+	args = append(args, auth.Workspace(ctx))
+	if owner != nil {
+		cond += " AND owner_id = ?"
+		args = append(args, *owner)
+	}
+	return fmt.Sprintf(q, cond), args
+}
+
+func mergeDays(created, completed []dbDayCount) []todoitem.DayStats {
+	byDay := map[string]*todoitem.DayStats{}
+	var days []todoitem.DayStats
+	get := func(day string) *todoitem.DayStats {
+		if d, ok := byDay[day]; ok {
+			return d
+		}
+		d := &todoitem.DayStats{Date: day}
+		byDay[day] = d
+		return d
+	}
+	for _, c := range created {
+		get(c.Day).Created = c.Count
+	}
+	for _, c := range completed {
+		get(c.Day).Completed = c.Count
+	}
+	for _, d := range byDay {
+		days = append(days, *d)
+	}
+	return days
+}
@@ -0,0 +1,51 @@
+package tododb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// nextVersion moves the sync clock on and returns the new version. The clock's row stays locked until tx is done, so
+// versions are committed in order and a sync can't miss a change that commits after it's read a higher version.
+func nextVersion(ctx context.Context, tx *sqlx.Tx) (int64, error) {
+	//LAST_INSERT_ID(expr) hands the new value back on the result, saving a SELECT
+	res, err := tx.ExecContext(ctx, `UPDATE sync_clock SET version = LAST_INSERT_ID(version + 1)`)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Changes returns items changed since the given version, tombstones and all.
+func (s *Store) Changes(ctx context.Context, since int64, limit int, lists []string) ([]todoitem.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-changes")
+	defer span.End()
+	q, args := `SELECT * FROM todo_item WHERE workspace_id = ? AND version > ? ORDER BY version LIMIT ?`, []any{auth.Workspace(ctx), since, limit}
+	if lists != nil {
+		if len(lists) == 0 {
+			return nil, nil
+		}
+		q = fmt.Sprintf(`SELECT * FROM todo_item WHERE workspace_id = ? AND owner_id IN (?%s) AND version > ? ORDER BY version LIMIT ?`,
+			strings.Repeat(", ?", len(lists)-1))
+		args = []any{auth.Workspace(ctx)}
+		for _, l := range lists {
+			args = append(args, l)
+		}
+		args = append(args, since, limit)
+	}
+	var dbItems []dbTodoItem
+	if err := s.db.SelectContext(ctx, &dbItems, q, args...); err != nil {
+		log.Default().Error("database query failed", zap.Error(err))
+		return nil, errors.ErrorWithCode("internal error", "Could not query for changes", 500)
+	}
+	return toCoreTodoSlice(dbItems), nil
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/stumacwastaken/todo/log"
 	"github.com/stumacwastaken/todo/tracing"
 	"go.uber.org/zap"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
 	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
 	"github.com/stumacwastaken/todo/todoitem"
 )
 
@@ -27,13 +30,19 @@ func NewStore(db *sqlx.DB) *Store {
 func (s *Store) Create(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
 	ctx, span := tracing.Tracer().Start(ctx, "store-create")
 	defer span.End()
-	statement := `INSERT into todo_item (summary) VALUES (?)`
+	statement := `INSERT into todo_item (summary, priority, due, tags, version, owner_id, assignee_ids, workspace_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 	tx, err := s.db.Beginx()
 	if err != nil {
 		log.Default().Error("failed to start transaction", zap.Error(err))
 		return todoitem.TodoItem{}, errors.ErrorWithCode("internal error", "Could not query for todos", 500)
 	}
-	res, err := tx.Exec(statement, item.Summary)
+	version, err := nextVersion(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to get next version", zap.Error(err))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	res, err := tx.Exec(statement, item.Summary, priorityRank(item.Priority), item.Due, dbTags(item.Tags), version, item.OwnerId, dbTags(item.AssigneeIds), auth.Workspace(ctx))
 	if err != nil {
 		log.Default().Warn("error creating new todo item in database", zap.Error(err))
 		tx.Rollback()
@@ -50,9 +59,8 @@ func (s *Store) Create(ctx context.Context, item todoitem.TodoItem) (todoitem.To
 	num, _ := res.RowsAffected()
 	log.Default().Info("inserted new todo item", zap.Int64("rows-affected", num), zap.Int64("lastId", id))
 
-	//hack to get the last element because mysql isn't postgres
-	getStatement := `SELECT * from todo_item ORDER BY date_created desc LIMIT 1`
-	row := tx.QueryRowxContext(ctx, getStatement)
+	//versions are unique, so it's how we find what we just inserted because mysql isn't postgres
+	row := tx.QueryRowxContext(ctx, `SELECT * FROM todo_item WHERE version = ? AND workspace_id = ?`, version, auth.Workspace(ctx))
 	if row.Err() != nil {
 		tx.Rollback()
 		log.Default().Warn("unknown error inserting row into database", zap.Error(err))
@@ -63,43 +71,150 @@ func (s *Store) Create(ctx context.Context, item todoitem.TodoItem) (todoitem.To
 		log.Default().Warn("unknown error inserting row into database", zap.Error(err))
 		return todoitem.TodoItem{}, errors.UnknownError()
 	}
-
-	tx.Commit()
-	return toCoreItem(*v), nil
+	created := toCoreItem(*v)
+	if err := writeOutbox(ctx, tx, todoitem.Event{Type: todoitem.EventCreated, Item: created, Time: v.DateCreated}); err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to write created event to the outbox", zap.Error(err))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	if err := writeAssigned(ctx, tx, todoitem.TodoItem{}, created, v.DateCreated); err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to write assigned event to the outbox", zap.Error(err))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit new todo item", zap.Error(err))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	return created, nil
 }
 
 func (s *Store) Update(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
 	ctx, span := tracing.Tracer().Start(ctx, "store-update")
 	defer span.End()
-	statement := `UPDATE todo_item SET summary = ?, date_updated = ?, deleted = ?, completed = ? WHERE id = ?`
+	statement := `UPDATE todo_item SET summary = ?, date_updated = ?, deleted = ?, completed = ?, priority = ?, due = ?, tags = ?, date_completed = ?, version = ?, assignee_ids = ? WHERE id = ? AND workspace_id = ?`
 	tx, err := s.db.Beginx()
 	if err != nil {
 		log.Default().Error("failed to start transaction", zap.Error(err))
 		return todoitem.TodoItem{}, errors.ErrorWithCode("internal error", "Could not query for todos", 500)
 	}
 
-	row := tx.QueryRowx(statement, item.Summary, item.Updated, item.Deleted, item.Completed, item.Id)
-	err = row.Err()
-	if err != nil {
+	//lock the row so the event we work out from the old item is the one that really happened. A row in another
+	//workspace is as good as not there
+	workspace := auth.Workspace(ctx)
+	old := new(dbTodoItem)
+	if err := tx.GetContext(ctx, old, `SELECT * FROM todo_item WHERE id = ? AND workspace_id = ? FOR UPDATE`, item.Id, workspace); err != nil {
 		tx.Rollback()
 		if err == sql.ErrNoRows {
-
 			log.Default().Error("error no rows on an id that's supposed to be there. How did you get here?", zap.Error(err), zap.String("id", *item.Id))
 			return todoitem.TodoItem{}, errors.ErrorWithCode("not found", fmt.Sprintf("Item with id %s not found", *item.Id), 404)
 		}
+		log.Default().Error("error reading row to update", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	//a version on the item is the one it was read at, if it's moved on since then someone else got there first
+	if item.Version != nil && *item.Version != old.Version {
+		tx.Rollback()
+		return todoitem.TodoItem{}, errors.ErrorWithCode("conflict", fmt.Sprintf("Item with id %s was changed by someone else, fetch it and try again", *item.Id), 409)
+	}
+	version, err := nextVersion(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to get next version", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	item.Version = &version
+	_, err = tx.ExecContext(ctx, statement, item.Summary, item.Updated, item.Deleted, item.Completed,
+		priorityRank(item.Priority), item.Due, dbTags(item.Tags), item.CompletedAt, version, dbTags(item.AssigneeIds), item.Id, workspace)
+	if err != nil {
+		tx.Rollback()
 		log.Default().Error("error updating row", zap.Error(err), zap.String("id", *item.Id))
 		return todoitem.TodoItem{}, errors.UnknownError()
 	}
-	tx.Commit()
+	e := todoitem.Event{Type: todoitem.EventFor(toCoreItem(*old), item), Item: item, Time: time.Now()}
+	if item.Updated != nil {
+		e.Time = *item.Updated
+	}
+	if err := writeOutbox(ctx, tx, e); err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to write update event to the outbox", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	if err := writeAssigned(ctx, tx, toCoreItem(*old), item, e.Time); err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to write assigned event to the outbox", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit todo item update", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
 
 	return item, nil
 }
 
+// Put inserts the item as it is, or overwrites the row with its id. Timestamps left out default to now, like a new row.
+// No event goes to the outbox, a bulk load isn't something webhooks want to hear about item by item. An id that's
+// taken in another workspace is a 409, it's never overwritten or moved.
+func (s *Store) Put(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-put")
+	defer span.End()
+	if item.Id == nil {
+		return todoitem.TodoItem{}, errors.ErrorWithCode("bad request", "Items need an id to be put", 400)
+	}
+	statement := `INSERT INTO todo_item (id, summary, date_created, date_updated, deleted, completed, priority, due, tags, date_completed, version, owner_id, assignee_ids, workspace_id)
+		VALUES (?, ?, COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, CURRENT_TIMESTAMP), COALESCE(?, FALSE), COALESCE(?, FALSE), ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE summary = VALUES(summary), date_created = VALUES(date_created), date_updated = VALUES(date_updated),
+		deleted = VALUES(deleted), completed = VALUES(completed), priority = VALUES(priority), due = VALUES(due), tags = VALUES(tags),
+		date_completed = VALUES(date_completed), version = VALUES(version), owner_id = VALUES(owner_id),
+		assignee_ids = VALUES(assignee_ids)`
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return todoitem.TodoItem{}, errors.ErrorWithCode("internal error", "Could not query for todos", 500)
+	}
+	workspace := auth.Workspace(ctx)
+	var existing string
+	err = tx.GetContext(ctx, &existing, `SELECT workspace_id FROM todo_item WHERE id = ? FOR UPDATE`, item.Id)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		log.Default().Error("error checking for an existing row to put", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	if err == nil && existing != workspace {
+		tx.Rollback()
+		return todoitem.TodoItem{}, errors.ErrorWithCode("conflict", fmt.Sprintf("Item id %s is already taken", *item.Id), 409)
+	}
+	version, err := nextVersion(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		log.Default().Error("failed to get next version", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	_, err = tx.ExecContext(ctx, statement, item.Id, item.Summary, item.Created, item.Updated, item.Deleted, item.Completed,
+		priorityRank(item.Priority), item.Due, dbTags(item.Tags), item.CompletedAt, version, item.OwnerId, dbTags(item.AssigneeIds), workspace)
+	if err != nil {
+		tx.Rollback()
+		log.Default().Error("error putting row", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	v := new(dbTodoItem)
+	if err := tx.GetContext(ctx, v, `SELECT * FROM todo_item WHERE id = ? AND workspace_id = ?`, item.Id, workspace); err != nil {
+		tx.Rollback()
+		log.Default().Error("error reading back put row", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit put todo item", zap.Error(err), zap.String("id", *item.Id))
+		return todoitem.TodoItem{}, errors.UnknownError()
+	}
+	return toCoreItem(*v), nil
+}
+
 func (s *Store) GetById(ctx context.Context, id string) (todoitem.TodoItem, error) {
 	ctx, span := tracing.Tracer().Start(ctx, "store-getById")
 	defer span.End()
-	statement := "SELECT * FROM todo_item where id=?"
-	row := s.db.QueryRowx(statement, id)
+	row := s.db.QueryRowx("SELECT * FROM todo_item where id=? AND workspace_id=?", id, auth.Workspace(ctx))
 	v := new(dbTodoItem)
 	err := row.StructScan(v)
 	if err != nil {
@@ -114,17 +229,17 @@ func (s *Store) GetById(ctx context.Context, id string) (todoitem.TodoItem, erro
 	return toCoreItem(*v), nil
 }
 
-//could be improved to return additional metadata and better query filtering
+// could be improved to return additional metadata and better query filtering
 func (s *Store) GetAll(ctx context.Context) ([]todoitem.TodoItem, error) {
 	ctx, span := tracing.Tracer().Start(ctx, "store-getall")
 	defer span.End()
-	q := fmt.Sprintf(`SELECT * FROM todo_item WHERE deleted=false ORDER BY date_created DESC`) //keep fmt here for now....cause queries and filters
+	q := fmt.Sprintf(`SELECT * FROM todo_item WHERE deleted=false AND workspace_id=? ORDER BY date_created DESC`) //keep fmt here for now....cause queries and filters
 	tx, err := s.db.Beginx()
 	if err != nil {
 		log.Default().Error("failed to start transaction", zap.Error(err))
 		return nil, errors.ErrorWithCode("internal error", "Could not query for todos", 500)
 	}
-	rows, err := tx.QueryxContext(ctx, q)
+	rows, err := tx.QueryxContext(ctx, q, auth.Workspace(ctx))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			tx.Rollback()
@@ -147,6 +262,32 @@ func (s *Store) GetAll(ctx context.Context) ([]todoitem.TodoItem, error) {
 	return toCoreTodoSlice(dbItems), nil
 }
 
+// Find runs a compiled filter against the todo table. See where.go for how predicates become sql.
+func (s *Store) Find(ctx context.Context, pred filter.Predicate) ([]todoitem.TodoItem, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-find")
+	defer span.End()
+	where, args, err := whereClause(pred)
+	if err != nil {
+		log.Default().Error("could not translate filter to sql", zap.Error(err))
+		return nil, errors.InternalError()
+	}
+	q := fmt.Sprintf(`SELECT * FROM todo_item WHERE workspace_id = ? AND (%s) ORDER BY date_created DESC`, where)
+	args = append([]any{auth.Workspace(ctx)}, args...)
+	var dbItems []dbTodoItem
+	if err := s.db.SelectContext(ctx, &dbItems, q, args...); err != nil {
+		log.Default().Error("database query failed", zap.Error(err))
+		return nil, errors.ErrorWithCode("internal error", "Could not query for todos", 500)
+	}
+	return toCoreTodoSlice(dbItems), nil
+}
+
+func priorityRank(p *todoitem.Priority) int {
+	if p == nil || p.Rank() < 0 {
+		return 0
+	}
+	return p.Rank()
+}
+
 func toCoreTodoSlice(dbTodoItems []dbTodoItem) []todoitem.TodoItem {
 	var coreItems []todoitem.TodoItem
 	for _, item := range dbTodoItems {
@@ -157,12 +298,23 @@ func toCoreTodoSlice(dbTodoItems []dbTodoItem) []todoitem.TodoItem {
 
 func toCoreItem(item dbTodoItem) todoitem.TodoItem {
 	coreTodoItem := todoitem.TodoItem{
-		Id:        &item.Id,
-		Created:   &item.DateCreated,
-		Updated:   &item.DateUpdated,
-		Completed: &item.Completed,
-		Deleted:   &item.Deleted,
-		Summary:   &item.Summary,
+		Id:          &item.Id,
+		Created:     &item.DateCreated,
+		Updated:     &item.DateUpdated,
+		Completed:   &item.Completed,
+		Deleted:     &item.Deleted,
+		Summary:     &item.Summary,
+		Due:         item.Due,
+		Tags:        []string(item.Tags),
+		CompletedAt: item.DateCompleted,
+		Version:     &item.Version,
+		OwnerId:     item.OwnerId,
+		AssigneeIds: []string(item.AssigneeIds),
+	}
+	//leave none out, an unset priority and no priority are the same thing to a client
+	if item.Priority > 0 {
+		p := todoitem.PriorityFromRank(item.Priority)
+		coreTodoItem.Priority = &p
 	}
 	return coreTodoItem
 }
@@ -3,14 +3,20 @@ package tododb
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
 	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
 	"github.com/stumacwastaken/todo/todoitem"
 )
 
@@ -26,14 +32,178 @@ func newTime(ti time.Time) *time.Time {
 func newBool(b bool) *bool {
 	return &b
 }
+func newVersion(v int64) *int64 {
+	return &v
+}
 
 var testTime = newTime(time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local))
 
+// outboxEvent matches the payload written to the outbox by the event type it holds
+type outboxEvent todoitem.EventType
+
+func (o outboxEvent) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var e todoitem.Event
+	return json.Unmarshal([]byte(s), &e) == nil && e.Type == todoitem.EventType(o)
+}
+
+func TestCreate(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE sync_clock SET version = LAST_INSERT_ID\(version \+ 1\)`).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(`INSERT into todo_item \(summary, priority, due, tags, version, owner_id, assignee_ids, workspace_id\) VALUES \(\?, \?, \?, \?, \?, \?, \?, \?\)`).
+		WithArgs("test summary", 0, nil, "[]", 7, "sam", `["alex"]`, "default").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE version = ? AND workspace_id = ?")).WithArgs(7, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version", "owner_id", "assignee_ids"}).
+			AddRow("1111", "test summary", testTime, testTime, false, false, 7, "sam", `["alex"]`))
+	mock.ExpectExec(`INSERT INTO outbox \(event, payload, workspace_id\) VALUES \(\?, \?, \?\)`).
+		WithArgs("created", outboxEvent(todoitem.EventCreated), "default").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox \(event, payload, workspace_id\) VALUES \(\?, \?, \?\)`).
+		WithArgs("assigned", outboxEvent(todoitem.EventAssigned), "default").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	val, err := store.Create(context.Background(), todoitem.TodoItem{Summary: newSummary("test summary"), OwnerId: newId("sam"),
+		AssigneeIds: []string{"alex"}})
+	assert.Nil(t, err)
+	assert.Equal(t, newId("1111"), val.Id)
+	assert.Equal(t, newVersion(7), val.Version)
+	assert.Equal(t, newId("sam"), val.OwnerId)
+	assert.Equal(t, []string{"alex"}, val.AssigneeIds)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdate(t *testing.T) {
+	type test struct {
+		name      string
+		item      todoitem.TodoItem
+		event     todoitem.EventType
+		mockErr   error
+		outboxErr error
+		expectErr error
+	}
+	tests := []test{
+		{
+			name:  "edit",
+			item:  todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("new summary"), Updated: testTime, Completed: newBool(false), Deleted: newBool(false)},
+			event: todoitem.EventUpdated,
+		},
+		{
+			name:  "complete",
+			item:  todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("test summary"), Updated: testTime, Completed: newBool(true), Deleted: newBool(false)},
+			event: todoitem.EventCompleted,
+		},
+		{
+			name: "assign",
+			item: todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("test summary"), Updated: testTime, Completed: newBool(false), Deleted: newBool(false),
+				AssigneeIds: []string{"alex"}},
+			event: todoitem.EventUpdated,
+		},
+		{
+			name:      "missing",
+			item:      todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("test summary"), Updated: testTime},
+			mockErr:   sql.ErrNoRows,
+			expectErr: terr.ErrorWithCode("not found", "Item with id 1111 not found", 404),
+		},
+		{
+			name:      "stale version",
+			item:      todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("new summary"), Updated: testTime, Version: newVersion(3)},
+			expectErr: terr.ErrorWithCode("conflict", "Item with id 1111 was changed by someone else, fetch it and try again", 409),
+		},
+		{
+			name:      "outbox fails the update",
+			item:      todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("new summary"), Updated: testTime, Completed: newBool(false), Deleted: newBool(false)},
+			event:     todoitem.EventUpdated,
+			outboxErr: errors.New("a random sql test error"),
+			expectErr: terr.UnknownError(),
+		},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mockDB.Close()
+			store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(`SELECT \* FROM todo_item WHERE id = \? AND workspace_id = \? FOR UPDATE`).WithArgs("1111", "default")
+			rows := sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version"}).
+				AddRow("1111", "test summary", testTime, testTime, false, false, 4)
+			if tt.mockErr != nil {
+				query.WillReturnError(tt.mockErr)
+				mock.ExpectRollback()
+			} else if tt.item.Version != nil && *tt.item.Version != 4 {
+				query.WillReturnRows(rows)
+				mock.ExpectRollback()
+			} else {
+				query.WillReturnRows(rows)
+				mock.ExpectExec(`UPDATE sync_clock SET version = LAST_INSERT_ID\(version \+ 1\)`).WillReturnResult(sqlmock.NewResult(5, 1))
+				mock.ExpectExec(`UPDATE todo_item SET summary = \?.* WHERE id = \? AND workspace_id = \?`).WillReturnResult(sqlmock.NewResult(0, 1))
+				outbox := mock.ExpectExec(`INSERT INTO outbox \(event, payload, workspace_id\) VALUES \(\?, \?, \?\)`).
+					WithArgs(string(tt.event), outboxEvent(tt.event), "default")
+				if tt.outboxErr != nil {
+					outbox.WillReturnError(tt.outboxErr)
+					mock.ExpectRollback()
+				} else {
+					outbox.WillReturnResult(sqlmock.NewResult(0, 1))
+					if len(tt.item.AssigneeIds) > 0 {
+						mock.ExpectExec(`INSERT INTO outbox`).WithArgs("assigned", outboxEvent(todoitem.EventAssigned), "default").
+							WillReturnResult(sqlmock.NewResult(0, 1))
+					}
+					mock.ExpectCommit()
+				}
+			}
+			val, err := store.Update(context.Background(), tt.item)
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				tt.item.Version = newVersion(5)
+				assert.Equal(t, tt.item, val)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+		t.Run(tt.name, tf)
+	}
+}
+func TestPut(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT workspace_id FROM todo_item WHERE id = \? FOR UPDATE`).WithArgs("1111").WillReturnRows(sqlmock.NewRows([]string{"workspace_id"}))
+	mock.ExpectExec(`UPDATE sync_clock SET version = LAST_INSERT_ID\(version \+ 1\)`).WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec(`INSERT INTO todo_item \(id, summary, date_created, .*ON DUPLICATE KEY UPDATE`).
+		WithArgs("1111", "test summary", testTime, testTime, false, true, 2, nil, `["home"]`, testTime, 9, nil, "[]", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE id = ? AND workspace_id = ?")).WithArgs("1111", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version"}).
+			AddRow("1111", "test summary", testTime, testTime, true, false, 9))
+	mock.ExpectCommit()
+
+	medium := todoitem.PriorityMedium
+	val, err := store.Put(context.Background(), todoitem.TodoItem{Id: newId("1111"), Summary: newSummary("test summary"),
+		Created: testTime, Updated: testTime, Deleted: newBool(false), Completed: newBool(true), Priority: &medium,
+		Tags: []string{"home"}, CompletedAt: testTime})
+	assert.Nil(t, err)
+	assert.Equal(t, newVersion(9), val.Version)
+	assert.Nil(t, mock.ExpectationsWereMet(), "no outbox event")
 
+	_, err = store.Put(context.Background(), todoitem.TodoItem{Summary: newSummary("no id")})
+	assert.Equal(t, terr.ErrorWithCode("bad request", "Items need an id to be put", 400), err)
 }
+
 func TestGetAll(t *testing.T) {
-	var rows = sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted"})
+	var rows = sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version"})
 	type test struct {
 		name      string
 		expect    []todoitem.TodoItem
@@ -66,10 +236,11 @@ func TestGetAll(t *testing.T) {
 					Deleted:   newBool(false),
 					Completed: newBool(false),
 					Summary:   newSummary("test summary"),
+					Version:   newVersion(4),
 				},
 			},
 			expectErr: nil,
-			mockRows:  rows.AddRow("1111", "test summary", testTime, testTime, false, false),
+			mockRows:  rows.AddRow("1111", "test summary", testTime, testTime, false, false, 4),
 			mockErr:   nil,
 		},
 	}
@@ -84,7 +255,7 @@ func TestGetAll(t *testing.T) {
 			store := NewStore(db)
 			// testTime := newTime(time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local))
 			mock.ExpectBegin()
-			query := mock.ExpectQuery(`SELECT \* FROM todo_item WHERE deleted=false ORDER BY date_created DESC`).WithArgs()
+			query := mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE deleted=false AND workspace_id=? ORDER BY date_created DESC")).WithArgs("default")
 
 			if tt.mockErr != nil {
 				query.WillReturnError(tt.mockErr)
@@ -102,7 +273,7 @@ func TestGetAll(t *testing.T) {
 }
 
 func TestGetById(t *testing.T) {
-	var rows = sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted"})
+	var rows = sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version"})
 	type test struct {
 		name      string
 		expect    todoitem.TodoItem
@@ -120,9 +291,10 @@ func TestGetById(t *testing.T) {
 				Deleted:   newBool(false),
 				Completed: newBool(false),
 				Summary:   newSummary("test summary"),
+				Version:   newVersion(4),
 			},
 			expectErr: nil,
-			mockRows:  rows.AddRow("1111", "test summary", testTime, testTime, false, false),
+			mockRows:  rows.AddRow("1111", "test summary", testTime, testTime, false, false, 4),
 			mockErr:   nil,
 		},
 		{
@@ -148,7 +320,7 @@ func TestGetById(t *testing.T) {
 			db := sqlx.NewDb(mockDB, "sqlmock")
 			store := NewStore(db)
 			// testTime := newTime(time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local))
-			query := mock.ExpectQuery(`SELECT \* FROM todo_item where id=\?`).WithArgs("1111")
+			query := mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item where id=? AND workspace_id=?")).WithArgs("1111", "default")
 			if tt.mockErr != nil {
 				query.WillReturnError(tt.mockErr)
 			} else {
@@ -161,3 +333,342 @@ func TestGetById(t *testing.T) {
 		t.Run(tt.name, tf)
 	}
 }
+
+func TestFind(t *testing.T) {
+	now := time.Date(2023, time.January, 12, 12, 0, 0, 0, time.UTC)
+	type test struct {
+		name      string
+		expr      string
+		where     string
+		args      []driver.Value
+		expectErr error
+		mockErr   error
+	}
+	tests := []test{
+		{
+			name:  "everything",
+			expr:  "",
+			where: "TRUE",
+		},
+		{
+			name:  "bools and enums",
+			expr:  "completed:false priority>=medium",
+			where: "completed = ? AND priority >= ?",
+			args:  []driver.Value{false, int64(2)},
+		},
+		{
+			name:  "text and tags",
+			expr:  `-"50%" tag:work`,
+			where: "NOT (summary LIKE ?) AND JSON_CONTAINS(tags, JSON_QUOTE(?))",
+			args:  []driver.Value{`%50\%%`, "work"},
+		},
+		{
+			name:  "dates",
+			expr:  "due<7d -due:none created:today",
+			where: "(due IS NOT NULL AND due < ?) AND NOT (due IS NULL) AND (date_created IS NOT NULL AND date_created >= ? AND date_created < ?)",
+			args: []driver.Value{now.AddDate(0, 0, 7),
+				time.Date(2023, time.January, 12, 0, 0, 0, 0, time.UTC), time.Date(2023, time.January, 13, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:      "query error",
+			expr:      "completed:true",
+			where:     "completed = ?",
+			args:      []driver.Value{true},
+			mockErr:   errors.New("a random sql test error"),
+			expectErr: terr.ErrorWithCode("internal error", "Could not query for todos", 500),
+		},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mockDB.Close()
+			store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+			pred, err := filter.Compile(tt.expr, todoitem.FilterSchema, now)
+			assert.Nil(t, err)
+			query := mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE workspace_id = ? AND (" + tt.where + ") ORDER BY date_created DESC")).
+				WithArgs(append([]driver.Value{"default"}, tt.args...)...)
+			if tt.mockErr != nil {
+				query.WillReturnError(tt.mockErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "priority", "due", "tags", "version"}).
+					AddRow("1111", "test summary", testTime, testTime, false, false, 3, nil, []byte(`["work"]`), 4))
+			}
+			val, err := store.Find(context.Background(), pred)
+			assert.Equal(t, tt.expectErr, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+			if tt.expectErr == nil {
+				high := todoitem.PriorityHigh
+				assert.Equal(t, []todoitem.TodoItem{{
+					Id:        newId("1111"),
+					Created:   testTime,
+					Updated:   testTime,
+					Deleted:   newBool(false),
+					Completed: newBool(false),
+					Summary:   newSummary("test summary"),
+					Priority:  &high,
+					Tags:      []string{"work"},
+					Version:   newVersion(4),
+				}}, val)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestStats(t *testing.T) {
+	r := todoitem.StatsRange{
+		From: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2023, time.January, 8, 0, 0, 0, 0, time.UTC),
+		Now:  time.Date(2023, time.January, 7, 12, 0, 0, 0, time.UTC),
+	}
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(totalsQuery, "workspace_id = ?"))).WithArgs(r.Now, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"open", "completed", "deleted", "overdue"}).AddRow(5, 4, 3, 2))
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(createdPerDayQuery, "workspace_id = ?"))).WithArgs(r.From, r.To, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"day", "n"}).AddRow("2023-01-02", 3).AddRow("2023-01-03", 1))
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(completedPerDayQuery, "workspace_id = ?"))).WithArgs(r.From, r.To, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"day", "n"}).AddRow("2023-01-03", 2))
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(timeToCompleteQuery, "workspace_id = ?"))).WithArgs(r.From, r.To, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(7200.5))
+	mock.ExpectRollback()
+
+	stats, err := store.Stats(context.Background(), r)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 5, stats.Open)
+	assert.Equal(t, 4, stats.Completed)
+	assert.Equal(t, 3, stats.Deleted)
+	assert.Equal(t, 2, stats.Overdue)
+	assert.Equal(t, 7200.5, *stats.AverageSecondsToComplete)
+	assert.ElementsMatch(t, []todoitem.DayStats{
+		{Date: "2023-01-02", Created: 3},
+		{Date: "2023-01-03", Created: 1, Completed: 2},
+	}, stats.Days)
+}
+
+func TestStatsNothingCompleted(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(totalsQuery, "workspace_id = ?"))).
+		WillReturnRows(sqlmock.NewRows([]string{"open", "completed", "deleted", "overdue"}).AddRow(0, 0, 0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(createdPerDayQuery, "workspace_id = ?"))).WillReturnRows(sqlmock.NewRows([]string{"day", "n"}))
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(completedPerDayQuery, "workspace_id = ?"))).WillReturnRows(sqlmock.NewRows([]string{"day", "n"}))
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(timeToCompleteQuery, "workspace_id = ?"))).WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+	mock.ExpectRollback()
+
+	stats, err := store.Stats(context.Background(), todoitem.StatsRange{})
+	assert.Nil(t, err)
+	assert.Nil(t, stats.AverageSecondsToComplete)
+	assert.Empty(t, stats.Days)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf(totalsQuery, "workspace_id = ?"))).WillReturnError(errors.New("a random sql test error"))
+	mock.ExpectRollback()
+	_, err = store.Stats(context.Background(), todoitem.StatsRange{})
+	assert.Equal(t, terr.ErrorWithCode("internal error", "Could not query for stats", 500), err)
+}
+
+func TestStatsForOwner(t *testing.T) {
+	owner := "sam"
+	r := todoitem.StatsRange{From: testTime.Add(-time.Hour), To: *testTime, Now: *testTime, Owner: &owner}
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	//the owner's rows are picked out of the workspace's
+	owned := regexp.QuoteMeta("workspace_id = ? AND owner_id = ?")
+	mock.ExpectBegin()
+	mock.ExpectQuery(owned).WithArgs(r.Now, "default", owner).
+		WillReturnRows(sqlmock.NewRows([]string{"open", "completed", "deleted", "overdue"}).AddRow(1, 0, 0, 0))
+	mock.ExpectQuery(owned).WithArgs(r.From, r.To, "default", owner).WillReturnRows(sqlmock.NewRows([]string{"day", "n"}))
+	mock.ExpectQuery(owned).WithArgs(r.From, r.To, "default", owner).WillReturnRows(sqlmock.NewRows([]string{"day", "n"}))
+	mock.ExpectQuery(owned).WithArgs(r.From, r.To, "default", owner).WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+	mock.ExpectRollback()
+
+	stats, err := store.Stats(context.Background(), r)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stats.Open)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestChanges(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE workspace_id = ? AND version > ? ORDER BY version LIMIT ?")).WithArgs("default", 3, 501).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version"}).
+			AddRow("1111", "test summary", testTime, testTime, false, true, 4).
+			AddRow("2222", "other summary", testTime, testTime, false, false, 6))
+	val, err := store.Changes(context.Background(), 3, 501, nil)
+	assert.Nil(t, err)
+	if assert.Len(t, val, 2) {
+		assert.True(t, *val[0].Deleted, "tombstones are included")
+		assert.Equal(t, newVersion(6), val[1].Version)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestChangesForLists(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE workspace_id = ? AND owner_id IN (?, ?) AND version > ? ORDER BY version LIMIT ?")).WithArgs("default", "sam", "jo", 3, 501).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version", "owner_id"}).
+			AddRow("1111", "test summary", testTime, testTime, false, false, 4, "sam").
+			AddRow("2222", "shared summary", testTime, testTime, false, false, 5, "jo"))
+	val, err := store.Changes(context.Background(), 3, 501, []string{"sam", "jo"})
+	assert.Nil(t, err)
+	if assert.Len(t, val, 2) {
+		assert.Equal(t, newId("jo"), val[1].OwnerId)
+	}
+
+	val, err = store.Changes(context.Background(), 3, 501, []string{})
+	assert.Nil(t, err)
+	assert.Empty(t, val, "no lists is no query")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestFindForOwners(t *testing.T) {
+	owner := filter.Field{Name: "owner", Kind: filter.Text}
+	type test struct {
+		name   string
+		owners []string
+		where  string
+		args   []driver.Value
+	}
+	tests := []test{
+		{name: "shared lists", owners: []string{"sam", "alex"}, where: "owner_id IN (?, ?)", args: []driver.Value{"sam", "alex"}},
+		{name: "no lists", owners: []string{}, where: "FALSE"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mockDB.Close()
+			store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE workspace_id = ? AND (" + tt.where + ") ORDER BY date_created DESC")).
+				WithArgs(append([]driver.Value{"default"}, tt.args...)...).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			_, err = store.Find(context.Background(), filter.Predicate{}.And(filter.Cond{Field: owner, Op: filter.In, Value: tt.owners}))
+			assert.Nil(t, err)
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestFindAssigned(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM todo_item WHERE workspace_id = ? AND (JSON_CONTAINS(assignee_ids, JSON_QUOTE(?))) ORDER BY date_created DESC")).
+		WithArgs("default", "alex").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version", "assignee_ids"}).
+			AddRow("1111", "test summary", testTime, testTime, false, false, 4, `["sam","alex"]`))
+	assignee := filter.Field{Name: "assignee", Kind: filter.Set}
+	val, err := store.Find(context.Background(), filter.Predicate{}.And(filter.Cond{Field: assignee, Op: filter.Contains, Value: "alex"}))
+	assert.Nil(t, err)
+	if assert.Len(t, val, 1) {
+		assert.Equal(t, []string{"sam", "alex"}, val[0].AssigneeIds)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestWorkspaceIsolation runs everything the store does in a workspace, checking that every query is held to it.
+// sqlmock fails any query that doesn't filter on workspace_id or doesn't get the workspace among its args, so this is
+// what stops a read being added that could see past it.
+func TestWorkspaceIsolation(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam", WorkspaceId: "ops"})
+	ops := `workspace_id ?= ?\?`
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	item := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "summary", "date_created", "date_updated", "completed", "deleted", "version", "owner_id", "workspace_id"}).
+			AddRow("1111", "test summary", testTime, testTime, false, false, 4, "sam", "ops")
+	}
+
+	mock.ExpectQuery(ops).WithArgs("1111", "ops").WillReturnRows(item())
+	_, err = store.GetById(ctx, "1111")
+	assert.Nil(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(ops).WithArgs("ops").WillReturnRows(item())
+	mock.ExpectCommit()
+	_, err = store.GetAll(ctx)
+	assert.Nil(t, err)
+
+	mock.ExpectQuery(ops).WithArgs("ops", true).WillReturnRows(item())
+	pred, _ := filter.Compile("completed:true", todoitem.FilterSchema, *testTime)
+	_, err = store.Find(ctx, pred)
+	assert.Nil(t, err)
+
+	mock.ExpectQuery(ops).WithArgs("ops", "sam", 0, 10).WillReturnRows(item())
+	_, err = store.Changes(ctx, 0, 10, []string{"sam"})
+	assert.Nil(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(ops).WithArgs(*testTime, "ops").WillReturnRows(sqlmock.NewRows([]string{"open", "completed", "deleted", "overdue"}).AddRow(1, 0, 0, 0))
+	mock.ExpectQuery(ops).WithArgs(*testTime, *testTime, "ops").WillReturnRows(sqlmock.NewRows([]string{"day", "n"}))
+	mock.ExpectQuery(ops).WithArgs(*testTime, *testTime, "ops").WillReturnRows(sqlmock.NewRows([]string{"day", "n"}))
+	mock.ExpectQuery(ops).WithArgs(*testTime, *testTime, "ops").WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(nil))
+	mock.ExpectRollback()
+	_, err = store.Stats(ctx, todoitem.StatsRange{From: *testTime, To: *testTime, Now: *testTime})
+	assert.Nil(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE sync_clock`).WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectExec(`INSERT into todo_item .*workspace_id`).WithArgs("test summary", 0, nil, "[]", 5, "sam", "[]", "ops").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(ops).WithArgs(5, "ops").WillReturnRows(item())
+	mock.ExpectExec(`INSERT INTO outbox`).WithArgs("created", outboxEvent(todoitem.EventCreated), "ops").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	_, err = store.Create(ctx, todoitem.TodoItem{Summary: newSummary("test summary"), OwnerId: newId("sam")})
+	assert.Nil(t, err)
+
+	//an item in another workspace is as good as not there
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM todo_item WHERE id = \? AND workspace_id = \? FOR UPDATE`).WithArgs("2222", "ops").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = store.Update(ctx, todoitem.TodoItem{Id: newId("2222"), Summary: newSummary("mine now")})
+	assert.Equal(t, terr.ErrorWithCode("not found", "Item with id 2222 not found", 404), err)
+
+	//and can't be put over
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT workspace_id FROM todo_item WHERE id = \? FOR UPDATE`).WithArgs("2222").
+		WillReturnRows(sqlmock.NewRows([]string{"workspace_id"}).AddRow("default"))
+	mock.ExpectRollback()
+	_, err = store.Put(ctx, todoitem.TodoItem{Id: newId("2222"), Summary: newSummary("mine now")})
+	assert.Equal(t, terr.ErrorWithCode("conflict", "Item id 2222 is already taken", 409), err)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,93 @@
+package tododb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/filter"
+)
+
+// columns maps filter fields on to their todo_item columns.
+var columns = map[string]string{
+	"summary":   "summary",
+	"completed": "completed",
+	"deleted":   "deleted",
+	"priority":  "priority",
+	"tag":       "tags",
+	"due":       "due",
+	"created":   "date_created",
+	"updated":   "date_updated",
+	//not in the schema, the core adds these to scope queries to a user and find what's assigned to them
+	"owner":    "owner_id",
+	"assignee": "assignee_ids",
+}
+
+var sqlOps = map[filter.Op]string{
+	filter.Eq: "=",
+	filter.Lt: "<",
+	filter.Le: "<=",
+	filter.Gt: ">",
+	filter.Ge: ">=",
+}
+
+// whereClause turns a predicate into a parameterized sql condition. Every condition is written so it can never
+// evaluate to NULL, otherwise negating a condition on an empty due date would quietly drop rows that Match keeps.
+func whereClause(pred filter.Predicate) (string, []any, error) {
+	if len(pred.Conds) == 0 {
+		return "TRUE", nil, nil
+	}
+	var parts []string
+	var args []any
+	for _, c := range pred.Conds {
+		col, ok := columns[c.Field.Name]
+		if !ok {
+			return "", nil, fmt.Errorf("no column for filter field %s", c.Field.Name)
+		}
+		var cond string
+		switch {
+		case c.Field.Kind == filter.Set:
+			cond = fmt.Sprintf("JSON_CONTAINS(%s, JSON_QUOTE(?))", col)
+			args = append(args, c.Value)
+		case c.Op == filter.In:
+			in := c.Value.([]string)
+			if len(in) == 0 {
+				cond = "FALSE"
+				break
+			}
+			cond = fmt.Sprintf("%s IN (?%s)", col, strings.Repeat(", ?", len(in)-1))
+			for _, v := range in {
+				args = append(args, v)
+			}
+		case c.Op == filter.Contains:
+			cond = fmt.Sprintf("%s LIKE ?", col)
+			args = append(args, "%"+escapeLike(c.Value.(string))+"%")
+		case c.Op == filter.Within:
+			span := c.Value.(filter.Span)
+			cond = fmt.Sprintf("(%s IS NOT NULL AND %s >= ? AND %s < ?)", col, col, col)
+			args = append(args, span.From, span.To)
+		case c.Value == nil:
+			cond = fmt.Sprintf("%s IS NULL", col)
+		default:
+			op, ok := sqlOps[c.Op]
+			if !ok {
+				return "", nil, fmt.Errorf("unsupported operator %d on %s", c.Op, c.Field.Name)
+			}
+			if _, isTime := c.Value.(time.Time); isTime {
+				cond = fmt.Sprintf("(%s IS NOT NULL AND %s %s ?)", col, col, op)
+			} else {
+				cond = fmt.Sprintf("%s %s ?", col, op)
+			}
+			args = append(args, c.Value)
+		}
+		if c.Negate {
+			cond = fmt.Sprintf("NOT (%s)", cond)
+		}
+		parts = append(parts, cond)
+	}
+	return strings.Join(parts, " AND "), args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
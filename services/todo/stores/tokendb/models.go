@@ -0,0 +1,16 @@
+package tokendb
+
+import "time"
+
+type dbToken struct {
+	Id          string     `db:"id"`
+	UserId      string     `db:"user_id"`
+	Name        string     `db:"name"`
+	Scope       string     `db:"scope"`
+	TokenHash   string     `db:"token_hash"`
+	DateCreated time.Time  `db:"date_created"`
+	Expires     *time.Time `db:"expires"`
+	LastUsed    *time.Time `db:"last_used"`
+	Revoked     *time.Time `db:"revoked"`
+	WorkspaceId string     `db:"workspace_id"`
+}
@@ -0,0 +1,157 @@
+package tokendb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/token"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, t token.Token) (token.Token, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-create")
+	defer span.End()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return token.Token{}, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var id string
+	if err := tx.GetContext(ctx, &id, `SELECT UUID()`); err != nil {
+		log.Default().Error("failed to generate token id", zap.Error(err))
+		return token.Token{}, errors.UnknownError()
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO access_token (id, user_id, name, scope, token_hash, expires, workspace_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, t.UserId, t.Name, t.Scope, t.Hash, t.Expires, t.WorkspaceId)
+	if err != nil {
+		log.Default().Warn("error creating new token in database", zap.Error(err))
+		return token.Token{}, errors.UnknownError()
+	}
+	v := new(dbToken)
+	if err := tx.GetContext(ctx, v, `SELECT * FROM access_token WHERE id=?`, id); err != nil {
+		log.Default().Warn("error reading back new token", zap.Error(err))
+		return token.Token{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit token", zap.Error(err))
+		return token.Token{}, errors.UnknownError()
+	}
+	return toCoreToken(*v), nil
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (token.Token, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-getById")
+	defer span.End()
+	v := new(dbToken)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM access_token WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return token.Token{}, notFound(id)
+		}
+		log.Default().Error("unknown error querying token by id", zap.Error(err), zap.String("req id", id))
+		return token.Token{}, errors.UnknownError()
+	}
+	return toCoreToken(*v), nil
+}
+
+func (s *Store) GetByHash(ctx context.Context, hash string) (token.Token, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-getByHash")
+	defer span.End()
+	v := new(dbToken)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM access_token WHERE token_hash=?`, hash); err != nil {
+		if err == sql.ErrNoRows {
+			return token.Token{}, errors.ErrorWithCode("not found", "Token not found", 404)
+		}
+		log.Default().Error("unknown error querying token by hash", zap.Error(err))
+		return token.Token{}, errors.UnknownError()
+	}
+	return toCoreToken(*v), nil
+}
+
+func (s *Store) GetAll(ctx context.Context, userId string) ([]token.Token, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-getAll")
+	defer span.End()
+	rows := []dbToken{}
+	if err := s.db.SelectContext(ctx, &rows, `SELECT * FROM access_token WHERE user_id=? AND workspace_id=? ORDER BY date_created DESC`,
+		userId, auth.Workspace(ctx)); err != nil {
+		log.Default().Error("unknown error querying tokens", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	tokens := make([]token.Token, 0, len(rows))
+	for _, r := range rows {
+		tokens = append(tokens, toCoreToken(r))
+	}
+	return tokens, nil
+}
+
+func (s *Store) Revoke(ctx context.Context, id string, at time.Time) (token.Token, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-revoke")
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, `UPDATE access_token SET revoked=? WHERE id=? AND workspace_id=?`, at, id, auth.Workspace(ctx))
+	if err != nil {
+		log.Default().Error("error revoking token", zap.Error(err), zap.String("req id", id))
+		return token.Token{}, errors.UnknownError()
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return token.Token{}, notFound(id)
+	}
+	return s.GetById(ctx, id)
+}
+
+func (s *Store) Used(ctx context.Context, id string, at time.Time) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-used")
+	defer span.End()
+	if _, err := s.db.ExecContext(ctx, `UPDATE access_token SET last_used=? WHERE id=?`, at, id); err != nil {
+		log.Default().Warn("error marking token used", zap.Error(err), zap.String("req id", id))
+		return errors.UnknownError()
+	}
+	return nil
+}
+
+func (s *Store) Prune(ctx context.Context, userId, name string, before time.Time) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-token-prune")
+	defer span.End()
+	_, err := s.db.ExecContext(ctx, `DELETE FROM access_token WHERE user_id=? AND name=? AND workspace_id=? AND (expires < ? OR revoked < ?)`,
+		userId, name, auth.Workspace(ctx), before, before)
+	if err != nil {
+		log.Default().Error("error pruning tokens", zap.Error(err))
+		return errors.UnknownError()
+	}
+	return nil
+}
+
+func notFound(id string) error {
+	return errors.ErrorWithCode("not found", fmt.Sprintf("Token with id %s not found", id), 404)
+}
+
+func toCoreToken(t dbToken) token.Token {
+	return token.Token{
+		Id:          &t.Id,
+		Name:        &t.Name,
+		Scope:       &t.Scope,
+		UserId:      &t.UserId,
+		Created:     &t.DateCreated,
+		Expires:     t.Expires,
+		LastUsed:    t.LastUsed,
+		Revoked:     t.Revoked,
+		Hash:        &t.TokenHash,
+		WorkspaceId: &t.WorkspaceId,
+	}
+}
@@ -0,0 +1,133 @@
+package tokendb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/token"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local)
+
+var tokenColumns = []string{"id", "user_id", "name", "scope", "token_hash", "date_created", "expires", "last_used", "revoked", "workspace_id"}
+
+func newStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	return NewStore(sqlx.NewDb(mockDB, "sqlmock")), mock
+}
+
+func TestCreate(t *testing.T) {
+	type test struct {
+		name      string
+		insertErr error
+		expectErr error
+	}
+	tests := []test{
+		{name: "happy path"},
+		{name: "database error", insertErr: errors.New("boom"), expectErr: terr.UnknownError()},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			store, mock := newStore(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT UUID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"UUID()"}).AddRow("1111"))
+			insert := mock.ExpectExec(`INSERT INTO access_token \(id, user_id, name, scope, token_hash, expires, workspace_id\) VALUES \(\?, \?, \?, \?, \?, \?, \?\)`).
+				WithArgs("1111", "sam", "ci", "write", "abc", &testTime, "ops")
+			if tt.insertErr != nil {
+				insert.WillReturnError(tt.insertErr)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT \* FROM access_token WHERE id=\?`).WithArgs("1111").
+					WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow("1111", "sam", "ci", "write", "abc", testTime, testTime, nil, nil, "ops"))
+				mock.ExpectCommit()
+			}
+
+			val, err := store.Create(context.Background(), token.Token{UserId: newString("sam"), Name: newString("ci"),
+				Scope: newString("write"), Hash: newString("abc"), Expires: &testTime, WorkspaceId: newString("ops")})
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				assert.Equal(t, token.Token{Id: newString("1111"), UserId: newString("sam"), Name: newString("ci"),
+					Scope: newString("write"), Hash: newString("abc"), Created: &testTime, Expires: &testTime, WorkspaceId: newString("ops")}, val)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetByHash(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectQuery(`SELECT \* FROM access_token WHERE token_hash=\?`).WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow("1111", "sam", "ci", "read", "abc", testTime, nil, testTime, nil, "ops"))
+	mock.ExpectQuery(`SELECT \* FROM access_token WHERE token_hash=\?`).WithArgs("nope").
+		WillReturnRows(sqlmock.NewRows(tokenColumns))
+
+	val, err := store.GetByHash(context.Background(), "abc")
+	assert.Nil(t, err)
+	assert.Equal(t, "1111", *val.Id)
+	assert.Equal(t, &testTime, val.LastUsed)
+	_, err = store.GetByHash(context.Background(), "nope")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Token not found", 404), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAll(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectQuery(`SELECT \* FROM access_token WHERE user_id=\? AND workspace_id=\? ORDER BY date_created DESC`).WithArgs("sam", "ops").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).
+			AddRow("2222", "sam", "deploy", "admin", "def", testTime, nil, nil, testTime, "ops").
+			AddRow("1111", "sam", "ci", "read", "abc", testTime, nil, nil, nil, "ops"))
+	tokens, err := store.GetAll(auth.WithWorkspace(context.Background(), "ops"), "sam")
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 2) {
+		assert.Equal(t, "2222", *tokens[0].Id)
+		assert.Equal(t, &testTime, tokens[0].Revoked)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRevoke(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectExec(`UPDATE access_token SET revoked=\? WHERE id=\? AND workspace_id=\?`).WithArgs(testTime, "nope", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE access_token SET revoked=\? WHERE id=\? AND workspace_id=\?`).WithArgs(testTime, "1111", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM access_token WHERE id=\? AND workspace_id=\?`).WithArgs("1111", "default").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).AddRow("1111", "sam", "ci", "read", "abc", testTime, nil, nil, testTime, "ops"))
+
+	_, err := store.Revoke(context.Background(), "nope", testTime)
+	assert.Equal(t, notFound("nope"), err)
+	val, err := store.Revoke(context.Background(), "1111", testTime)
+	assert.Nil(t, err)
+	assert.Equal(t, &testTime, val.Revoked)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestPrune(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectExec(`DELETE FROM access_token WHERE user_id=\? AND name=\? AND workspace_id=\? AND \(expires < \? OR revoked < \?\)`).
+		WithArgs("sam", "oidc login", "ops", testTime, testTime).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM access_token`).WillReturnError(errors.New("connection reset"))
+
+	ops := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam", WorkspaceId: "ops"})
+	assert.Nil(t, store.Prune(ops, "sam", "oidc login", testTime))
+	assert.Equal(t, terr.UnknownError(), store.Prune(ops, "sam", "oidc login", testTime))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
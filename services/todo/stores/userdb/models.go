@@ -0,0 +1,13 @@
+package userdb
+
+import "time"
+
+type dbUser struct {
+	Id           string    `db:"id"`
+	Email        string    `db:"email"`
+	Name         string    `db:"name"`
+	PasswordHash *string   `db:"password_hash"`
+	DateCreated  time.Time `db:"date_created"`
+	ExternalId   *string   `db:"external_id"`
+	WorkspaceId  string    `db:"workspace_id"`
+}
@@ -0,0 +1,133 @@
+package userdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/user"
+	"go.uber.org/zap"
+)
+
+// duplicateEntry is mysql's error number for breaking a unique index
+const duplicateEntry = 1062
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Create inserts the user. user is a reserved word in mysql, hence the backticks.
+func (s *Store) Create(ctx context.Context, u user.User) (user.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-user-create")
+	defer span.End()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return user.User{}, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var id string
+	if err := tx.GetContext(ctx, &id, `SELECT UUID()`); err != nil {
+		log.Default().Error("failed to generate user id", zap.Error(err))
+		return user.User{}, errors.UnknownError()
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO `user` (id, email, name, password_hash, external_id, workspace_id) VALUES (?, ?, ?, ?, ?, ?)",
+		id, u.Email, u.Name, u.PasswordHash, u.ExternalId, u.WorkspaceId)
+	if err != nil {
+		if merr, ok := err.(*mysql.MySQLError); ok && merr.Number == duplicateEntry {
+			return user.User{}, errors.ErrorWithCode("conflict", fmt.Sprintf("A user with email %s already exists", *u.Email), 409)
+		}
+		log.Default().Warn("error creating new user in database", zap.Error(err))
+		return user.User{}, errors.UnknownError()
+	}
+	v := new(dbUser)
+	if err := tx.GetContext(ctx, v, "SELECT * FROM `user` WHERE id=?", id); err != nil {
+		log.Default().Warn("error reading back new user", zap.Error(err))
+		return user.User{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit user", zap.Error(err))
+		return user.User{}, errors.UnknownError()
+	}
+	return toCoreUser(*v), nil
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (user.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-user-getById")
+	defer span.End()
+	v := new(dbUser)
+	if err := s.db.GetContext(ctx, v, "SELECT * FROM `user` WHERE id=? AND workspace_id=?", id, auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with id %s not found", id), 404)
+		}
+		log.Default().Error("unknown error querying user by id", zap.Error(err), zap.String("req id", id))
+		return user.User{}, errors.UnknownError()
+	}
+	return toCoreUser(*v), nil
+}
+
+func (s *Store) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-user-getByEmail")
+	defer span.End()
+	v := new(dbUser)
+	if err := s.db.GetContext(ctx, v, "SELECT * FROM `user` WHERE email=?", email); err != nil {
+		if err == sql.ErrNoRows {
+			return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with email %s not found", email), 404)
+		}
+		log.Default().Error("unknown error querying user by email", zap.Error(err))
+		return user.User{}, errors.UnknownError()
+	}
+	return toCoreUser(*v), nil
+}
+
+func (s *Store) GetByExternalId(ctx context.Context, externalId string) (user.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-user-getByExternalId")
+	defer span.End()
+	v := new(dbUser)
+	if err := s.db.GetContext(ctx, v, "SELECT * FROM `user` WHERE external_id=?", externalId); err != nil {
+		if err == sql.ErrNoRows {
+			return user.User{}, errors.ErrorWithCode("not found", fmt.Sprintf("User with external id %s not found", externalId), 404)
+		}
+		log.Default().Error("unknown error querying user by external id", zap.Error(err))
+		return user.User{}, errors.UnknownError()
+	}
+	return toCoreUser(*v), nil
+}
+
+func (s *Store) SetExternalId(ctx context.Context, id, externalId string) (user.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-user-setExternalId")
+	defer span.End()
+	if _, err := s.db.ExecContext(ctx, "UPDATE `user` SET external_id=? WHERE id=?", externalId, id); err != nil {
+		if merr, ok := err.(*mysql.MySQLError); ok && merr.Number == duplicateEntry {
+			return user.User{}, errors.ErrorWithCode("conflict", fmt.Sprintf("A user with external id %s already exists", externalId), 409)
+		}
+		log.Default().Error("error linking user", zap.Error(err), zap.String("req id", id))
+		return user.User{}, errors.UnknownError()
+	}
+	//linking happens while logging in, before we know the user's workspace
+	return s.GetByExternalId(ctx, externalId)
+}
+
+func toCoreUser(u dbUser) user.User {
+	return user.User{
+		Id:           &u.Id,
+		Email:        &u.Email,
+		Name:         &u.Name,
+		Created:      &u.DateCreated,
+		PasswordHash: u.PasswordHash,
+		ExternalId:   u.ExternalId,
+		WorkspaceId:  &u.WorkspaceId,
+	}
+}
@@ -0,0 +1,147 @@
+package userdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/user"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local)
+
+var userColumns = []string{"id", "email", "name", "password_hash", "date_created", "external_id", "workspace_id"}
+
+func TestCreate(t *testing.T) {
+	type test struct {
+		name      string
+		insertErr error
+		expectErr error
+	}
+	tests := []test{
+		{name: "happy path"},
+		{
+			name:      "email taken",
+			insertErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+			expectErr: terr.ErrorWithCode("conflict", "A user with email sam@example.com already exists", 409),
+		},
+		{name: "database error", insertErr: errors.New("boom"), expectErr: terr.UnknownError()},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mockDB.Close()
+			store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT UUID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"UUID()"}).AddRow("1111"))
+			insert := mock.ExpectExec("INSERT INTO `user` \\(id, email, name, password_hash, external_id, workspace_id\\) VALUES \\(\\?, \\?, \\?, \\?, \\?, \\?\\)").
+				WithArgs("1111", "sam@example.com", "Sam", "hash", nil, "ops")
+			if tt.insertErr != nil {
+				insert.WillReturnError(tt.insertErr)
+				mock.ExpectRollback()
+			} else {
+				insert.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("SELECT \\* FROM `user` WHERE id=\\?").WithArgs("1111").
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow("1111", "sam@example.com", "Sam", "hash", testTime, nil, "ops"))
+				mock.ExpectCommit()
+			}
+
+			val, err := store.Create(context.Background(), user.User{Email: newString("sam@example.com"), Name: newString("Sam"), WorkspaceId: newString("ops"), PasswordHash: newString("hash")})
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				assert.Equal(t, user.User{
+					Id: newString("1111"), Email: newString("sam@example.com"), Name: newString("Sam"), Created: &testTime, WorkspaceId: newString("ops"), PasswordHash: newString("hash"),
+				}, val)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetByEmail(t *testing.T) {
+	type test struct {
+		name      string
+		mockErr   error
+		expectErr error
+	}
+	tests := []test{
+		{name: "happy path"},
+		{name: "not found", mockErr: sql.ErrNoRows, expectErr: terr.ErrorWithCode("not found", "User with email sam@example.com not found", 404)},
+		{name: "database error", mockErr: errors.New("boom"), expectErr: terr.UnknownError()},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mockDB.Close()
+			store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+			query := mock.ExpectQuery("SELECT \\* FROM `user` WHERE email=\\?").WithArgs("sam@example.com")
+			if tt.mockErr != nil {
+				query.WillReturnError(tt.mockErr)
+			} else {
+				//users from single sign on have no password
+				query.WillReturnRows(sqlmock.NewRows(userColumns).AddRow("1111", "sam@example.com", "Sam", nil, testTime, nil, "ops"))
+			}
+			val, err := store.GetByEmail(context.Background(), "sam@example.com")
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				assert.Equal(t, "1111", *val.Id)
+				assert.Nil(t, val.PasswordHash)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetById(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectQuery("SELECT \\* FROM `user` WHERE id=\\? AND workspace_id=\\?").WithArgs("2222", "default").WillReturnError(sql.ErrNoRows)
+	_, err = store.GetById(context.Background(), "2222")
+	assert.Equal(t, terr.ErrorWithCode("not found", "User with id 2222 not found", 404), err)
+}
+
+func TestExternalId(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	store := NewStore(sqlx.NewDb(mockDB, "sqlmock"))
+	mock.ExpectQuery("SELECT \\* FROM `user` WHERE external_id=\\?").WithArgs("idp sub").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE `user` SET external_id=\\? WHERE id=\\?").WithArgs("idp sub", "1111").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT \\* FROM `user` WHERE external_id=\\?").WithArgs("idp sub").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("1111", "sam@example.com", "Sam", nil, testTime, "idp sub", "ops"))
+	mock.ExpectExec("UPDATE `user` SET external_id=\\? WHERE id=\\?").WithArgs("idp sub", "2222").
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+
+	_, err = store.GetByExternalId(context.Background(), "idp sub")
+	assert.Equal(t, terr.ErrorWithCode("not found", "User with external id idp sub not found", 404), err)
+	u, err := store.SetExternalId(context.Background(), "1111", "idp sub")
+	assert.Nil(t, err)
+	assert.Equal(t, "idp sub", *u.ExternalId)
+	_, err = store.SetExternalId(context.Background(), "2222", "idp sub")
+	assert.Equal(t, terr.ErrorWithCode("conflict", "A user with external id idp sub already exists", 409), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,58 @@
+package webhookdb
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type dbWebhook struct {
+	Id          string    `db:"id"`
+	Url         string    `db:"url"`
+	Secret      string    `db:"secret"`
+	Events      dbEvents  `db:"events"`
+	DateCreated time.Time `db:"date_created"`
+	OwnerId     *string   `db:"owner_id"`
+	WorkspaceId string    `db:"workspace_id"`
+}
+
+type dbDelivery struct {
+	Id          string     `db:"id"`
+	WebhookId   string     `db:"webhook_id"`
+	EventId     *string    `db:"event_id"`
+	Event       string     `db:"event"`
+	Payload     []byte     `db:"payload"`
+	Status      string     `db:"status"`
+	Attempts    int        `db:"attempts"`
+	NextAttempt *time.Time `db:"next_attempt"`
+	LastAttempt *time.Time `db:"last_attempt"`
+	LastStatus  int        `db:"last_status"`
+	LastError   *string    `db:"last_error"`
+	DateCreated time.Time  `db:"date_created"`
+	WorkspaceId string     `db:"workspace_id"`
+}
+
+// dbEvents is the json array of event types a webhook wants.
+type dbEvents []string
+
+func (e dbEvents) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(e))
+	return string(b), err
+}
+
+func (e *dbEvents) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]string)(e))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]string)(e))
+	}
+	return fmt.Errorf("cannot scan %T into events", src)
+}
@@ -0,0 +1,258 @@
+package webhookdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/webhook"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, hook webhook.Webhook) (webhook.Webhook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-create")
+	defer span.End()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return webhook.Webhook{}, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var id string
+	if err := tx.GetContext(ctx, &id, `SELECT UUID()`); err != nil {
+		log.Default().Error("failed to generate webhook id", zap.Error(err))
+		return webhook.Webhook{}, errors.UnknownError()
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO webhook (id, url, secret, events, owner_id, workspace_id) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, hook.Url, hook.Secret, toDbEvents(hook.Events), hook.OwnerId, hook.WorkspaceId)
+	if err != nil {
+		log.Default().Warn("error creating new webhook in database", zap.Error(err))
+		return webhook.Webhook{}, errors.UnknownError()
+	}
+	v := new(dbWebhook)
+	if err := tx.GetContext(ctx, v, `SELECT * FROM webhook WHERE id=?`, id); err != nil {
+		log.Default().Warn("error reading back new webhook", zap.Error(err))
+		return webhook.Webhook{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit webhook", zap.Error(err))
+		return webhook.Webhook{}, errors.UnknownError()
+	}
+	return toCoreWebhook(*v), nil
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]webhook.Webhook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-getall")
+	defer span.End()
+	var rows []dbWebhook
+	if err := s.db.SelectContext(ctx, &rows, `SELECT * FROM webhook WHERE workspace_id=? ORDER BY date_created`, auth.Workspace(ctx)); err != nil {
+		log.Default().Error("database query failed", zap.Error(err))
+		return nil, errors.ErrorWithCode("internal error", "Could not query for webhooks", 500)
+	}
+	hooks := []webhook.Webhook{}
+	for _, r := range rows {
+		hooks = append(hooks, toCoreWebhook(r))
+	}
+	return hooks, nil
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (webhook.Webhook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-getById")
+	defer span.End()
+	v := new(dbWebhook)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM webhook WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return webhook.Webhook{}, errors.ErrorWithCode("not found", fmt.Sprintf("Webhook with id %s not found", id), 404)
+		}
+		log.Default().Error("unknown error querying webhook by id", zap.Error(err), zap.String("req id", id))
+		return webhook.Webhook{}, errors.UnknownError()
+	}
+	return toCoreWebhook(*v), nil
+}
+
+// Delete removes the webhook. Its deliveries go with it through the foreign key.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-delete")
+	defer span.End()
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhook WHERE id=? AND workspace_id=?`, id, auth.Workspace(ctx))
+	if err != nil {
+		log.Default().Error("error deleting webhook", zap.Error(err), zap.String("id", id))
+		return errors.UnknownError()
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrorWithCode("not found", fmt.Sprintf("Webhook with id %s not found", id), 404)
+	}
+	return nil
+}
+
+func (s *Store) Enqueue(ctx context.Context, deliveries []webhook.Delivery) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-enqueue")
+	defer span.End()
+	if len(deliveries) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(deliveries))
+	args := make([]any, 0, 6*len(deliveries))
+	workspace := auth.Workspace(ctx)
+	for _, d := range deliveries {
+		var eventId *string
+		if d.EventId != "" {
+			id := d.EventId
+			eventId = &id
+		}
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, d.WebhookId, eventId, string(d.Event), string(d.Payload), d.NextAttempt, workspace)
+	}
+	//IGNORE skips anything already queued for the same event (see the unique key on webhook_id, event_id), and
+	//anything for a webhook deleted in the meantime
+	query := `INSERT IGNORE INTO webhook_delivery (webhook_id, event_id, event, payload, next_attempt, workspace_id) VALUES ` + strings.Join(values, ", ")
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		log.Default().Error("error queueing webhook deliveries", zap.Error(err))
+		return errors.UnknownError()
+	}
+	return nil
+}
+
+// Claim locks the due rows with SKIP LOCKED so other replicas claiming at the same time get different ones, then
+// pushes them back by the lease before letting go. Due deliveries are claimed whatever their workspace, see
+// webhook.Storer.
+func (s *Store) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-claim")
+	defer span.End()
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return nil, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var rows []dbDelivery
+	err = tx.SelectContext(ctx, &rows, `SELECT * FROM webhook_delivery WHERE status = ? AND next_attempt <= ? ORDER BY next_attempt LIMIT ? FOR UPDATE SKIP LOCKED`,
+		string(webhook.StatusPending), now, limit)
+	if err != nil {
+		log.Default().Error("failed to select due webhook deliveries", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.Id)
+	}
+	query, args, err := sqlx.In(`UPDATE webhook_delivery SET next_attempt = ? WHERE id IN (?)`, now.Add(lease), ids)
+	if err != nil {
+		log.Default().Error("failed to build claim query", zap.Error(err))
+		return nil, errors.InternalError()
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		log.Default().Error("failed to lease webhook deliveries", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit webhook claim", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	deliveries := make([]webhook.Delivery, 0, len(rows))
+	for _, r := range rows {
+		deliveries = append(deliveries, toCoreDelivery(r))
+	}
+	return deliveries, nil
+}
+
+func (s *Store) SaveAttempt(ctx context.Context, d webhook.Delivery) error {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-save-attempt")
+	defer span.End()
+	var lastError *string
+	if d.LastError != "" {
+		lastError = &d.LastError
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE webhook_delivery SET status = ?, attempts = ?, next_attempt = ?, last_attempt = ?, last_status = ?, last_error = ? WHERE id = ? AND workspace_id = ?`,
+		string(d.Status), d.Attempts, d.NextAttempt, d.LastAttempt, d.LastStatus, lastError, d.Id, auth.Workspace(ctx))
+	if err != nil {
+		log.Default().Error("error saving webhook delivery attempt", zap.Error(err), zap.String("id", d.Id))
+		return errors.UnknownError()
+	}
+	return nil
+}
+
+func (s *Store) Deliveries(ctx context.Context, webhookId string, limit int) ([]webhook.Delivery, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-webhook-deliveries")
+	defer span.End()
+	var rows []dbDelivery
+	err := s.db.SelectContext(ctx, &rows, `SELECT * FROM webhook_delivery WHERE webhook_id = ? AND workspace_id = ? ORDER BY date_created DESC LIMIT ?`,
+		webhookId, auth.Workspace(ctx), limit)
+	if err != nil {
+		log.Default().Error("database query failed", zap.Error(err))
+		return nil, errors.ErrorWithCode("internal error", "Could not query for webhook deliveries", 500)
+	}
+	deliveries := []webhook.Delivery{}
+	for _, r := range rows {
+		deliveries = append(deliveries, toCoreDelivery(r))
+	}
+	return deliveries, nil
+}
+
+func toDbEvents(events []todoitem.EventType) dbEvents {
+	e := dbEvents{}
+	for _, v := range events {
+		e = append(e, string(v))
+	}
+	return e
+}
+
+func toCoreWebhook(h dbWebhook) webhook.Webhook {
+	events := []todoitem.EventType{}
+	for _, e := range h.Events {
+		events = append(events, todoitem.EventType(e))
+	}
+	return webhook.Webhook{
+		Id:          &h.Id,
+		Url:         &h.Url,
+		Secret:      &h.Secret,
+		Events:      events,
+		Created:     &h.DateCreated,
+		OwnerId:     h.OwnerId,
+		WorkspaceId: &h.WorkspaceId,
+	}
+}
+
+func toCoreDelivery(d dbDelivery) webhook.Delivery {
+	del := webhook.Delivery{
+		Id:          d.Id,
+		WebhookId:   d.WebhookId,
+		Event:       todoitem.EventType(d.Event),
+		Payload:     d.Payload,
+		Status:      webhook.DeliveryStatus(d.Status),
+		Attempts:    d.Attempts,
+		NextAttempt: d.NextAttempt,
+		LastAttempt: d.LastAttempt,
+		LastStatus:  d.LastStatus,
+		Created:     d.DateCreated,
+		WorkspaceId: d.WorkspaceId,
+	}
+	if d.LastError != nil {
+		del.LastError = *d.LastError
+	}
+	if d.EventId != nil {
+		del.EventId = *d.EventId
+	}
+	return del
+}
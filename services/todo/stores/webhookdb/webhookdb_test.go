@@ -0,0 +1,178 @@
+package webhookdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/todoitem"
+	"github.com/stumacwastaken/todo/webhook"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 12, time.UTC)
+
+var deliveryColumns = []string{"id", "webhook_id", "event_id", "event", "payload", "status", "attempts", "next_attempt", "last_attempt", "last_status", "last_error", "date_created", "workspace_id"}
+
+func newMock(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	return NewStore(sqlx.NewDb(mockDB, "sqlmock")), mock
+}
+
+func TestCreate(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT UUID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"UUID()"}).AddRow("1111"))
+	mock.ExpectExec(`INSERT INTO webhook \(id, url, secret, events, owner_id, workspace_id\) VALUES \(\?, \?, \?, \?, \?, \?\)`).
+		WithArgs("1111", "https://example.com", "0123456789abcdef", `["created","deleted"]`, "sam", "ops").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM webhook WHERE id=\?`).WithArgs("1111").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "secret", "events", "date_created", "owner_id", "workspace_id"}).
+			AddRow("1111", "https://example.com", "0123456789abcdef", []byte(`["created","deleted"]`), testTime, "sam", "ops"))
+	mock.ExpectCommit()
+
+	val, err := store.Create(context.Background(), webhook.Webhook{
+		Url: newString("https://example.com"), Secret: newString("0123456789abcdef"),
+		Events: []todoitem.EventType{todoitem.EventCreated, todoitem.EventDeleted}, OwnerId: newString("sam"), WorkspaceId: newString("ops"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, webhook.Webhook{
+		Id: newString("1111"), Url: newString("https://example.com"), Secret: newString("0123456789abcdef"),
+		Events: []todoitem.EventType{todoitem.EventCreated, todoitem.EventDeleted}, Created: &testTime, OwnerId: newString("sam"),
+		WorkspaceId: newString("ops"),
+	}, val)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetById(t *testing.T) {
+	type test struct {
+		name      string
+		expectErr error
+		mockErr   error
+	}
+	tests := []test{
+		{name: "happy path"},
+		{name: "no rows found", mockErr: sql.ErrNoRows, expectErr: terr.ErrorWithCode("not found", "Webhook with id 1111 not found", 404)},
+		{name: "unknown error", mockErr: errors.New("some random mysql error"), expectErr: terr.UnknownError()},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			store, mock := newMock(t)
+			query := mock.ExpectQuery(`SELECT \* FROM webhook WHERE id=\? AND workspace_id=\?`).WithArgs("1111", "default")
+			if tt.mockErr != nil {
+				query.WillReturnError(tt.mockErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"id", "url", "secret", "events", "date_created"}).
+					AddRow("1111", "https://example.com", "0123456789abcdef", []byte(`["created"]`), testTime))
+			}
+			val, err := store.GetById(context.Background(), "1111")
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr == nil {
+				assert.Equal(t, []todoitem.EventType{todoitem.EventCreated}, val.Events)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectQuery(`SELECT \* FROM webhook WHERE workspace_id=\? ORDER BY date_created`).WithArgs("ops").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "secret", "events", "date_created", "workspace_id"}).
+			AddRow("1111", "https://example.com", "0123456789abcdef", []byte(`["created"]`), testTime, "ops"))
+	val, err := store.GetAll(auth.WithWorkspace(context.Background(), "ops"))
+	assert.Nil(t, err)
+	if assert.Len(t, val, 1) {
+		assert.Equal(t, newString("ops"), val[0].WorkspaceId)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestEnqueue(t *testing.T) {
+	store, mock := newMock(t)
+	payload := json.RawMessage(`{"type":"created"}`)
+	mock.ExpectExec(`INSERT IGNORE INTO webhook_delivery \(webhook_id, event_id, event, payload, next_attempt, workspace_id\) VALUES \(\?, \?, \?, \?, \?, \?\), \(\?, \?, \?, \?, \?, \?\)`).
+		WithArgs("1111", "e1", "created", `{"type":"created"}`, testTime, "ops", "2222", nil, "created", `{"type":"created"}`, testTime, "ops").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	err := store.Enqueue(auth.WithWorkspace(context.Background(), "ops"), []webhook.Delivery{
+		{WebhookId: "1111", EventId: "e1", Event: todoitem.EventCreated, Payload: payload, NextAttempt: &testTime},
+		{WebhookId: "2222", Event: todoitem.EventCreated, Payload: payload, NextAttempt: &testTime},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestClaim(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM webhook_delivery WHERE status = \? AND next_attempt <= \? ORDER BY next_attempt LIMIT \? FOR UPDATE SKIP LOCKED`).
+		WithArgs("pending", testTime, 20).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns).
+			AddRow("d1", "1111", nil, "created", []byte(`{}`), "pending", 0, testTime, nil, 0, nil, testTime, "default").
+			AddRow("d2", "3333", "e2", "deleted", []byte(`{}`), "pending", 2, testTime, testTime, 500, "receiver answered 500", testTime, "ops"))
+	mock.ExpectExec(`UPDATE webhook_delivery SET next_attempt = \? WHERE id IN \(\?, \?\)`).
+		WithArgs(testTime.Add(time.Minute), "d1", "d2").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	due, err := store.Claim(context.Background(), testTime, time.Minute, 20)
+	assert.Nil(t, err)
+	if assert.Len(t, due, 2) {
+		assert.Equal(t, webhook.StatusPending, due[0].Status)
+		assert.Nil(t, due[0].LastAttempt)
+		assert.Equal(t, "receiver answered 500", due[1].LastError)
+		assert.Equal(t, 2, due[1].Attempts)
+		assert.Equal(t, "e2", due[1].EventId)
+		assert.Equal(t, "ops", due[1].WorkspaceId)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestClaimNothingDue(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM webhook_delivery WHERE`).WillReturnRows(sqlmock.NewRows(deliveryColumns))
+	mock.ExpectRollback()
+	due, err := store.Claim(context.Background(), testTime, time.Minute, 20)
+	assert.Nil(t, err)
+	assert.Empty(t, due)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestSaveAttempt(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectExec(`UPDATE webhook_delivery SET status = \?, attempts = \?, next_attempt = \?, last_attempt = \?, last_status = \?, last_error = \? WHERE id = \? AND workspace_id = \?`).
+		WithArgs("delivered", 3, nil, testTime, 200, nil, "d1", "ops").WillReturnResult(sqlmock.NewResult(0, 1))
+	err := store.SaveAttempt(auth.WithWorkspace(context.Background(), "ops"), webhook.Delivery{
+		Id: "d1", Status: webhook.StatusDelivered, Attempts: 3, LastAttempt: &testTime, LastStatus: 200,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveries(t *testing.T) {
+	store, mock := newMock(t)
+	mock.ExpectQuery(`SELECT \* FROM webhook_delivery WHERE webhook_id = \? AND workspace_id = \? ORDER BY date_created DESC LIMIT \?`).
+		WithArgs("1111", "ops", 10).
+		WillReturnRows(sqlmock.NewRows(deliveryColumns).
+			AddRow("d1", "1111", nil, "created", []byte(`{}`), "delivered", 1, nil, testTime, 200, nil, testTime, "ops"))
+	val, err := store.Deliveries(auth.WithWorkspace(context.Background(), "ops"), "1111", 10)
+	assert.Nil(t, err)
+	if assert.Len(t, val, 1) {
+		assert.Equal(t, webhook.StatusDelivered, val[0].Status)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
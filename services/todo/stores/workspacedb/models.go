@@ -0,0 +1,9 @@
+package workspacedb
+
+import "time"
+
+type dbWorkspace struct {
+	Id          string    `db:"id"`
+	Name        string    `db:"name"`
+	DateCreated time.Time `db:"date_created"`
+}
@@ -0,0 +1,91 @@
+package workspacedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/tracing"
+	"github.com/stumacwastaken/todo/workspace"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *sqlx.DB
+}
+
+func NewStore(db *sqlx.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) Create(ctx context.Context, w workspace.Workspace) (workspace.Workspace, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-workspace-create")
+	defer span.End()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		log.Default().Error("failed to start transaction", zap.Error(err))
+		return workspace.Workspace{}, errors.InternalError()
+	}
+	defer tx.Rollback()
+	var id string
+	if err := tx.GetContext(ctx, &id, `SELECT UUID()`); err != nil {
+		log.Default().Error("failed to generate workspace id", zap.Error(err))
+		return workspace.Workspace{}, errors.UnknownError()
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO workspace (id, name) VALUES (?, ?)`, id, w.Name); err != nil {
+		log.Default().Warn("error creating new workspace in database", zap.Error(err))
+		return workspace.Workspace{}, errors.UnknownError()
+	}
+	v := new(dbWorkspace)
+	if err := tx.GetContext(ctx, v, `SELECT * FROM workspace WHERE id=?`, id); err != nil {
+		log.Default().Warn("error reading back new workspace", zap.Error(err))
+		return workspace.Workspace{}, errors.UnknownError()
+	}
+	if err := tx.Commit(); err != nil {
+		log.Default().Error("failed to commit workspace", zap.Error(err))
+		return workspace.Workspace{}, errors.UnknownError()
+	}
+	return toCoreWorkspace(*v), nil
+}
+
+func (s *Store) GetById(ctx context.Context, id string) (workspace.Workspace, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-workspace-getById")
+	defer span.End()
+	v := new(dbWorkspace)
+	if err := s.db.GetContext(ctx, v, `SELECT * FROM workspace WHERE id=?`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return workspace.Workspace{}, errors.ErrorWithCode("not found", fmt.Sprintf("Workspace with id %s not found", id), 404)
+		}
+		log.Default().Error("unknown error querying workspace by id", zap.Error(err), zap.String("req id", id))
+		return workspace.Workspace{}, errors.UnknownError()
+	}
+	return toCoreWorkspace(*v), nil
+}
+
+func (s *Store) GetAll(ctx context.Context) ([]workspace.Workspace, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "store-workspace-getAll")
+	defer span.End()
+	rows := []dbWorkspace{}
+	if err := s.db.SelectContext(ctx, &rows, `SELECT * FROM workspace ORDER BY date_created`); err != nil {
+		log.Default().Error("unknown error querying workspaces", zap.Error(err))
+		return nil, errors.UnknownError()
+	}
+	workspaces := make([]workspace.Workspace, 0, len(rows))
+	for _, r := range rows {
+		workspaces = append(workspaces, toCoreWorkspace(r))
+	}
+	return workspaces, nil
+}
+
+func toCoreWorkspace(w dbWorkspace) workspace.Workspace {
+	return workspace.Workspace{
+		Id:      &w.Id,
+		Name:    &w.Name,
+		Created: &w.DateCreated,
+	}
+}
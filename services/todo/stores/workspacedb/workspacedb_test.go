@@ -0,0 +1,50 @@
+package workspacedb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/workspace"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+var testTime = time.Date(2023, time.January, 12, 12, 12, 12, 12, time.Local)
+
+func newStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	return NewStore(sqlx.NewDb(mockDB, "sqlmock")), mock
+}
+
+func TestCreate(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT UUID\(\)`).WillReturnRows(sqlmock.NewRows([]string{"UUID()"}).AddRow("1111"))
+	mock.ExpectExec(`INSERT INTO workspace \(id, name\) VALUES \(\?, \?\)`).WithArgs("1111", "Ops").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM workspace WHERE id=\?`).WithArgs("1111").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_created"}).AddRow("1111", "Ops", testTime))
+	mock.ExpectCommit()
+	val, err := store.Create(context.Background(), workspace.Workspace{Name: newString("Ops")})
+	assert.Nil(t, err)
+	assert.Equal(t, workspace.Workspace{Id: newString("1111"), Name: newString("Ops"), Created: &testTime}, val)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetById(t *testing.T) {
+	store, mock := newStore(t)
+	mock.ExpectQuery(`SELECT \* FROM workspace WHERE id=\?`).WithArgs("nope").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "date_created"}))
+	_, err := store.GetById(context.Background(), "nope")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Workspace with id nope not found", 404), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
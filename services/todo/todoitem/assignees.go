@@ -0,0 +1,97 @@
+package todoitem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/member"
+)
+
+// assigneeField finds the items assigned to a user. Like ownerField it's left out of FilterSchema, Assigned is how
+// it's asked for.
+var assigneeField = filter.Field{Name: "assignee", Kind: filter.Set}
+
+// Assigned returns the items assigned to a user that match a filter language expression, the same way Find does.
+// member.Me is whoever is asking.
+func (c *Core) Assigned(ctx context.Context, assignee, expr string) ([]TodoItem, error) {
+	if assignee == member.Me {
+		p, ok := auth.FromContext(ctx)
+		if !ok {
+			return nil, terr.ErrorWithCode("unauthorized", "log in to see what's assigned to you", 401)
+		}
+		assignee = p.UserId
+	}
+	pred, err := c.Predicate(expr)
+	if err != nil {
+		return nil, err
+	}
+	pred, err = c.scoped(ctx, pred.And(filter.Cond{Field: assigneeField, Op: filter.Contains, Value: assignee}))
+	if err != nil {
+		return nil, err
+	}
+	return c.storer.Find(ctx, pred)
+}
+
+// assignees checks everyone assigned to an item in a list can see it, the list's owner or someone it's been shared
+// with. Repeats are dropped. Items without an owner aren't in a list, so can't be assigned to anyone.
+func (c *Core) assignees(ctx context.Context, listId *string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return ids, nil
+	}
+	if listId == nil {
+		return nil, terr.ErrorWithCode("invalid param", "only items in a list can be assigned", 400)
+	}
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return nil, terr.ErrorWithCode("invalid param", "assignee ids cannot be empty", 400)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id == *listId {
+			unique = append(unique, id)
+			continue
+		}
+		var role member.Role
+		if c.members != nil {
+			r, err := c.members.Role(ctx, *listId, id)
+			if err != nil {
+				return nil, asTodoError(err)
+			}
+			role = r
+		}
+		if role == "" {
+			return nil, terr.ErrorWithCode("invalid param", fmt.Sprintf("%s isn't a member of list %s", id, *listId), 400)
+		}
+		unique = append(unique, id)
+	}
+	return unique, nil
+}
+
+// NewAssignees is who saved is assigned to that old wasn't, the people to tell about it.
+func NewAssignees(old, saved TodoItem) []string {
+	var added []string
+	for _, id := range saved.AssigneeIds {
+		if !contains(old.AssigneeIds, id) {
+			added = append(added, id)
+		}
+	}
+	return added
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package todoitem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/member"
+)
+
+// assignStorer has the one item, which updates change.
+type assignStorer struct {
+	scopeStorer
+	item TodoItem
+}
+
+func (s *assignStorer) GetById(ctx context.Context, id string) (TodoItem, error) {
+	return s.item, nil
+}
+
+func (s *assignStorer) Update(ctx context.Context, item TodoItem) (TodoItem, error) {
+	s.item = item
+	return item, nil
+}
+
+func TestAssignees(t *testing.T) {
+	store := &assignStorer{item: TodoItem{Id: newId("3333"), Summary: newSummary("sam's item"), OwnerId: newId("sam")}}
+	core := NewCore(store)
+	core.ShareWith(sharedLists{"sam": {"alex": member.RoleViewer}})
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+
+	type test struct {
+		name      string
+		ctx       context.Context
+		assignees []string
+		expect    []string
+		err       error
+	}
+	tests := []test{
+		{name: "a member", ctx: sam, assignees: []string{"alex"}, expect: []string{"alex"}},
+		{name: "the list's owner", ctx: sam, assignees: []string{"sam"}, expect: []string{"sam"}},
+		{name: "repeats are dropped", ctx: sam, assignees: []string{"alex", "sam", "alex"}, expect: []string{"alex", "sam"}},
+		{name: "nobody", ctx: sam, assignees: []string{}, expect: []string{}},
+		{name: "not a member", ctx: sam, assignees: []string{"kim"},
+			err: terr.ErrorWithCode("invalid param", "kim isn't a member of list sam", 400)},
+		{name: "empty id", ctx: sam, assignees: []string{" "},
+			err: terr.ErrorWithCode("invalid param", "assignee ids cannot be empty", 400)},
+		{name: "not in a list", ctx: context.Background(), assignees: []string{"alex"},
+			err: terr.ErrorWithCode("invalid param", "only items in a list can be assigned", 400)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			_, err := core.Create(tt.ctx, TodoItem{Summary: newSummary("new"), AssigneeIds: tt.assignees})
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, tt.expect, store.created.AssigneeIds)
+			}
+
+			_, err = core.Update(tt.ctx, TodoItem{Summary: newSummary("changed"), AssigneeIds: tt.assignees}, "3333")
+			if tt.ctx == sam {
+				assert.Equal(t, tt.err, err, "updates are checked the same way")
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestAssignedEvents(t *testing.T) {
+	store := &assignStorer{}
+	pub := &recordingPublisher{}
+	core := NewCore(store, pub)
+	core.ShareWith(sharedLists{"sam": {"alex": member.RoleEditor, "jo": member.RoleViewer}})
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+
+	created, err := core.Create(sam, TodoItem{Summary: newSummary("new"), AssigneeIds: []string{"alex"}})
+	assert.Nil(t, err)
+	if assert.Len(t, pub.events, 2) {
+		assert.Equal(t, EventCreated, pub.events[0].Type)
+		assert.Equal(t, EventAssigned, pub.events[1].Type)
+		assert.Equal(t, []string{"alex"}, pub.events[1].Assignees)
+	}
+
+	store.item = created
+	pub.events = nil
+	_, err = core.Update(sam, TodoItem{Summary: newSummary("new"), AssigneeIds: []string{"alex", "jo"}}, *created.Id)
+	assert.Nil(t, err)
+	if assert.Len(t, pub.events, 2) {
+		assert.Equal(t, EventUpdated, pub.events[0].Type)
+		assert.Equal(t, []string{"jo"}, pub.events[1].Assignees, "only the newly assigned are told")
+	}
+
+	pub.events = nil
+	_, err = core.Update(sam, TodoItem{Summary: newSummary("changed")}, *created.Id)
+	assert.Nil(t, err)
+	assert.Len(t, pub.events, 1, "no one new to tell")
+
+	assigned := Event{Type: EventAssigned, Item: created, Assignees: []string{"jo"}}
+	assert.True(t, assigned.VisibleTo(newId("jo")))
+	assert.False(t, assigned.VisibleTo(newId("sam")), "the owner already hears about the change itself")
+	assert.True(t, assigned.VisibleTo(nil))
+	aud, err := core.Audience(auth.WithPrincipal(context.Background(), auth.Principal{UserId: "jo"}))
+	assert.Nil(t, err)
+	assert.True(t, aud.Sees(assigned))
+}
+
+func TestAssigned(t *testing.T) {
+	store := &scopeStorer{}
+	core := NewCore(store)
+	core.ShareWith(sharedLists{"sam": {"alex": member.RoleEditor}})
+	alex := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "alex"})
+
+	_, err := core.Assigned(alex, member.Me, "milk")
+	assert.Nil(t, err)
+	assert.True(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), Deleted: newBool(false), OwnerId: newId("sam"),
+		AssigneeIds: []string{"alex"}}))
+	assert.False(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), Deleted: newBool(false), OwnerId: newId("sam"),
+		AssigneeIds: []string{"sam"}}), "assigned to someone else")
+	assert.False(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), Deleted: newBool(false), OwnerId: newId("kim"),
+		AssigneeIds: []string{"alex"}}), "in a list alex can't see")
+
+	_, err = core.Assigned(context.Background(), member.Me, "")
+	assert.Equal(t, terr.ErrorWithCode("unauthorized", "log in to see what's assigned to you", 401), err)
+}
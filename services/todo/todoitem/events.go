@@ -0,0 +1,84 @@
+package todoitem
+
+import (
+	"context"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+)
+
+type EventType string
+
+const (
+	EventCreated   EventType = "created"
+	EventUpdated   EventType = "updated"
+	EventCompleted EventType = "completed"
+	EventDeleted   EventType = "deleted"
+	//EventAssigned tells the people in Assignees an item was given to them. It follows the created or updated event
+	//for the same change, and is only visible to them.
+	EventAssigned EventType = "assigned"
+)
+
+// Event describes a change the core made to a todo item. Item is the item as it was saved.
+type Event struct {
+	Type EventType `json:"type"`
+	Item TodoItem  `json:"item"`
+	Time time.Time `json:"time"`
+	//Workspace is where the change happened, only requests in the same workspace hear about it. Empty is the default
+	//workspace.
+	Workspace string `json:"-"`
+	//Assignees are who was just assigned the item, only set on EventAssigned.
+	Assignees []string `json:"assignees,omitempty"`
+}
+
+// InWorkspace reports if the event happened in a workspace.
+func (e Event) InWorkspace(id string) bool {
+	if e.Workspace == "" {
+		return id == auth.DefaultWorkspace
+	}
+	return e.Workspace == id
+}
+
+// VisibleTo reports if a user should hear about the event, see VisibleTo. An assignment is for the people assigned
+// rather than the item's owner.
+func (e Event) VisibleTo(userId *string) bool {
+	if e.Type == EventAssigned {
+		return userId == nil || contains(e.Assignees, *userId)
+	}
+	return VisibleTo(userId, e.Item)
+}
+
+// Publisher is anything that wants to hear about changes, i.e: the events hub behind the change feed. Publish is
+// called inline with the request that made the change, so it should hand work off (i.e: queue a webhook delivery)
+// rather than do it.
+type Publisher interface {
+	Publish(context.Context, Event)
+}
+
+func (c *Core) publish(ctx context.Context, t EventType, item TodoItem, assignees ...string) {
+	e := Event{Type: t, Item: item, Time: dateUpdateFn(), Workspace: auth.Workspace(ctx), Assignees: assignees}
+	for _, p := range c.publishers {
+		p.Publish(ctx, e)
+	}
+}
+
+// publishAssigned tells anyone saved was just assigned to, if there is anyone.
+func (c *Core) publishAssigned(ctx context.Context, old, saved TodoItem) {
+	if added := NewAssignees(old, saved); len(added) > 0 {
+		c.publish(ctx, EventAssigned, saved, added...)
+	}
+}
+
+// EventFor works out what kind of change an update was. Deleting or completing an item wins over any other edits
+// made at the same time.
+func EventFor(old, saved TodoItem) EventType {
+	wasDeleted := old.Deleted != nil && *old.Deleted
+	wasCompleted := old.Completed != nil && *old.Completed
+	switch {
+	case !wasDeleted && saved.Deleted != nil && *saved.Deleted:
+		return EventDeleted
+	case !wasCompleted && saved.Completed != nil && *saved.Completed:
+		return EventCompleted
+	}
+	return EventUpdated
+}
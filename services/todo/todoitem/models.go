@@ -1,12 +1,113 @@
 package todoitem
 
-import "time"
+import (
+	"time"
+
+	"github.com/stumacwastaken/todo/filter"
+)
 
 type TodoItem struct {
 	Id        *string    `json:"id,omitempty"`
 	Created   *time.Time `json:"created,omitempty"`
-	Updated   *time.Time `json:"updatedomitempty"`
+	Updated   *time.Time `json:"updated,omitempty"`
 	Deleted   *bool      `json:"deleted,omitempty"`
 	Completed *bool      `json:"completed,omitempty"`
 	Summary   *string    `json:"summary,omitempty"`
+	Priority  *Priority  `json:"priority,omitempty"`
+	Due       *time.Time `json:"due,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	//CompletedAt is set by the core when an item is completed, and cleared if it's reopened.
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	//Version is set by the store on every change and only ever goes up, across all items. See Changes.
+	Version *int64 `json:"version,omitempty"`
+	//OwnerId is the user the item belongs to, whoever created it unless they made it in a list shared with them. It
+	//can't be changed through the core after that.
+	OwnerId *string `json:"ownerId,omitempty"`
+	//AssigneeIds are the users doing the item, who have to be able to see the list it's in. An empty list unassigns
+	//everyone.
+	AssigneeIds []string `json:"assigneeIds,omitempty"`
+}
+
+// Priority is stored by its rank (see Rank) so it can be compared and sorted on, but is always named in the api.
+type Priority string
+
+const (
+	PriorityNone   Priority = "none"
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+)
+
+// ordered lowest to highest. Don't reorder these, the index is what ends up in the database.
+var priorities = []Priority{PriorityNone, PriorityLow, PriorityMedium, PriorityHigh}
+
+// Rank returns the position of the priority from lowest to highest, or -1 if it isn't a known priority.
+func (p Priority) Rank() int {
+	for i, v := range priorities {
+		if v == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// PriorityFromRank is the inverse of Rank. Unknown ranks fall back to PriorityNone.
+func PriorityFromRank(rank int) Priority {
+	if rank < 0 || rank >= len(priorities) {
+		return PriorityNone
+	}
+	return priorities[rank]
+}
+
+// FilterSchema lists the fields todo items can be filtered on with the filter language. Bare words search summaries.
+var FilterSchema = filter.Schema{
+	Default: "summary",
+	Fields: []filter.Field{
+		{Name: "summary", Kind: filter.Text},
+		{Name: "completed", Kind: filter.Bool},
+		{Name: "deleted", Kind: filter.Bool},
+		{Name: "priority", Kind: filter.Enum, Values: []string{
+			string(PriorityNone), string(PriorityLow), string(PriorityMedium), string(PriorityHigh),
+		}},
+		{Name: "tag", Kind: filter.Set},
+		{Name: "due", Kind: filter.Time},
+		{Name: "created", Kind: filter.Time},
+		{Name: "updated", Kind: filter.Time},
+	},
+}
+
+// FilterValue lets a TodoItem be matched in memory against a filter.Predicate.
+func (t TodoItem) FilterValue(field string) any {
+	switch field {
+	case "summary":
+		if t.Summary == nil {
+			return ""
+		}
+		return *t.Summary
+	case "completed":
+		return t.Completed != nil && *t.Completed
+	case "deleted":
+		return t.Deleted != nil && *t.Deleted
+	case "priority":
+		if t.Priority == nil {
+			return 0
+		}
+		return t.Priority.Rank()
+	case "tag":
+		return t.Tags
+	case "due":
+		return t.Due
+	case "created":
+		return t.Created
+	case "updated":
+		return t.Updated
+	case "owner":
+		if t.OwnerId == nil {
+			return ""
+		}
+		return *t.OwnerId
+	case "assignee":
+		return t.AssigneeIds
+	}
+	return nil
 }
@@ -0,0 +1,177 @@
+package todoitem
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/member"
+)
+
+// ownerField scopes queries to a user. It's left out of FilterSchema, so it can't be written in an expression.
+var ownerField = filter.Field{Name: "owner", Kind: filter.Text}
+
+// scope is the id of the user the request in ctx is scoped to, nil if it's unscoped (see auth.Unscoped). Anonymous
+// requests that aren't unscoped are scoped to nobody, an empty id that owns nothing.
+func scope(ctx context.Context) *string {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		if auth.Unscoped(ctx) {
+			return nil
+		}
+		nobody := ""
+		return &nobody
+	}
+	return &p.UserId
+}
+
+// VisibleTo reports if a user owns an item. A nil user is unscoped and sees everything, items without an owner (made
+// before there were users) are only seen unscoped. Lists shared with the user aren't looked at, see Audience for that.
+func VisibleTo(userId *string, item TodoItem) bool {
+	if userId == nil {
+		return true
+	}
+	return item.OwnerId != nil && *item.OwnerId == *userId
+}
+
+// Audience is who a stream of changes is for and the lists they can see, worked out once when the stream starts
+// rather than for every event. A list shared after that shows up when the client reconnects.
+type Audience struct {
+	workspace string
+	userId    *string
+	lists     []string
+}
+
+// Audience is the audience for the request in ctx, the change feeds use it to pick what to send.
+func (c *Core) Audience(ctx context.Context) (Audience, error) {
+	lists, err := c.lists(ctx)
+	if err != nil {
+		return Audience{}, err
+	}
+	return Audience{workspace: auth.Workspace(ctx), userId: scope(ctx), lists: lists}, nil
+}
+
+// Sees reports if the audience should hear about an event: it happened in their workspace to an item in one of their
+// lists. An assignment is only for the people assigned, see Event.VisibleTo.
+func (a Audience) Sees(e Event) bool {
+	if !e.InWorkspace(a.workspace) {
+		return false
+	}
+	if a.userId == nil || e.Type == EventAssigned {
+		return e.VisibleTo(a.userId)
+	}
+	return e.Item.OwnerId != nil && contains(a.lists, *e.Item.OwnerId)
+}
+
+// lists is the ids of the lists the request in ctx can see, its own and any shared with it. It's nil if the request
+// is unscoped and empty for nobody.
+func (c *Core) lists(ctx context.Context) ([]string, error) {
+	owner := scope(ctx)
+	switch {
+	case owner == nil:
+		return nil, nil
+	case *owner == "":
+		return []string{}, nil
+	case c.members == nil:
+		return []string{*owner}, nil
+	}
+	lists, err := c.members.Lists(ctx, *owner)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	return lists, nil
+}
+
+// scoped limits a predicate to the items the request in ctx can see, its own and any lists shared with it.
+func (c *Core) scoped(ctx context.Context, pred filter.Predicate) (filter.Predicate, error) {
+	lists, err := c.lists(ctx)
+	if err != nil {
+		return filter.Predicate{}, err
+	}
+	if lists == nil {
+		return pred, nil
+	}
+	//an empty In is nobody, which an item without an owner would otherwise match as an empty owner
+	return pred.And(filter.Cond{Field: ownerField, Op: filter.In, Value: lists}), nil
+}
+
+// role is what the request in ctx can do with an item. Unscoped requests can do anything, and items without an owner
+// are only seen unscoped.
+func (c *Core) role(ctx context.Context, item TodoItem) (member.Role, error) {
+	userId := scope(ctx)
+	switch {
+	case userId == nil:
+		return member.RoleOwner, nil
+	case item.OwnerId == nil:
+		return "", nil
+	case *item.OwnerId == *userId:
+		return member.RoleOwner, nil
+	case c.members == nil:
+		return "", nil
+	}
+	role, err := c.members.Role(ctx, *item.OwnerId, *userId)
+	if err != nil {
+		return "", asTodoError(err)
+	}
+	return role, nil
+}
+
+// owner is who a new item belongs to: whoever made it, or the list it was asked to be made in if they're an editor
+// of it. Without sharing, what was asked for is ignored.
+func (c *Core) owner(ctx context.Context, listId *string) (*string, error) {
+	userId := scope(ctx)
+	if userId == nil || c.members == nil || listId == nil || *listId == *userId {
+		return userId, nil
+	}
+	role, err := c.role(ctx, TodoItem{OwnerId: listId})
+	if err != nil {
+		return nil, err
+	}
+	if !role.Allows(member.RoleEditor) {
+		return nil, terr.ErrorWithCode("forbidden", fmt.Sprintf("can't add items to list %s, it needs a list editor", *listId), 403)
+	}
+	return listId, nil
+}
+
+// get reads an item for the request in ctx. An item in a list the caller can't see is the same 404 as one that
+// doesn't exist, so ids can't be probed to find out what other people have.
+func (c *Core) get(ctx context.Context, id string) (TodoItem, error) {
+	item, _, err := c.getWithRole(ctx, id)
+	return item, err
+}
+
+// edit is get for changing an item, which viewers of a shared list can't do.
+func (c *Core) edit(ctx context.Context, id string) (TodoItem, error) {
+	item, role, err := c.getWithRole(ctx, id)
+	if err != nil {
+		return TodoItem{}, err
+	}
+	if !role.Allows(member.RoleEditor) {
+		return TodoItem{}, member.Forbidden(role, member.RoleEditor)
+	}
+	return item, nil
+}
+
+func (c *Core) getWithRole(ctx context.Context, id string) (TodoItem, member.Role, error) {
+	item, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return TodoItem{}, "", asTodoError(err)
+	}
+	role, err := c.role(ctx, item)
+	if err != nil {
+		return TodoItem{}, "", err
+	}
+	if role == "" {
+		return TodoItem{}, "", terr.ErrorWithCode("not found", fmt.Sprintf("Item with id %s not found", id), 404)
+	}
+	return item, role, nil
+}
+
+func asTodoError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
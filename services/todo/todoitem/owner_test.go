@@ -0,0 +1,255 @@
+package todoitem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/member"
+)
+
+// scopeStorer keeps what the core asked the store for, so tests can check it was scoped.
+type scopeStorer struct {
+	MockStorer
+	created      TodoItem
+	pred         filter.Predicate
+	changesLists []string
+	statsRange   StatsRange
+}
+
+func (s *scopeStorer) Create(ctx context.Context, item TodoItem) (TodoItem, error) {
+	s.created = item
+	item.Id = newId("1111")
+	return item, nil
+}
+
+func (s *scopeStorer) Find(ctx context.Context, pred filter.Predicate) ([]TodoItem, error) {
+	s.pred = pred
+	return nil, nil
+}
+
+func (s *scopeStorer) Changes(ctx context.Context, since int64, limit int, lists []string) ([]TodoItem, error) {
+	s.changesLists = lists
+	return nil, nil
+}
+
+func (s *scopeStorer) Stats(ctx context.Context, r StatsRange) (Stats, error) {
+	s.statsRange = r
+	return Stats{}, nil
+}
+
+func TestVisibleTo(t *testing.T) {
+	type test struct {
+		name   string
+		user   *string
+		owner  *string
+		expect bool
+	}
+	tests := []test{
+		{name: "unscoped sees everything", owner: newId("sam"), expect: true},
+		{name: "unscoped sees unowned items", expect: true},
+		{name: "owner", user: newId("sam"), owner: newId("sam"), expect: true},
+		{name: "someone else", user: newId("alex"), owner: newId("sam"), expect: false},
+		{name: "unowned items are only seen unscoped", user: newId("sam"), expect: false},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			assert.Equal(t, tt.expect, VisibleTo(tt.user, TodoItem{OwnerId: tt.owner}))
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestCrossUserIsNotFound(t *testing.T) {
+	samsItem := TodoItem{Id: newId("3333"), Summary: newSummary("sam's item"), Deleted: newBool(false), OwnerId: newId("sam")}
+	store := &scopeStorer{MockStorer: MockStorer{resp: func(method string) ([]TodoItem, error) {
+		return []TodoItem{samsItem}, nil
+	}}}
+	core := NewCore(store)
+	alex := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "alex"})
+	notFound := terr.ErrorWithCode("not found", "Item with id 3333 not found", 404)
+
+	_, err := core.GetById(alex, "3333")
+	assert.Equal(t, notFound, err, "the same as an item that isn't there")
+	_, err = core.Update(alex, TodoItem{Summary: newSummary("mine now")}, "3333")
+	assert.Equal(t, notFound, err)
+	_, err = core.Delete(alex, "3333")
+	assert.Equal(t, notFound, err)
+	results, err := core.Sync(alex, []Change{{Item: TodoItem{Id: newId("3333"), Summary: newSummary("mine now")}}})
+	assert.Nil(t, err)
+	assert.Equal(t, ChangeRejected, results[0].Status)
+
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+	item, err := core.GetById(sam, "3333")
+	assert.Nil(t, err)
+	assert.Equal(t, samsItem, item)
+	item, err = core.GetById(context.Background(), "3333")
+	assert.Nil(t, err, "unscoped")
+}
+
+func TestQueriesAreScoped(t *testing.T) {
+	store := &scopeStorer{}
+	core := NewCore(store)
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+
+	created, err := core.Create(sam, TodoItem{Summary: newSummary("buy milk"), OwnerId: newId("alex")})
+	assert.Nil(t, err)
+	assert.Equal(t, newId("sam"), store.created.OwnerId, "whoever made it, not what was sent")
+	assert.Equal(t, newId("sam"), created.OwnerId)
+
+	_, err = core.Find(sam, "milk")
+	assert.Nil(t, err)
+	assert.True(t, store.pred.Has("owner"))
+	assert.True(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), OwnerId: newId("sam")}))
+	assert.False(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), OwnerId: newId("alex")}))
+
+	_, err = core.GetAll(sam)
+	assert.Nil(t, err)
+	assert.True(t, store.pred.Has("owner"))
+	assert.True(t, store.pred.Has("deleted"))
+
+	_, err = core.Changes(sam, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sam"}, store.changesLists)
+
+	_, err = core.Stats(sam, time.Now().Add(-time.Hour), time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, newId("sam"), store.statsRange.Owner)
+
+	_, err = core.Compile("owner:alex")
+	assert.NotNil(t, err, "owner can't be filtered on by hand")
+
+	core.Find(context.Background(), "milk")
+	assert.False(t, store.pred.Has("owner"), "unscoped")
+
+	anonymous := auth.Anonymous(context.Background(), false)
+	_, err = core.GetAll(anonymous)
+	assert.Nil(t, err)
+	assert.False(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), Deleted: newBool(false), OwnerId: newId("sam")}),
+		"anonymous requests see nobody's items")
+	assert.False(t, store.pred.Match(TodoItem{Summary: newSummary("buy milk"), Deleted: newBool(false)}))
+	_, err = core.Create(anonymous, TodoItem{Summary: newSummary("buy milk")})
+	assert.Equal(t, terr.ErrorWithCode("unauthorized", "authentication required", 401), err)
+	core.Find(auth.Anonymous(context.Background(), true), "milk")
+	assert.False(t, store.pred.Has("owner"), "unless the server was started open")
+}
+
+// sharedLists is the roles users have in other people's lists, by list then user.
+type sharedLists map[string]map[string]member.Role
+
+func (s sharedLists) Role(ctx context.Context, listId, userId string) (member.Role, error) {
+	if listId == userId {
+		return member.RoleOwner, nil
+	}
+	return s[listId][userId], nil
+}
+
+func (s sharedLists) Lists(ctx context.Context, userId string) ([]string, error) {
+	lists := []string{userId}
+	for list, members := range s {
+		if _, ok := members[userId]; ok {
+			lists = append(lists, list)
+		}
+	}
+	return lists, nil
+}
+
+func TestSharedLists(t *testing.T) {
+	samsItem := TodoItem{Id: newId("3333"), Summary: newSummary("sam's item"), Deleted: newBool(false), OwnerId: newId("sam")}
+	store := &scopeStorer{MockStorer: MockStorer{resp: func(method string) ([]TodoItem, error) {
+		return []TodoItem{samsItem}, nil
+	}}}
+	core := NewCore(store)
+	core.ShareWith(sharedLists{"sam": {"alex": member.RoleViewer, "jo": member.RoleEditor}})
+	as := func(userId string) context.Context {
+		return auth.WithPrincipal(context.Background(), auth.Principal{UserId: userId})
+	}
+	alex, jo, kim := as("alex"), as("jo"), as("kim")
+	viewerErr := member.Forbidden(member.RoleViewer, member.RoleEditor)
+
+	type test struct {
+		name string
+		ctx  context.Context
+		do   func(ctx context.Context) error
+		err  error
+	}
+	update := func(ctx context.Context) error {
+		_, err := core.Update(ctx, TodoItem{Summary: newSummary("changed")}, "3333")
+		return err
+	}
+	create := func(ctx context.Context) error {
+		_, err := core.Create(ctx, TodoItem{Summary: newSummary("new"), OwnerId: newId("sam")})
+		return err
+	}
+	tests := []test{
+		{name: "viewer can read", ctx: alex, do: func(ctx context.Context) error { _, err := core.GetById(ctx, "3333"); return err }},
+		{name: "viewer can't change", ctx: alex, do: update, err: viewerErr},
+		{name: "viewer can't delete", ctx: alex, do: func(ctx context.Context) error { _, err := core.Delete(ctx, "3333"); return err }, err: viewerErr},
+		{name: "viewer can't add", ctx: alex, do: create, err: terr.ErrorWithCode("forbidden", "can't add items to list sam, it needs a list editor", 403)},
+		{name: "editor can change", ctx: jo, do: update},
+		{name: "editor can add", ctx: jo, do: create},
+		{name: "not a member", ctx: kim, do: update, err: terr.ErrorWithCode("not found", "Item with id 3333 not found", 404)},
+		{name: "not a member can't add", ctx: kim, do: create, err: terr.ErrorWithCode("forbidden", "can't add items to list sam, it needs a list editor", 403)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			assert.Equal(t, tt.err, tt.do(tt.ctx))
+		}
+		t.Run(tt.name, tf)
+	}
+
+	res, err := core.Sync(alex, []Change{{Item: TodoItem{Id: newId("3333"), Summary: newSummary("changed")}}})
+	assert.Nil(t, err)
+	assert.Equal(t, ChangeRejected, res[0].Status, "viewers can't sync changes either")
+
+	_, err = core.Create(jo, TodoItem{Summary: newSummary("new"), OwnerId: newId("sam")})
+	assert.Nil(t, err)
+	assert.Equal(t, newId("sam"), store.created.OwnerId, "made in the shared list")
+
+	_, err = core.Find(alex, "item")
+	assert.Nil(t, err)
+	assert.True(t, store.pred.Match(samsItem), "shared lists are found")
+	assert.True(t, store.pred.Match(TodoItem{Summary: newSummary("alex's item"), OwnerId: newId("alex")}))
+	assert.False(t, store.pred.Match(TodoItem{Summary: newSummary("kim's item"), OwnerId: newId("kim")}))
+
+	_, err = core.Changes(alex, "")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"alex", "sam"}, store.changesLists, "shared lists are synced")
+}
+
+func TestAudience(t *testing.T) {
+	core := NewCore(&scopeStorer{})
+	core.ShareWith(sharedLists{"sam": {"jo": member.RoleEditor}})
+	as := func(userId string) context.Context {
+		return auth.WithPrincipal(context.Background(), auth.Principal{UserId: userId})
+	}
+	samsItem := TodoItem{Id: newId("3333"), OwnerId: newId("sam")}
+	type test struct {
+		name   string
+		ctx    context.Context
+		event  Event
+		expect bool
+	}
+	tests := []test{
+		{name: "owner", ctx: as("sam"), event: Event{Type: EventUpdated, Item: samsItem}, expect: true},
+		{name: "editor of a shared list", ctx: as("jo"), event: Event{Type: EventUpdated, Item: samsItem}, expect: true},
+		{name: "not a member", ctx: as("kim"), event: Event{Type: EventUpdated, Item: samsItem}, expect: false},
+		{name: "another workspace", ctx: auth.WithWorkspace(as("sam"), "ops"), event: Event{Type: EventUpdated, Item: samsItem}, expect: false},
+		{name: "assignments are for the assignees", ctx: as("jo"), event: Event{Type: EventAssigned, Item: samsItem, Assignees: []string{"kim"}}, expect: false},
+		{name: "assignee", ctx: as("kim"), event: Event{Type: EventAssigned, Item: samsItem, Assignees: []string{"kim"}}, expect: true},
+		{name: "unscoped", ctx: context.Background(), event: Event{Type: EventUpdated, Item: TodoItem{Id: newId("4444")}}, expect: true},
+		{name: "anonymous", ctx: auth.Anonymous(context.Background(), false), event: Event{Type: EventUpdated, Item: samsItem}, expect: false},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			aud, err := core.Audience(tt.ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expect, aud.Sees(tt.event))
+		}
+		t.Run(tt.name, tf)
+	}
+}
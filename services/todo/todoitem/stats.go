@@ -0,0 +1,96 @@
+package todoitem
+
+import (
+	"context"
+	"time"
+
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// StatsRange is the half open [From, To) window the per day numbers in Stats cover.
+type StatsRange struct {
+	From time.Time
+	To   time.Time
+	//Now decides what counts as overdue
+	Now time.Time
+	//Owner limits the stats to one user's items when set
+	Owner *string
+}
+
+// Stats summarizes the todo list. Open, Completed, Deleted and Overdue are current totals, everything else only
+// covers the requested range.
+type Stats struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Open      int       `json:"open"`
+	Completed int       `json:"completed"`
+	Deleted   int       `json:"deleted"`
+	Overdue   int       `json:"overdue"`
+	//CreatedInRange and CompletedInRange include items that have since been deleted.
+	CreatedInRange   int `json:"createdInRange"`
+	CompletedInRange int `json:"completedInRange"`
+	//AverageSecondsToComplete is worked out over the items completed in range, and is nil if there weren't any.
+	AverageSecondsToComplete *float64 `json:"averageSecondsToComplete"`
+	//WeeklyThroughput is CompletedInRange spread over the weeks in the range.
+	WeeklyThroughput float64    `json:"weeklyThroughput"`
+	Days             []DayStats `json:"days"`
+}
+
+// DayStats are the items created and completed on a given (UTC) day.
+type DayStats struct {
+	Date      string `json:"date"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+}
+
+// DayFormat is the layout of DayStats.Date
+const DayFormat = "2006-01-02"
+
+// MaxStatsRange keeps a single stats request from turning into a table scan over years of data.
+const MaxStatsRange = 366 * 24 * time.Hour
+
+// Stats returns completion stats for the range. Stores only have to return the days that had any activity, the
+// core fills in the gaps so clients always get one entry per day.
+func (c *Core) Stats(ctx context.Context, from, to time.Time) (Stats, error) {
+	from, to = from.UTC(), to.UTC()
+	if !from.Before(to) {
+		return Stats{}, terr.ErrorWithCode("invalid param", "from must be before to", 400)
+	}
+	if to.Sub(from) > MaxStatsRange {
+		return Stats{}, terr.ErrorWithCode("invalid param", "stats range cannot be longer than a year", 400)
+	}
+	stats, err := c.storer.Stats(ctx, StatsRange{From: from, To: to, Now: dateUpdateFn(), Owner: scope(ctx)})
+	if err != nil {
+		if v, ok := err.(*terr.TodoError); ok {
+			return Stats{}, v
+		}
+		return Stats{}, terr.InternalError()
+	}
+	stats.From, stats.To = from, to
+	stats.Days = fillDays(from, to, stats.Days)
+	stats.CreatedInRange, stats.CompletedInRange = 0, 0
+	for _, d := range stats.Days {
+		stats.CreatedInRange += d.Created
+		stats.CompletedInRange += d.Completed
+	}
+	weeks := to.Sub(from).Hours() / (24 * 7)
+	stats.WeeklyThroughput = float64(stats.CompletedInRange) / weeks
+	return stats, nil
+}
+
+func fillDays(from, to time.Time, sparse []DayStats) []DayStats {
+	byDate := map[string]DayStats{}
+	for _, d := range sparse {
+		byDate[d.Date] = d
+	}
+	days := []DayStats{}
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC); day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(DayFormat)
+		d, ok := byDate[date]
+		if !ok {
+			d = DayStats{Date: date}
+		}
+		days = append(days, d)
+	}
+	return days
+}
@@ -0,0 +1,210 @@
+package todoitem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+const (
+	// SyncPageSize is the most items Changes returns at once. Clients keep asking while More is set.
+	SyncPageSize = 500
+	// MaxSyncChanges is the most changes that can be pushed in one go.
+	MaxSyncChanges = 500
+)
+
+// ChangeSet is what's changed since a sync token, deletes included as tombstones (items with deleted set). Token is
+// what to ask with next time.
+type ChangeSet struct {
+	Items []TodoItem `json:"items"`
+	Token string     `json:"token"`
+	More  bool       `json:"more"`
+	//Reset means the lists the caller can see have changed since the token, by joining or leaving a shared one, so the
+	//set starts again from the beginning. The client should drop its copy before taking these items.
+	Reset bool `json:"reset,omitempty"`
+}
+
+// Change is an edit made on a client, possibly a while ago. Items without an id are created, anything else is merged
+// into the item the same way Update does. BaseVersion is the version the client last saw, and Ref is anything the
+// client wants echoed back to match up results (i.e: its own id for an item it created offline).
+type Change struct {
+	Ref         string   `json:"ref,omitempty"`
+	BaseVersion *int64   `json:"baseVersion,omitempty"`
+	Item        TodoItem `json:"item"`
+}
+
+type ChangeStatus string
+
+const (
+	// ChangeApplied means the change was saved.
+	ChangeApplied ChangeStatus = "applied"
+	// ChangeConflict means the item was changed on the server since the client last saw it, and the server's copy
+	// won. The client should take the item in the result.
+	ChangeConflict ChangeStatus = "conflict"
+	// ChangeRejected means the change can never be applied, i.e: it's invalid or the item doesn't exist.
+	ChangeRejected ChangeStatus = "rejected"
+)
+
+// ChangeResult is the outcome of a Change, in the same order they were sent. Item is the server's copy after the
+// change, or the error is set if there isn't one.
+type ChangeResult struct {
+	Ref    string          `json:"ref,omitempty"`
+	Status ChangeStatus    `json:"status"`
+	Item   *TodoItem       `json:"item,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// Changes returns the items changed since token, oldest change first. An empty token starts from the beginning.
+// Tokens are opaque to clients, under the hood they're the highest version seen so far along with a digest of the lists
+// the caller could see. Versions only cover what happened to items, so when the lists have changed since, the items
+// of a list just joined may be older than the token and a list just left sends no deletes. Both start again with Reset.
+func (c *Core) Changes(ctx context.Context, token string) (ChangeSet, error) {
+	lists, err := c.lists(ctx)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	digest := listsDigest(lists)
+	var since int64
+	reset := false
+	if token != "" {
+		version, seen, _ := strings.Cut(token, ".")
+		v, err := strconv.ParseInt(version, 10, 64)
+		if err != nil || v < 0 {
+			return ChangeSet{}, terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid sync token %s", token), 400)
+		}
+		since = v
+		if seen != digest {
+			since, reset = 0, true
+		}
+	}
+	//ask for one more than we need to find out if there's another page
+	items, err := c.storer.Changes(ctx, since, SyncPageSize+1, lists)
+	if err != nil {
+		if v, ok := err.(*terr.TodoError); ok {
+			return ChangeSet{}, v
+		}
+		return ChangeSet{}, terr.InternalError()
+	}
+	set := ChangeSet{Items: items, Token: syncToken(since, digest), Reset: reset}
+	if len(items) > SyncPageSize {
+		set.Items, set.More = items[:SyncPageSize], true
+	}
+	if set.Items == nil {
+		set.Items = []TodoItem{}
+	}
+	if n := len(set.Items); n > 0 && set.Items[n-1].Version != nil {
+		set.Token = syncToken(*set.Items[n-1].Version, digest)
+	}
+	return set, nil
+}
+
+// listsDigest stands in for a set of lists in a sync token, empty for an unscoped caller who sees every list.
+func listsDigest(lists []string) string {
+	if lists == nil {
+		return ""
+	}
+	sorted := append([]string{}, lists...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func syncToken(version int64, digest string) string {
+	if digest == "" {
+		return strconv.FormatInt(version, 10)
+	}
+	return strconv.FormatInt(version, 10) + "." + digest
+}
+
+// Sync applies a batch of client changes in order. A change to an item that's moved on since BaseVersion is a
+// conflict, settled by last write wins: the client's change is applied if its Updated is after the server's,
+// otherwise the server's copy is kept. Changes without a BaseVersion are always settled on Updated.
+func (c *Core) Sync(ctx context.Context, changes []Change) ([]ChangeResult, error) {
+	if len(changes) > MaxSyncChanges {
+		return nil, terr.ErrorWithCode("invalid param", fmt.Sprintf("can't sync more than %d changes at once", MaxSyncChanges), 400)
+	}
+	if len(changes) > 0 {
+		if err := auth.Require(ctx, auth.ScopeWrite); err != nil {
+			return nil, err
+		}
+	}
+	results := make([]ChangeResult, 0, len(changes))
+	for _, ch := range changes {
+		res := c.apply(ctx, ch)
+		res.Ref = ch.Ref
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (c *Core) apply(ctx context.Context, ch Change) ChangeResult {
+	if ch.Item.Id == nil {
+		ch.Item.Version = nil
+		created, err := c.Create(ctx, ch.Item)
+		if err != nil {
+			return rejected(err)
+		}
+		//items are always created open, but one finished while offline has to arrive finished
+		if ch.Item.Completed != nil && *ch.Item.Completed {
+			if created, err = c.save(ctx, created, TodoItem{Completed: ch.Item.Completed}); err != nil {
+				return rejected(err)
+			}
+		}
+		return ChangeResult{Status: ChangeApplied, Item: &created}
+	}
+	if ch.Item.Summary != nil && *ch.Item.Summary == "" {
+		return rejected(terr.ErrorWithCode("bad request", "cannot have empty summary", 400))
+	}
+	if err := validatePriority(ch.Item.Priority); err != nil {
+		return rejected(err)
+	}
+	current, err := c.edit(ctx, *ch.Item.Id)
+	if err != nil {
+		return rejected(err)
+	}
+	if ch.Item.AssigneeIds, err = c.assignees(ctx, current.OwnerId, ch.Item.AssigneeIds); err != nil {
+		return rejected(err)
+	}
+	if !clientWins(ch, current) {
+		return ChangeResult{Status: ChangeConflict, Item: &current}
+	}
+	saved, err := c.save(ctx, current, ch.Item)
+	if err != nil {
+		if v, ok := err.(*terr.TodoError); ok && v.HttpCode == 409 {
+			//changed between reading and saving, whoever did it is newer than anything we were sent
+			if latest, err := c.get(ctx, *ch.Item.Id); err == nil {
+				return ChangeResult{Status: ChangeConflict, Item: &latest}
+			}
+		}
+		return rejected(err)
+	}
+	return ChangeResult{Status: ChangeApplied, Item: &saved}
+}
+
+// clientWins decides whether a change gets applied over the current item.
+func clientWins(ch Change, current TodoItem) bool {
+	if ch.BaseVersion != nil && current.Version != nil && *ch.BaseVersion == *current.Version {
+		return true
+	}
+	if ch.Item.Updated == nil {
+		return false
+	}
+	//ties go to the server, it's the copy everyone else already has
+	return current.Updated == nil || ch.Item.Updated.After(*current.Updated)
+}
+
+func rejected(err error) ChangeResult {
+	v, ok := err.(*terr.TodoError)
+	if !ok {
+		v = terr.InternalError()
+	}
+	return ChangeResult{Status: ChangeRejected, Error: json.RawMessage(v.Error())}
+}
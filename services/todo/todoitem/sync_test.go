@@ -0,0 +1,205 @@
+package todoitem
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/member"
+)
+
+func newVersion(v int64) *int64 {
+	return &v
+}
+
+func TestChanges(t *testing.T) {
+	type test struct {
+		name        string
+		token       string
+		stored      int
+		expectToken string
+		expectMore  bool
+		expectErr   error
+	}
+	tests := []test{
+		{name: "from the start", token: "", stored: 3, expectToken: "3"},
+		{name: "nothing new keeps the token", token: "12", stored: 0, expectToken: "12"},
+		{name: "a page at a time", token: "", stored: SyncPageSize + 1, expectToken: "500", expectMore: true},
+		{name: "bad token", token: "yesterday", expectErr: terr.ErrorWithCode("invalid param", "invalid sync token yesterday", 400)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			mocks := &MockStorer{resp: func(method string) ([]TodoItem, error) {
+				var items []TodoItem
+				for i := 1; i <= tt.stored; i++ {
+					items = append(items, TodoItem{Id: newId("1111"), Version: newVersion(int64(i))})
+				}
+				return items, nil
+			}}
+			set, err := NewCore(mocks).Changes(context.Background(), tt.token)
+			assert.Equal(t, tt.expectErr, err)
+			if tt.expectErr != nil {
+				return
+			}
+			assert.Equal(t, tt.expectToken, set.Token)
+			assert.Equal(t, tt.expectMore, set.More)
+			assert.NotNil(t, set.Items)
+			assert.LessOrEqual(t, len(set.Items), SyncPageSize)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+// listStorer answers Changes from items, the way the stores do.
+type listStorer struct {
+	MockStorer
+	items []TodoItem
+}
+
+func (s *listStorer) Changes(ctx context.Context, since int64, limit int, lists []string) ([]TodoItem, error) {
+	var changed []TodoItem
+	for _, item := range s.items {
+		if *item.Version > since && (lists == nil || contains(lists, *item.OwnerId)) && len(changed) < limit {
+			changed = append(changed, item)
+		}
+	}
+	return changed, nil
+}
+
+func TestChangesListMembership(t *testing.T) {
+	store := &listStorer{items: []TodoItem{
+		{Id: newId("1111"), OwnerId: newId("alex"), Version: newVersion(1)},
+		{Id: newId("2222"), OwnerId: newId("sam"), Version: newVersion(2)},
+	}}
+	shared := sharedLists{}
+	core := NewCore(store)
+	core.ShareWith(shared)
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+
+	set, err := core.Changes(sam, "")
+	assert.Nil(t, err)
+	assert.Len(t, set.Items, 1)
+	assert.False(t, set.Reset)
+	set, _ = core.Changes(sam, set.Token)
+	assert.Empty(t, set.Items)
+	assert.False(t, set.Reset, "nothing's changed")
+
+	//alex's item is older than sam's token, so it'd never turn up without starting again
+	shared["alex"] = map[string]member.Role{"sam": member.RoleViewer}
+	set, err = core.Changes(sam, set.Token)
+	assert.Nil(t, err)
+	assert.True(t, set.Reset)
+	assert.Equal(t, []string{"1111", "2222"}, ids(set.Items))
+	set, _ = core.Changes(sam, set.Token)
+	assert.False(t, set.Reset)
+	assert.Empty(t, set.Items)
+
+	//and nothing would say it's gone once sam leaves
+	delete(shared, "alex")
+	set, _ = core.Changes(sam, set.Token)
+	assert.True(t, set.Reset)
+	assert.Equal(t, []string{"2222"}, ids(set.Items))
+
+	set, _ = core.Changes(sam, "2")
+	assert.True(t, set.Reset, "tokens from before lists were in them start again too")
+	set, _ = core.Changes(context.Background(), set.Token)
+	assert.True(t, set.Reset, "as do a user's tokens used unscoped")
+	assert.Equal(t, "2", set.Token)
+}
+
+func ids(items []TodoItem) []string {
+	var ids []string
+	for _, item := range items {
+		ids = append(ids, *item.Id)
+	}
+	return ids
+}
+
+func TestClientWins(t *testing.T) {
+	serverTime := time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+	current := TodoItem{Id: newId("1111"), Updated: &serverTime, Version: newVersion(5)}
+	type test struct {
+		name   string
+		change Change
+		expect bool
+	}
+	tests := []test{
+		{name: "nothing changed since", change: Change{BaseVersion: newVersion(5)}, expect: true},
+		{name: "changed since, client older", change: Change{BaseVersion: newVersion(4), Item: TodoItem{Updated: newTime(serverTime.Add(-time.Minute))}}},
+		{name: "changed since, client newer", change: Change{BaseVersion: newVersion(4), Item: TodoItem{Updated: newTime(serverTime.Add(time.Minute))}}, expect: true},
+		{name: "ties go to the server", change: Change{BaseVersion: newVersion(4), Item: TodoItem{Updated: &serverTime}}},
+		{name: "no version, client newer", change: Change{Item: TodoItem{Updated: newTime(serverTime.Add(time.Minute))}}, expect: true},
+		{name: "nothing to go on", change: Change{}},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			assert.Equal(t, tt.expect, clientWins(tt.change, current))
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestSyncTooMany(t *testing.T) {
+	_, err := NewCore(&MockStorer{}).Sync(context.Background(), make([]Change, MaxSyncChanges+1))
+	assert.Equal(t, terr.ErrorWithCode("invalid param", "can't sync more than 500 changes at once", 400), err)
+}
+
+func TestSyncAssignees(t *testing.T) {
+	store := &assignStorer{item: TodoItem{Id: newId("3333"), Summary: newSummary("sam's item"), OwnerId: newId("sam"), Version: newVersion(4)}}
+	core := NewCore(store)
+	core.ShareWith(sharedLists{"sam": {"alex": member.RoleViewer}})
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+
+	res, err := core.Sync(sam, []Change{
+		{Ref: "1", BaseVersion: newVersion(4), Item: TodoItem{Id: newId("3333"), AssigneeIds: []string{"kim"}}},
+		{Ref: "2", BaseVersion: newVersion(4), Item: TodoItem{Id: newId("3333"), AssigneeIds: []string{"alex", "alex"}}},
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, ChangeRejected, res[0].Status, "kim isn't a member of sam's list")
+		assert.JSONEq(t, terr.ErrorWithCode("invalid param", "kim isn't a member of list sam", 400).Error(), string(res[0].Error))
+		assert.Equal(t, ChangeApplied, res[1].Status)
+		assert.Equal(t, []string{"alex"}, store.item.AssigneeIds)
+	}
+}
+
+// createStorer creates items the way the stores do, open whatever they're sent.
+type createStorer struct {
+	MockStorer
+	items map[string]TodoItem
+}
+
+func (s *createStorer) Create(ctx context.Context, item TodoItem) (TodoItem, error) {
+	id, open, version := fmt.Sprint(len(s.items)+1), false, int64(len(s.items)+1)
+	item.Id, item.Completed, item.Version = &id, &open, &version
+	s.items[id] = item
+	return item, nil
+}
+
+func (s *createStorer) Update(ctx context.Context, item TodoItem) (TodoItem, error) {
+	s.items[*item.Id] = item
+	return item, nil
+}
+
+func TestSyncCreatesCompleted(t *testing.T) {
+	store := &createStorer{items: map[string]TodoItem{}}
+	done, open := true, false
+	res, err := NewCore(store).Sync(context.Background(), []Change{
+		{Ref: "1", Item: TodoItem{Summary: newSummary("finished offline"), Completed: &done}},
+		{Ref: "2", Item: TodoItem{Summary: newSummary("still to do"), Completed: &open}},
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, ChangeApplied, res[0].Status)
+		assert.True(t, *res[0].Item.Completed)
+		assert.NotNil(t, res[0].Item.CompletedAt)
+		assert.Equal(t, store.items[*res[0].Item.Id], *res[0].Item, "it's saved completed")
+		assert.Equal(t, ChangeApplied, res[1].Status)
+		assert.False(t, *res[1].Item.Completed)
+		assert.Nil(t, res[1].Item.CompletedAt)
+	}
+}
@@ -2,9 +2,14 @@ package todoitem
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/stumacwastaken/todo/auth"
 	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
+	"github.com/stumacwastaken/todo/member"
 )
 
 type Storer interface {
@@ -12,33 +17,84 @@ type Storer interface {
 	GetAll(ctx context.Context) ([]TodoItem, error) //could be improved to return additional metadata/page/row/etc
 	Update(context.Context, TodoItem) (TodoItem, error)
 	GetById(context.Context, string) (TodoItem, error)
+	Find(context.Context, filter.Predicate) ([]TodoItem, error)
+	Stats(context.Context, StatsRange) (Stats, error)
+	// Changes returns up to limit items with a version above since, deleted ones included, lowest version first. If
+	// lists isn't nil only items in those lists are returned, none if it's empty.
+	Changes(ctx context.Context, since int64, limit int, lists []string) ([]TodoItem, error)
+	// Put stores an item exactly as given, id and timestamps included, replacing any item with the same id. It skips
+	// the core's rules and events, it's for moving items between stores (see the bulk package). The version is new.
+	Put(context.Context, TodoItem) (TodoItem, error)
+}
+
+// Members is who lists are shared with, see the member package.
+type Members interface {
+	// Role is what a user can do with a list, "" if they can't see it.
+	Role(ctx context.Context, listId, userId string) (member.Role, error)
+	// Lists is the ids of every list a user can see, their own included.
+	Lists(ctx context.Context, userId string) ([]string, error)
 }
 
 type Core struct {
-	storer Storer
+	storer     Storer
+	publishers []Publisher
+	members    Members
 }
 
-func NewCore(storer Storer) *Core {
+// NewCore creates the todo item domain. Every publisher is told about each change the core makes.
+func NewCore(storer Storer, publishers ...Publisher) *Core {
 	return &Core{
-		storer: storer,
+		storer:     storer,
+		publishers: publishers,
 	}
 }
 
+// ShareWith lets users see and change the items of lists that have been shared with them. Without it, everyone only
+// has their own items.
+func (c *Core) ShareWith(members Members) {
+	c.members = members
+}
+
 //pull out so we can change give a custom time at testing.
 var dateUpdateFn = time.Now
 
-//Creates and Inserts a new Todo item into the database after basic validation
+//Creates and Inserts a new Todo item into the database after basic validation. It belongs to whoever made it, unless
+//it's made in a list shared with them by setting the owner, which needs them to be an editor. Anyone it's assigned to
+//has to be in that list, and is told about it with an EventAssigned.
 func (c *Core) Create(ctx context.Context, newTodo TodoItem) (TodoItem, error) {
+	if err := auth.Require(ctx, auth.ScopeWrite); err != nil {
+		return TodoItem{}, err
+	}
 	if newTodo.Id != nil {
 		return TodoItem{}, terr.ErrorWithCode("invalid param", "cannot create a todo item with an already existing id", 400)
 	}
 	if newTodo.Summary == nil || *newTodo.Summary == "" {
 		return TodoItem{}, terr.ErrorWithCode("invalid param", "summary cannot be empty", 400)
 	}
-	return c.storer.Create(ctx, newTodo)
+	if err := validatePriority(newTodo.Priority); err != nil {
+		return TodoItem{}, err
+	}
+	owner, err := c.owner(ctx, newTodo.OwnerId)
+	if err != nil {
+		return TodoItem{}, err
+	}
+	newTodo.OwnerId = owner
+	if newTodo.AssigneeIds, err = c.assignees(ctx, owner, newTodo.AssigneeIds); err != nil {
+		return TodoItem{}, err
+	}
+	created, err := c.storer.Create(ctx, newTodo)
+	if err != nil {
+		return TodoItem{}, err
+	}
+	c.publish(ctx, EventCreated, created)
+	c.publishAssigned(ctx, TodoItem{}, created)
+	return created, nil
 }
 
 func (c *Core) Update(ctx context.Context, newItem TodoItem, id string) (TodoItem, error) {
+	if err := auth.Require(ctx, auth.ScopeWrite); err != nil {
+		return TodoItem{}, err
+	}
 	if id == "" {
 		//should never really get here from restful api
 		return TodoItem{}, terr.ErrorWithCode("no id", "no id found in request", 404)
@@ -46,19 +102,35 @@ func (c *Core) Update(ctx context.Context, newItem TodoItem, id string) (TodoIte
 	if newItem.Summary == nil || *newItem.Summary == "" {
 		return TodoItem{}, terr.ErrorWithCode("bad request", "cannot have empty summary", 400)
 	}
-	//getItem
-	oldItem, err := c.storer.GetById(ctx, id)
+	if err := validatePriority(newItem.Priority); err != nil {
+		return TodoItem{}, err
+	}
+	oldItem, err := c.edit(ctx, id)
 	if err != nil {
-		if v, ok := err.(*terr.TodoError); ok {
-			return TodoItem{}, v
-		} else {
-			return TodoItem{}, terr.InternalError()
-		}
+		return TodoItem{}, err
+	}
+	if newItem.AssigneeIds, err = c.assignees(ctx, oldItem.OwnerId, newItem.AssigneeIds); err != nil {
+		return TodoItem{}, err
 	}
+	return c.save(ctx, oldItem, newItem)
+}
+
+// save merges newItem into oldItem and stores it. oldItem's version goes along with it, so if the item changed since
+// it was read the store refuses with a 409 rather than overwrite whatever happened in between.
+func (c *Core) save(ctx context.Context, oldItem, newItem TodoItem) (TodoItem, error) {
 	//merge new into old
 	toSave := mergeItems(oldItem, newItem)
 	t := dateUpdateFn()
 	toSave.Updated = &t
+	//completion time is ours to track, whatever the client sent is ignored
+	wasCompleted := oldItem.Completed != nil && *oldItem.Completed
+	if toSave.Completed != nil && *toSave.Completed {
+		if !wasCompleted {
+			toSave.CompletedAt = &t
+		}
+	} else {
+		toSave.CompletedAt = nil
+	}
 
 	//update
 	saved, err := c.storer.Update(ctx, toSave)
@@ -69,11 +141,90 @@ func (c *Core) Update(ctx context.Context, newItem TodoItem, id string) (TodoIte
 			return TodoItem{}, terr.InternalError()
 		}
 	}
+	c.publish(ctx, EventFor(oldItem, saved), saved)
+	c.publishAssigned(ctx, oldItem, saved)
 	return saved, nil
 }
 
+// Delete soft deletes an item. It's the same as updating deleted to true, without needing to send the summary.
+func (c *Core) Delete(ctx context.Context, id string) (TodoItem, error) {
+	if id == "" {
+		return TodoItem{}, terr.ErrorWithCode("no id", "no id found in request", 404)
+	}
+	item, err := c.get(ctx, id)
+	if err != nil {
+		return TodoItem{}, err
+	}
+	deleted := true
+	return c.Update(ctx, TodoItem{Summary: item.Summary, Deleted: &deleted}, id)
+}
+
+// GetById returns an item whether or not it's been deleted.
+func (c *Core) GetById(ctx context.Context, id string) (TodoItem, error) {
+	return c.get(ctx, id)
+}
+
+// GetAll returns every item that isn't deleted, out of the ones the caller can see.
 func (c *Core) GetAll(ctx context.Context) ([]TodoItem, error) {
-	return c.storer.GetAll(ctx)
+	if scope(ctx) == nil {
+		return c.storer.GetAll(ctx)
+	}
+	notDeleted := filter.Predicate{Conds: []filter.Cond{
+		{Field: filter.Field{Name: "deleted", Kind: filter.Bool}, Op: filter.Eq, Value: false},
+	}}
+	pred, err := c.scoped(ctx, notDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return c.storer.Find(ctx, pred)
+}
+
+// Find returns the items matching a filter language expression (see the filter package). Deleted items are left out
+// unless the expression asks about them, and so is anything the caller can't see.
+func (c *Core) Find(ctx context.Context, expr string) ([]TodoItem, error) {
+	pred, err := c.Predicate(expr)
+	if err != nil {
+		return nil, err
+	}
+	pred, err = c.scoped(ctx, pred)
+	if err != nil {
+		return nil, err
+	}
+	return c.storer.Find(ctx, pred)
+}
+
+// Predicate compiles an expression the same way Find does, leaving deleted items out unless asked about. It's for
+// matching items in memory, i.e: working out if a changed item belongs to a live subscription.
+func (c *Core) Predicate(expr string) (filter.Predicate, error) {
+	pred, err := c.Compile(expr)
+	if err != nil {
+		return filter.Predicate{}, err
+	}
+	if !pred.Has("deleted") {
+		pred = pred.And(filter.Cond{Field: filter.Field{Name: "deleted", Kind: filter.Bool}, Op: filter.Eq, Value: false})
+	}
+	return pred, nil
+}
+
+// Compile checks an expression against the todo item schema, turning any mistakes into a 400 that points at where
+// in the expression things went wrong.
+func (c *Core) Compile(expr string) (filter.Predicate, error) {
+	pred, err := filter.Compile(expr, FilterSchema, dateUpdateFn())
+	if err != nil {
+		var ferr *filter.Error
+		if errors.As(err, &ferr) {
+			return filter.Predicate{}, terr.ErrorWithCode("invalid filter", ferr.Error(), 400).AtPosition(ferr.Pos)
+		}
+		return filter.Predicate{}, terr.InternalError()
+	}
+	return pred, nil
+}
+
+func validatePriority(p *Priority) error {
+	if p != nil && p.Rank() < 0 {
+		return terr.ErrorWithCode("invalid param", fmt.Sprintf("unknown priority %s", *p), 400)
+	}
+	return nil
 }
 
 func mergeItems(old, new TodoItem) TodoItem {
@@ -86,5 +237,17 @@ func mergeItems(old, new TodoItem) TodoItem {
 	if new.Summary != nil {
 		old.Summary = new.Summary
 	}
+	if new.Priority != nil {
+		old.Priority = new.Priority
+	}
+	if new.Due != nil {
+		old.Due = new.Due
+	}
+	if new.Tags != nil {
+		old.Tags = new.Tags
+	}
+	if new.AssigneeIds != nil {
+		old.AssigneeIds = new.AssigneeIds
+	}
 	return old
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/filter"
 )
 
 type MockStorer struct {
@@ -35,6 +36,27 @@ func (m *MockStorer) Update(context.Context, TodoItem) (TodoItem, error) {
 	return TodoItem{}, err
 }
 
+func (m *MockStorer) Find(context.Context, filter.Predicate) ([]TodoItem, error) {
+	return m.resp("Find")
+}
+
+func (m *MockStorer) Stats(context.Context, StatsRange) (Stats, error) {
+	_, err := m.resp("Stats")
+	return Stats{}, err
+}
+
+func (m *MockStorer) Changes(context.Context, int64, int, []string) ([]TodoItem, error) {
+	return m.resp("Changes")
+}
+
+func (m *MockStorer) Put(context.Context, TodoItem) (TodoItem, error) {
+	res, err := m.resp("Put")
+	if len(res) > 0 {
+		return res[0], err
+	}
+	return TodoItem{}, err
+}
+
 func (m *MockStorer) GetById(context.Context, string) (TodoItem, error) {
 	res, err := m.resp("GetById")
 	if len(res) > 0 {
@@ -443,3 +465,217 @@ func TestCreate(t *testing.T) {
 	}
 
 }
+
+func TestFind(t *testing.T) {
+	type test struct {
+		name       string
+		expr       string
+		err        error
+		hasDeleted bool
+	}
+	tests := []test{
+		{name: "adds deleted false", expr: "completed:false"},
+		{name: "keeps asked for deleted", expr: "deleted:true", hasDeleted: true},
+		{name: "bad expression", expr: "completed:nope", err: terr.ErrorWithCode("invalid filter", "completed expects true or false (at position 10)", 400).AtPosition(10)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			var got filter.Predicate
+			mocks := &MockStorer{resp: func(method string) ([]TodoItem, error) { return []TodoItem{}, nil }}
+			subject := NewCore(&findStorer{MockStorer: mocks, pred: &got})
+			_, err := subject.Find(context.Background(), tt.expr)
+			assert.Equal(t, tt.err, err, "errors should match")
+			if tt.err != nil {
+				return
+			}
+			last := got.Conds[len(got.Conds)-1]
+			assert.Equal(t, "deleted", last.Field.Name)
+			assert.Equal(t, tt.hasDeleted, last.Value)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+// findStorer records the predicate handed to Find
+type findStorer struct {
+	*MockStorer
+	pred *filter.Predicate
+}
+
+func (f *findStorer) Find(ctx context.Context, pred filter.Predicate) ([]TodoItem, error) {
+	*f.pred = pred
+	return f.MockStorer.Find(ctx, pred)
+}
+
+func TestCreateInvalidPriority(t *testing.T) {
+	p := Priority("urgent")
+	subject := NewCore(&MockStorer{})
+	_, err := subject.Create(context.Background(), TodoItem{Summary: newSummary("test summary"), Priority: &p})
+	assert.Equal(t, terr.ErrorWithCode("invalid param", "unknown priority urgent", 400), err)
+}
+
+func TestUpdateTracksCompletion(t *testing.T) {
+	completedAt := time.Date(2023, time.January, 14, 12, 12, 12, 12, time.Local)
+	type test struct {
+		name         string
+		wasCompleted bool
+		oldAt        *time.Time
+		completed    bool
+		expectAt     *time.Time
+	}
+	tests := []test{
+		{name: "newly completed", completed: true, expectAt: &completedAt},
+		{name: "already completed keeps time", wasCompleted: true, oldAt: newTime(completedAt.AddDate(0, 0, -1)), completed: true, expectAt: newTime(completedAt.AddDate(0, 0, -1))},
+		{name: "reopened clears time", wasCompleted: true, oldAt: &completedAt, completed: false, expectAt: nil},
+	}
+	defer func() { dateUpdateFn = time.Now }()
+	dateUpdateFn = func() time.Time { return completedAt }
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			var saved TodoItem
+			mocks := &MockStorer{resp: func(method string) ([]TodoItem, error) {
+				return []TodoItem{{Id: newId("3333"), Summary: newSummary("a summary"), Completed: newBool(tt.wasCompleted), CompletedAt: tt.oldAt}}, nil
+			}}
+			subject := NewCore(&updateStorer{MockStorer: mocks, saved: &saved})
+			_, err := subject.Update(context.Background(), TodoItem{Summary: newSummary("a summary"), Completed: newBool(tt.completed), CompletedAt: newTime(time.Now())}, "3333")
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectAt, saved.CompletedAt)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+// updateStorer records the item handed to Update
+type updateStorer struct {
+	*MockStorer
+	saved *TodoItem
+}
+
+func (u *updateStorer) Update(ctx context.Context, item TodoItem) (TodoItem, error) {
+	*u.saved = item
+	return item, nil
+}
+
+// statsStorer hands back a canned set of sparse stats
+type statsStorer struct {
+	*MockStorer
+	stats Stats
+	err   error
+}
+
+func (s *statsStorer) Stats(ctx context.Context, r StatsRange) (Stats, error) {
+	return s.stats, s.err
+}
+
+func TestStats(t *testing.T) {
+	from := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 14)
+	avg := 3600.0
+	store := &statsStorer{MockStorer: &MockStorer{}, stats: Stats{
+		Open: 3, Completed: 4, Deleted: 1, Overdue: 2, AverageSecondsToComplete: &avg,
+		Days: []DayStats{
+			{Date: "2023-01-05", Created: 2, Completed: 1},
+			{Date: "2023-01-02", Created: 1, Completed: 6},
+		},
+	}}
+	subject := NewCore(store)
+
+	stats, err := subject.Stats(context.Background(), from, to)
+	assert.Nil(t, err)
+	assert.Len(t, stats.Days, 14, "one entry a day")
+	assert.Equal(t, DayStats{Date: "2023-01-01"}, stats.Days[0])
+	assert.Equal(t, DayStats{Date: "2023-01-02", Created: 1, Completed: 6}, stats.Days[1])
+	assert.Equal(t, DayStats{Date: "2023-01-05", Created: 2, Completed: 1}, stats.Days[4])
+	assert.Equal(t, 3, stats.CreatedInRange)
+	assert.Equal(t, 7, stats.CompletedInRange)
+	assert.Equal(t, 3.5, stats.WeeklyThroughput)
+	assert.Equal(t, 3, stats.Open)
+	assert.Equal(t, &avg, stats.AverageSecondsToComplete)
+
+	_, err = subject.Stats(context.Background(), to, from)
+	assert.Equal(t, terr.ErrorWithCode("invalid param", "from must be before to", 400), err)
+	_, err = subject.Stats(context.Background(), from.AddDate(-2, 0, 0), to)
+	assert.Equal(t, terr.ErrorWithCode("invalid param", "stats range cannot be longer than a year", 400), err)
+
+	store.err = errors.New("random storage error")
+	_, err = subject.Stats(context.Background(), from, to)
+	assert.Equal(t, terr.InternalError(), err)
+}
+
+type recordingPublisher struct {
+	events []Event
+}
+
+func (r *recordingPublisher) Publish(ctx context.Context, e Event) {
+	r.events = append(r.events, e)
+}
+
+func TestPublishesEvents(t *testing.T) {
+	type test struct {
+		name   string
+		old    TodoItem
+		req    TodoItem
+		expect EventType
+	}
+	tests := []test{
+		{name: "edit", old: TodoItem{Completed: newBool(false)}, req: TodoItem{Summary: newSummary("new summary")}, expect: EventUpdated},
+		{name: "complete", old: TodoItem{Completed: newBool(false)}, req: TodoItem{Summary: newSummary("a summary"), Completed: newBool(true)}, expect: EventCompleted},
+		{name: "already complete", old: TodoItem{Completed: newBool(true)}, req: TodoItem{Summary: newSummary("a summary"), Completed: newBool(true)}, expect: EventUpdated},
+		{name: "delete", old: TodoItem{Deleted: newBool(false)}, req: TodoItem{Summary: newSummary("a summary"), Deleted: newBool(true), Completed: newBool(true)}, expect: EventDeleted},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			pub := &recordingPublisher{}
+			var saved TodoItem
+			mocks := &MockStorer{resp: func(method string) ([]TodoItem, error) {
+				old := tt.old
+				old.Id, old.Summary = newId("3333"), newSummary("a summary")
+				return []TodoItem{old}, nil
+			}}
+			subject := NewCore(&updateStorer{MockStorer: mocks, saved: &saved}, pub)
+			_, err := subject.Update(context.Background(), tt.req, "3333")
+			assert.Nil(t, err)
+			if assert.Len(t, pub.events, 1) {
+				assert.Equal(t, tt.expect, pub.events[0].Type)
+				assert.Equal(t, saved, pub.events[0].Item)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+
+	pub := &recordingPublisher{}
+	mocks := &MockStorer{resp: func(method string) ([]TodoItem, error) {
+		return []TodoItem{{Id: newId("4444"), Summary: newSummary("created")}}, nil
+	}}
+	subject := NewCore(mocks, pub)
+	_, err := subject.Create(context.Background(), TodoItem{Summary: newSummary("created")})
+	assert.Nil(t, err)
+	assert.Equal(t, []EventType{EventCreated}, []EventType{pub.events[0].Type})
+
+	mocks.resp = func(method string) ([]TodoItem, error) {
+		return nil, terr.UnknownError()
+	}
+	_, err = subject.Create(context.Background(), TodoItem{Summary: newSummary("created")})
+	assert.Equal(t, terr.UnknownError(), err)
+	assert.Len(t, pub.events, 1, "failed changes shouldn't be published")
+}
+
+func TestDelete(t *testing.T) {
+	pub := &recordingPublisher{}
+	var saved TodoItem
+	mocks := &MockStorer{resp: func(method string) ([]TodoItem, error) {
+		return []TodoItem{{Id: newId("3333"), Summary: newSummary("a summary"), Deleted: newBool(false)}}, nil
+	}}
+	subject := NewCore(&updateStorer{MockStorer: mocks, saved: &saved}, pub)
+	deleted, err := subject.Delete(context.Background(), "3333")
+	assert.Nil(t, err)
+	assert.Equal(t, newBool(true), deleted.Deleted)
+	assert.Equal(t, newSummary("a summary"), saved.Summary)
+	assert.Equal(t, EventDeleted, pub.events[0].Type)
+
+	mocks.resp = func(method string) ([]TodoItem, error) {
+		return nil, terr.ErrorWithCode("not found", "Item with id 4444 not found", 404)
+	}
+	_, err = subject.Delete(context.Background(), "4444")
+	assert.Equal(t, terr.ErrorWithCode("not found", "Item with id 4444 not found", 404), err)
+}
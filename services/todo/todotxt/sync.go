@@ -0,0 +1,199 @@
+package todotxt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// Remote is the server side of a sync. It's satisfied by wrapping a *client.Client.
+type Remote interface {
+	//All is every item that isn't deleted
+	All(ctx context.Context) ([]todoitem.TodoItem, error)
+	Create(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error)
+	Update(ctx context.Context, item todoitem.TodoItem, id string) (todoitem.TodoItem, error)
+	Delete(ctx context.Context, id string) (todoitem.TodoItem, error)
+}
+
+// State is how each item looked in the file and on the server after the last sync. Without it there's no telling
+// which side changed an item, so it's saved alongside the file between syncs.
+type State struct {
+	Items map[string]Synced `json:"items"`
+}
+
+type Synced struct {
+	//Line is the item's line as Format wrote it
+	Line    string `json:"line"`
+	Version int64  `json:"version"`
+}
+
+// Report is what a sync did.
+type Report struct {
+	//Pushed local changes to the server, Pulled server changes into the file
+	Pushed int
+	Pulled int
+	//Created on the server from new lines, Added to the file from new items
+	Created int
+	Added   int
+	//Deleted on the server as their line was removed, Removed from the file as they were deleted on the server
+	Deleted int
+	Removed int
+	//Conflicts are items changed on both sides. The server's version wins.
+	Conflicts []string
+}
+
+func (r Report) String() string {
+	s := fmt.Sprintf("pushed %d, pulled %d, created %d, added %d, deleted %d, removed %d", r.Pushed, r.Pulled,
+		r.Created, r.Added, r.Deleted, r.Removed)
+	if len(r.Conflicts) > 0 {
+		s += fmt.Sprintf("\n%d changed on both sides, kept the server's: %s", len(r.Conflicts), strings.Join(r.Conflicts, ", "))
+	}
+	return s
+}
+
+// Sync reconciles the lines of a todo.txt file with the server, returning the file's new lines and the state to keep
+// for next time. Lines that changed since the last sync are pushed, items that changed on the server are pulled, and
+// if both changed the server wins. Blank lines and lines that don't parse are left where they are.
+func Sync(ctx context.Context, remote Remote, lines []string, state State) ([]string, State, Report, error) {
+	var report Report
+	if state.Items == nil {
+		state.Items = map[string]Synced{}
+	}
+	serverItems, err := remote.All(ctx)
+	if err != nil {
+		return nil, State{}, report, err
+	}
+	server := map[string]todoitem.TodoItem{}
+	for _, item := range serverItems {
+		server[*item.Id] = item
+	}
+	next := State{Items: map[string]Synced{}}
+	out := []string{}
+	keep := func(item todoitem.TodoItem) {
+		line := Format(item)
+		out = append(out, line)
+		next.Items[*item.Id] = Synced{Line: line, Version: version(item)}
+	}
+
+	for _, line := range lines {
+		local, err := Parse(line)
+		if err != nil {
+			out = append(out, line)
+			continue
+		}
+		if local.Id == nil {
+			created, err := create(ctx, remote, local)
+			if err != nil {
+				return nil, State{}, report, err
+			}
+			report.Created++
+			keep(created)
+			continue
+		}
+		id := *local.Id
+		prev, known := state.Items[id]
+		localChanged := !known || prev.Line != Format(local)
+		srv, ok := server[id]
+		delete(server, id)
+		if !ok {
+			if known && !localChanged {
+				report.Removed++
+				continue
+			}
+			//changed here but gone from the server, or from somewhere else entirely. Either way it's new to the server.
+			local.Id = nil
+			created, err := create(ctx, remote, local)
+			if err != nil {
+				return nil, State{}, report, err
+			}
+			report.Created++
+			keep(created)
+			continue
+		}
+		serverChanged := !known || prev.Version != version(srv)
+		switch {
+		case Format(srv) == Format(local):
+			keep(srv)
+		case !localChanged:
+			report.Pulled++
+			keep(srv)
+		case !serverChanged:
+			updated, err := remote.Update(ctx, changes(local), id)
+			if err != nil {
+				return nil, State{}, report, err
+			}
+			report.Pushed++
+			keep(updated)
+		default:
+			report.Conflicts = append(report.Conflicts, id)
+			keep(srv)
+		}
+	}
+
+	//whatever's left on the server isn't in the file
+	rest := []todoitem.TodoItem{}
+	for _, item := range server {
+		rest = append(rest, item)
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		if rest[i].Created != nil && rest[j].Created != nil && !rest[i].Created.Equal(*rest[j].Created) {
+			return rest[i].Created.Before(*rest[j].Created)
+		}
+		return *rest[i].Id < *rest[j].Id
+	})
+	for _, item := range rest {
+		prev, known := state.Items[*item.Id]
+		switch {
+		case !known:
+			report.Added++
+			keep(item)
+		case prev.Version == version(item):
+			if _, err := remote.Delete(ctx, *item.Id); err != nil {
+				return nil, State{}, report, err
+			}
+			report.Deleted++
+		default:
+			//removed from the file, but changed on the server since. Bring it back rather than lose the change.
+			report.Conflicts = append(report.Conflicts, *item.Id)
+			keep(item)
+		}
+	}
+	return out, next, report, nil
+}
+
+// create makes a new item on the server. Completion can't be set on create, so it takes an update too.
+func create(ctx context.Context, remote Remote, item todoitem.TodoItem) (todoitem.TodoItem, error) {
+	created, err := remote.Create(ctx, todoitem.TodoItem{Summary: item.Summary, Priority: item.Priority, Due: item.Due, Tags: item.Tags})
+	if err != nil {
+		return todoitem.TodoItem{}, err
+	}
+	if item.Completed == nil || !*item.Completed {
+		return created, nil
+	}
+	return remote.Update(ctx, todoitem.TodoItem{Summary: created.Summary, Completed: item.Completed}, *created.Id)
+}
+
+// changes is the update that makes the server's item match a line. A line without a priority clears it.
+func changes(item todoitem.TodoItem) todoitem.TodoItem {
+	priority := todoitem.PriorityNone
+	if item.Priority != nil {
+		priority = *item.Priority
+	}
+	return todoitem.TodoItem{
+		Summary:   item.Summary,
+		Completed: item.Completed,
+		Priority:  &priority,
+		Due:       item.Due,
+		Tags:      item.Tags,
+	}
+}
+
+func version(item todoitem.TodoItem) int64 {
+	if item.Version == nil {
+		return 0
+	}
+	return *item.Version
+}
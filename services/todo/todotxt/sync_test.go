@@ -0,0 +1,118 @@
+package todotxt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// coreRemote syncs straight against a core rather than through the api.
+type coreRemote struct {
+	*todoitem.Core
+}
+
+func (r coreRemote) All(ctx context.Context) ([]todoitem.TodoItem, error) {
+	return r.Core.GetAll(ctx)
+}
+
+func newSummary(s string) *string {
+	return &s
+}
+
+// line finds the synced line for a summary, failing if there isn't exactly one.
+func line(t *testing.T, lines []string, summary string) string {
+	found := []string{}
+	for _, l := range lines {
+		if strings.Contains(l, " "+summary+" ") || strings.HasPrefix(l, summary+" ") {
+			found = append(found, l)
+		}
+	}
+	if !assert.Len(t, found, 1, summary) {
+		return ""
+	}
+	return found[0]
+}
+
+func idOf(t *testing.T, l string) string {
+	item, err := Parse(l)
+	if !assert.Nil(t, err) || !assert.NotNil(t, item.Id) {
+		return ""
+	}
+	return *item.Id
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	core := todoitem.NewCore(memdb.NewStore())
+	remote := coreRemote{core}
+	milk, _ := core.Create(ctx, todoitem.TodoItem{Summary: newSummary("buy milk")})
+
+	//first sync, everything's new on both sides
+	lines, state, report, err := Sync(ctx, remote, []string{"(A) call mom +family", "", "+just +tags", "x file taxes"}, State{})
+	assert.Nil(t, err)
+	assert.Equal(t, Report{Created: 2, Added: 1}, report)
+	assert.Len(t, lines, 5)
+	assert.Equal(t, "", lines[1], "blank lines stay put")
+	assert.Equal(t, "+just +tags", lines[2], "so do lines that aren't todos")
+	assert.Contains(t, line(t, lines, "buy milk"), "id:"+*milk.Id)
+	taxes, _ := core.GetById(ctx, idOf(t, line(t, lines, "file taxes")))
+	assert.True(t, *taxes.Completed, "completed on the server too")
+
+	//nothing changed
+	again, state, report, err := Sync(ctx, remote, lines, state)
+	assert.Nil(t, err)
+	assert.Equal(t, Report{}, report)
+	assert.Equal(t, lines, again)
+
+	//a change on each side, a removed line and an item deleted on the server
+	mom := idOf(t, line(t, lines, "call mom"))
+	core.Update(ctx, todoitem.TodoItem{Summary: newSummary("buy oat milk")}, *milk.Id)
+	core.Delete(ctx, *taxes.Id)
+	edited := []string{}
+	for _, l := range lines {
+		switch {
+		case strings.Contains(l, "call mom"):
+			edited = append(edited, strings.Replace(l, "(A)", "(B)", 1))
+		default:
+			edited = append(edited, l)
+		}
+	}
+	edited = append(edited, "walk dog")
+	lines, state, report, err = Sync(ctx, remote, edited, state)
+	assert.Nil(t, err)
+	assert.Equal(t, Report{Pushed: 1, Pulled: 1, Created: 1, Removed: 1}, report)
+	item, _ := core.GetById(ctx, mom)
+	assert.Equal(t, todoitem.PriorityMedium, *item.Priority)
+	line(t, lines, "buy oat milk")
+	line(t, lines, "walk dog")
+	assert.NotContains(t, strings.Join(lines, "\n"), "file taxes", "deleted on the server")
+
+	//a line removed from the file is deleted on the server
+	dog := idOf(t, line(t, lines, "walk dog"))
+	edited = []string{}
+	for _, l := range lines {
+		if !strings.Contains(l, "walk dog") {
+			edited = append(edited, l)
+		}
+	}
+	lines, state, report, err = Sync(ctx, remote, edited, state)
+	assert.Nil(t, err)
+	assert.Equal(t, Report{Deleted: 1}, report)
+	item, _ = core.GetById(ctx, dog)
+	assert.True(t, *item.Deleted)
+
+	//changed on both sides, the server wins
+	core.Update(ctx, todoitem.TodoItem{Summary: newSummary("call dad")}, mom)
+	edited = []string{}
+	for _, l := range lines {
+		edited = append(edited, strings.Replace(l, "call mom", "call grandma", 1))
+	}
+	lines, _, report, err = Sync(ctx, remote, edited, state)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{mom}, report.Conflicts)
+	line(t, lines, "call dad")
+}
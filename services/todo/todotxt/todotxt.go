@@ -0,0 +1,194 @@
+// Package todotxt converts todo items to and from the todo.txt format (https://github.com/todotxt/todo.txt), and
+// keeps a todo.txt file and the server in step, see Sync.
+//
+// Priorities A, B and C are high, medium and low, anything lower is low too. Projects (+work) become tags as they
+// are, contexts (@phone) become tags with the @ kept so they go back out as contexts. due: is the due date and id: the
+// todo item's id, which is how a line is matched back up to its item. Other key:values stay in the summary.
+package todotxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	priorityPattern = regexp.MustCompile(`^\(([A-Z])\)$`)
+	keyValuePattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_-]*):(\S+)$`)
+)
+
+// Parse reads a todo.txt line into a todo item. Dates are taken as midnight local time.
+func Parse(line string) (todoitem.TodoItem, error) {
+	var item todoitem.TodoItem
+	words := strings.Fields(line)
+	completed := false
+	if len(words) > 0 && words[0] == "x" {
+		completed = true
+		words = words[1:]
+		if t, ok := parseDate(words); ok {
+			item.CompletedAt = &t
+			words = words[1:]
+		}
+	} else if len(words) > 0 && priorityPattern.MatchString(words[0]) {
+		p := fromLetter(words[0][1])
+		item.Priority = &p
+		words = words[1:]
+	}
+	item.Completed = &completed
+	if t, ok := parseDate(words); ok {
+		item.Created = &t
+		words = words[1:]
+	}
+
+	summary := []string{}
+	for _, w := range words {
+		switch {
+		case len(w) > 1 && w[0] == '+':
+			item.Tags = append(item.Tags, w[1:])
+		case len(w) > 1 && w[0] == '@':
+			item.Tags = append(item.Tags, w)
+		case keyValuePattern.MatchString(w):
+			m := keyValuePattern.FindStringSubmatch(w)
+			switch m[1] {
+			case "due":
+				t, err := time.ParseInLocation(dateLayout, m[2], time.Local)
+				if err != nil {
+					return todoitem.TodoItem{}, fmt.Errorf("invalid due date %s, use 2006-01-02", m[2])
+				}
+				item.Due = &t
+			case "id":
+				id := m[2]
+				item.Id = &id
+			//completed tasks lose their (A), a lot of tools keep it as pri:A instead
+			case "pri":
+				if len(m[2]) == 1 && m[2][0] >= 'A' && m[2][0] <= 'Z' {
+					p := fromLetter(m[2][0])
+					item.Priority = &p
+				} else {
+					summary = append(summary, w)
+				}
+			default:
+				summary = append(summary, w)
+			}
+		default:
+			summary = append(summary, w)
+		}
+	}
+	if len(summary) == 0 {
+		return todoitem.TodoItem{}, fmt.Errorf("no description")
+	}
+	s := strings.Join(summary, " ")
+	item.Summary = &s
+	return item, nil
+}
+
+// Format writes a todo item as a todo.txt line. Parse(Format(item)) gets the same line back out of Format, so lines
+// can be compared to see if anything changed.
+func Format(item todoitem.TodoItem) string {
+	parts := []string{}
+	completed := item.Completed != nil && *item.Completed
+	priority := ""
+	if item.Priority != nil {
+		priority = toLetter(*item.Priority)
+	}
+	if completed {
+		parts = append(parts, "x")
+		//a creation date can only follow a completion date
+		if item.CompletedAt != nil {
+			parts = append(parts, item.CompletedAt.Local().Format(dateLayout))
+			if item.Created != nil {
+				parts = append(parts, item.Created.Local().Format(dateLayout))
+			}
+		}
+	} else {
+		if priority != "" {
+			parts = append(parts, "("+priority+")")
+		}
+		if item.Created != nil {
+			parts = append(parts, item.Created.Local().Format(dateLayout))
+		}
+	}
+	if item.Summary != nil {
+		parts = append(parts, *item.Summary)
+	}
+	for _, tag := range item.Tags {
+		if strings.HasPrefix(tag, "@") {
+			parts = append(parts, tag)
+		} else {
+			parts = append(parts, "+"+tag)
+		}
+	}
+	if completed && priority != "" {
+		parts = append(parts, "pri:"+priority)
+	}
+	if item.Due != nil {
+		parts = append(parts, "due:"+item.Due.Local().Format(dateLayout))
+	}
+	if item.Id != nil {
+		parts = append(parts, "id:"+*item.Id)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ReadLines reads a todo.txt file's lines, without their line endings.
+func ReadLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+// ParseAll parses every line that isn't blank. Errors name the line they're on, counting from 1.
+func ParseAll(lines []string) ([]todoitem.TodoItem, error) {
+	items := []todoitem.TodoItem{}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		item, err := Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func parseDate(words []string) (time.Time, bool) {
+	if len(words) == 0 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(dateLayout, words[0], time.Local)
+	return t, err == nil
+}
+
+func fromLetter(l byte) todoitem.Priority {
+	switch l {
+	case 'A':
+		return todoitem.PriorityHigh
+	case 'B':
+		return todoitem.PriorityMedium
+	}
+	return todoitem.PriorityLow
+}
+
+func toLetter(p todoitem.Priority) string {
+	switch p {
+	case todoitem.PriorityHigh:
+		return "A"
+	case todoitem.PriorityMedium:
+		return "B"
+	case todoitem.PriorityLow:
+		return "C"
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package todotxt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func date(s string) *time.Time {
+	t, _ := time.ParseInLocation(dateLayout, s, time.Local)
+	return &t
+}
+
+func priority(p todoitem.Priority) *todoitem.Priority {
+	return &p
+}
+
+func TestParse(t *testing.T) {
+	type test struct {
+		name string
+		line string
+		want todoitem.TodoItem
+		err  bool
+		//out is what Format writes back, when it isn't the line itself
+		out string
+	}
+	summary := func(s string) *string { return &s }
+	yes, no := true, false
+	id := "abc-123"
+
+	tests := []test{
+		{
+			name: "plain",
+			line: "call mom",
+			want: todoitem.TodoItem{Summary: summary("call mom"), Completed: &no},
+		},
+		{
+			name: "everything",
+			line: "(A) 2026-10-01 call mom +family @phone due:2026-10-20 id:abc-123",
+			want: todoitem.TodoItem{Id: &id, Summary: summary("call mom"), Completed: &no, Priority: priority(todoitem.PriorityHigh),
+				Created: date("2026-10-01"), Due: date("2026-10-20"), Tags: []string{"family", "@phone"}},
+		},
+		{
+			name: "completed",
+			line: "x 2026-10-02 2026-10-01 call mom pri:B",
+			want: todoitem.TodoItem{Summary: summary("call mom"), Completed: &yes, Priority: priority(todoitem.PriorityMedium),
+				CompletedAt: date("2026-10-02"), Created: date("2026-10-01")},
+		},
+		{
+			name: "lower priorities are low",
+			line: "(D) file taxes",
+			want: todoitem.TodoItem{Summary: summary("file taxes"), Completed: &no, Priority: priority(todoitem.PriorityLow)},
+			out:  "(C) file taxes",
+		},
+		{
+			name: "tags move to the end",
+			line: "call +family mom about:dinner",
+			want: todoitem.TodoItem{Summary: summary("call mom about:dinner"), Completed: &no, Tags: []string{"family"}},
+			out:  "call mom about:dinner +family",
+		},
+		{
+			name: "x needs a space after it",
+			line: "xylophone lessons",
+			want: todoitem.TodoItem{Summary: summary("xylophone lessons"), Completed: &no},
+		},
+		{
+			name: "no description",
+			line: "(A) +family due:2026-10-20",
+			err:  true,
+		},
+		{
+			name: "bad due date",
+			line: "call mom due:tomorrow",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			item, err := Parse(tt.line)
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, item)
+			out := tt.out
+			if out == "" {
+				out = tt.line
+			}
+			assert.Equal(t, out, Format(item))
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	items, err := ParseAll([]string{"call mom", "", "walk dog"})
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+
+	_, err = ParseAll([]string{"call mom", "", "(A)"})
+	assert.EqualError(t, err, "line 3: no description")
+}
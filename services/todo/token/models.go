@@ -0,0 +1,25 @@
+package token
+
+import "time"
+
+// Token is a personal access token, a credential for scripts and CI jobs that isn't anyone's password. It's sent as
+// a bearer token and can do whatever its user can, up to its scope.
+type Token struct {
+	Id *string `json:"id,omitempty"`
+	//Name is what the token is for, i.e: nightly export
+	Name *string `json:"name,omitempty"`
+	//Scope is the most the token can do, one of read, write and admin. Defaults to read.
+	Scope *string `json:"scope,omitempty"`
+	//Token is the credential itself. It's only handed back when the token is created, we only keep its hash.
+	Token   *string    `json:"token,omitempty"`
+	UserId  *string    `json:"userId,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+	//Expires is when the token stops working, it never does if left empty
+	Expires *time.Time `json:"expires,omitempty"`
+	//LastUsed is roughly when the token was last used, it's only updated every few minutes
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+	Revoked  *time.Time `json:"revoked,omitempty"`
+	Hash     *string    `json:"-"`
+	//WorkspaceId is the workspace of the token's user, requests made with it only see what's in there.
+	WorkspaceId *string `json:"-"`
+}
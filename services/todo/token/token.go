@@ -0,0 +1,246 @@
+// Package token is personal access tokens: long random secrets handed out once, kept only as a hash, that
+// authenticate as the user that made them with a limited scope.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"go.uber.org/zap"
+)
+
+// Prefix starts every token, so they're easy to tell apart from JWTs (and for secret scanners to spot).
+const Prefix = "todo_pat_"
+
+// usedEvery is how stale LastUsed can get, so a busy CI job isn't a write on every request.
+const usedEvery = 5 * time.Minute
+
+type Storer interface {
+	// Create saves a new token, the store gives out the id.
+	Create(context.Context, Token) (Token, error)
+	// GetById, GetAll and Revoke only see tokens in the workspace in ctx, see auth.Workspace.
+	GetById(context.Context, string) (Token, error)
+	// GetByHash looks a token up by the hash of its secret, 404 if there isn't one. It looks in every workspace.
+	GetByHash(context.Context, string) (Token, error)
+	// GetAll returns a user's tokens, newest first.
+	GetAll(ctx context.Context, userId string) ([]Token, error)
+	Revoke(ctx context.Context, id string, at time.Time) (Token, error)
+	Used(ctx context.Context, id string, at time.Time) error
+	// Prune deletes a user's tokens called name that expired or were revoked before a time.
+	Prune(ctx context.Context, userId, name string, before time.Time) error
+}
+
+type Core struct {
+	storer Storer
+}
+
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// pulled out for testing
+var nowFn = time.Now
+
+// Create makes a token for whoever is asking, returning it with its secret. It needs the admin scope, so a token
+// can't be used to make more of them unless it was made for that.
+func (c *Core) Create(ctx context.Context, t Token) (Token, error) {
+	userId, err := user(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	return c.Issue(ctx, userId, t)
+}
+
+// Issue makes a token for a user without anyone asking, i.e: for someone who just logged in through an identity
+// provider. Anything else should go through Create. The token works in the workspace in ctx, which has to be the
+// user's (see auth.WithWorkspace).
+func (c *Core) Issue(ctx context.Context, userId string, t Token) (Token, error) {
+	if t.Id != nil || t.Token != nil {
+		return Token{}, terr.ErrorWithCode("invalid param", "cannot create a token with an id or secret", 400)
+	}
+	if t.Name == nil || strings.TrimSpace(*t.Name) == "" {
+		return Token{}, terr.ErrorWithCode("invalid param", "name cannot be empty", 400)
+	}
+	if t.Scope == nil {
+		read := auth.ScopeRead
+		t.Scope = &read
+	}
+	if !auth.ValidScope(*t.Scope) {
+		return Token{}, terr.ErrorWithCode("invalid param", fmt.Sprintf("unknown scope %s, use %s", *t.Scope, strings.Join(auth.Scopes, ", ")), 400)
+	}
+	if t.Expires != nil && !t.Expires.After(nowFn()) {
+		return Token{}, terr.ErrorWithCode("invalid param", "expires has to be in the future", 400)
+	}
+	secret, err := newSecret()
+	if err != nil {
+		log.Default().Error("failed to generate token", zap.Error(err))
+		return Token{}, terr.InternalError()
+	}
+	h := hash(secret)
+	workspace := auth.Workspace(ctx)
+	t.UserId = &userId
+	t.Hash = &h
+	t.WorkspaceId = &workspace
+	t.LastUsed, t.Revoked = nil, nil
+	created, err := c.storer.Create(ctx, t)
+	if err != nil {
+		return Token{}, asTodoError(err)
+	}
+	created.Token = &secret
+	return created, nil
+}
+
+// Session is Issue for someone who just logged in, but the token has to expire. Their sessions that have since run
+// out or been revoked are cleared away, so logging in every day doesn't leave a token behind each time.
+func (c *Core) Session(ctx context.Context, userId string, t Token) (Token, error) {
+	if t.Expires == nil {
+		return Token{}, terr.ErrorWithCode("invalid param", "a session has to expire", 400)
+	}
+	created, err := c.Issue(ctx, userId, t)
+	if err != nil {
+		return Token{}, err
+	}
+	if err := c.storer.Prune(ctx, userId, *t.Name, nowFn()); err != nil {
+		//the login still worked, the old ones go next time
+		log.Default().Warn("failed to prune old sessions", zap.Error(err), zap.String("user", userId))
+	}
+	return created, nil
+}
+
+// GetAll is the tokens of whoever is asking, revoked and expired ones included.
+func (c *Core) GetAll(ctx context.Context) ([]Token, error) {
+	userId, err := user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := c.storer.GetAll(ctx, userId)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	return tokens, nil
+}
+
+// Revoke stops a token working straight away. Revoking it again leaves it as it was.
+func (c *Core) Revoke(ctx context.Context, id string) (Token, error) {
+	userId, err := user(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	t, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return Token{}, asTodoError(err)
+	}
+	//someone else's token is the same as one that doesn't exist
+	if *t.UserId != userId {
+		return Token{}, notFound(id)
+	}
+	if t.Revoked != nil {
+		return t, nil
+	}
+	revoked, err := c.storer.Revoke(ctx, id, nowFn())
+	if err != nil {
+		return Token{}, asTodoError(err)
+	}
+	return revoked, nil
+}
+
+// Authenticate checks a bearer token, making Core an auth.Authenticator. See Bearer for sharing the scheme with JWTs.
+func (c *Core) Authenticate(ctx context.Context, authorization string) (auth.Principal, error) {
+	scheme, secret, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "bearer") {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", fmt.Sprintf("unsupported authorization scheme %s", scheme), 401)
+	}
+	secret = strings.TrimSpace(secret)
+	if !strings.HasPrefix(secret, Prefix) {
+		return auth.Principal{}, invalidToken("not an access token")
+	}
+	t, err := c.storer.GetByHash(ctx, hash(secret))
+	if err != nil {
+		if v, ok := err.(*terr.TodoError); ok && v.HttpCode == 404 {
+			return auth.Principal{}, invalidToken("unknown access token")
+		}
+		return auth.Principal{}, asTodoError(err)
+	}
+	now := nowFn()
+	if t.Revoked != nil {
+		return auth.Principal{}, invalidToken("access token was revoked")
+	}
+	if t.Expires != nil && !now.Before(*t.Expires) {
+		return auth.Principal{}, invalidToken("access token has expired")
+	}
+	if t.LastUsed == nil || now.Sub(*t.LastUsed) > usedEvery {
+		//not worth failing the request over
+		if err := c.storer.Used(ctx, *t.Id, now); err != nil {
+			log.Default().Warn("failed to mark token used", zap.Error(err), zap.String("token", *t.Id))
+		}
+	}
+	p := auth.Principal{UserId: *t.UserId, Scope: *t.Scope}
+	if t.WorkspaceId != nil {
+		p.WorkspaceId = *t.WorkspaceId
+	}
+	return p, nil
+}
+
+// Bearer checks access tokens itself and leaves any other bearer token to next, such as an *auth.JWT. next can be nil
+// when access tokens are the only bearer tokens taken.
+func (c *Core) Bearer(next auth.Authenticator) auth.Authenticator {
+	return auth.AuthenticatorFunc(func(ctx context.Context, authorization string) (auth.Principal, error) {
+		_, value, _ := strings.Cut(authorization, " ")
+		if next == nil || strings.HasPrefix(strings.TrimSpace(value), Prefix) {
+			return c.Authenticate(ctx, authorization)
+		}
+		return next.Authenticate(ctx, authorization)
+	})
+}
+
+// user is the id of whoever is asking. Tokens belong to someone, so unscoped requests can't manage them.
+func user(ctx context.Context) (string, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", terr.ErrorWithCode("unauthorized", "log in to manage access tokens", 401)
+	}
+	if err := auth.Require(ctx, auth.ScopeAdmin); err != nil {
+		return "", err
+	}
+	return p.UserId, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hash is what's stored. The secrets are random, so unlike passwords a fast hash is all they need.
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func invalidToken(reason string) error {
+	return terr.ErrorWithCode("unauthorized", fmt.Sprintf("invalid bearer token: %s", reason), 401)
+}
+
+func notFound(id string) error {
+	return terr.ErrorWithCode("not found", fmt.Sprintf("Token with id %s not found", id), 404)
+}
+
+func asTodoError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
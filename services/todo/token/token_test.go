@@ -0,0 +1,233 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+// mapStorer is the smallest store that does the job. memdb has a real one, but it imports this package.
+type mapStorer struct {
+	tokens  map[string]Token
+	used    int
+	created int
+}
+
+func (s *mapStorer) Create(ctx context.Context, t Token) (Token, error) {
+	s.created++
+	id := fmt.Sprintf("token-%d", s.created)
+	now := nowFn()
+	t.Id, t.Created = &id, &now
+	s.tokens[id] = t
+	return t, nil
+}
+
+func (s *mapStorer) GetById(ctx context.Context, id string) (Token, error) {
+	t, ok := s.tokens[id]
+	if !ok {
+		return Token{}, notFound(id)
+	}
+	return t, nil
+}
+
+func (s *mapStorer) GetByHash(ctx context.Context, hash string) (Token, error) {
+	for _, t := range s.tokens {
+		if *t.Hash == hash {
+			return t, nil
+		}
+	}
+	return Token{}, terr.ErrorWithCode("not found", "Token not found", 404)
+}
+
+func (s *mapStorer) GetAll(ctx context.Context, userId string) ([]Token, error) {
+	var res []Token
+	for _, t := range s.tokens {
+		if *t.UserId == userId {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (s *mapStorer) Revoke(ctx context.Context, id string, at time.Time) (Token, error) {
+	t := s.tokens[id]
+	t.Revoked = &at
+	s.tokens[id] = t
+	return t, nil
+}
+
+func (s *mapStorer) Used(ctx context.Context, id string, at time.Time) error {
+	s.used++
+	t := s.tokens[id]
+	t.LastUsed = &at
+	s.tokens[id] = t
+	return nil
+}
+
+func (s *mapStorer) Prune(ctx context.Context, userId, name string, before time.Time) error {
+	for id, t := range s.tokens {
+		if *t.UserId == userId && *t.Name == name && ((t.Expires != nil && t.Expires.Before(before)) || (t.Revoked != nil && t.Revoked.Before(before))) {
+			delete(s.tokens, id)
+		}
+	}
+	return nil
+}
+
+func newString(s string) *string {
+	return &s
+}
+
+func as(userId, scope string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserId: userId, Scope: scope})
+}
+
+func TestCreate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	type test struct {
+		name  string
+		ctx   context.Context
+		token Token
+		err   string
+	}
+	tests := []test{
+		{name: "happy path", ctx: as("sam", ""), token: Token{Name: newString("ci"), Scope: newString("write")}},
+		{name: "defaults to read", ctx: as("sam", ""), token: Token{Name: newString("ci")}},
+		{name: "unscoped", ctx: context.Background(), token: Token{Name: newString("ci")}, err: "log in to manage access tokens"},
+		{name: "from a write token", ctx: as("sam", "write"), token: Token{Name: newString("ci")}, err: "this needs the admin scope, the token only has write"},
+		{name: "from an admin token", ctx: as("sam", "admin"), token: Token{Name: newString("ci")}},
+		{name: "no name", ctx: as("sam", ""), token: Token{Name: newString(" ")}, err: "name cannot be empty"},
+		{name: "unknown scope", ctx: as("sam", ""), token: Token{Name: newString("ci"), Scope: newString("root")}, err: "unknown scope root, use read, write, admin"},
+		{name: "already expired", ctx: as("sam", ""), token: Token{Name: newString("ci"), Expires: &past}, err: "expires has to be in the future"},
+		{name: "with a secret", ctx: as("sam", ""), token: Token{Name: newString("ci"), Token: newString(Prefix + "mine")}, err: "cannot create a token with an id or secret"},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			c := NewCore(&mapStorer{tokens: map[string]Token{}})
+			created, err := c.Create(tt.ctx, tt.token)
+			if tt.err != "" {
+				if assert.NotNil(t, err) {
+					assert.Equal(t, tt.err, err.(*terr.TodoError).Details())
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, strings.HasPrefix(*created.Token, Prefix))
+			assert.Equal(t, hash(*created.Token), *created.Hash, "only the hash is stored")
+			assert.Equal(t, "sam", *created.UserId)
+			if tt.token.Scope == nil {
+				assert.Equal(t, auth.ScopeRead, *created.Scope)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := &mapStorer{tokens: map[string]Token{}}
+	c := NewCore(store)
+	soon := time.Now().Add(time.Hour)
+	ops := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam", WorkspaceId: "ops"})
+	ci, _ := c.Create(ops, Token{Name: newString("ci"), Scope: newString("write"), Expires: &soon})
+	old, _ := c.Create(as("sam", ""), Token{Name: newString("old")})
+	c.Revoke(as("sam", ""), *old.Id)
+
+	p, err := c.Authenticate(context.Background(), "Bearer "+*ci.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, auth.Principal{UserId: "sam", Scope: "write", WorkspaceId: "ops"}, p, "the token works in its user's workspace")
+	c.Authenticate(context.Background(), "bearer "+*ci.Token)
+	assert.Equal(t, 1, store.used, "last used isn't written on every request")
+
+	for token, reason := range map[string]string{
+		*old.Token:          "access token was revoked",
+		Prefix + "made-up":  "unknown access token",
+		"eyJhbGciOiJIUzI1N": "not an access token",
+	} {
+		_, err := c.Authenticate(context.Background(), "Bearer "+token)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, 401, err.(*terr.TodoError).HttpCode)
+			assert.Equal(t, "invalid bearer token: "+reason, err.(*terr.TodoError).Details())
+		}
+	}
+
+	prev := nowFn
+	nowFn = func() time.Time { return soon }
+	defer func() { nowFn = prev }()
+	_, err = c.Authenticate(context.Background(), "Bearer "+*ci.Token)
+	assert.Equal(t, "invalid bearer token: access token has expired", err.(*terr.TodoError).Details())
+}
+
+func TestRevoke(t *testing.T) {
+	c := NewCore(&mapStorer{tokens: map[string]Token{}})
+	ci, _ := c.Create(as("sam", ""), Token{Name: newString("ci")})
+
+	_, err := c.Revoke(as("alex", ""), *ci.Id)
+	assert.Equal(t, notFound(*ci.Id), err, "someone else's token")
+	revoked, err := c.Revoke(as("sam", ""), *ci.Id)
+	assert.Nil(t, err)
+	assert.NotNil(t, revoked.Revoked)
+	again, err := c.Revoke(as("sam", ""), *ci.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, revoked.Revoked, again.Revoked, "revoking again leaves it as it was")
+
+	tokens, _ := c.GetAll(as("sam", ""))
+	assert.Len(t, tokens, 1)
+	tokens, _ = c.GetAll(as("alex", ""))
+	assert.Empty(t, tokens)
+}
+
+func TestBearer(t *testing.T) {
+	c := NewCore(&mapStorer{tokens: map[string]Token{}})
+	ci, _ := c.Create(as("sam", ""), Token{Name: newString("ci")})
+	jwt := auth.AuthenticatorFunc(func(ctx context.Context, authorization string) (auth.Principal, error) {
+		return auth.Principal{UserId: "from a jwt"}, nil
+	})
+
+	p, err := c.Bearer(jwt).Authenticate(context.Background(), "Bearer "+*ci.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, "sam", p.UserId)
+	p, _ = c.Bearer(jwt).Authenticate(context.Background(), "Bearer eyJhbGciOiJIUzI1N")
+	assert.Equal(t, "from a jwt", p.UserId)
+	_, err = c.Bearer(nil).Authenticate(context.Background(), "Bearer eyJhbGciOiJIUzI1N")
+	assert.NotNil(t, err)
+}
+
+func TestSession(t *testing.T) {
+	store := &mapStorer{tokens: map[string]Token{}}
+	c := NewCore(store)
+	start := time.Now()
+	prev := nowFn
+	defer func() { nowFn = prev }()
+	login := func(at time.Time) Token {
+		nowFn = func() time.Time { return at }
+		expires := at.Add(24 * time.Hour)
+		s, err := c.Session(context.Background(), "sam", Token{Name: newString("oidc login"), Scope: newString("write"), Expires: &expires})
+		assert.Nil(t, err)
+		return s
+	}
+	first := login(start)
+	ci, _ := c.Issue(context.Background(), "sam", Token{Name: newString("ci")})
+	second := login(start.Add(time.Hour))
+	assert.Len(t, store.tokens, 3, "sessions that haven't run out are kept, someone can be logged in on two browsers")
+
+	third := login(start.Add(25 * time.Hour))
+	assert.Len(t, store.tokens, 3, "the first session had run out")
+	assert.NotContains(t, store.tokens, *first.Id)
+	assert.Contains(t, store.tokens, *ci.Id, "only sessions are cleared away")
+
+	c.Revoke(as("sam", ""), *third.Id)
+	fourth := login(start.Add(25*time.Hour + time.Minute))
+	assert.Len(t, store.tokens, 2, "revoked sessions go too, and the second has run out by now")
+	assert.NotContains(t, store.tokens, *second.Id)
+	assert.Contains(t, store.tokens, *fourth.Id)
+
+	_, err := c.Session(context.Background(), "sam", Token{Name: newString("oidc login")})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "a session has to expire", err.(*terr.TodoError).Details())
+	}
+}
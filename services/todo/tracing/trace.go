@@ -17,7 +17,12 @@ import (
 
 var tracer trace.Tracer
 
+// Tracer returns the service tracer. Until InitTracingProvider has been called (i.e: in tests or commands that
+// don't export traces) it falls back to whatever the global otel provider hands out, which is a no-op by default.
 func Tracer() trace.Tracer {
+	if tracer == nil {
+		return otel.Tracer("todo")
+	}
 	return tracer
 }
 func newExporter() (tracesdk.SpanExporter, error) {
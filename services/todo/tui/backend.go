@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"context"
+
+	"github.com/stumacwastaken/todo/client"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// Backend is what the tui needs to read and change todo items. RemoteBackend goes through the api, LocalBackend
+// straight to a core.
+type Backend interface {
+	List(ctx context.Context, query string) ([]todoitem.TodoItem, error)
+	Create(ctx context.Context, item todoitem.TodoItem) (todoitem.TodoItem, error)
+	Update(ctx context.Context, item todoitem.TodoItem, id string) (todoitem.TodoItem, error)
+	Delete(ctx context.Context, id string) (todoitem.TodoItem, error)
+}
+
+// RemoteBackend talks to a running server.
+type RemoteBackend struct {
+	*client.Client
+}
+
+func NewRemoteBackend(c *client.Client) RemoteBackend {
+	return RemoteBackend{Client: c}
+}
+
+func (b RemoteBackend) List(ctx context.Context, query string) ([]todoitem.TodoItem, error) {
+	return b.Client.List(ctx, query).All()
+}
+
+// LocalBackend works on a core directly, i.e: one over a database the tui connected to itself.
+type LocalBackend struct {
+	*todoitem.Core
+}
+
+func NewLocalBackend(core *todoitem.Core) LocalBackend {
+	return LocalBackend{Core: core}
+}
+
+func (b LocalBackend) List(ctx context.Context, query string) ([]todoitem.TodoItem, error) {
+	if query == "" {
+		return b.Core.GetAll(ctx)
+	}
+	return b.Core.Find(ctx, query)
+}
@@ -0,0 +1,366 @@
+// Package tui is a full screen terminal interface for triaging todo items, built on bubbletea. The Model works against
+// a Backend, so it doesn't care if the items are behind the api or in a local database.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+type mode int
+
+const (
+	browsing mode = iota
+	filtering
+	creating
+	editing
+)
+
+// messages the backend commands send back to Update
+type (
+	itemsMsg []todoitem.TodoItem
+	savedMsg struct {
+		item   todoitem.TodoItem
+		status string
+	}
+	errMsg struct{ err error }
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	doneStyle     = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+	helpStyle     = lipgloss.NewStyle().Faint(true)
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	priorityStyle = map[todoitem.Priority]lipgloss.Style{
+		todoitem.PriorityLow:    lipgloss.NewStyle().Foreground(lipgloss.Color("4")),
+		todoitem.PriorityMedium: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		todoitem.PriorityHigh:   lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true),
+	}
+)
+
+const help = "j/k move • space done • n new • e edit • p priority • d delete • / filter • r reload • q quit"
+
+// Model is the bubbletea model for the tui. Run it with tea.NewProgram(tui.NewModel(ctx, backend, filter)).
+type Model struct {
+	ctx     context.Context
+	backend Backend
+	items   []todoitem.TodoItem
+	cursor  int
+	//offset is the first item shown when the list is taller than the screen
+	offset int
+	height int
+	filter string
+	mode   mode
+	input  textinput.Model
+	status string
+	err    error
+}
+
+// NewModel starts on the items matching filter, a filter language expression. Empty is every item that isn't deleted.
+func NewModel(ctx context.Context, backend Backend, filter string) Model {
+	input := textinput.New()
+	input.CharLimit = 255
+	return Model{
+		ctx:     ctx,
+		backend: backend,
+		filter:  filter,
+		input:   input,
+	}
+}
+
+func (m Model) Init() tea.Cmd {
+	return m.load()
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.input.Width = msg.Width - 12
+		return m, nil
+	case itemsMsg:
+		selected := m.selectedId()
+		m.items = msg
+		m.cursor = 0
+		//stay on the same item if it's still there
+		for i, item := range m.items {
+			if item.Id != nil && *item.Id == selected {
+				m.cursor = i
+			}
+		}
+		m.scroll()
+		return m, nil
+	case savedMsg:
+		m.status, m.err = msg.status, nil
+		return m, m.load()
+	case errMsg:
+		m.err = msg.err
+		return m, nil
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		if m.mode != browsing {
+			return m.updateInput(msg)
+		}
+		return m.updateBrowsing(msg)
+	}
+	return m, nil
+}
+
+func (m Model) updateBrowsing(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.status, m.err = "", nil
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.items)-1 {
+			m.cursor++
+		}
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = max(len(m.items)-1, 0)
+	case "r":
+		return m, m.load()
+	case "/":
+		return m.startInput(filtering, m.filter), textinput.Blink
+	case "n":
+		return m.startInput(creating, ""), textinput.Blink
+	case "e", "enter":
+		if item, ok := m.selected(); ok {
+			return m.startInput(editing, *item.Summary), textinput.Blink
+		}
+	case " ", "x":
+		if item, ok := m.selected(); ok {
+			completed := item.Completed == nil || !*item.Completed
+			status := "completed"
+			if !completed {
+				status = "reopened"
+			}
+			return m, m.update(todoitem.TodoItem{Summary: item.Summary, Completed: &completed}, *item.Id, status)
+		}
+	case "p":
+		if item, ok := m.selected(); ok {
+			p := nextPriority(item.Priority)
+			return m, m.update(todoitem.TodoItem{Summary: item.Summary, Priority: &p}, *item.Id, "priority "+string(p))
+		}
+	case "d":
+		if item, ok := m.selected(); ok {
+			id := *item.Id
+			return m, m.run(func(ctx context.Context) (todoitem.TodoItem, error) {
+				return m.backend.Delete(ctx, id)
+			}, "deleted")
+		}
+	}
+	m.scroll()
+	return m, nil
+}
+
+func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.mode = browsing
+		m.input.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		value := strings.TrimSpace(m.input.Value())
+		mode := m.mode
+		m.mode = browsing
+		m.input.Blur()
+		switch mode {
+		case filtering:
+			m.filter = value
+			return m, m.load()
+		case creating:
+			if value == "" {
+				return m, nil
+			}
+			return m, m.run(func(ctx context.Context) (todoitem.TodoItem, error) {
+				return m.backend.Create(ctx, todoitem.TodoItem{Summary: &value})
+			}, "created")
+		case editing:
+			if item, ok := m.selected(); ok {
+				return m, m.update(todoitem.TodoItem{Summary: &value}, *item.Id, "saved")
+			}
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m Model) startInput(mode mode, value string) Model {
+	m.mode = mode
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+	m.input.Focus()
+	return m
+}
+
+func (m Model) View() string {
+	b := &strings.Builder{}
+	title := "todo"
+	if m.filter != "" {
+		title += " — " + m.filter
+	}
+	fmt.Fprintf(b, "%s (%d)\n\n", titleStyle.Render(title), len(m.items))
+	if len(m.items) == 0 {
+		b.WriteString(helpStyle.Render("  nothing here, n to add something") + "\n")
+	}
+	end := len(m.items)
+	if rows := m.rows(); rows > 0 && m.offset+rows < end {
+		end = m.offset + rows
+	}
+	for i := m.offset; i < end; i++ {
+		line := renderItem(m.items[i])
+		if i == m.cursor {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("\n")
+	switch m.mode {
+	case filtering:
+		b.WriteString("filter: " + m.input.View())
+	case creating:
+		b.WriteString("new: " + m.input.View())
+	case editing:
+		b.WriteString("edit: " + m.input.View())
+	default:
+		switch {
+		case m.err != nil:
+			b.WriteString(errorStyle.Render(describe(m.err)))
+		case m.status != "":
+			b.WriteString(m.status)
+		}
+	}
+	b.WriteString("\n" + helpStyle.Render(help))
+	return b.String()
+}
+
+func renderItem(item todoitem.TodoItem) string {
+	check := "[ ]"
+	summary := ""
+	if item.Summary != nil {
+		summary = *item.Summary
+	}
+	if item.Completed != nil && *item.Completed {
+		check = "[x]"
+		summary = doneStyle.Render(summary)
+	}
+	parts := []string{check}
+	if item.Priority != nil && *item.Priority != todoitem.PriorityNone {
+		parts = append(parts, priorityStyle[*item.Priority].Render("!"+string(*item.Priority)))
+	}
+	parts = append(parts, summary)
+	for _, tag := range item.Tags {
+		parts = append(parts, helpStyle.Render("#"+tag))
+	}
+	if item.Due != nil {
+		parts = append(parts, helpStyle.Render("due "+item.Due.Local().Format("2006-01-02")))
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// rows is how many items fit on screen, leaving room for the title, input and help lines. 0 until we know the size.
+func (m Model) rows() int {
+	if m.height == 0 {
+		return 0
+	}
+	return max(m.height-5, 1)
+}
+
+// scroll moves the window of shown items so the cursor stays in it.
+func (m *Model) scroll() {
+	rows := m.rows()
+	if rows == 0 {
+		return
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+func (m Model) selected() (todoitem.TodoItem, bool) {
+	if m.cursor >= len(m.items) {
+		return todoitem.TodoItem{}, false
+	}
+	return m.items[m.cursor], true
+}
+
+func (m Model) selectedId() string {
+	if item, ok := m.selected(); ok && item.Id != nil {
+		return *item.Id
+	}
+	return ""
+}
+
+func (m Model) load() tea.Cmd {
+	backend, ctx, filter := m.backend, m.ctx, m.filter
+	return func() tea.Msg {
+		items, err := backend.List(ctx, filter)
+		if err != nil {
+			return errMsg{err: err}
+		}
+		return itemsMsg(items)
+	}
+}
+
+func (m Model) update(item todoitem.TodoItem, id, status string) tea.Cmd {
+	return m.run(func(ctx context.Context) (todoitem.TodoItem, error) {
+		return m.backend.Update(ctx, item, id)
+	}, status)
+}
+
+// run calls the backend off the ui goroutine, the way bubbletea wants io done.
+func (m Model) run(call func(context.Context) (todoitem.TodoItem, error), status string) tea.Cmd {
+	ctx := m.ctx
+	return func() tea.Msg {
+		item, err := call(ctx)
+		if err != nil {
+			return errMsg{err: err}
+		}
+		return savedMsg{item: item, status: status}
+	}
+}
+
+// describe makes an error fit on one line, TodoErrors would otherwise show as json.
+func describe(err error) string {
+	if v, ok := err.(*terr.TodoError); ok {
+		return fmt.Sprintf("%s: %s", v.Message(), v.Details())
+	}
+	return err.Error()
+}
+
+// nextPriority cycles none → low → medium → high → none.
+func nextPriority(p *todoitem.Priority) todoitem.Priority {
+	current := todoitem.PriorityNone
+	if p != nil {
+		current = *p
+	}
+	return todoitem.PriorityFromRank((current.Rank() + 1) % 4)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
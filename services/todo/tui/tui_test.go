@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+func newSummary(summary string) *string {
+	return &summary
+}
+
+// drive sends msg to the model and runs whatever commands come back until things settle, the way the bubbletea
+// runtime would.
+func drive(t *testing.T, m tea.Model, msgs ...tea.Msg) Model {
+	queue := msgs
+	for len(queue) > 0 {
+		var cmd tea.Cmd
+		m, cmd = m.Update(queue[0])
+		queue = queue[1:]
+		if cmd == nil {
+			continue
+		}
+		//only follow up on the backend, textinput's cursor blinking would keep this going forever
+		if msg := cmd(); isBackend(msg) {
+			queue = append(queue, msg)
+		}
+	}
+	return m.(Model)
+}
+
+func isBackend(msg tea.Msg) bool {
+	switch msg.(type) {
+	case itemsMsg, savedMsg, errMsg:
+		return true
+	}
+	return false
+}
+
+func keys(s string) []tea.Msg {
+	msgs := []tea.Msg{}
+	for _, r := range s {
+		msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return msgs
+}
+
+func key(t tea.KeyType) tea.Msg {
+	return tea.KeyMsg{Type: t}
+}
+
+func newModel(t *testing.T, summaries ...string) (Model, *todoitem.Core) {
+	core := todoitem.NewCore(memdb.NewStore())
+	for _, s := range summaries {
+		core.Create(context.Background(), todoitem.TodoItem{Summary: newSummary(s)})
+	}
+	m := NewModel(context.Background(), NewLocalBackend(core), "")
+	return drive(t, m, m.Init()()), core
+}
+
+func summaries(m Model) []string {
+	res := []string{}
+	for _, item := range m.items {
+		res = append(res, *item.Summary)
+	}
+	return res
+}
+
+func TestBrowse(t *testing.T) {
+	m, _ := newModel(t, "one", "two", "three")
+	assert.Len(t, m.items, 3)
+	m = drive(t, m, keys("jj")...)
+	assert.Equal(t, 2, m.cursor)
+	m = drive(t, m, keys("j")...)
+	assert.Equal(t, 2, m.cursor, "stops at the bottom")
+	m = drive(t, m, keys("gk")...)
+	assert.Equal(t, 0, m.cursor, "stops at the top")
+
+	m = drive(t, m, tea.WindowSizeMsg{Width: 80, Height: 7})
+	m = drive(t, m, keys("G")...)
+	assert.Equal(t, 1, m.offset, "scrolled to keep the cursor on screen")
+	view := m.View()
+	assert.Equal(t, 2, strings.Count(view, "[ ]"), "only what fits")
+}
+
+func TestChanges(t *testing.T) {
+	m, core := newModel(t, "buy milk")
+	ctx := context.Background()
+
+	m = drive(t, m, keys("n")...)
+	m = drive(t, m, keys("walk dog")...)
+	m = drive(t, m, key(tea.KeyEnter))
+	assert.ElementsMatch(t, []string{"buy milk", "walk dog"}, summaries(m))
+	assert.Equal(t, "created", m.status)
+
+	selected, _ := m.selected()
+	m = drive(t, m, keys(" ")...)
+	item, _ := core.GetById(ctx, *selected.Id)
+	assert.True(t, *item.Completed)
+	m = drive(t, m, keys("p")...)
+	m = drive(t, m, keys("p")...)
+	item, _ = core.GetById(ctx, *selected.Id)
+	assert.Equal(t, todoitem.PriorityMedium, *item.Priority)
+
+	m = drive(t, m, keys("e")...)
+	assert.Equal(t, *selected.Summary, m.input.Value(), "starts from the summary")
+	m = drive(t, m, keys("!")...)
+	m = drive(t, m, key(tea.KeyEnter))
+	item, _ = core.GetById(ctx, *selected.Id)
+	assert.Equal(t, *selected.Summary+"!", *item.Summary)
+	assert.Equal(t, *selected.Id, *m.items[m.cursor].Id, "still on the same item")
+
+	m = drive(t, m, keys("d")...)
+	assert.Len(t, m.items, 1)
+}
+
+func TestFilter(t *testing.T) {
+	m, _ := newModel(t, "buy milk", "walk dog")
+	m = drive(t, m, keys("/milk")...)
+	m = drive(t, m, key(tea.KeyEnter))
+	assert.Equal(t, []string{"buy milk"}, summaries(m))
+	assert.Contains(t, m.View(), "todo — milk")
+
+	m = drive(t, m, keys("/")...)
+	m = drive(t, m, key(tea.KeyEsc))
+	assert.Equal(t, "milk", m.filter, "esc leaves it alone")
+
+	m = drive(t, m, keys("/")...)
+	m = drive(t, m, key(tea.KeyBackspace), key(tea.KeyBackspace), key(tea.KeyBackspace), key(tea.KeyBackspace))
+	m = drive(t, m, keys("priority:urgent")...)
+	m = drive(t, m, key(tea.KeyEnter))
+	assert.NotNil(t, m.err)
+	assert.Contains(t, m.View(), "urgent")
+	assert.NotContains(t, m.View(), `"code"`, "not the raw json")
+}
+
+func TestQuit(t *testing.T) {
+	m, _ := newModel(t)
+	_, cmd := m.Update(keys("q")[0])
+	assert.Equal(t, tea.Quit(), cmd())
+
+	m = drive(t, m, keys("nq")...)
+	assert.Equal(t, "q", m.input.Value(), "q is typed while entering text")
+}
@@ -0,0 +1,40 @@
+package user
+
+import "time"
+
+// User is someone who can sign in and own todo items.
+type User struct {
+	Id      *string    `json:"id,omitempty"`
+	Email   *string    `json:"email,omitempty"`
+	Name    *string    `json:"name,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+	//WorkspaceId is the workspace the user belongs to, they only ever see what's in it.
+	WorkspaceId *string `json:"workspaceId,omitempty"`
+	//PasswordHash is the bcrypt hash of the user's password. It never leaves the service. Users that log in through an
+	//identity provider don't have one.
+	PasswordHash *string `json:"-"`
+	//ExternalId is who the user is to the identity provider they log in through, see External.
+	ExternalId *string `json:"-"`
+}
+
+// External is someone an identity provider vouches for, i.e: the claims of an OIDC id token.
+type External struct {
+	//Id has to be unique across providers, i.e: the issuer and subject together
+	Id            string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// NewUser is what's sent to register. The password is hashed before anything is stored.
+type NewUser struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// Credentials are what's sent to log in.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
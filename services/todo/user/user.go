@@ -0,0 +1,247 @@
+// Package user is accounts: registering, logging in with a password and checking credentials on requests. Who owns
+// what is left to the other domains, they only ever see the user's id (see the auth package).
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Storer interface {
+	// Create saves a new user, the store gives out the id. An email that's already taken is a 409.
+	Create(context.Context, User) (User, error)
+	// GetById only sees users in the workspace in ctx, see auth.Workspace.
+	GetById(context.Context, string) (User, error)
+	// GetByEmail looks a user up by their (lower case) email, 404 if there isn't one. It looks in every workspace.
+	GetByEmail(context.Context, string) (User, error)
+	// GetByExternalId looks a user up by their id with an identity provider, 404 if there isn't one. It looks in every
+	// workspace.
+	GetByExternalId(context.Context, string) (User, error)
+	// SetExternalId links a user to an identity provider's id for them.
+	SetExternalId(ctx context.Context, id, externalId string) (User, error)
+}
+
+type Core struct {
+	storer   Storer
+	verified *verified
+}
+
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer:   storer,
+		verified: newVerified(),
+	}
+}
+
+const (
+	minPasswordLength = 8
+	//bcrypt only looks at the first 72 bytes, anything longer would quietly be the same password
+	maxPasswordLength = 72
+)
+
+// pulled out so tests don't spend their time hashing
+var hashCost = bcrypt.DefaultCost
+
+// Register validates and saves a new user into the workspace in ctx, see auth.Workspace. Emails are case insensitive,
+// they're stored lower case, and unique across workspaces so logging in doesn't need to know which one to look in.
+func (c *Core) Register(ctx context.Context, nu NewUser) (User, error) {
+	email, err := normalizeEmail(nu.Email)
+	if err != nil {
+		return User{}, err
+	}
+	if len(nu.Password) < minPasswordLength || len(nu.Password) > maxPasswordLength {
+		return User{}, terr.ErrorWithCode("invalid param", fmt.Sprintf("password must be between %d and %d characters", minPasswordLength, maxPasswordLength), 400)
+	}
+	name := strings.TrimSpace(nu.Name)
+	if name == "" {
+		name = email
+	}
+	if _, err := c.storer.GetByEmail(ctx, email); err == nil {
+		return User{}, emailTaken(email)
+	} else if !isNotFound(err) {
+		return User{}, asTodoError(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), hashCost)
+	if err != nil {
+		log.Default().Error("failed to hash password", zap.Error(err))
+		return User{}, terr.InternalError()
+	}
+	h := string(hash)
+	workspace := auth.Workspace(ctx)
+	created, err := c.storer.Create(ctx, User{Email: &email, Name: &name, WorkspaceId: &workspace, PasswordHash: &h})
+	if err != nil {
+		return User{}, asTodoError(err)
+	}
+	return created, nil
+}
+
+// Login checks an email and password, returning the user they belong to. Both a wrong password and an unknown email
+// are the same 401, so it can't be used to find out who has an account.
+func (c *Core) Login(ctx context.Context, creds Credentials) (User, error) {
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		return User{}, wrongLogin()
+	}
+	u, err := c.storer.GetByEmail(ctx, email)
+	if err != nil {
+		if isNotFound(err) {
+			//still do the work of a compare, so how long we take doesn't give it away either
+			bcrypt.CompareHashAndPassword(dummyHash(), []byte(creds.Password))
+			return User{}, wrongLogin()
+		}
+		return User{}, asTodoError(err)
+	}
+	if u.PasswordHash == nil || bcrypt.CompareHashAndPassword([]byte(*u.PasswordHash), []byte(creds.Password)) != nil {
+		return User{}, wrongLogin()
+	}
+	return u, nil
+}
+
+// LoginExternal returns the user an identity provider has vouched for, making them one the first time. Someone who
+// already registered with a password is linked by email, but only if the provider has verified it, otherwise anyone
+// who could set that email with the provider would get their account.
+func (c *Core) LoginExternal(ctx context.Context, ext External) (User, error) {
+	u, err := c.storer.GetByExternalId(ctx, ext.Id)
+	if err == nil {
+		return u, nil
+	}
+	if !isNotFound(err) {
+		return User{}, asTodoError(err)
+	}
+	email, err := normalizeEmail(ext.Email)
+	if err != nil {
+		return User{}, terr.ErrorWithCode("unauthorized", "the identity provider didn't give a valid email", 401)
+	}
+	u, err = c.storer.GetByEmail(ctx, email)
+	switch {
+	case err == nil && (!ext.EmailVerified || u.ExternalId != nil):
+		return User{}, emailTaken(email)
+	case err == nil:
+		linked, err := c.storer.SetExternalId(ctx, *u.Id, ext.Id)
+		if err != nil {
+			return User{}, asTodoError(err)
+		}
+		return linked, nil
+	case !isNotFound(err):
+		return User{}, asTodoError(err)
+	}
+	name := strings.TrimSpace(ext.Name)
+	if name == "" {
+		name = email
+	}
+	workspace := auth.Workspace(ctx)
+	created, err := c.storer.Create(ctx, User{Email: &email, Name: &name, WorkspaceId: &workspace, ExternalId: &ext.Id})
+	if err != nil {
+		return User{}, asTodoError(err)
+	}
+	return created, nil
+}
+
+func (c *Core) GetById(ctx context.Context, id string) (User, error) {
+	u, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return User{}, asTodoError(err)
+	}
+	return u, nil
+}
+
+// GetByEmail finds a user however their email is written, i.e: to share something with them.
+func (c *Core) GetByEmail(ctx context.Context, email string) (User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	u, err := c.storer.GetByEmail(ctx, email)
+	if err != nil {
+		return User{}, asTodoError(err)
+	}
+	return u, nil
+}
+
+// Authenticate checks a basic auth header (email and password), making Core an auth.Authenticator. Clients send them
+// with every request, so ones that were right are remembered for a minute rather than bcrypt compared every time.
+func (c *Core) Authenticate(ctx context.Context, authorization string) (auth.Principal, error) {
+	scheme, value, _ := strings.Cut(authorization, " ")
+	if !strings.EqualFold(scheme, "basic") {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", fmt.Sprintf("unsupported authorization scheme %s", scheme), 401)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", "malformed basic credentials", 401)
+	}
+	email, password, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return auth.Principal{}, terr.ErrorWithCode("unauthorized", "malformed basic credentials", 401)
+	}
+	if p, ok := c.verified.get(strings.ToLower(strings.TrimSpace(email)), password); ok {
+		return p, nil
+	}
+	u, err := c.Login(ctx, Credentials{Email: email, Password: password})
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	p := auth.Principal{UserId: *u.Id, WorkspaceId: workspaceOf(u)}
+	c.verified.put(*u.Email, password, p)
+	return p, nil
+}
+
+// workspaceOf is the user's workspace, users saved before there were workspaces are in the default one.
+func workspaceOf(u User) string {
+	if u.WorkspaceId == nil {
+		return auth.DefaultWorkspace
+	}
+	return *u.WorkspaceId
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	//a display name or angle brackets parse fine, but aren't an email on their own
+	if err != nil || addr.Address != email {
+		return "", terr.ErrorWithCode("invalid param", fmt.Sprintf("invalid email %q", email), 400)
+	}
+	return email, nil
+}
+
+var (
+	dummyOnce sync.Once
+	dummy     []byte
+)
+
+// dummyHash is compared against when there's no user, at the same cost as a real hash.
+func dummyHash() []byte {
+	dummyOnce.Do(func() {
+		dummy, _ = bcrypt.GenerateFromPassword([]byte("not anyone's password"), hashCost)
+	})
+	return dummy
+}
+
+func wrongLogin() error {
+	return terr.ErrorWithCode("unauthorized", "Wrong email or password", 401)
+}
+
+func emailTaken(email string) error {
+	return terr.ErrorWithCode("conflict", fmt.Sprintf("A user with email %s already exists", email), 409)
+}
+
+func isNotFound(err error) bool {
+	v, ok := err.(*terr.TodoError)
+	return ok && v.HttpCode == 404
+}
+
+func asTodoError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
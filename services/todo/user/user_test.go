@@ -0,0 +1,227 @@
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mapStorer is the smallest store that does the job. memdb has a real one, but it imports this package.
+type mapStorer struct {
+	users map[string]User
+}
+
+func (s *mapStorer) Create(ctx context.Context, u User) (User, error) {
+	id := "user-" + *u.Email
+	u.Id = &id
+	s.users[id] = u
+	return u, nil
+}
+
+func (s *mapStorer) GetById(ctx context.Context, id string) (User, error) {
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, terr.ErrorWithCode("not found", "User not found", 404)
+	}
+	return u, nil
+}
+
+func (s *mapStorer) GetByEmail(ctx context.Context, email string) (User, error) {
+	for _, u := range s.users {
+		if *u.Email == email {
+			return u, nil
+		}
+	}
+	return User{}, terr.ErrorWithCode("not found", "User not found", 404)
+}
+
+func (s *mapStorer) GetByExternalId(ctx context.Context, externalId string) (User, error) {
+	for _, u := range s.users {
+		if u.ExternalId != nil && *u.ExternalId == externalId {
+			return u, nil
+		}
+	}
+	return User{}, terr.ErrorWithCode("not found", "User not found", 404)
+}
+
+func (s *mapStorer) SetExternalId(ctx context.Context, id, externalId string) (User, error) {
+	u := s.users[id]
+	u.ExternalId = &externalId
+	s.users[id] = u
+	return u, nil
+}
+
+func newCore(t *testing.T) *Core {
+	prev := hashCost
+	hashCost = bcrypt.MinCost
+	t.Cleanup(func() { hashCost = prev })
+	return NewCore(&mapStorer{users: map[string]User{}})
+}
+
+func TestRegister(t *testing.T) {
+	type test struct {
+		name string
+		req  NewUser
+		err  error
+	}
+	tests := []test{
+		{name: "happy path", req: NewUser{Email: "Sam@Example.com ", Name: "Sam", Password: "correct horse"}},
+		{name: "bad email", req: NewUser{Email: "sam", Password: "correct horse"},
+			err: terr.ErrorWithCode("invalid param", `invalid email "sam"`, 400)},
+		{name: "display name isn't an email", req: NewUser{Email: "Sam <sam@example.com>", Password: "correct horse"},
+			err: terr.ErrorWithCode("invalid param", `invalid email "sam <sam@example.com>"`, 400)},
+		{name: "short password", req: NewUser{Email: "sam@example.com", Password: "short"},
+			err: terr.ErrorWithCode("invalid param", "password must be between 8 and 72 characters", 400)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			u, err := newCore(t).Register(context.Background(), tt.req)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, "sam@example.com", *u.Email)
+				assert.Equal(t, "Sam", *u.Name)
+				assert.NotContains(t, *u.PasswordHash, "correct horse")
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	core := newCore(t)
+	_, err := core.Register(context.Background(), NewUser{Email: "sam@example.com", Password: "correct horse"})
+	assert.Nil(t, err)
+	_, err = core.Register(context.Background(), NewUser{Email: "SAM@example.com", Password: "battery staple"})
+	assert.Equal(t, terr.ErrorWithCode("conflict", "A user with email sam@example.com already exists", 409), err)
+}
+
+func TestLoginAndAuthenticate(t *testing.T) {
+	core := newCore(t)
+	ctx := auth.WithWorkspace(context.Background(), "ops")
+	registered, err := core.Register(ctx, NewUser{Email: "sam@example.com", Name: "Sam", Password: "correct horse"})
+	assert.Nil(t, err)
+	assert.Equal(t, "ops", *registered.WorkspaceId, "users join the workspace they're registered in")
+	wrong := terr.ErrorWithCode("unauthorized", "Wrong email or password", 401)
+
+	u, err := core.Login(ctx, Credentials{Email: "SAM@example.com", Password: "correct horse"})
+	assert.Nil(t, err)
+	assert.Equal(t, registered.Id, u.Id)
+	_, err = core.Login(ctx, Credentials{Email: "sam@example.com", Password: "wrong horse"})
+	assert.Equal(t, wrong, err)
+	_, err = core.Login(ctx, Credentials{Email: "alex@example.com", Password: "correct horse"})
+	assert.Equal(t, wrong, err, "no telling who has an account")
+
+	basic := func(s string) string { return "Basic " + base64.StdEncoding.EncodeToString([]byte(s)) }
+	type test struct {
+		name   string
+		header string
+		err    error
+	}
+	tests := []test{
+		{name: "happy path", header: basic("sam@example.com:correct horse")},
+		{name: "scheme is case insensitive", header: "basic " + basic("sam@example.com:correct horse")[6:]},
+		{name: "wrong password", header: basic("sam@example.com:nope"), err: wrong},
+		{name: "no colon", header: basic("sam@example.com"), err: terr.ErrorWithCode("unauthorized", "malformed basic credentials", 401)},
+		{name: "not base64", header: "Basic !!!", err: terr.ErrorWithCode("unauthorized", "malformed basic credentials", 401)},
+		{name: "other scheme", header: "Digest abc", err: terr.ErrorWithCode("unauthorized", "unsupported authorization scheme Digest", 401)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			p, err := core.Authenticate(ctx, tt.header)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, auth.Principal{UserId: *registered.Id, WorkspaceId: "ops"}, p)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+// countingStorer counts lookups by email, which is what a login costs besides the compare.
+type countingStorer struct {
+	*mapStorer
+	lookups int
+}
+
+func (s *countingStorer) GetByEmail(ctx context.Context, email string) (User, error) {
+	s.lookups++
+	return s.mapStorer.GetByEmail(ctx, email)
+}
+
+func TestAuthenticateRemembers(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+	prev := verifiedNowFn
+	t.Cleanup(func() { verifiedNowFn = prev })
+	verifiedNowFn = func() time.Time { return now }
+	store := &countingStorer{mapStorer: &mapStorer{users: map[string]User{}}}
+	core := newCore(t)
+	core.storer = store
+	ctx := context.Background()
+	registered, _ := core.Register(ctx, NewUser{Email: "sam@example.com", Password: "correct horse"})
+	basic := func(s string) string { return "Basic " + base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	store.lookups = 0
+	_, err := core.Authenticate(ctx, basic("sam@example.com:correct horse"))
+	assert.Nil(t, err)
+	p, err := core.Authenticate(ctx, basic("Sam@example.com:correct horse"))
+	assert.Nil(t, err)
+	assert.Equal(t, *registered.Id, p.UserId)
+	assert.Equal(t, 1, store.lookups, "checked once")
+
+	_, err = core.Authenticate(ctx, basic("sam@example.com:correct horsf"))
+	assert.Equal(t, terr.ErrorWithCode("unauthorized", "Wrong email or password", 401), err, "only the right password is remembered")
+	assert.Equal(t, 2, store.lookups)
+
+	now = now.Add(verifiedFor)
+	_, err = core.Authenticate(ctx, basic("sam@example.com:correct horse"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, store.lookups, "checked again once it's been a while")
+}
+
+func TestLoginExternal(t *testing.T) {
+	ctx := context.Background()
+	type test struct {
+		name     string
+		ext      External
+		userId   string
+		password bool
+		err      error
+	}
+	tests := []test{
+		{name: "first time", ext: External{Id: "idp sub-alex", Email: "Alex@example.com", Name: "Alex"}, userId: "user-alex@example.com"},
+		{name: "again", ext: External{Id: "idp sub-sam", Email: "changed@example.com"}, userId: "user-sam@example.com", password: true},
+		{name: "linked by a verified email", ext: External{Id: "idp sub-pat", Email: "pat@example.com", EmailVerified: true}, userId: "user-pat@example.com", password: true},
+		{name: "not linked by an unverified email", ext: External{Id: "idp sub-pat", Email: "pat@example.com"},
+			err: terr.ErrorWithCode("conflict", "A user with email pat@example.com already exists", 409)},
+		{name: "already linked to someone else", ext: External{Id: "other sub-sam", Email: "sam@example.com", EmailVerified: true},
+			err: terr.ErrorWithCode("conflict", "A user with email sam@example.com already exists", 409)},
+		{name: "no email", ext: External{Id: "idp sub-nobody"},
+			err: terr.ErrorWithCode("unauthorized", "the identity provider didn't give a valid email", 401)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			core := newCore(t)
+			core.Register(ctx, NewUser{Email: "pat@example.com", Password: "correct horse"})
+			sam, _ := core.Register(ctx, NewUser{Email: "sam@example.com", Password: "correct horse"})
+			core.storer.SetExternalId(ctx, *sam.Id, "idp sub-sam")
+
+			u, err := core.LoginExternal(ctx, tt.ext)
+			assert.Equal(t, tt.err, err)
+			if tt.err != nil {
+				return
+			}
+			assert.Equal(t, tt.userId, *u.Id)
+			assert.Equal(t, tt.ext.Id, *u.ExternalId)
+			assert.Equal(t, tt.password, u.PasswordHash != nil)
+			again, _ := core.LoginExternal(ctx, tt.ext)
+			assert.Equal(t, u.Id, again.Id)
+		}
+		t.Run(tt.name, tf)
+	}
+}
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+)
+
+// pulled out so tests can move the clock
+var verifiedNowFn = time.Now
+
+const (
+	//verifiedFor is how long a checked email and password are taken at their word. It's also how long a password
+	//change or a removed user takes to reach requests that use basic auth
+	verifiedFor = time.Minute
+	//maxVerified caps how many are remembered, past it the expired ones are dropped and then, if need be, all of them
+	maxVerified = 10000
+)
+
+// verified remembers basic auth credentials that were right recently, so a client sending them with every request
+// doesn't cost a bcrypt compare (and a database lookup) every time. Only successes are kept, wrong passwords are
+// always checked in full. Credentials are kept as an HMAC under a key that only lives in memory, never as they are.
+type verified struct {
+	key     []byte
+	mu      sync.Mutex
+	entries map[string]verifiedEntry
+}
+
+type verifiedEntry struct {
+	principal auth.Principal
+	expires   time.Time
+}
+
+func newVerified() *verified {
+	key := make([]byte, 32)
+	//without a random key it still works, the entries are just easier to guess from a memory dump
+	rand.Read(key)
+	return &verified{key: key, entries: map[string]verifiedEntry{}}
+}
+
+func (v *verified) sum(email, password string) string {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(email))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return string(mac.Sum(nil))
+}
+
+// get returns who the credentials belong to, if they were right less than verifiedFor ago.
+func (v *verified) get(email, password string) (auth.Principal, bool) {
+	key := v.sum(email, password)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	e, ok := v.entries[key]
+	if !ok {
+		return auth.Principal{}, false
+	}
+	if !verifiedNowFn().Before(e.expires) {
+		delete(v.entries, key)
+		return auth.Principal{}, false
+	}
+	return e.principal, true
+}
+
+func (v *verified) put(email, password string, p auth.Principal) {
+	key := v.sum(email, password)
+	now := verifiedNowFn()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.entries) >= maxVerified {
+		for k, e := range v.entries {
+			if !now.Before(e.expires) {
+				delete(v.entries, k)
+			}
+		}
+		if len(v.entries) >= maxVerified {
+			v.entries = map[string]verifiedEntry{}
+		}
+	}
+	v.entries[key] = verifiedEntry{principal: p, expires: now.Add(verifiedFor)}
+}
@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/tracing"
+	"go.uber.org/zap"
+)
+
+// Headers sent with every delivery. Receivers should check the signature, and can use the delivery id to ignore
+// repeats, since a delivery is retried until it gets a 2xx back.
+const (
+	HeaderEvent     = "X-Todo-Event"
+	HeaderDelivery  = "X-Todo-Delivery"
+	HeaderSignature = "X-Todo-Signature-256"
+)
+
+// Sign is the value of the signature header for a payload: sha256= followed by the hex HMAC-SHA256 of the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header against a payload in constant time.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// pulled out so tests don't have to wait around
+var (
+	pollInterval = time.Second
+	//retryBase is the wait after the first failure, doubling with every failure after that up to maxRetryWait
+	retryBase    = 10 * time.Second
+	maxRetryWait = time.Hour
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it's marked failed. With the default backoff that's
+	// about three and a half hours of trying.
+	MaxAttempts = 12
+	claimBatch  = 20
+	//sendTimeout is how long a receiver has to answer. It's also the claim lease, with some headroom
+	sendTimeout = 10 * time.Second
+	claimLease  = 3 * sendTimeout
+)
+
+// errPrivate is what a delivery to somewhere on our own network fails with.
+var errPrivate = errors.New("receiver is on a private, loopback or link-local address")
+
+// cgnat is the shared address space carriers use (RFC 6598), private in all but name.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Dispatcher sends queued deliveries. Claims are leased in the store, so running one per replica is fine.
+type Dispatcher struct {
+	storer Storer
+	client *http.Client
+}
+
+// NewDispatcher only delivers to public addresses. Anyone with an admin token can register a webhook, so otherwise
+// they could have the server post to (and time) things only it can reach, like the cloud metadata service.
+func NewDispatcher(storer Storer) *Dispatcher {
+	return &Dispatcher{
+		storer: storer,
+		client: newClient(publicOnly),
+	}
+}
+
+// AllowPrivate lets deliveries go to private and loopback addresses too, for receivers on the same network.
+func (d *Dispatcher) AllowPrivate() {
+	d.client = newClient(nil)
+}
+
+// newClient checks every address it connects to with control, redirects included. There's no proxy, since the proxy
+// would be what's checked rather than the receiver.
+func newClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: sendTimeout, Control: control}
+	return &http.Client{
+		Timeout: sendTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: sendTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// publicOnly refuses connections to anywhere but the public internet. It runs on the address being dialed, after the
+// name is resolved, so a name that points somewhere else by the time it's used can't get around it.
+func publicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !public(ip) {
+		return errPrivate
+	}
+	return nil
+}
+
+func public(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip))
+}
+
+// Run sends deliveries as they come due until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		//keep going while there's a backlog, otherwise wait for the next tick
+		n, err := d.DeliverDue(ctx)
+		if err != nil {
+			log.Default().Error("failed to claim webhook deliveries", zap.Error(err))
+		}
+		if n == claimBatch && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes one pass over the queue, sending whatever is due. It returns how many deliveries it tried.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook-deliver-due")
+	defer span.End()
+	due, err := d.storer.Claim(ctx, nowFn(), claimLease, claimBatch)
+	if err != nil {
+		return 0, err
+	}
+	hooks := map[string]Webhook{}
+	for _, del := range due {
+		//the webhook is only found in the workspace it was made in
+		ctx := auth.WithWorkspace(ctx, del.WorkspaceId)
+		hook, ok := hooks[del.WebhookId]
+		if !ok {
+			hook, err = d.storer.GetById(ctx, del.WebhookId)
+			if err != nil {
+				//most likely deleted since we claimed, which takes the delivery with it
+				log.Default().Warn("skipping delivery for missing webhook", zap.String("webhook", del.WebhookId), zap.Error(err))
+				continue
+			}
+			hooks[del.WebhookId] = hook
+		}
+		del = d.attempt(ctx, hook, del)
+		if err := d.storer.SaveAttempt(ctx, del); err != nil {
+			//it'll be claimed again once the lease runs out, so the receiver may see it twice
+			log.Default().Error("failed to save webhook delivery attempt", zap.String("delivery", del.Id), zap.Error(err))
+		}
+	}
+	return len(due), nil
+}
+
+// attempt sends the delivery once and works out what happens to it next.
+func (d *Dispatcher) attempt(ctx context.Context, hook Webhook, del Delivery) Delivery {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook-attempt")
+	defer span.End()
+	now := nowFn()
+	del.Attempts++
+	del.LastAttempt = &now
+	del.LastStatus, del.LastError = 0, ""
+
+	status, err := d.send(ctx, hook, del)
+	del.LastStatus = status
+	if err == nil {
+		del.Status = StatusDelivered
+		del.NextAttempt = nil
+		return del
+	}
+	del.LastError = err.Error()
+	if del.Attempts >= MaxAttempts {
+		del.Status = StatusFailed
+		del.NextAttempt = nil
+		return del
+	}
+	next := now.Add(backoff(del.Attempts))
+	del.NextAttempt = &next
+	return del
+}
+
+func (d *Dispatcher) send(ctx context.Context, hook Webhook, del Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *hook.Url, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-webhooks")
+	req.Header.Set(HeaderEvent, string(del.Event))
+	req.Header.Set(HeaderDelivery, del.Id)
+	req.Header.Set(HeaderSignature, Sign(*hook.Secret, del.Payload))
+	res, err := d.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivate) {
+			return 0, errPrivate
+		}
+		return 0, err
+	}
+	defer res.Body.Close()
+	//the body is left out of the delivery log, it'd be a way to read whatever the url points at
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver answered %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// backoff is how long to wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	wait := retryBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxRetryWait {
+			return maxRetryWait
+		}
+	}
+	return wait
+}
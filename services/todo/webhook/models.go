@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// Webhook is a url we POST todo item events to.
+type Webhook struct {
+	Id  *string `json:"id,omitempty"`
+	Url *string `json:"url,omitempty"`
+	//Secret signs every payload (see Sign). It's generated if left empty, and only handed back when the webhook is created.
+	Secret *string `json:"secret,omitempty"`
+	//Events the webhook wants, defaults to created, completed and deleted.
+	Events  []todoitem.EventType `json:"events,omitempty"`
+	Created *time.Time           `json:"created,omitempty"`
+	//OwnerId is the user that made the webhook. It only hears about their items.
+	OwnerId *string `json:"ownerId,omitempty"`
+	//WorkspaceId is the workspace the webhook was made in, it only hears about what happens there.
+	WorkspaceId *string `json:"-"`
+}
+
+// Wants reports if the webhook is subscribed to the event type.
+func (w Webhook) Wants(t todoitem.EventType) bool {
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	StatusPending   DeliveryStatus = "pending"
+	StatusDelivered DeliveryStatus = "delivered"
+	//StatusFailed means we've given up retrying
+	StatusFailed DeliveryStatus = "failed"
+)
+
+// Delivery is an event queued up for a webhook, along with how sending it has gone so far. The delivery log is made
+// of these.
+type Delivery struct {
+	Id        string `json:"id"`
+	WebhookId string `json:"webhookId"`
+	//EventId is the outbox id of the event, if it came through the outbox
+	EventId     string             `json:"eventId,omitempty"`
+	Event       todoitem.EventType `json:"event"`
+	Payload     json.RawMessage    `json:"payload"`
+	Status      DeliveryStatus     `json:"status"`
+	Attempts    int                `json:"attempts"`
+	NextAttempt *time.Time         `json:"nextAttempt,omitempty"`
+	LastAttempt *time.Time         `json:"lastAttempt,omitempty"`
+	//LastStatus is the http status code the receiver answered with, 0 if it never answered
+	LastStatus int       `json:"lastStatus,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+	Created    time.Time `json:"created"`
+	//WorkspaceId is the workspace of the webhook the delivery is for, Claim fills it in so the Dispatcher knows where to
+	//look the webhook up.
+	WorkspaceId string `json:"-"`
+}
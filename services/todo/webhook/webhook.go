@@ -0,0 +1,308 @@
+// Package webhook lets users register urls that todo item events are POSTed to. Events are queued in the store as
+// they happen and sent by a Dispatcher, which retries with exponential backoff until the receiver takes them.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/log"
+	"github.com/stumacwastaken/todo/todoitem"
+	"go.uber.org/zap"
+)
+
+type Storer interface {
+	Create(context.Context, Webhook) (Webhook, error)
+	// GetAll, GetById and Delete only see webhooks in the workspace in ctx, see auth.Workspace. So do Enqueue,
+	// SaveAttempt and Deliveries with deliveries.
+	GetAll(context.Context) ([]Webhook, error)
+	GetById(context.Context, string) (Webhook, error)
+	Delete(context.Context, string) error
+	// Enqueue saves new pending deliveries, ids are given out by the store. Deliveries with an EventId the webhook
+	// already has a delivery for are skipped.
+	Enqueue(context.Context, []Delivery) error
+	// Claim returns up to limit pending deliveries due by now, pushing their next attempt back by lease so nobody
+	// else picks them up while they're being sent. It's the one read across every workspace, since the Dispatcher
+	// sends for all of them, so each delivery comes back with its WorkspaceId.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// SaveAttempt records the outcome of sending a delivery.
+	SaveAttempt(context.Context, Delivery) error
+	// Deliveries returns a webhook's most recent deliveries, newest first.
+	Deliveries(ctx context.Context, webhookId string, limit int) ([]Delivery, error)
+}
+
+type Core struct {
+	storer  Storer
+	members todoitem.Members
+}
+
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// ShareWith sends webhooks the events of lists shared with their owner as well as the owner's own, like the other
+// change feeds. Without it a webhook only hears about its owner's items.
+func (c *Core) ShareWith(members todoitem.Members) {
+	c.members = members
+}
+
+// DefaultEvents are what a webhook gets if it doesn't ask for anything in particular.
+var DefaultEvents = []todoitem.EventType{todoitem.EventCreated, todoitem.EventCompleted, todoitem.EventDeleted}
+
+var knownEvents = []todoitem.EventType{todoitem.EventCreated, todoitem.EventUpdated, todoitem.EventCompleted, todoitem.EventDeleted,
+	todoitem.EventAssigned}
+
+const (
+	minSecretLength = 16
+	// MaxDeliveries is the most of the delivery log that can be asked for at once.
+	MaxDeliveries = 100
+)
+
+// pulled out for testing
+var nowFn = time.Now
+
+// Create needs the admin scope, webhooks send items somewhere else.
+func (c *Core) Create(ctx context.Context, hook Webhook) (Webhook, error) {
+	if err := auth.Require(ctx, auth.ScopeAdmin); err != nil {
+		return Webhook{}, err
+	}
+	if hook.Id != nil {
+		return Webhook{}, terr.ErrorWithCode("invalid param", "cannot create a webhook with an already existing id", 400)
+	}
+	if hook.Url == nil {
+		return Webhook{}, terr.ErrorWithCode("invalid param", "url cannot be empty", 400)
+	}
+	u, err := url.Parse(*hook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, terr.ErrorWithCode("invalid param", "url must be an absolute http or https url", 400)
+	}
+	if len(hook.Events) == 0 {
+		hook.Events = DefaultEvents
+	}
+	for _, e := range hook.Events {
+		if !known(e) {
+			return Webhook{}, terr.ErrorWithCode("invalid param", fmt.Sprintf("unknown event %s", e), 400)
+		}
+	}
+	if hook.Secret == nil || *hook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			log.Default().Error("failed to generate webhook secret", zap.Error(err))
+			return Webhook{}, terr.InternalError()
+		}
+		hook.Secret = &secret
+	} else if len(*hook.Secret) < minSecretLength {
+		return Webhook{}, terr.ErrorWithCode("invalid param", fmt.Sprintf("secret must be at least %d characters", minSecretLength), 400)
+	}
+	workspace := auth.Workspace(ctx)
+	hook.OwnerId = owner(ctx)
+	hook.WorkspaceId = &workspace
+	created, err := c.storer.Create(ctx, hook)
+	if err != nil {
+		return Webhook{}, asTodoError(err)
+	}
+	//this is the one time the secret goes back out, so the caller can verify payloads
+	created.Secret = hook.Secret
+	return created, nil
+}
+
+// GetAll returns the caller's webhooks.
+func (c *Core) GetAll(ctx context.Context) ([]Webhook, error) {
+	all, err := c.storer.GetAll(ctx)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	hooks := []Webhook{}
+	for _, h := range all {
+		if visible(ctx, h) {
+			h.Secret = nil
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks, nil
+}
+
+func (c *Core) GetById(ctx context.Context, id string) (Webhook, error) {
+	hook, err := c.get(ctx, id)
+	if err != nil {
+		return Webhook{}, err
+	}
+	hook.Secret = nil
+	return hook, nil
+}
+
+// Delete removes the webhook, along with anything still waiting to be sent to it.
+func (c *Core) Delete(ctx context.Context, id string) error {
+	if err := auth.Require(ctx, auth.ScopeAdmin); err != nil {
+		return err
+	}
+	if _, err := c.get(ctx, id); err != nil {
+		return err
+	}
+	return asTodoError(c.storer.Delete(ctx, id))
+}
+
+// Deliveries is the delivery log for a webhook, newest first.
+func (c *Core) Deliveries(ctx context.Context, id string, limit int) ([]Delivery, error) {
+	if limit <= 0 || limit > MaxDeliveries {
+		return nil, terr.ErrorWithCode("invalid param", fmt.Sprintf("limit must be between 1 and %d", MaxDeliveries), 400)
+	}
+	if _, err := c.get(ctx, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := c.storer.Deliveries(ctx, id, limit)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	return deliveries, nil
+}
+
+// Publish queues the event for every webhook that wants it, making Core a todoitem.Publisher. Nothing is sent here,
+// that's left to the Dispatcher, so a slow receiver can't hold up the change that caused the event. When events come
+// through the outbox use Enqueue instead.
+func (c *Core) Publish(ctx context.Context, e todoitem.Event) {
+	if err := c.Enqueue(ctx, "", e); err != nil {
+		log.Default().Error("failed to queue webhook deliveries", zap.Error(err), zap.String("event", string(e.Type)))
+	}
+}
+
+// Enqueue queues the event for every webhook in its workspace that wants it and whose owner can see the item, in
+// their own list or one shared with them. eventId makes it safe to call again with the same event, each webhook only
+// gets one delivery per id. It can be left empty if there's nothing to dedupe on.
+func (c *Core) Enqueue(ctx context.Context, eventId string, e todoitem.Event) error {
+	//events from the outbox don't come with a request to say which workspace they're in, so the store is told
+	workspace := e.Workspace
+	if workspace == "" {
+		workspace = auth.DefaultWorkspace
+	}
+	ctx = auth.WithWorkspace(ctx, workspace)
+	hooks, err := c.storer.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	now := nowFn()
+	var queued []Delivery
+	for _, h := range hooks {
+		if !h.Wants(e.Type) || !e.InWorkspace(workspaceOf(h)) {
+			continue
+		}
+		sees, err := c.sees(ctx, h, e)
+		if err != nil {
+			return err
+		}
+		if !sees {
+			continue
+		}
+		queued = append(queued, Delivery{
+			WebhookId:   *h.Id,
+			EventId:     eventId,
+			Event:       e.Type,
+			Payload:     payload,
+			Status:      StatusPending,
+			NextAttempt: &now,
+		})
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+	return c.storer.Enqueue(ctx, queued)
+}
+
+// sees reports if a webhook's owner can see the item an event is about. Assignments are only for the people assigned,
+// sharing doesn't come into it.
+func (c *Core) sees(ctx context.Context, hook Webhook, e todoitem.Event) (bool, error) {
+	if e.VisibleTo(hook.OwnerId) {
+		return true, nil
+	}
+	if c.members == nil || e.Type == todoitem.EventAssigned || hook.OwnerId == nil || e.Item.OwnerId == nil {
+		return false, nil
+	}
+	role, err := c.members.Role(ctx, *e.Item.OwnerId, *hook.OwnerId)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
+}
+
+// get reads a webhook for the request in ctx, someone else's is the same 404 as one that doesn't exist.
+func (c *Core) get(ctx context.Context, id string) (Webhook, error) {
+	hook, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return Webhook{}, asTodoError(err)
+	}
+	if !visible(ctx, hook) {
+		return Webhook{}, terr.ErrorWithCode("not found", fmt.Sprintf("Webhook with id %s not found", id), 404)
+	}
+	return hook, nil
+}
+
+// owner is the user the request in ctx is for, nil if it's unscoped. Like todoitem's scope, anonymous requests that
+// aren't unscoped are for nobody, and see no webhooks.
+func owner(ctx context.Context) *string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return &p.UserId
+	}
+	if auth.Unscoped(ctx) {
+		return nil
+	}
+	nobody := ""
+	return &nobody
+}
+
+// visible follows the same rules as todoitem.VisibleTo: unscoped requests see every webhook in the workspace, users
+// only their own.
+func visible(ctx context.Context, hook Webhook) bool {
+	if workspaceOf(hook) != auth.Workspace(ctx) {
+		return false
+	}
+	user := owner(ctx)
+	return user == nil || (hook.OwnerId != nil && *hook.OwnerId == *user)
+}
+
+// workspaceOf is the webhook's workspace, webhooks made before there were workspaces are in the default one.
+func workspaceOf(hook Webhook) string {
+	if hook.WorkspaceId == nil {
+		return auth.DefaultWorkspace
+	}
+	return *hook.WorkspaceId
+}
+
+func known(e todoitem.EventType) bool {
+	for _, k := range knownEvents {
+		if k == e {
+			return true
+		}
+	}
+	return false
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func asTodoError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
@@ -0,0 +1,563 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stumacwastaken/todo/auth"
+	terr "github.com/stumacwastaken/todo/errors"
+	"github.com/stumacwastaken/todo/member"
+	"github.com/stumacwastaken/todo/stores/memdb"
+	"github.com/stumacwastaken/todo/todoitem"
+)
+
+// memStorer is a Storer backed by maps, standing in for the database in tests.
+// memStorer keeps to the workspace in ctx like webhookdb does, so the core and dispatcher get no more than they would
+// from the database.
+type memStorer struct {
+	mu         sync.Mutex
+	hooks      map[string]Webhook
+	deliveries map[string]Delivery
+	seq        int
+}
+
+func newMemStorer() *memStorer {
+	return &memStorer{hooks: map[string]Webhook{}, deliveries: map[string]Delivery{}}
+}
+
+func (m *memStorer) Create(ctx context.Context, h Webhook) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h.Id = newString(memdb.NewId())
+	created := nowFn()
+	h.Created = &created
+	m.hooks[*h.Id] = h
+	return h, nil
+}
+
+func (m *memStorer) GetAll(ctx context.Context) ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := []Webhook{}
+	for _, h := range m.hooks {
+		if workspaceOf(h) == auth.Workspace(ctx) {
+			all = append(all, h)
+		}
+	}
+	return all, nil
+}
+
+func (m *memStorer) GetById(ctx context.Context, id string) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.hooks[id]
+	if !ok || workspaceOf(h) != auth.Workspace(ctx) {
+		return Webhook{}, terr.ErrorWithCode("not found", "Webhook with id "+id+" not found", 404)
+	}
+	return h, nil
+}
+
+func (m *memStorer) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok := m.hooks[id]; !ok || workspaceOf(h) != auth.Workspace(ctx) {
+		return terr.ErrorWithCode("not found", "Webhook with id "+id+" not found", 404)
+	}
+	delete(m.hooks, id)
+	for k, d := range m.deliveries {
+		if d.WebhookId == id {
+			delete(m.deliveries, k)
+		}
+	}
+	return nil
+}
+
+func (m *memStorer) Enqueue(ctx context.Context, ds []Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range ds {
+		if m.queued(d) {
+			continue
+		}
+		m.seq++
+		d.Id = fmt.Sprintf("d%03d", m.seq)
+		d.Created = nowFn()
+		d.WorkspaceId = auth.Workspace(ctx)
+		m.deliveries[d.Id] = d
+	}
+	return nil
+}
+
+func (m *memStorer) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Delivery
+	for _, d := range m.sorted() {
+		if len(due) == limit {
+			break
+		}
+		if d.Status == StatusPending && !d.NextAttempt.After(now) {
+			leased := now.Add(lease)
+			d.NextAttempt = &leased
+			m.deliveries[d.Id] = d
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (m *memStorer) SaveAttempt(ctx context.Context, d Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deliveries[d.Id].WorkspaceId != auth.Workspace(ctx) {
+		return nil
+	}
+	m.deliveries[d.Id] = d
+	return nil
+}
+
+func (m *memStorer) Deliveries(ctx context.Context, webhookId string, limit int) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := m.sorted()
+	var res []Delivery
+	for i := len(all) - 1; i >= 0 && len(res) < limit; i-- {
+		if all[i].WebhookId == webhookId && all[i].WorkspaceId == auth.Workspace(ctx) {
+			res = append(res, all[i])
+		}
+	}
+	return res, nil
+}
+
+// queued must be called with the lock held
+func (m *memStorer) queued(d Delivery) bool {
+	if d.EventId == "" {
+		return false
+	}
+	for _, q := range m.deliveries {
+		if q.WebhookId == d.WebhookId && q.EventId == d.EventId {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted must be called with the lock held
+func (m *memStorer) sorted() []Delivery {
+	var all []Delivery
+	for _, d := range m.deliveries {
+		all = append(all, d)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Id < all[j].Id })
+	return all
+}
+
+func newString(s string) *string {
+	return &s
+}
+
+var testNow = time.Date(2023, time.January, 12, 12, 12, 12, 0, time.UTC)
+
+// setClock pins nowFn, returning a func to move it along.
+func setClock(t *testing.T) func(time.Duration) {
+	now := testNow
+	nowFn = func() time.Time { return now }
+	t.Cleanup(func() { nowFn = time.Now })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCreate(t *testing.T) {
+	type test struct {
+		name string
+		req  Webhook
+		err  error
+	}
+	tests := []test{
+		{name: "happy path", req: Webhook{Url: newString("https://example.com/hook")}},
+		{name: "own secret", req: Webhook{Url: newString("https://example.com/hook"), Secret: newString("0123456789abcdef")}},
+		{name: "has id", req: Webhook{Id: newString("1"), Url: newString("https://example.com/hook")},
+			err: terr.ErrorWithCode("invalid param", "cannot create a webhook with an already existing id", 400)},
+		{name: "no url", req: Webhook{}, err: terr.ErrorWithCode("invalid param", "url cannot be empty", 400)},
+		{name: "relative url", req: Webhook{Url: newString("/hook")}, err: terr.ErrorWithCode("invalid param", "url must be an absolute http or https url", 400)},
+		{name: "not http", req: Webhook{Url: newString("ftp://example.com")}, err: terr.ErrorWithCode("invalid param", "url must be an absolute http or https url", 400)},
+		{name: "unknown event", req: Webhook{Url: newString("https://example.com"), Events: []todoitem.EventType{"exploded"}},
+			err: terr.ErrorWithCode("invalid param", "unknown event exploded", 400)},
+		{name: "short secret", req: Webhook{Url: newString("https://example.com"), Secret: newString("hunter2")},
+			err: terr.ErrorWithCode("invalid param", "secret must be at least 16 characters", 400)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			store := newMemStorer()
+			subject := NewCore(store)
+			created, err := subject.Create(context.Background(), tt.req)
+			assert.Equal(t, tt.err, err)
+			if tt.err != nil {
+				return
+			}
+			assert.Equal(t, DefaultEvents, created.Events)
+			if assert.NotNil(t, created.Secret) {
+				assert.GreaterOrEqual(t, len(*created.Secret), minSecretLength)
+			}
+			if tt.req.Secret != nil {
+				assert.Equal(t, *tt.req.Secret, *created.Secret)
+			}
+			got, err := subject.GetById(context.Background(), *created.Id)
+			assert.Nil(t, err)
+			assert.Nil(t, got.Secret, "secrets are only shown on create")
+			all, err := subject.GetAll(context.Background())
+			assert.Nil(t, err)
+			assert.Nil(t, all[0].Secret)
+		}
+		t.Run(tt.name, tf)
+	}
+}
+
+func TestPublishQueuesWantedEvents(t *testing.T) {
+	setClock(t)
+	store := newMemStorer()
+	subject := NewCore(store)
+	all, err := subject.Create(context.Background(), Webhook{Url: newString("https://example.com/all"), Events: knownEvents})
+	assert.Nil(t, err)
+	_, err = subject.Create(context.Background(), Webhook{Url: newString("https://example.com/default")})
+	assert.Nil(t, err)
+
+	subject.Publish(context.Background(), todoitem.Event{Type: todoitem.EventUpdated, Item: todoitem.TodoItem{Id: newString("1")}, Time: testNow})
+	subject.Publish(context.Background(), todoitem.Event{Type: todoitem.EventCompleted, Item: todoitem.TodoItem{Id: newString("1")}, Time: testNow})
+	assert.Len(t, store.deliveries, 3, "updates only go to the webhook that asked for them")
+
+	log, err := subject.Deliveries(context.Background(), *all.Id, 10)
+	assert.Nil(t, err)
+	if assert.Len(t, log, 2) {
+		assert.Equal(t, todoitem.EventCompleted, log[0].Event, "newest first")
+		assert.Equal(t, StatusPending, log[0].Status)
+		var e todoitem.Event
+		assert.Nil(t, json.Unmarshal(log[0].Payload, &e))
+		assert.Equal(t, todoitem.EventCompleted, e.Type)
+		assert.Equal(t, "1", *e.Item.Id)
+		assert.Equal(t, testNow, e.Time)
+	}
+	_, err = subject.Deliveries(context.Background(), "nope", 10)
+	assert.Equal(t, terr.ErrorWithCode("not found", "Webhook with id nope not found", 404), err)
+	_, err = subject.Deliveries(context.Background(), *all.Id, 1000)
+	assert.Equal(t, terr.ErrorWithCode("invalid param", "limit must be between 1 and 100", 400), err)
+}
+
+func TestWebhooksBelongToTheirOwner(t *testing.T) {
+	store := newMemStorer()
+	subject := NewCore(store)
+	sam := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "sam"})
+	alex := auth.WithPrincipal(context.Background(), auth.Principal{UserId: "alex"})
+	hook, err := subject.Create(sam, Webhook{Url: newString("https://example.com/sam")})
+	assert.Nil(t, err)
+	assert.Equal(t, "sam", *hook.OwnerId)
+
+	hooks, _ := subject.GetAll(alex)
+	assert.Empty(t, hooks)
+	hooks, _ = subject.GetAll(sam)
+	assert.Len(t, hooks, 1)
+	notFound := terr.ErrorWithCode("not found", fmt.Sprintf("Webhook with id %s not found", *hook.Id), 404)
+	_, err = subject.GetById(alex, *hook.Id)
+	assert.Equal(t, notFound, err)
+	_, err = subject.Deliveries(alex, *hook.Id, 10)
+	assert.Equal(t, notFound, err)
+	assert.Equal(t, notFound, subject.Delete(alex, *hook.Id))
+
+	subject.Publish(context.Background(), todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1"), OwnerId: newString("alex")}})
+	subject.Publish(context.Background(), todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("2")}})
+	assert.Empty(t, store.deliveries, "sam doesn't hear about items that aren't theirs")
+	subject.Publish(context.Background(), todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("3"), OwnerId: newString("sam")}})
+	assert.Len(t, store.deliveries, 1)
+	assert.Nil(t, subject.Delete(sam, *hook.Id))
+}
+
+func TestWebhooksStayInTheirWorkspace(t *testing.T) {
+	store := newMemStorer()
+	subject := NewCore(store)
+	ops := auth.WithWorkspace(context.Background(), "ops")
+	hook, err := subject.Create(ops, Webhook{Url: newString("https://example.com/ops")})
+	assert.Nil(t, err)
+
+	hooks, _ := subject.GetAll(context.Background())
+	assert.Empty(t, hooks, "unscoped is still only the one workspace")
+	_, err = subject.GetById(context.Background(), *hook.Id)
+	assert.Equal(t, terr.ErrorWithCode("not found", fmt.Sprintf("Webhook with id %s not found", *hook.Id), 404), err)
+
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-1", todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1")}}))
+	assert.Empty(t, store.deliveries, "events in the default workspace")
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-2", todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("2")}, Workspace: "ops"}))
+	assert.Len(t, store.deliveries, 1)
+}
+
+func TestEnqueueOncePerEvent(t *testing.T) {
+	store := newMemStorer()
+	subject := NewCore(store)
+	_, err := subject.Create(context.Background(), Webhook{Url: newString("https://example.com/hook")})
+	assert.Nil(t, err)
+	e := todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1")}}
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-1", e))
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-1", e))
+	assert.Len(t, store.deliveries, 1, "the outbox can hand us the same event twice")
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-2", e))
+	assert.Len(t, store.deliveries, 2)
+}
+
+func TestEnqueueAssigned(t *testing.T) {
+	store := newMemStorer()
+	subject := NewCore(store)
+	assigned := []todoitem.EventType{todoitem.EventAssigned}
+	for _, userId := range []string{"sam", "alex"} {
+		ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserId: userId})
+		_, err := subject.Create(ctx, Webhook{Url: newString("https://example.com/" + userId), Events: assigned})
+		assert.Nil(t, err)
+	}
+	e := todoitem.Event{Type: todoitem.EventAssigned, Item: todoitem.TodoItem{Id: newString("1"), OwnerId: newString("sam")},
+		Assignees: []string{"alex"}}
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-1", e))
+	if assert.Len(t, store.deliveries, 1, "the assignee hears about it, not the owner") {
+		for _, d := range store.deliveries {
+			hook, _ := store.GetById(context.Background(), d.WebhookId)
+			assert.Equal(t, "https://example.com/alex", *hook.Url)
+		}
+	}
+}
+
+// sharedWith stands in for the member package, it's the users each list is shared with.
+type sharedWith map[string][]string
+
+func (s sharedWith) Role(ctx context.Context, listId, userId string) (member.Role, error) {
+	for _, u := range s[listId] {
+		if u == userId {
+			return member.RoleViewer, nil
+		}
+	}
+	return "", nil
+}
+
+func (s sharedWith) Lists(ctx context.Context, userId string) ([]string, error) {
+	return nil, nil
+}
+
+func TestEnqueueSharedList(t *testing.T) {
+	store := newMemStorer()
+	subject := NewCore(store)
+	subject.ShareWith(sharedWith{"sam": {"alex"}})
+	for _, userId := range []string{"alex", "kim"} {
+		ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserId: userId})
+		_, err := subject.Create(ctx, Webhook{Url: newString("https://example.com/" + userId)})
+		assert.Nil(t, err)
+	}
+	e := todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1"), OwnerId: newString("sam")}}
+	assert.Nil(t, subject.Enqueue(context.Background(), "event-1", e))
+	if assert.Len(t, store.deliveries, 1, "sam's list is shared with alex, not kim") {
+		for _, d := range store.deliveries {
+			hook, _ := store.GetById(context.Background(), d.WebhookId)
+			assert.Equal(t, "https://example.com/alex", *hook.Url)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, backoff(1))
+	assert.Equal(t, 20*time.Second, backoff(2))
+	assert.Equal(t, 80*time.Second, backoff(4))
+	assert.Equal(t, time.Hour, backoff(11))
+	assert.Equal(t, time.Hour, backoff(100))
+}
+
+type received struct {
+	event     string
+	delivery  string
+	signature string
+	body      []byte
+}
+
+// receiver records what it's sent, answering with whatever status is next in line (200 once they run out).
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	got      []received
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, received{
+		event:     req.Header.Get(HeaderEvent),
+		delivery:  req.Header.Get(HeaderDelivery),
+		signature: req.Header.Get(HeaderSignature),
+		body:      body,
+	})
+	status := 200
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+	w.Write([]byte("nope"))
+}
+
+func TestDelivery(t *testing.T) {
+	tick := setClock(t)
+	rec := &receiver{statuses: []int{500, 503}}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	store := newMemStorer()
+	core := NewCore(store)
+	hook, err := core.Create(context.Background(), Webhook{Url: newString(srv.URL)})
+	assert.Nil(t, err)
+	dispatcher := NewDispatcher(store)
+	dispatcher.AllowPrivate()
+
+	//hooked up to a todo core the same way the server does it
+	todos := todoitem.NewCore(memdb.NewStore(), core)
+	item, err := todos.Create(context.Background(), todoitem.TodoItem{Summary: newString("call the bank")})
+	assert.Nil(t, err)
+
+	n, err := dispatcher.DeliverDue(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	log, _ := core.Deliveries(context.Background(), *hook.Id, 10)
+	assert.Equal(t, StatusPending, log[0].Status)
+	assert.Equal(t, 1, log[0].Attempts)
+	assert.Equal(t, 500, log[0].LastStatus)
+	assert.Equal(t, "receiver answered 500", log[0].LastError, "what the receiver said isn't kept")
+	assert.Equal(t, testNow.Add(retryBase), *log[0].NextAttempt)
+
+	//not due yet
+	n, _ = dispatcher.DeliverDue(context.Background())
+	assert.Equal(t, 0, n)
+
+	tick(retryBase)
+	dispatcher.DeliverDue(context.Background())
+	tick(2 * retryBase)
+	dispatcher.DeliverDue(context.Background())
+	log, _ = core.Deliveries(context.Background(), *hook.Id, 10)
+	assert.Equal(t, StatusDelivered, log[0].Status)
+	assert.Equal(t, 3, log[0].Attempts)
+	assert.Equal(t, 200, log[0].LastStatus)
+	assert.Empty(t, log[0].LastError)
+	assert.Nil(t, log[0].NextAttempt)
+
+	if assert.Len(t, rec.got, 3) {
+		for _, got := range rec.got {
+			assert.Equal(t, "created", got.event)
+			assert.Equal(t, log[0].Id, got.delivery, "retries keep the delivery id so receivers can dedupe")
+			assert.True(t, Verify(*hook.Secret, got.body, got.signature))
+			assert.Contains(t, string(got.body), *item.Id)
+		}
+		assert.False(t, Verify("some other secret", rec.got[0].body, rec.got[0].signature))
+	}
+}
+
+func TestDeliveryInAnotherWorkspace(t *testing.T) {
+	setClock(t)
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	store := newMemStorer()
+	core := NewCore(store)
+	ops := auth.WithWorkspace(context.Background(), "ops")
+	hook, err := core.Create(ops, Webhook{Url: newString(srv.URL)})
+	assert.Nil(t, err)
+	core.Publish(ops, todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1")}, Workspace: "ops"})
+	dispatcher := NewDispatcher(store)
+	dispatcher.AllowPrivate()
+
+	//the dispatcher runs outside of any workspace, it has to find the webhook in the delivery's
+	n, err := dispatcher.DeliverDue(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, rec.got, 1)
+	log, _ := core.Deliveries(ops, *hook.Id, 10)
+	if assert.Len(t, log, 1) {
+		assert.Equal(t, StatusDelivered, log[0].Status)
+	}
+	log, _ = store.Deliveries(context.Background(), *hook.Id, 10)
+	assert.Empty(t, log, "the log is only read in its own workspace")
+}
+
+func TestDeliveryToPrivateAddress(t *testing.T) {
+	setClock(t)
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	store := newMemStorer()
+	core := NewCore(store)
+	hook, _ := core.Create(context.Background(), Webhook{Url: newString(srv.URL)})
+	core.Publish(context.Background(), todoitem.Event{Type: todoitem.EventDeleted, Item: todoitem.TodoItem{Id: newString("1")}})
+
+	NewDispatcher(store).DeliverDue(context.Background())
+	log, _ := core.Deliveries(context.Background(), *hook.Id, 10)
+	assert.Equal(t, errPrivate.Error(), log[0].LastError)
+	assert.Equal(t, 0, log[0].LastStatus)
+	assert.Empty(t, rec.got, "loopback is never dialed")
+
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "192.168.0.1", "169.254.169.254", "100.64.0.1", "::1", "fe80::1", "0.0.0.0", "::ffff:127.0.0.1"} {
+		assert.False(t, public(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		assert.True(t, public(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestDeliveryGivesUp(t *testing.T) {
+	tick := setClock(t)
+	rec := &receiver{}
+	for i := 0; i < MaxAttempts; i++ {
+		rec.statuses = append(rec.statuses, 410)
+	}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	store := newMemStorer()
+	core := NewCore(store)
+	hook, _ := core.Create(context.Background(), Webhook{Url: newString(srv.URL)})
+	core.Publish(context.Background(), todoitem.Event{Type: todoitem.EventDeleted, Item: todoitem.TodoItem{Id: newString("1")}})
+	dispatcher := NewDispatcher(store)
+	dispatcher.AllowPrivate()
+	for i := 0; i < MaxAttempts+2; i++ {
+		dispatcher.DeliverDue(context.Background())
+		tick(maxRetryWait)
+	}
+	log, _ := core.Deliveries(context.Background(), *hook.Id, 10)
+	assert.Equal(t, StatusFailed, log[0].Status)
+	assert.Equal(t, MaxAttempts, log[0].Attempts)
+	assert.Nil(t, log[0].NextAttempt)
+	assert.Len(t, rec.got, MaxAttempts)
+}
+
+func TestRun(t *testing.T) {
+	defer func(p time.Duration) { pollInterval = p }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	store := newMemStorer()
+	core := NewCore(store)
+	core.Create(context.Background(), Webhook{Url: newString(srv.URL)})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher := NewDispatcher(store)
+		dispatcher.AllowPrivate()
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	core.Publish(context.Background(), todoitem.Event{Type: todoitem.EventCreated, Item: todoitem.TodoItem{Id: newString("1")}})
+	assert.Eventually(t, func() bool {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return len(rec.got) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+}
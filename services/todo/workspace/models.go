@@ -0,0 +1,11 @@
+package workspace
+
+import "time"
+
+// Workspace is a tenant, i.e: a department sharing the instance with others. Everything anyone makes belongs to the
+// workspace they were registered into, and nothing is ever seen from another one.
+type Workspace struct {
+	Id      *string    `json:"id,omitempty"`
+	Name    *string    `json:"name,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+}
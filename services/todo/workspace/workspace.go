@@ -0,0 +1,69 @@
+// Package workspace is the tenants an instance is shared between. Keeping their data apart is up to the stores, which
+// scope everything to auth.Workspace. Workspaces are made by whoever runs the instance (see `todo workspace`), users
+// are registered into one by its id.
+package workspace
+
+import (
+	"context"
+	"strings"
+
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+type Storer interface {
+	// Create saves a new workspace, the store gives out the id.
+	Create(context.Context, Workspace) (Workspace, error)
+	GetById(context.Context, string) (Workspace, error)
+	GetAll(context.Context) ([]Workspace, error)
+}
+
+type Core struct {
+	storer Storer
+}
+
+func NewCore(storer Storer) *Core {
+	return &Core{
+		storer: storer,
+	}
+}
+
+// Create makes a new workspace. Its id is what users register into it with.
+func (c *Core) Create(ctx context.Context, w Workspace) (Workspace, error) {
+	if w.Id != nil {
+		return Workspace{}, terr.ErrorWithCode("invalid param", "cannot create a workspace with an id", 400)
+	}
+	if w.Name == nil || strings.TrimSpace(*w.Name) == "" {
+		return Workspace{}, terr.ErrorWithCode("invalid param", "name cannot be empty", 400)
+	}
+	name := strings.TrimSpace(*w.Name)
+	w.Name = &name
+	created, err := c.storer.Create(ctx, w)
+	if err != nil {
+		return Workspace{}, asTodoError(err)
+	}
+	return created, nil
+}
+
+func (c *Core) GetById(ctx context.Context, id string) (Workspace, error) {
+	w, err := c.storer.GetById(ctx, id)
+	if err != nil {
+		return Workspace{}, asTodoError(err)
+	}
+	return w, nil
+}
+
+// GetAll is every workspace, the default one included.
+func (c *Core) GetAll(ctx context.Context) ([]Workspace, error) {
+	all, err := c.storer.GetAll(ctx)
+	if err != nil {
+		return nil, asTodoError(err)
+	}
+	return all, nil
+}
+
+func asTodoError(err error) error {
+	if v, ok := err.(*terr.TodoError); ok {
+		return v
+	}
+	return terr.InternalError()
+}
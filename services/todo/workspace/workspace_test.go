@@ -0,0 +1,68 @@
+package workspace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	terr "github.com/stumacwastaken/todo/errors"
+)
+
+type mapStorer struct {
+	workspaces map[string]Workspace
+}
+
+func (s *mapStorer) Create(ctx context.Context, w Workspace) (Workspace, error) {
+	id := "ws-" + *w.Name
+	w.Id = &id
+	s.workspaces[id] = w
+	return w, nil
+}
+
+func (s *mapStorer) GetById(ctx context.Context, id string) (Workspace, error) {
+	w, ok := s.workspaces[id]
+	if !ok {
+		return Workspace{}, terr.ErrorWithCode("not found", "Workspace not found", 404)
+	}
+	return w, nil
+}
+
+func (s *mapStorer) GetAll(ctx context.Context) ([]Workspace, error) {
+	all := []Workspace{}
+	for _, w := range s.workspaces {
+		all = append(all, w)
+	}
+	return all, nil
+}
+
+func newString(s string) *string {
+	return &s
+}
+
+func TestCreate(t *testing.T) {
+	type test struct {
+		name      string
+		workspace Workspace
+		err       error
+	}
+	tests := []test{
+		{name: "happy path", workspace: Workspace{Name: newString(" Ops ")}},
+		{name: "no name", workspace: Workspace{Name: newString(" ")}, err: terr.ErrorWithCode("invalid param", "name cannot be empty", 400)},
+		{name: "with an id", workspace: Workspace{Id: newString("ops"), Name: newString("Ops")},
+			err: terr.ErrorWithCode("invalid param", "cannot create a workspace with an id", 400)},
+	}
+	for _, tt := range tests {
+		tf := func(t *testing.T) {
+			core := NewCore(&mapStorer{workspaces: map[string]Workspace{}})
+			created, err := core.Create(context.Background(), tt.workspace)
+			assert.Equal(t, tt.err, err)
+			if tt.err == nil {
+				assert.Equal(t, "Ops", *created.Name)
+				got, err := core.GetById(context.Background(), *created.Id)
+				assert.Nil(t, err)
+				assert.Equal(t, created, got)
+			}
+		}
+		t.Run(tt.name, tf)
+	}
+}
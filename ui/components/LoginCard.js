@@ -0,0 +1,67 @@
+import { address, setToken } from '@/lib/session'
+import { Button, Card, CardBody, Flex, Input, Text } from '@chakra-ui/react'
+import { useState } from 'react'
+
+// sessionHours is how long the access token made from a password login lasts
+const sessionHours = 24
+
+const post = async (path, body, headers = {}) => {
+  const res = await fetch(`${address}/api${path}`, {
+    method: "POST",
+    body: JSON.stringify(body),
+    headers: { "content-type": "application/json", ...headers },
+  })
+  const json = await res.json()
+  if (!res.ok) {
+    throw new Error(json.details || json.message || "error occurred")
+  }
+  return json
+}
+
+// passwordLogin swaps an email and password for an access token, so the password isn't kept in the browser
+const passwordLogin = async (email, password) => {
+  await post("/login", { email, password })
+  const expires = new Date(Date.now() + sessionHours * 60 * 60 * 1000).toISOString()
+  const basic = `Basic ${btoa(`${email}:${password}`)}`
+  const created = await post("/tokens", { name: "ui login", scope: "write", expires }, { authorization: basic })
+  return created.token
+}
+
+export default function LoginCard({ onLogin, error }) {
+  const [email, setEmail] = useState("")
+  const [password, setPassword] = useState("")
+  const [failed, setFailed] = useState(error)
+
+  const login = async (register) => {
+    try {
+      if (register) {
+        await post("/users", { email, password })
+      }
+      const token = await passwordLogin(email, password)
+      setToken(token)
+      onLogin(token)
+    } catch (e) {
+      setFailed(e.message)
+    }
+  }
+
+  return (
+    <Card mb={3}>
+      <CardBody>
+        <Flex direction="column" gap={3}>
+          {failed ? <Text color="red.500">{failed}</Text> : <></>}
+          {process.env.NEXT_PUBLIC_OIDC_LOGIN === "true" ? (
+            <Button as="a" href={`${address}/api/oidc/login`} colorScheme="blue">Log in with single sign on</Button>
+          ) : <></>}
+          <Input placeholder="Email" value={email} onChange={(e) => setEmail(e.target.value)} />
+          <Input placeholder="Password" type="password" value={password} onChange={(e) => setPassword(e.target.value)}
+            onKeyUp={(e) => e.key === "Enter" && login(false)} />
+          <Flex gap={3} justifyContent="center">
+            <Button onClick={() => login(false)}>Log in</Button>
+            <Button variant="ghost" onClick={() => login(true)}>Register</Button>
+          </Flex>
+        </Flex>
+      </CardBody>
+    </Card>
+  )
+}
@@ -0,0 +1,39 @@
+// the access token from logging in is kept in local storage so it lasts across reloads. The server decides how long
+// it's good for, a 401 means it's time to log in again
+const tokenKey = "todo_token"
+
+export const address = process.env.NEXT_PUBLIC_SERVER_HOST
+
+export const getToken = () => localStorage.getItem(tokenKey)
+
+export const setToken = (token) => localStorage.setItem(tokenKey, token)
+
+export const clearToken = () => localStorage.removeItem(tokenKey)
+
+// takeFragment picks up what an oidc login sent the browser back with, which is in the url's fragment so it never
+// goes to a server. It's taken off the url straight away so it doesn't end up in history or bookmarks.
+export const takeFragment = () => {
+  const hash = window.location.hash.slice(1)
+  if (!hash) {
+    return {}
+  }
+  const params = new URLSearchParams(hash)
+  if (!params.has("access_token") && !params.has("error")) {
+    return {}
+  }
+  window.history.replaceState(null, "", window.location.pathname + window.location.search)
+  if (params.has("error")) {
+    return { error: `${params.get("error")}: ${params.get("error_description")}` }
+  }
+  setToken(params.get("access_token"))
+  return { token: params.get("access_token") }
+}
+
+// withAuth adds the access token to a fetch's headers, if there is one
+export const withAuth = (init = {}) => {
+  const token = getToken()
+  if (!token) {
+    return init
+  }
+  return { ...init, headers: { ...init.headers, authorization: `Bearer ${token}` } }
+}
@@ -1,13 +1,20 @@
 import FailedCard from '@/components/FailedCard'
+import LoginCard from '@/components/LoginCard'
 import NewTodoItem from '@/components/NewTodoItem'
 import TodoItem from '@/components/TodoItem'
+import { address, clearToken, getToken, takeFragment, withAuth } from '@/lib/session'
 import { Flex, Heading, Spinner } from '@chakra-ui/react'
 import Head from 'next/head'
+import { useEffect, useState } from 'react'
 import useSWR from 'swr'
 
 
-const fetcher = async (...args) => {
-  const res = await fetch(...args)
+const fetcher = async (url, init) => {
+  const res = await fetch(url, withAuth(init))
+  if (res.status === 401) {
+    //the token has expired or been revoked, whoever it was has to log in again
+    clearToken()
+  }
   if (!res.ok) {
     const error = new Error("error occurred")
     error.info = await res.json()
@@ -17,7 +24,6 @@ const fetcher = async (...args) => {
   return await res.json()
 }
 
-const address = process.env.NEXT_PUBLIC_SERVER_HOST
 const basePath = `${address}/api/todo`
 const onCheck = async (id, done, data, mutate) => {
   console.log("oncheck")
@@ -57,8 +63,67 @@ const onArchive = async (id, data, mutate) => {
   mutate(data)
 }
 
+// openEvents keeps the change feed open. EventSource can't send the access token, so each connection is opened with a
+// ticket from the server instead. Tickets don't last, so when the stream drops for good (a 401 rather than the browser
+// retrying) it's opened again with a new one, refreshing in case anything was missed in between.
+const openEvents = (token, refresh) => {
+  let source, timer
+  let closed = false
+  const open = async () => {
+    let url = `${basePath}/events`
+    if (token) {
+      try {
+        const ticket = await fetcher(`${basePath}/events/ticket`, { method: "POST" })
+        url += `?ticket=${encodeURIComponent(ticket.ticket)}`
+      } catch (e) {
+        //a 401 is the token running out, which logs us out
+        if (e.status !== 401) {
+          timer = setTimeout(open, 5000)
+        }
+        return
+      }
+    }
+    if (closed) {
+      return
+    }
+    source = new EventSource(url)
+    for (const type of ["created", "updated", "completed", "deleted", "reset"]) {
+      source.addEventListener(type, refresh)
+    }
+    source.onerror = () => {
+      if (token && source.readyState === EventSource.CLOSED) {
+        timer = setTimeout(() => {
+          refresh()
+          open()
+        }, 3000)
+      }
+    }
+  }
+  open()
+  return () => {
+    closed = true
+    clearTimeout(timer)
+    source?.close()
+  }
+}
+
 export default function Home() {
-  const { data, error, isLoading, mutate } = useSWR(basePath, fetcher)
+  //local storage and the url's fragment are only there in the browser, so nothing is fetched until they've been read
+  const [session, setSession] = useState({ ready: false })
+  useEffect(() => {
+    const { token, error } = takeFragment()
+    setSession({ ready: true, token: token || getToken(), error })
+  }, [])
+  const { data, error, isLoading, mutate } = useSWR(session.ready ? [basePath, session.token] : null, ([url]) => fetcher(url))
+  useEffect(() => {
+    if (!session.ready) {
+      return
+    }
+    // changes made elsewhere come in over the change feed. Refetch whenever anything happens, including a reset
+    // which means we were gone too long to catch up on what we missed.
+    return openEvents(session.token, () => mutate())
+  }, [session, mutate])
+  const loggedOut = session.error || error?.status === 401
   return (
     <>
       <Head>
@@ -70,8 +135,14 @@ export default function Home() {
       <Flex height="100vh" justifyContent="center" paddingTop={"5rem"} backgroundColor="gray.100">
         <Flex direction="column" width="66%" minWidth="300px" textAlign="center">
           <Heading mb={6} textAlign="center">Todo List</Heading>
-          <NewTodoItem onCreate={(summary) => onCreate(summary, mutate, data)}/>
-          { isLoading ? (
+          { loggedOut ? (
+            <LoginCard error={session.error} onLogin={(token) => setSession({ ready: true, token })} />
+          ) : (
+            <NewTodoItem onCreate={(summary) => onCreate(summary, mutate, data)}/>
+          )}
+          { loggedOut ? (
+            <></>
+          ) : isLoading ? (
             <>
               <Spinner
                 thickness='4px'